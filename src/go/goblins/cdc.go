@@ -0,0 +1,104 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"crypto/sha256"
+	"math/rand"
+
+	mpb "github.com/google/minions/proto/minions"
+)
+
+const (
+	// minChunkSize, avgChunkSize and maxChunkSize bound the content-defined
+	// chunks getDataChunks splits a file's bytes into (see
+	// splitContentDefined): small enough that two files sharing a region
+	// still dedupe at a fine grain, large enough to keep the per-chunk
+	// SHA-256/offset overhead from dominating on large files.
+	minChunkSize = 2 * 1024
+	avgChunkSize = 8 * 1024
+	maxChunkSize = 64 * 1024
+)
+
+// gearTable maps each possible byte value to a pseudo-random 64-bit
+// constant, used by splitContentDefined's rolling hash. It's seeded
+// deterministically (rather than from crypto/rand or the current time) so
+// the same input always cuts at the same offsets, scan after scan and
+// process after process - that determinism is what lets the Overlord's
+// per-scan chunk cache recognize a chunk it has already forwarded.
+var gearTable = buildGearTable()
+
+func buildGearTable() [256]uint64 {
+	var t [256]uint64
+	r := rand.New(rand.NewSource(1))
+	for i := range t {
+		t[i] = r.Uint64()
+	}
+	return t
+}
+
+// splitContentDefined splits data into content-defined chunks using a
+// FastCDC-style rolling hash: a cut point is any byte whose
+// gearTable-weighted trailing hash has its low mask bits all zero, which -
+// unlike fixed-size splitting - keeps most chunk boundaries stable even
+// when bytes are inserted or removed earlier in the data, so two files
+// sharing a region still produce some identical chunks. avgSize must be a
+// power of two. A chunk is always cut at maxSize even without a hash hit,
+// and never cut before minSize.
+func splitContentDefined(data []byte, minSize, avgSize, maxSize int) []int {
+	if len(data) == 0 {
+		return nil
+	}
+	mask := uint64(avgSize - 1)
+
+	var cuts []int
+	start := 0
+	var hash uint64
+	for i, b := range data {
+		hash = (hash << 1) + gearTable[b]
+		length := i - start + 1
+		if length < minSize {
+			continue
+		}
+		if length >= maxSize || hash&mask == 0 {
+			cuts = append(cuts, i+1)
+			start = i + 1
+			hash = 0
+		}
+	}
+	if start < len(data) {
+		cuts = append(cuts, len(data))
+	}
+	return cuts
+}
+
+// chunkData splits data into content-defined chunks (see
+// splitContentDefined) and stamps each with its SHA-256, so the Overlord
+// can recognize a chunk it has already forwarded to a given minion during
+// the current scan and send a reference (DataChunk.RefId) instead of the
+// raw bytes again.
+func chunkData(data []byte) []*mpb.DataChunk {
+	var chunks []*mpb.DataChunk
+	start := 0
+	for _, end := range splitContentDefined(data, minChunkSize, avgChunkSize, maxChunkSize) {
+		sum := sha256.Sum256(data[start:end])
+		chunks = append(chunks, &mpb.DataChunk{
+			Offset: int64(start),
+			Data:   data[start:end],
+			Sha256: sum[:],
+		})
+		start = end
+	}
+	return chunks
+}
@@ -0,0 +1,96 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"encoding/json"
+
+	mpb "github.com/google/minions/proto/minions"
+)
+
+// cycloneDXBOM and friends cover only the subset of the CycloneDX 1.5 VEX
+// JSON schema RenderCycloneDXVEX actually populates: a vulnerabilities-only
+// BOM, with no component inventory (goblins don't build one today).
+type cycloneDXBOM struct {
+	BOMFormat       string                   `json:"bomFormat"`
+	SpecVersion     string                   `json:"specVersion"`
+	Version         int                      `json:"version"`
+	Vulnerabilities []cycloneDXVulnerability `json:"vulnerabilities"`
+}
+
+type cycloneDXVulnerability struct {
+	ID          string            `json:"id"`
+	Source      cycloneDXSource   `json:"source,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Ratings     []cycloneDXRating `json:"ratings,omitempty"`
+	Affects     []cycloneDXAffect `json:"affects,omitempty"`
+}
+
+type cycloneDXSource struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXRating struct {
+	Severity string `json:"severity"`
+}
+
+type cycloneDXAffect struct {
+	Ref string `json:"ref"`
+}
+
+// RenderCycloneDXVEX serializes results into a CycloneDX 1.5 VEX document,
+// one vulnerability entry per Finding: Advisory.Reference becomes the
+// vulnerability id, Source.Minion its source.name, Severity a CycloneDX
+// rating, and every VulnerableResource.Path an affects[].ref entry.
+func RenderCycloneDXVEX(results []*mpb.Finding) ([]byte, error) {
+	bom := cycloneDXBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+	}
+
+	for _, f := range results {
+		var affects []cycloneDXAffect
+		for _, res := range f.GetVulnerableResources() {
+			affects = append(affects, cycloneDXAffect{Ref: res.GetPath()})
+		}
+
+		bom.Vulnerabilities = append(bom.Vulnerabilities, cycloneDXVulnerability{
+			ID:          f.GetAdvisory().GetReference(),
+			Source:      cycloneDXSource{Name: f.GetSource().GetMinion()},
+			Description: f.GetAdvisory().GetDescription(),
+			Ratings:     []cycloneDXRating{{Severity: cycloneDXSeverity(f.GetSeverity())}},
+			Affects:     affects,
+		})
+	}
+
+	return json.MarshalIndent(bom, "", "  ")
+}
+
+// cycloneDXSeverity maps a Finding's Severity to a CycloneDX rating
+// severity string.
+func cycloneDXSeverity(s mpb.Finding_Severity) string {
+	switch s {
+	case mpb.Finding_SEVERITY_CRITICAL:
+		return "critical"
+	case mpb.Finding_SEVERITY_HIGH:
+		return "high"
+	case mpb.Finding_SEVERITY_MEDIUM:
+		return "medium"
+	case mpb.Finding_SEVERITY_LOW:
+		return "low"
+	default:
+		return "unknown"
+	}
+}
@@ -16,6 +16,7 @@
 package docker
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -29,10 +30,41 @@ import (
 const aufsPath = "/sbin/mount.aufs"
 
 // Mount mounts the container's filesystem into a mount directory.
-// Note that this method just executes a bunch of shell command
-// to build the filesystem. Not much magic here.
-// All of this is based on https://github.com/google/docker-explorer/blob/master/docker_explorer/lib/storage.py
+//
+// When a containerd socket is reachable (see defaultContainerdSocket),
+// Mount asks containerd's snapshotter for a read-only view of the
+// container's rootfs and applies it directly - no shelling out, and no
+// dependency on aufs-tools. Otherwise, it falls back to mountLegacy, which
+// builds the mount by hand from the on-disk docker graphdriver layout and
+// is kept around for pre-containerd hosts.
 func Mount(mountDir string, dockerDir string, dockerVersion int, containerID string, driver string) error {
+	ctx := context.Background()
+	if containerdReachable(ctx) {
+		return mountContainerd(ctx, mountDir, containerID)
+	}
+	return mountLegacy(mountDir, dockerDir, dockerVersion, containerID, driver)
+}
+
+// Unmount undoes a previous Mount, regardless of whether it was served by
+// containerd or the legacy shell-out path.
+func Unmount(mountDir string) error {
+	ctx := context.Background()
+	if containerdReachable(ctx) {
+		if err := unmountContainerd(ctx, mountDir); err == nil {
+			return nil
+		}
+		// Fall through: the mount may predate the containerd path, or
+		// containerd may have become unreachable between Mount and Unmount.
+	}
+	return exec.Command("umount", mountDir).Run()
+}
+
+// mountLegacy mounts the container's filesystem into a mount directory by
+// hand-rolling `mount` invocations for the AUFS/overlay/overlay2 graph
+// drivers. Kept as a fallback for hosts with no containerd socket.
+//
+// Deprecated: prefer Mount, which uses containerd when available.
+func mountLegacy(mountDir string, dockerDir string, dockerVersion int, containerID string, driver string) error {
 	var err error
 	var commands []*exec.Cmd
 
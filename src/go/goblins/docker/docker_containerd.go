@@ -0,0 +1,95 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/mount"
+	"github.com/containerd/containerd/namespaces"
+)
+
+// defaultContainerdSocket is where containerd listens on most Linux
+// distributions. It matches the default used by the containerd client
+// library, but is spelled out here so callers can tell at a glance what
+// Mount is probing for.
+const defaultContainerdSocket = "/run/containerd/containerd.sock"
+
+// defaultContainerdNamespace is the namespace docker itself uses when it
+// is configured with the containerd runtime, which is where the
+// containers we're asked to mount will actually live.
+const defaultContainerdNamespace = "moby"
+
+// containerdReachable reports whether a containerd socket can be dialed,
+// which we use to decide between the containerd-backed mount path and the
+// legacy shell-out one.
+func containerdReachable(ctx context.Context) bool {
+	conn, err := net.DialTimeout("unix", defaultContainerdSocket, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// mountContainerd mounts containerID's root filesystem read-only into
+// mountDir by asking containerd's snapshotter for a view of it, rather
+// than reconstructing the graphdriver layout by hand.
+func mountContainerd(ctx context.Context, mountDir string, containerID string) error {
+	client, err := containerd.New(defaultContainerdSocket)
+	if err != nil {
+		return fmt.Errorf("cannot connect to containerd: %v", err)
+	}
+	defer client.Close()
+
+	ctx = namespaces.WithNamespace(ctx, defaultContainerdNamespace)
+
+	container, err := client.LoadContainer(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("cannot load container %s: %v", containerID, err)
+	}
+
+	info, err := container.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot read container %s info: %v", containerID, err)
+	}
+
+	snapshotter := client.SnapshotService(info.Snapshotter)
+	// viewKey is kept distinct from the container's own snapshot key so we
+	// never race with, or disturb, a running container using the same
+	// snapshot.
+	viewKey := "minions-goblins-" + containerID
+	mounts, err := snapshotter.View(ctx, viewKey, info.SnapshotKey)
+	if err != nil {
+		return fmt.Errorf("cannot create snapshot view for %s: %v", containerID, err)
+	}
+
+	if err := mount.All(mounts, mountDir); err != nil {
+		// Best-effort cleanup of the view we just created; the mount itself
+		// never succeeded so there's nothing else to unwind.
+		snapshotter.Remove(ctx, viewKey)
+		return fmt.Errorf("cannot mount %s onto %s: %v", containerID, mountDir, err)
+	}
+	return nil
+}
+
+// unmountContainerd undoes mountContainerd's mount of mountDir. It does
+// not remove the snapshotter view that backed the mount.
+func unmountContainerd(ctx context.Context, mountDir string) error {
+	return mount.UnmountAll(mountDir, 0)
+}
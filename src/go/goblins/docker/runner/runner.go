@@ -21,8 +21,11 @@ import (
 	"os"
 	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/minions/go/goblins"
 	"github.com/google/minions/go/goblins/docker"
+	"github.com/google/minions/go/goblins/oci"
 	mpb "github.com/google/minions/proto/minions"
 	pb "github.com/google/minions/proto/overlord"
 	"golang.org/x/net/context"
@@ -35,8 +38,63 @@ var (
 	dockerVersion = flag.Int("docker_version", 2, "Version of Docker - 1 or 2")
 	containerID   = flag.String("container_id", "", "ID of the Docker container to scan")
 	driver        = flag.String("storage_driver", "overlay2", "Storage driver to use: aufs, overlay, overlay2")
+	output        = flag.String("output", "text", "Output format for findings: text, sarif or cyclonedx")
+	ignoreFile    = flag.String("ignore_file", "", "Path to a dockerignore-style file of paths to exclude from the walk (e.g. /proc, /sys), merged with any per-directory .minionsignore found under the mount. Disabled if empty.")
+
+	image      = flag.String("image", "", "Registry reference of an image to pull and scan (e.g. registry.example.com/foo/bar:tag), instead of mounting a local --container_id")
+	authFile   = flag.String("auth_file", "", "Path to a docker-style config.json used to authenticate against the registry for -image. Falls back to the host's ambient Docker credentials if empty.")
+	platform   = flag.String("platform", "", "Platform to select out of a multi-arch -image index, as os/arch or os/arch/variant (e.g. linux/amd64). Uses the registry's default if empty.")
+	layerCache = flag.String("layer_cache_dir", "", "Directory to cache pulled -image layers in, keyed by digest, so re-scanning shares layers with an earlier pull. Disabled if empty.")
 )
 
+// ImageSource prepares a filesystem tree under mountDir for startScan to
+// walk, and tears it back down afterwards. dockerDirSource and
+// registryImageSource are its two implementations, selected in main based
+// on whether -image or -container_id was given.
+type ImageSource interface {
+	Prepare(ctx context.Context, mountDir string) error
+	Cleanup(mountDir string)
+}
+
+// dockerDirSource mounts a container already present on a local Docker
+// installation, the goblin's original (and still default) mode.
+type dockerDirSource struct {
+	dockerPath    string
+	dockerVersion int
+	containerID   string
+	driver        string
+}
+
+func (s *dockerDirSource) Prepare(ctx context.Context, mountDir string) error {
+	return docker.Mount(mountDir, s.dockerPath, s.dockerVersion, s.containerID, s.driver)
+}
+
+func (s *dockerDirSource) Cleanup(mountDir string) {
+	if err := docker.Unmount(mountDir); err != nil {
+		log.Printf("unmounting %s: %v", mountDir, err)
+	}
+}
+
+// registryImageSource pulls an image straight from an OCI registry, with no
+// local Docker daemon involved, reusing goblins/oci's layer-pulling and
+// whiteout handling (already built for the standalone OCI-image goblin;
+// see goblins/oci/runner).
+type registryImageSource struct {
+	imageRef string
+	keychain authn.Keychain
+	platform *v1.Platform
+	cache    oci.LayerCache
+}
+
+func (s *registryImageSource) Prepare(ctx context.Context, mountDir string) error {
+	return oci.MaterializeWithAuth(ctx, s.imageRef, mountDir, s.cache, s.keychain, s.platform)
+}
+
+func (s *registryImageSource) Cleanup(mountDir string) {
+	// Nothing beyond the mountDir removal main already defers: unlike
+	// dockerDirSource there's no separate mount to tear down.
+}
+
 func startScan(client pb.OverlordClient, mountPath string) []*mpb.Finding {
 	log.Printf("Connecting to server")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -49,16 +107,59 @@ func startScan(client pb.OverlordClient, mountPath string) []*mpb.Finding {
 	log.Printf("Created scan %s", scanID)
 	log.Printf("Will now send files for each interests, a bit at a time")
 
-	results, err := goblins.SendFiles(client, scanID, response.GetInterests(), mountPath)
+	results, err := goblins.SendFilesWithFilter(client, scanID, response.GetInterests(), goblins.LoadFilesOptions{
+		Root:       mountPath,
+		IgnoreFile: *ignoreFile,
+	}, nil)
 	if err != nil {
 		log.Fatalf("Failed sending files to the overlord: %v", err)
 	}
 	return results
 }
 
+// newImageSource picks the ImageSource matching the flags the user gave:
+// -image for a registry pull, -container_id for the original local-Docker
+// mount. Exactly one of the two is expected to be set.
+func newImageSource() (ImageSource, error) {
+	if *image != "" {
+		keychain := authn.Keychain(authn.DefaultKeychain)
+		if *authFile != "" {
+			k, err := oci.NewAuthFileKeychain(*authFile)
+			if err != nil {
+				return nil, err
+			}
+			keychain = k
+		}
+		platformRef, err := oci.ParsePlatform(*platform)
+		if err != nil {
+			return nil, err
+		}
+		var cache oci.LayerCache
+		if *layerCache != "" {
+			cache, err = oci.NewDiskLayerCache(*layerCache)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &registryImageSource{imageRef: *image, keychain: keychain, platform: platformRef, cache: cache}, nil
+	}
+	return &dockerDirSource{dockerPath: *dockerPath, dockerVersion: *dockerVersion, containerID: *containerID, driver: *driver}, nil
+}
+
 func main() {
 	flag.Parse()
 	// TODO: check flags validity
+	if *image == "" && *containerID == "" {
+		log.Fatal("one of -image or -container_id is required")
+	}
+	if *image != "" && *containerID != "" {
+		log.Fatal("-image and -container_id are mutually exclusive")
+	}
+
+	source, err := newImageSource()
+	if err != nil {
+		log.Fatalf("setting up the image source: %v", err)
+	}
 
 	// Create a temp dir to mount image/container in.
 	mountPath, err := ioutil.TempDir("", "DOCKER_MINION")
@@ -70,12 +171,10 @@ func main() {
 	// TODO: double check this removeall, but should probably make sure we don't have weird symlinks/dir is empty
 	defer os.RemoveAll(mountPath) // clean up dcker mount point.
 
-	// Now mount the container.
-	err = docker.Mount(mountPath, *dockerPath, *dockerVersion, *containerID, *driver)
-	if err != nil {
-		log.Fatalf("Failed to mount the docker container: %v", err)
+	if err := source.Prepare(context.Background(), mountPath); err != nil {
+		log.Fatalf("Failed to prepare %s: %v", mountPath, err)
 	}
-	defer docker.Umount(mountPath)
+	defer source.Cleanup(mountPath)
 
 	conn, err := grpc.Dial(*overlordAddr, grpc.WithInsecure())
 	if err != nil {
@@ -92,5 +191,9 @@ func main() {
 	}
 
 	log.Println("Scan finished - we've got some results!")
-	log.Println(goblins.HumanReadableDebug(results))
+	rendered, err := goblins.RenderResults(*output, results)
+	if err != nil {
+		log.Fatalf("Rendering results: %v", err)
+	}
+	log.Println(rendered)
 }
@@ -0,0 +1,270 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// estargzFooterSize is the fixed size, in bytes, of the trailing gzip
+// member every eStargz blob ends with - a gzip stream with no payload of
+// its own, whose Extra header field carries a 16-hex-digit offset
+// (pointing at the TOC's own gzip stream, earlier in the blob) followed by
+// the "STARGZ" magic. See the stargz-snapshotter/estargz format.
+const estargzFooterSize = 51
+
+const estargzMagic = "STARGZ"
+
+// tocEntry is the subset of an eStargz TOC entry (stargz.index.json) that
+// walkEstargzTOC needs: enough to match a path against an Interest and,
+// for a match, know where that entry's independent gzip stream lives in
+// the blob.
+type tocEntry struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // "reg", "dir", "symlink", "chunk", ...
+	Size        int64  `json:"size"`
+	Offset      int64  `json:"offset"`      // Start of this entry's own gzip stream within the blob.
+	ChunkOffset int64  `json:"chunkOffset"` // Byte offset of this piece within the decompressed entry, for a large file split across several TOC entries.
+	UID         int    `json:"uid"`
+	GID         int    `json:"gid"`
+	Mode        int64  `json:"mode"`
+}
+
+type estargzTOC struct {
+	Version int        `json:"version"`
+	Entries []tocEntry `json:"entries"`
+}
+
+// blobRangeFetcher issues byte-range HTTP GETs against a single registry
+// blob, authenticating through go-containerregistry's transport package
+// rather than hand-rolling the bearer-token handshake (see
+// overlord/registry for that approach, used server-side).
+type blobRangeFetcher struct {
+	client *http.Client
+	url    string
+}
+
+func newBlobRangeFetcher(ref string, digest v1.Hash) (*blobRangeFetcher, error) {
+	r, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("parsing image reference %q: %v", ref, err)
+	}
+	repo := r.Context()
+	auth, err := authn.DefaultKeychain.Resolve(repo)
+	if err != nil {
+		return nil, fmt.Errorf("resolving credentials for %q: %v", ref, err)
+	}
+	rt, err := transport.NewWithContext(context.Background(), repo, auth, http.DefaultTransport, []string{repo.Scope(transport.PullScope)})
+	if err != nil {
+		return nil, fmt.Errorf("authenticating against %q: %v", ref, err)
+	}
+	url := fmt.Sprintf("https://%s/v2/%s/blobs/%s", repo.RegistryStr(), repo.RepositoryStr(), digest.String())
+	return &blobRangeFetcher{client: &http.Client{Transport: rt}, url: url}, nil
+}
+
+// fetchRange returns the blob bytes in [start, start+length).
+func (f *blobRangeFetcher) fetchRange(start, length int64) ([]byte, error) {
+	return f.do(fmt.Sprintf("bytes=%d-%d", start, start+length-1))
+}
+
+// fetchFrom returns the blob bytes from start to the end of the blob.
+func (f *blobRangeFetcher) fetchFrom(start int64) ([]byte, error) {
+	return f.do(fmt.Sprintf("bytes=%d-", start))
+}
+
+// fetchSuffix returns the last n bytes of the blob.
+func (f *blobRangeFetcher) fetchSuffix(n int64) ([]byte, error) {
+	return f.do(fmt.Sprintf("bytes=-%d", n))
+}
+
+func (f *blobRangeFetcher) do(rangeHeader string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", rangeHeader)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("range fetch %s %q: unexpected status %s", f.url, rangeHeader, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// fetchEstargzTOC fetches and parses ref's eStargz TOC via two small Range
+// requests - the fixed-size footer, then the TOC's own gzip stream the
+// footer points at - without downloading any entry body. It returns an
+// error for any layer that isn't a valid eStargz blob (too old an image,
+// a plain gzip layer, a registry that doesn't honor Range, ...), which
+// callers treat as "fall back to a full tar walk" rather than fatal.
+func fetchEstargzTOC(ref string, layer v1.Layer) (*estargzTOC, *blobRangeFetcher, int64, error) {
+	digest, err := layer.Digest()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	fetcher, err := newBlobRangeFetcher(ref, digest)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	footer, err := fetcher.fetchSuffix(estargzFooterSize)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	tocOffset, err := parseEstargzFooter(footer)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	tocAndFooter, err := fetcher.fetchFrom(tocOffset)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if len(tocAndFooter) <= estargzFooterSize {
+		return nil, nil, 0, fmt.Errorf("estargz: TOC region of %s shorter than the footer it should precede", ref)
+	}
+	tocGz := tocAndFooter[:len(tocAndFooter)-estargzFooterSize]
+
+	gz, err := gzip.NewReader(bytes.NewReader(tocGz))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("estargz: TOC at offset %d isn't gzip: %v", tocOffset, err)
+	}
+	defer gz.Close()
+
+	var t estargzTOC
+	if err := json.NewDecoder(gz).Decode(&t); err != nil {
+		return nil, nil, 0, fmt.Errorf("estargz: decoding TOC: %v", err)
+	}
+	return &t, fetcher, tocOffset, nil
+}
+
+// estargzExtraSubfieldHeaderSize is the 4-byte FEXTRA subfield header
+// (2-byte subfield ID + 2-byte little-endian subfield length) Go's
+// compress/gzip keeps at the front of Header.Extra - the actual offset
+// and magic bytes start right after it.
+const estargzExtraSubfieldHeaderSize = 4
+
+// parseEstargzFooter extracts the TOC offset out of an eStargz footer's
+// gzip Extra header field, returning an error if footer doesn't look like
+// one (the case for a layer that isn't eStargz at all).
+func parseEstargzFooter(footer []byte) (int64, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(footer))
+	if err != nil {
+		return 0, fmt.Errorf("estargz: footer isn't gzip: %v", err)
+	}
+	defer gz.Close()
+	// Header.Extra still carries the subfield's own SI1/SI2 + 2-byte LEN
+	// prefix; skip it before reading the offset and magic it introduces.
+	extra := gz.Header.Extra
+	if len(extra) < estargzExtraSubfieldHeaderSize {
+		return 0, fmt.Errorf("estargz: footer Extra field too short")
+	}
+	extra = extra[estargzExtraSubfieldHeaderSize:]
+	if len(extra) != 16+len(estargzMagic) || string(extra[16:]) != estargzMagic {
+		return 0, fmt.Errorf("estargz: footer Extra field missing %q magic", estargzMagic)
+	}
+	offset, err := hex.DecodeString(string(extra[:16]))
+	if err != nil || len(offset) != 8 {
+		return 0, fmt.Errorf("estargz: malformed TOC offset in footer")
+	}
+	var tocOffset int64
+	for _, b := range offset {
+		tocOffset = tocOffset<<8 | int64(b)
+	}
+	return tocOffset, nil
+}
+
+// walkEstargzTOC matches each regular-file entry in t against intrs,
+// without downloading anything for a non-match, and without downloading
+// anything at all for a METADATA-only match. For a METADATA_AND_DATA
+// match it issues a single Range request for that entry's independent
+// gzip stream (bounded by the next entry's Offset, or the TOC's own
+// offset for the last one) and decompresses it in isolation.
+//
+// Only the first TOC entry of a large file split across several "chunk"
+// entries (ChunkOffset > 0 for all but the first) is matched. Its Range
+// request still runs up to the next regular file's Offset, so it covers
+// the later chunks' gzip streams too, and compress/gzip's multistream
+// reader decompresses all of them into the emitted data.
+func walkEstargzTOC(fetcher *blobRangeFetcher, t *estargzTOC, tocOffset int64, intrs []*mpb.Interest, emit func(*pb.File)) error {
+	var regEntries []tocEntry
+	for _, e := range t.Entries {
+		if e.Type == "reg" && e.ChunkOffset == 0 {
+			regEntries = append(regEntries, e)
+		}
+	}
+	sort.Slice(regEntries, func(i, j int) bool { return regEntries[i].Offset < regEntries[j].Offset })
+
+	for idx, e := range regEntries {
+		var interest *mpb.Interest
+		for _, i := range intrs {
+			if r, err := regexp.MatchString(i.GetPathRegexp(), "/"+e.Name); err == nil && r {
+				interest = i
+			}
+		}
+		if interest == nil {
+			continue
+		}
+
+		metadata := &mpb.FileMetadata{
+			Path:        "/" + e.Name,
+			OwnerUid:    int32(e.UID),
+			OwnerGid:    int32(e.GID),
+			Permissions: uint32(e.Mode),
+			Size:        e.Size,
+		}
+		pf := &pb.File{Metadata: metadata}
+		if interest.GetDataType() == mpb.Interest_METADATA_AND_DATA {
+			end := tocOffset
+			if idx+1 < len(regEntries) {
+				end = regEntries[idx+1].Offset
+			}
+			gzEntry, err := fetcher.fetchRange(e.Offset, end-e.Offset)
+			if err != nil {
+				return fmt.Errorf("fetching %s: %v", e.Name, err)
+			}
+			gz, err := gzip.NewReader(bytes.NewReader(gzEntry))
+			if err != nil {
+				return fmt.Errorf("decompressing %s: %v", e.Name, err)
+			}
+			data, err := io.ReadAll(gz)
+			gz.Close()
+			if err != nil {
+				return fmt.Errorf("reading %s: %v", e.Name, err)
+			}
+			pf.DataChunks = chunkData(data)
+		}
+		emit(pf)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// buildEstargzFooter gzips an empty payload with a spec-shaped Extra
+// field: the 4-byte FEXTRA subfield header ("SG" + little-endian
+// subfield length) Go's gzip reader hands back as part of Header.Extra,
+// followed by the 16-hex-digit tocOffset and the "STARGZ" magic.
+func buildEstargzFooter(t *testing.T, tocOffset int64) []byte {
+	subfield := append([]byte(fmt.Sprintf("%016x", tocOffset)), []byte(estargzMagic)...)
+	extra := make([]byte, estargzExtraSubfieldHeaderSize+len(subfield))
+	extra[0], extra[1] = 'S', 'G'
+	binary.LittleEndian.PutUint16(extra[2:4], uint16(len(subfield)))
+	copy(extra[estargzExtraSubfieldHeaderSize:], subfield)
+
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.NoCompression)
+	require.NoError(t, err)
+	gz.Extra = extra
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestParseEstargzFooter_onSpecFooter_roundTripsOffset(t *testing.T) {
+	offset, err := parseEstargzFooter(buildEstargzFooter(t, 0xdeadbeef))
+	require.NoError(t, err)
+	require.EqualValues(t, 0xdeadbeef, offset)
+}
+
+func TestParseEstargzFooter_onNonGzipData_returnsError(t *testing.T) {
+	_, err := parseEstargzFooter([]byte("not a gzip stream at all"))
+	require.Error(t, err)
+}
+
+func TestParseEstargzFooter_onMissingMagic_returnsError(t *testing.T) {
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.NoCompression)
+	require.NoError(t, err)
+	gz.Extra = []byte("SG\x06\x00notstargz")
+	require.NoError(t, gz.Close())
+
+	_, err = parseEstargzFooter(buf.Bytes())
+	require.Error(t, err)
+}
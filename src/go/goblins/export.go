@@ -0,0 +1,63 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"fmt"
+	"io"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// exportFormats maps the --export_format flag value goblin/overlord CLIs
+// expose onto the wire enum Overlord.ExportReport expects.
+var exportFormats = map[string]pb.ExportFormat{
+	"spdx-json":      pb.ExportFormat_SPDX_JSON,
+	"spdx-tag-value": pb.ExportFormat_SPDX_TAG_VALUE,
+	"cyclonedx-json": pb.ExportFormat_CYCLONEDX_JSON,
+	"html-notice":    pb.ExportFormat_HTML_NOTICE,
+}
+
+// ExportScanReport calls scanID's Overlord.ExportReport in format (one of
+// the keys of exportFormats) and reassembles the streamed document into a
+// single buffer.
+func ExportScanReport(ctx context.Context, client pb.OverlordClient, scanID, format string) ([]byte, error) {
+	wireFormat, ok := exportFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+
+	stream, err := client.ExportReport(ctx, &pb.ExportReportRequest{ScanId: scanID, Format: wireFormat})
+	if err != nil {
+		return nil, err
+	}
+
+	var doc []byte
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		doc = append(doc, chunk.GetData()...)
+		if chunk.GetEof() {
+			break
+		}
+	}
+	return doc, nil
+}
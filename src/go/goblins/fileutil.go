@@ -16,8 +16,10 @@ package goblins
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -32,89 +34,251 @@ import (
 	pb "github.com/google/minions/proto/overlord"
 )
 
-// loadFiles builds the File protos for a slice of interests in chunks,
-// topping at maximum size and files count. Note we do not support
-// content regexps at this point (i.e. we do not check file contents).
-// Note that the root path gets automatically removed from the file's path.
-func loadFiles(intrs []*mpb.Interest, maxKb int, maxFiles int, root string) ([][]*pb.File, error) {
+// LoadFiles walks root looking for files matching intrs, streaming them
+// back as batches of at most maxFiles files or maxKb kilobytes (whichever
+// limit is hit first) rather than materializing the whole corpus in
+// memory before returning: a multi-gigabyte scan only ever holds one
+// batch's worth of file data at a time. Callers must drain out until it
+// is closed and then read errc once: it yields the walk's error, or nil
+// (errc is closed without a send) when the walk succeeded; abandoning the channels part-way through leaks the
+// walking goroutine. Note we do not support content regexps at this point
+// (i.e. we do not check file contents), and that the root path gets
+// automatically removed from each file's path.
+//
+// LoadFiles never applies ignore-file filtering; use LoadFilesWithFilter
+// for that.
+func LoadFiles(intrs []*mpb.Interest, maxKb int, maxFiles int, root string) (<-chan []*pb.File, <-chan error) {
+	return LoadFilesWithFilter(intrs, LoadFilesOptions{Root: root, MaxKb: maxKb, MaxFiles: maxFiles})
+}
+
+// LoadFilesOptions bundles LoadFiles' walk and batching parameters
+// together with optional ignore-file filtering, so adding the latter
+// didn't mean growing LoadFiles' positional argument list further.
+type LoadFilesOptions struct {
+	Root     string // Directory to walk.
+	MaxKb    int    // See LoadFiles.
+	MaxFiles int    // See LoadFiles.
+	// IgnoreFile, if non-empty, is a dockerignore-style file (one glob
+	// pattern per line, "**"/"*"/"?" wildcards, a leading "!" to negate)
+	// applied to every path under Root, merged with any per-directory
+	// .minionsignore found while walking - see ignore.go. Leave empty to
+	// disable ignore-file filtering entirely.
+	IgnoreFile string
+	// ExcludeRegexps, if any match a path's root-relative form (the same
+	// one an Interest's PathRegexp is matched against), skips it - for a
+	// directory, the whole subtree beneath it is never walked at all, same
+	// as a matching IgnoreFile rule. Unlike IgnoreFile (one shared
+	// dockerignore-style file), these are plain regexps, typically set per
+	// root by a ScanConfig - see LoadFilesMultiRoot.
+	ExcludeRegexps []string
+	// MaxFileSize skips any file larger than this many bytes instead of
+	// reading it; 0 means no cap.
+	MaxFileSize int64
+}
+
+// LoadFilesWithFilter is LoadFiles with ignore-file support: a path (file
+// or directory) matched by the effective ignore rules is skipped, and a
+// skipped directory is never descended into at all, via filepath.SkipDir -
+// so a pattern like "node_modules" or "/proc" keeps the walk from ever
+// touching what's inside it rather than just discarding the results.
+func LoadFilesWithFilter(intrs []*mpb.Interest, opts LoadFilesOptions) (<-chan []*pb.File, <-chan error) {
 	// Defensively minify the interests: this should have already happened but better safe than sorry.
 	intrs = interests.Minify(intrs)
+	root := opts.Root
 
-	paths := make(map[string]mpb.Interest_DataType)
-	// Note we assume a unix filesystem here. Might want to revisit.
-	err := filepath.Walk(root, func(path string, f os.FileInfo, e error) error {
+	out := make(chan []*pb.File)
+	errc := make(chan error, 1)
 
-		if e != nil {
-			// If we don't have permission, skip the directory but don't bail out.
-			if os.IsPermission(e) {
-				return filepath.SkipDir
+	go func() {
+		defer close(out)
+		defer close(errc)
+
+		matcher, err := newIgnoreMatcher(root, opts.IgnoreFile)
+		if err != nil {
+			errc <- fmt.Errorf("loading ignore file %s: %v", opts.IgnoreFile, err)
+			return
+		}
+		if err := matcher.loadDir(root); err != nil {
+			errc <- fmt.Errorf("loading %s in %s: %v", minionsignoreFile, root, err)
+			return
+		}
+
+		maxBytes := int64(opts.MaxKb) * 1024
+		var batch []*pb.File
+		var batchBytes int64
+		flush := func() {
+			if len(batch) == 0 {
+				return
 			}
-			// This seems to happen for volatile dirs.
-			if os.IsNotExist(e) {
-				return filepath.SkipDir
+			out <- batch
+			batch = nil
+			batchBytes = 0
+		}
+
+		// Note we assume a unix filesystem here. Might want to revisit.
+		walkErr := filepath.Walk(root, func(path string, f os.FileInfo, e error) error {
+			if e != nil {
+				// If we don't have permission, skip the directory but don't bail out.
+				if os.IsPermission(e) {
+					return filepath.SkipDir
+				}
+				// This seems to happen for volatile dirs.
+				if os.IsNotExist(e) {
+					return filepath.SkipDir
+				}
+
+				log.Printf("prevent panic by handling failure accessing a path %q: %v\n", path, e)
+				return e
+			}
+			if f.IsDir() {
+				if path != root {
+					if err := matcher.loadDir(path); err != nil {
+						return err
+					}
+					if matcher.matches(path) || matchesAnyRegexp(opts.ExcludeRegexps, removeRoot(path, root)) {
+						return filepath.SkipDir
+					}
+				}
+				return nil
+			}
+			if matcher.matches(path) || matchesAnyRegexp(opts.ExcludeRegexps, removeRoot(path, root)) {
+				return nil
 			}
 
-			log.Printf("prevent panic by handling failure accessing a path %q: %v\n", path, e)
-			return e
-		}
-		// For the naive implementation, let's check every file, but really
-		// here we need to bail out early instead and return filepath.SkipDir
-		// anytime we take a wrong turn.
-		if !f.IsDir() {
-			// Let's see if we match any interest!
+			// Let's see if we match any interest! If several do, the last
+			// one to match wins, same as before minify took care of
+			// collapsing duplicates.
+			var interest *mpb.Interest
 			for _, i := range intrs {
 				// Note we have to remove the root directory.
 				r, err := regexp.MatchString(i.GetPathRegexp(), removeRoot(path, root))
 				if err == nil && r {
 					log.Printf("Great, %s matched %s\n", path, i.GetPathRegexp())
-					// NOTE: this overwrites existing datatypes, under the assumption that the
-					// minification has taken care of this.
-					paths[path] = i.GetDataType()
+					interest = i
 				}
 			}
-		}
-		return nil
-	})
+			if interest == nil {
+				return nil
+			}
 
-	if err != nil {
-		return nil, fmt.Errorf("Failed Walk: %v", err)
-	}
+			metadata, err := getMetadata(path, root)
+			if err != nil {
+				// Metadata building can fail for a lot of reasons, so we don't
+				// just kill it, but rather continue.
+				log.Printf("Cannot create Metadata for %s. %v", path, err)
+				return nil
+			}
+			if opts.MaxFileSize > 0 && metadata.GetSize() > opts.MaxFileSize {
+				return nil
+			}
+			pf := &pb.File{Metadata: metadata, DataChunks: nil}
+			switch interest.GetDataType() {
+			case mpb.Interest_METADATA:
+				break
+			case mpb.Interest_METADATA_AND_DATA:
+				chunks, err := getDataChunks(path, interest.GetByteRanges())
+				if err != nil {
+					if os.IsPermission(err) {
+						log.Printf("Cannot send %s. Permission denied. Skipping.", path)
+						return nil
+					}
+					return err
+				}
+				pf.DataChunks = chunks
+				break
+			default:
+				return errors.New("Unknown interest type")
+			}
 
-	var files [][]*pb.File
-	var fs []*pb.File
-	// TODO(paradoxengine): implement limits on count of files and size.
-	for path, dataType := range paths {
-		metadata, err := getMetadata(path, root)
-		if err != nil {
-			// Metadata building can fail for a lot of reasons, so we don't
-			// just kill it, but rather continue.
-			log.Printf("Cannot create Metadata for %s. %v", path, err)
-			continue
+			batch = append(batch, pf)
+			batchBytes += metadata.GetSize()
+			if (opts.MaxFiles > 0 && len(batch) >= opts.MaxFiles) || (maxBytes > 0 && batchBytes >= maxBytes) {
+				flush()
+			}
+			return nil
+		})
+		flush()
+		if walkErr != nil {
+			errc <- fmt.Errorf("Failed Walk: %v", walkErr)
 		}
-		f := &pb.File{Metadata: metadata, DataChunks: nil}
-		switch dataType {
-		case mpb.Interest_METADATA:
-			break
-		case mpb.Interest_METADATA_AND_DATA:
-			chunks, err := getDataChunks(path)
-			if err != nil {
-				if os.IsPermission(err) {
-					log.Printf("Cannot send %s. Permission denied. Skipping.", path)
-					continue
-				} else {
-					return nil, err
+	}()
+
+	return out, errc
+}
+
+// LoadFilesMultiRoot is LoadFilesWithFilter run once per cfg.Roots entry,
+// in order, with opts.Root, opts.ExcludeRegexps and opts.MaxFileSize
+// overridden from that RootPolicy and intrs' DataType overridden per
+// RootPolicy.DataOverride. A path already claimed by an earlier Root -
+// because that Root's directory is a prefix of this one's - is never
+// yielded again under a later Root, so two overlapping Roots (e.g. "/" and
+// "/etc", or a Root whose own ExcludeRegexps covers where a later Root is
+// rooted) still produce each file at most once, attributed to whichever
+// Root was declared first.
+func LoadFilesMultiRoot(cfg ScanConfig, intrs []*mpb.Interest, opts LoadFilesOptions) (<-chan []*pb.File, <-chan error) {
+	out := make(chan []*pb.File)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		defer close(errc)
+
+		for i, rp := range cfg.Roots {
+			rootOpts := opts
+			rootOpts.Root = rp.Root
+			rootOpts.ExcludeRegexps = rp.ExcludeRegexps
+			rootOpts.MaxFileSize = rp.MaxFileSize
+
+			files, rootErrc := LoadFilesWithFilter(applyDataOverride(intrs, rp.DataOverride), rootOpts)
+			for fs := range files {
+				var kept []*pb.File
+				for _, pf := range fs {
+					if ownedByEarlierRoot(cfg.Roots[:i], filepath.Join(rp.Root, pf.GetMetadata().GetPath())) {
+						continue
+					}
+					kept = append(kept, pf)
+				}
+				if len(kept) > 0 {
+					out <- kept
 				}
 			}
-			f.DataChunks = chunks
-			break
-		default:
-			return nil, errors.New("Unknown interest type")
+			if err := <-rootErrc; err != nil {
+				errc <- fmt.Errorf("root %s: %v", rp.Root, err)
+				return
+			}
+		}
+	}()
+
+	return out, errc
+}
+
+// applyDataOverride returns intrs with every DataType replaced by
+// *override, or intrs unchanged if override is nil.
+func applyDataOverride(intrs []*mpb.Interest, override *mpb.Interest_DataType) []*mpb.Interest {
+	if override == nil {
+		return intrs
+	}
+	out := make([]*mpb.Interest, len(intrs))
+	for i, it := range intrs {
+		out[i] = &mpb.Interest{
+			PathRegexp: it.GetPathRegexp(),
+			DataType:   *override,
+			ByteRanges: it.GetByteRanges(),
 		}
-		fs = append(fs, f)
 	}
-	files = append(files, fs)
+	return out
+}
 
-	return files, nil
+// ownedByEarlierRoot reports whether absPath falls under any of roots -
+// i.e. an earlier-declared RootPolicy already claims it, so the
+// currently-walked, later Root must not yield it again.
+func ownedByEarlierRoot(roots []RootPolicy, absPath string) bool {
+	for _, rp := range roots {
+		if strings.HasPrefix(absPath, rp.Root) {
+			return true
+		}
+	}
+	return false
 }
 
 // getMetadata is heavily linux skewed, but so is minions right now.
@@ -142,27 +306,58 @@ func getMetadata(path string, rootDir string) (*mpb.FileMetadata, error) {
 	return m, nil
 }
 
-// getDataChunks splits the file at the path in a set of chunks.
-func getDataChunks(path string) ([]*pb.DataChunk, error) {
+// getDataChunks splits the file at path into a set of content-defined
+// chunks (see splitContentDefined/chunkData), each stamped with its
+// SHA-256 so the Overlord can dedupe identical chunks - whether from the
+// same file re-scanned or a shared region across different files -
+// instead of resending bytes it has already forwarded to a minion this
+// scan. If ranges is non-empty, only those byte ranges are read - this is
+// how a Minion with a sparse Interest (e.g. just the ELF header and a
+// section deep into a multi-gigabyte image) avoids shipping the whole
+// file; those chunks follow the requested ranges exactly rather than
+// being content-defined, since they're already as small as the minion
+// asked for.
+func getDataChunks(path string, ranges []*mpb.ByteRange) ([]*mpb.DataChunk, error) {
+	if len(ranges) > 0 {
+		return getRangedDataChunks(path, ranges)
+	}
+
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return chunkData(data), nil
+}
+
+// getRangedDataChunks reads only the requested byte ranges out of path,
+// each becoming its own DataChunk at its real file offset, so the chunks
+// downstream (see state.StateManager.AddFiles) stay sparse rather than
+// forming one contiguous blob. Each chunk is still stamped with its
+// SHA-256, so a repeated sparse read (e.g. the same ELF header re-scanned
+// by another Interest) can dedupe the same as a content-defined one.
+func getRangedDataChunks(path string, ranges []*mpb.ByteRange) ([]*mpb.DataChunk, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
-	var chunks []*pb.DataChunk
-	// Arbitrary size of each data chunk.
-	var chunkSize = 1024 * 1024 * 2
-	dataLen := len(data)
-	for i := 0; i < dataLen; i += chunkSize {
-		var chunk []byte
-		if i+chunkSize >= dataLen {
-			chunk = data[i:]
-		} else {
-			chunk = data[i : i+chunkSize]
-		}
-		chunks = append(chunks, &pb.DataChunk{
-			Offset: int64(i),
-			Data:   chunk,
+	var chunks []*mpb.DataChunk
+	for _, r := range ranges {
+		size := r.GetEnd() - r.GetStart()
+		if size <= 0 {
+			continue
+		}
+		buf := make([]byte, size)
+		n, err := f.ReadAt(buf, r.GetStart())
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		sum := sha256.Sum256(buf[:n])
+		chunks = append(chunks, &mpb.DataChunk{
+			Offset: r.GetStart(),
+			Data:   buf[:n],
+			Sha256: sum[:],
 		})
 	}
 	return chunks, nil
@@ -172,31 +367,50 @@ func removeRoot(path string, root string) string {
 	return filepath.Clean(strings.Replace(path, root, "/", 1))
 }
 
+// matchesAnyRegexp reports whether any of patterns matches s. A malformed
+// pattern is treated as a non-match rather than aborting the walk, same as
+// the existing per-Interest PathRegexp matching above.
+func matchesAnyRegexp(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if match, err := regexp.MatchString(p, s); err == nil && match {
+			return true
+		}
+	}
+	return false
+}
+
 // SendFiles iteratively sends files drawing them from the rootpath,
-// using the provided Overlord client.
+// using the provided Overlord client. Uploads are unthrottled; see
+// SendFilesWithFilter to pass a *Limiter.
 func SendFiles(client pb.OverlordClient, scanID string, interests []*mpb.Interest, rootPath string) ([]*mpb.Finding, error) {
+	return SendFilesWithFilter(client, scanID, interests, LoadFilesOptions{Root: rootPath}, nil)
+}
+
+// SendFilesWithFilter is SendFiles, but walks rootPath through
+// LoadFilesWithFilter so opts.IgnoreFile (and any per-directory
+// .minionsignore) is honored, and - if limiter is non-nil - throttles the
+// outbound ScanFiles traffic to limiter's configured rate.
+func SendFilesWithFilter(client pb.OverlordClient, scanID string, interests []*mpb.Interest, opts LoadFilesOptions, limiter *Limiter) ([]*mpb.Finding, error) {
 	var results []*mpb.Finding
 	// TODO: handle max files and bytes per request limits
-	files, err := loadFiles(interests, 0, 0, rootPath)
-	if err != nil {
-		return nil, err
-	}
+	files, errc := LoadFilesWithFilter(interests, opts)
 
-	for _, fs := range files {
+	for fs := range files {
 		for _, ff := range fs {
 			log.Printf("Sending file %s", ff.GetMetadata().GetPath())
 		}
+		limiter.Wait(filesByteSize(fs))
 		sfr := &pb.ScanFilesRequest{ScanId: scanID, Files: fs}
 		ctx, _ := context.WithTimeout(context.Background(), 60*time.Second)
 		resp, err := client.ScanFiles(ctx, sfr)
-		log.Printf("Files sent. Response: %v", resp)
+		log.Printf("Files sent. Response: %v (upload rate: %.0f bytes/sec)", resp, limiter.Stats().RateBytesPerSec)
 		if err != nil {
 			return nil, err
 		}
 		// Iterate on new interests
 		if len(resp.GetNewInterests()) > 0 {
 			log.Printf("Got new interests!")
-			r, err := SendFiles(client, scanID, resp.GetNewInterests(), rootPath)
+			r, err := SendFilesWithFilter(client, scanID, resp.GetNewInterests(), opts, limiter)
 			if err != nil {
 				return nil, err
 			}
@@ -204,5 +418,172 @@ func SendFiles(client pb.OverlordClient, scanID string, interests []*mpb.Interes
 		}
 		results = append(results, resp.GetResults()...)
 	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// filesByteSize sums the data bytes a batch of files carries, for feeding
+// into a Limiter.
+func filesByteSize(files []*pb.File) int64 {
+	var n int64
+	for _, f := range files {
+		for _, c := range f.GetDataChunks() {
+			n += int64(len(c.GetData()))
+		}
+	}
+	return n
+}
+
+// defaultStreamChunkKb is the per-message chunk size SendFilesStreaming
+// reads and sends at a time when opts.MaxKb is unset.
+const defaultStreamChunkKb = 256
+
+// SendFilesStreaming is SendFilesWithFilter's counterpart for
+// StreamScanFiles, the Overlord's client-streaming RPC: rather than
+// calling LoadFilesWithFilter (which reads a whole file into memory via
+// getDataChunks/chunkData before ever touching the network), it walks
+// root itself and opens each matched file with os.Open, streaming it
+// straight onto the wire in opts.MaxKb-sized reads - here a per-message
+// cap, not a whole-batch one like LoadFilesOptions.MaxKb is elsewhere.
+// This is what lets a file larger than the goblin's own RAM be scanned at
+// all. Each file's chunks are followed by a zero-length sentinel
+// DataChunk at its final offset, marking it complete independently of
+// whatever Metadata.Size claims.
+//
+// Unlike SendFilesWithFilter, this opens one StreamScanFiles call for the
+// whole walk rather than one ScanFiles call per batch; a minion's
+// NewInterests can only be reacted to once this call returns, at which
+// point SendFilesStreaming recurses the same way SendFilesWithFilter does.
+func SendFilesStreaming(client pb.OverlordClient, scanID string, intrs []*mpb.Interest, opts LoadFilesOptions, limiter *Limiter) ([]*mpb.Finding, error) {
+	intrs = interests.Minify(intrs)
+	root := opts.Root
+
+	matcher, err := newIgnoreMatcher(root, opts.IgnoreFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading ignore file %s: %v", opts.IgnoreFile, err)
+	}
+	if err := matcher.loadDir(root); err != nil {
+		return nil, fmt.Errorf("loading %s in %s: %v", minionsignoreFile, root, err)
+	}
+
+	chunkBytes := int64(opts.MaxKb) * 1024
+	if chunkBytes <= 0 {
+		chunkBytes = defaultStreamChunkKb * 1024
+	}
+
+	stream, err := client.StreamScanFiles(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	walkErr := filepath.Walk(root, func(path string, f os.FileInfo, e error) error {
+		if e != nil {
+			if os.IsPermission(e) || os.IsNotExist(e) {
+				return filepath.SkipDir
+			}
+			log.Printf("prevent panic by handling failure accessing a path %q: %v\n", path, e)
+			return e
+		}
+		if f.IsDir() {
+			if path != root {
+				if err := matcher.loadDir(path); err != nil {
+					return err
+				}
+				if matcher.matches(path) {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if matcher.matches(path) {
+			return nil
+		}
+
+		// Same last-match-wins interest resolution as LoadFilesWithFilter.
+		var interest *mpb.Interest
+		for _, i := range intrs {
+			r, err := regexp.MatchString(i.GetPathRegexp(), removeRoot(path, root))
+			if err == nil && r {
+				interest = i
+			}
+		}
+		if interest == nil {
+			return nil
+		}
+
+		metadata, err := getMetadata(path, root)
+		if err != nil {
+			log.Printf("Cannot create Metadata for %s. %v", path, err)
+			return nil
+		}
+
+		if interest.GetDataType() == mpb.Interest_METADATA {
+			return stream.Send(&pb.ScanFilesRequest{ScanId: scanID, Files: []*pb.File{{Metadata: metadata}}})
+		}
+		return streamFileChunks(stream, scanID, metadata, path, chunkBytes, limiter)
+	})
+	if walkErr != nil {
+		stream.CloseSend()
+		return nil, fmt.Errorf("Failed Walk: %v", walkErr)
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, err
+	}
+	results := resp.GetResults()
+	if len(resp.GetNewInterests()) > 0 {
+		r, err := SendFilesStreaming(client, scanID, resp.GetNewInterests(), opts, limiter)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r...)
+	}
 	return results, nil
 }
+
+// streamFileChunks opens path and streams it to stream as DataChunks no
+// larger than chunkBytes each, tagged with their real file offset, ending
+// with a zero-length sentinel chunk at the file's final offset.
+func streamFileChunks(stream pb.Overlord_StreamScanFilesClient, scanID string, metadata *mpb.FileMetadata, path string, chunkBytes int64, limiter *Limiter) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsPermission(err) {
+			log.Printf("Cannot send %s. Permission denied. Skipping.", path)
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, chunkBytes)
+	var offset int64
+	for {
+		n, readErr := f.Read(buf)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buf[:n])
+			sum := sha256.Sum256(data)
+			limiter.Wait(int64(n))
+			if err := stream.Send(&pb.ScanFilesRequest{ScanId: scanID, Files: []*pb.File{{
+				Metadata:   metadata,
+				DataChunks: []*mpb.DataChunk{{Offset: offset, Data: data, Sha256: sum[:]}},
+			}}}); err != nil {
+				return err
+			}
+			offset += int64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	return stream.Send(&pb.ScanFilesRequest{ScanId: scanID, Files: []*pb.File{{
+		Metadata:   metadata,
+		DataChunks: []*mpb.DataChunk{{Offset: offset}},
+	}}})
+}
@@ -19,19 +19,31 @@ import (
 	"testing"
 
 	minions "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
 	"github.com/stretchr/testify/require"
 )
 
+// collectFiles drains out into a single slice and asserts that errc (read
+// once; nil when closed without a send) carried no error, mirroring how SendFiles and the local
+// runner consume LoadFiles.
+func collectFiles(t *testing.T, out <-chan []*pb.File, errc <-chan error) []*pb.File {
+	var all []*pb.File
+	for batch := range out {
+		all = append(all, batch...)
+	}
+	require.NoError(t, <-errc)
+	return all
+}
+
 func TestParsesFiles_onFilesPresent_selectsFiles(t *testing.T) {
 	dir, err := createFile(t, "common_goblins_test", "/foo/bar", "temp.tmp", os.ModePerm)
 	require.NoError(t, err)
 	defer os.RemoveAll(dir)
 
 	i := &minions.Interest{DataType: minions.Interest_METADATA, PathRegexp: ".*\\.tmp"}
-	files, err := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
-	require.NoError(t, err)
-	p := files[0][0].GetMetadata().GetPath()
-	require.Equal(t, dir+"/foo/bar/temp.tmp", p)
+	out, errc := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
+	files := collectFiles(t, out, errc)
+	require.Equal(t, "/foo/bar/temp.tmp", files[0].GetMetadata().GetPath())
 }
 
 func TestParsesFiles_onSelectedFileUnaccessible_doesNotCrash(t *testing.T) {
@@ -43,10 +55,10 @@ func TestParsesFiles_onSelectedFileUnaccessible_doesNotCrash(t *testing.T) {
 	defer os.RemoveAll(dir)
 
 	i := &minions.Interest{DataType: minions.Interest_METADATA_AND_DATA, PathRegexp: ".*\\.tmp"}
-	files, err := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
-	require.NoError(t, err)
-	// Expect that will will skip, with no errors.
-	require.Empty(t, files[0])
+	out, errc := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
+	files := collectFiles(t, out, errc)
+	// Expect that we will skip, with no errors.
+	require.Empty(t, files)
 }
 
 func TestParsesFiles_onMultipleInterests_selectsFiles(t *testing.T) {
@@ -62,12 +74,13 @@ func TestParsesFiles_onMultipleInterests_selectsFiles(t *testing.T) {
 	i := &minions.Interest{DataType: minions.Interest_METADATA, PathRegexp: ".*\\.tmp"}
 	i2 := &minions.Interest{DataType: minions.Interest_METADATA, PathRegexp: ".*\\.foo"}
 
-	files, err := LoadFiles([]*minions.Interest{i, i2}, 10000, 1000, dir)
-	require.NoError(t, err)
+	out, errc := LoadFiles([]*minions.Interest{i, i2}, 10000, 1000, dir)
+	files := collectFiles(t, out, errc)
 
 	expectedFiles := []string{dir + "/foo/bar/temp.foo", dir + "/foo/bar/temp.tmp"}
-	require.Contains(t, expectedFiles, files[0][0].GetMetadata().GetPath())
-	require.Contains(t, expectedFiles, files[0][1].GetMetadata().GetPath())
+	require.Len(t, files, 2)
+	require.Contains(t, expectedFiles, files[0].GetMetadata().GetPath())
+	require.Contains(t, expectedFiles, files[1].GetMetadata().GetPath())
 }
 
 func TestParsesFiles_onFilesPresent_getsMetadata(t *testing.T) {
@@ -76,11 +89,9 @@ func TestParsesFiles_onFilesPresent_getsMetadata(t *testing.T) {
 	defer os.RemoveAll(dir)
 
 	i := &minions.Interest{DataType: minions.Interest_METADATA, PathRegexp: ".*\\.tmp"}
-	files, err := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
-	require.NoError(t, err)
-	p := files[0][0].GetMetadata().GetPermissions()
-
-	require.Equal(t, uint32(0700), p)
+	out, errc := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
+	files := collectFiles(t, out, errc)
+	require.Equal(t, uint32(0700), files[0].GetMetadata().GetPermissions())
 }
 
 func TestParsesFiles_onFilesPresent_readsContents(t *testing.T) {
@@ -93,23 +104,77 @@ func TestParsesFiles_onFilesPresent_readsContents(t *testing.T) {
 	defer os.RemoveAll(dir)
 
 	i := &minions.Interest{DataType: minions.Interest_METADATA_AND_DATA, PathRegexp: ".*\\.tmp"}
-	files, err := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
-	require.NoError(t, err)
-	chunks := files[0][0].GetDataChunks()
+	out, errc := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
+	files := collectFiles(t, out, errc)
+	chunks := files[0].GetDataChunks()
 	data := chunks[0].GetData()
 	require.Equal(t, b, data)
 }
 
+func TestParsesFiles_onFilesPresent_chunkBoundariesStableAcrossReruns(t *testing.T) {
+	dir, err := createFile(t, "common_goblins_test", "", "data.tmp", 0700)
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	f, err := os.Create(dir + "/data.tmp")
+	require.NoError(t, err)
+	// Bigger than maxChunkSize, so this exercises more than one cut.
+	b := make([]byte, 200*1024)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	_, err = f.Write(b)
+	require.NoError(t, err)
+	f.Close()
+
+	i := &minions.Interest{DataType: minions.Interest_METADATA_AND_DATA, PathRegexp: ".*\\.tmp"}
+
+	out1, errc1 := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
+	chunks1 := collectFiles(t, out1, errc1)[0].GetDataChunks()
+
+	out2, errc2 := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
+	chunks2 := collectFiles(t, out2, errc2)[0].GetDataChunks()
+
+	require.Greater(t, len(chunks1), 1)
+	require.Len(t, chunks2, len(chunks1))
+	for idx, c := range chunks1 {
+		require.Equal(t, c.GetOffset(), chunks2[idx].GetOffset())
+		require.Equal(t, c.GetSha256(), chunks2[idx].GetSha256())
+	}
+}
+
 func TestParsesFiles_onFilesMissing_doesNotSelectFiles(t *testing.T) {
 	dir, err := createFile(t, "common_goblins_test", "/", "temp.val1", os.ModePerm)
 	require.NoError(t, err)
 	defer os.RemoveAll(dir)
 
 	i := &minions.Interest{DataType: minions.Interest_METADATA, PathRegexp: ".*\\.val2"}
-	files, err := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
+	out, errc := LoadFiles([]*minions.Interest{i}, 10000, 1000, dir)
+	files := collectFiles(t, out, errc)
+	require.Empty(t, files)
+}
+
+func TestParsesFiles_onManyFiles_batchesByMaxFiles(t *testing.T) {
+	dir, err := createFile(t, "common_goblins_test", "/foo", "temp1.tmp", os.ModePerm)
 	require.NoError(t, err)
-	// Sadly empty does not really support 2 dimensional slices.
-	require.Empty(t, files[0])
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"temp2.tmp", "temp3.tmp"} {
+		_, err = os.Create(dir + "/foo/" + name)
+		require.NoError(t, err)
+	}
+
+	i := &minions.Interest{DataType: minions.Interest_METADATA, PathRegexp: ".*\\.tmp"}
+	out, errc := LoadFiles([]*minions.Interest{i}, 10000, 1, dir)
+
+	var batches int
+	var total int
+	for batch := range out {
+		batches++
+		require.LessOrEqual(t, len(batch), 1)
+		total += len(batch)
+	}
+	require.NoError(t, <-errc)
+	require.Equal(t, 3, total)
+	require.Equal(t, 3, batches)
 }
 
 func createFile(t *testing.T, base string, subdirs string, name string, perm os.FileMode) (string, error) {
@@ -0,0 +1,179 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// minionsignoreFile is the name of the per-directory ignore file
+// LoadFilesWithFilter merges with the global one passed through
+// LoadFilesOptions.IgnoreFile, the same way a .dockerignore or .gitignore
+// applies underneath a repo root.
+const minionsignoreFile = ".minionsignore"
+
+// ignoreRule is a single compiled line out of an ignore file: a glob
+// pattern (dockerignore-style: "**" for any number of path segments, "*"
+// within one segment, "?" for a single character), optionally negated
+// with a leading "!" to un-ignore a path an earlier rule ignored.
+type ignoreRule struct {
+	negate bool
+	re     *regexp.Regexp
+}
+
+// compileIgnoreFile parses path as a dockerignore-style ignore file: one
+// pattern per line, blank lines and "#"-prefixed comments skipped. It's
+// not an error for path not to exist - that just means no rules came from
+// it - but any other read or pattern error is returned.
+func compileIgnoreFile(path string) ([]ignoreRule, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		negate := strings.HasPrefix(line, "!")
+		if negate {
+			line = strings.TrimSpace(line[1:])
+		}
+		re, err := compileIgnorePattern(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: compiling pattern %q: %v", path, line, err)
+		}
+		rules = append(rules, ignoreRule{negate: negate, re: re})
+	}
+	return rules, nil
+}
+
+// compileIgnorePattern turns a single dockerignore-style glob into a
+// regexp matching the full root-relative path (no leading "/") it's
+// evaluated against, so a pattern matching a directory also matches
+// everything below it. A pattern containing no "/" (e.g. "node_modules"
+// or "*.tmp") matches at any depth; a leading "/" (e.g. "/proc") anchors
+// it to the root.
+func compileIgnorePattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	if strings.Contains(pattern, "/") {
+		b.WriteString("^")
+	} else {
+		b.WriteString("^(.*/)?")
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	for i := 0; i < len(pattern); {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i += 2
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+			i++
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+			i++
+		case strings.ContainsRune(`.+()|{}^$\`, rune(pattern[i])):
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			i++
+		default:
+			b.WriteByte(pattern[i])
+			i++
+		}
+	}
+	b.WriteString(`(/.*)?$`)
+	return regexp.Compile(b.String())
+}
+
+// ignoreMatcher decides whether a path under root should be skipped,
+// merging a global ignore file with every per-directory .minionsignore
+// found between root and that path - the nearer (more specific) file's
+// rules are applied last, so it can override a broader rule the same way
+// a nested .dockerignore would.
+type ignoreMatcher struct {
+	root     string
+	global   []ignoreRule
+	dirRules map[string][]ignoreRule // absolute directory path -> its own .minionsignore rules, loaded lazily as Walk reaches it
+}
+
+// newIgnoreMatcher builds an ignoreMatcher rooted at root. globalIgnoreFile
+// may be empty, in which case only per-directory .minionsignore files
+// apply.
+func newIgnoreMatcher(root, globalIgnoreFile string) (*ignoreMatcher, error) {
+	m := &ignoreMatcher{root: root, dirRules: make(map[string][]ignoreRule)}
+	if globalIgnoreFile != "" {
+		rules, err := compileIgnoreFile(globalIgnoreFile)
+		if err != nil {
+			return nil, err
+		}
+		m.global = rules
+	}
+	return m, nil
+}
+
+// loadDir compiles dir's own .minionsignore, if any, caching the result so
+// a directory with many entries only pays the read once. Callers must
+// call this for dir before calling matches on anything inside it - Walk
+// naturally does, since filepath.Walk visits a directory before its
+// children.
+func (m *ignoreMatcher) loadDir(dir string) error {
+	if _, ok := m.dirRules[dir]; ok {
+		return nil
+	}
+	rules, err := compileIgnoreFile(filepath.Join(dir, minionsignoreFile))
+	if err != nil {
+		return err
+	}
+	m.dirRules[dir] = rules
+	return nil
+}
+
+// matches reports whether path should be ignored: the last rule to match
+// it, across the global file followed by every ancestor directory's
+// .minionsignore (root to leaf), wins; a negated match un-ignores.
+func (m *ignoreMatcher) matches(path string) bool {
+	rel := strings.TrimPrefix(removeRoot(path, m.root), "/")
+
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == m.root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	ignored := false
+	apply := func(rules []ignoreRule) {
+		for _, r := range rules {
+			if r.re.MatchString(rel) {
+				ignored = !r.negate
+			}
+		}
+	}
+	apply(m.global)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		apply(m.dirRules[dirs[i]])
+	}
+	return ignored
+}
@@ -0,0 +1,89 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	minions "github.com/google/minions/proto/minions"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, contents string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), os.ModePerm))
+}
+
+func TestLoadFilesWithFilter_globalIgnoreFile_skipsMatchedDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignore_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeFile(t, dir+"/keep.tmp", "a")
+	writeFile(t, dir+"/node_modules/dep/dropped.tmp", "b")
+
+	ignoreFile := dir + "-ignore"
+	writeFile(t, ignoreFile, "node_modules\n")
+	defer os.Remove(ignoreFile)
+
+	i := &minions.Interest{DataType: minions.Interest_METADATA, PathRegexp: ".*\\.tmp"}
+	out, errc := LoadFilesWithFilter([]*minions.Interest{i}, LoadFilesOptions{
+		Root: dir, MaxKb: 10000, MaxFiles: 1000, IgnoreFile: ignoreFile,
+	})
+	files := collectFiles(t, out, errc)
+
+	require.Len(t, files, 1)
+	require.Equal(t, "/keep.tmp", files[0].GetMetadata().GetPath())
+}
+
+func TestLoadFilesWithFilter_perDirectoryMinionsignore_overridesGlobal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignore_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	writeFile(t, dir+"/foo/a.tmp", "a")
+	writeFile(t, dir+"/foo/b.tmp", "b")
+	// The global file ignores every .tmp; foo's own .minionsignore
+	// un-ignores just a.tmp, so only that one should come through.
+	writeFile(t, dir+"/foo/.minionsignore", "!a.tmp\n")
+
+	ignoreFile := dir + "-ignore"
+	writeFile(t, ignoreFile, "*.tmp\n")
+	defer os.Remove(ignoreFile)
+
+	i := &minions.Interest{DataType: minions.Interest_METADATA, PathRegexp: ".*\\.tmp"}
+	out, errc := LoadFilesWithFilter([]*minions.Interest{i}, LoadFilesOptions{
+		Root: dir, MaxKb: 10000, MaxFiles: 1000, IgnoreFile: ignoreFile,
+	})
+	files := collectFiles(t, out, errc)
+
+	require.Len(t, files, 1)
+	require.Equal(t, "/foo/a.tmp", files[0].GetMetadata().GetPath())
+}
+
+func TestLoadFilesWithFilter_noIgnoreFile_behavesLikeLoadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignore_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	writeFile(t, dir+"/a.tmp", "a")
+
+	i := &minions.Interest{DataType: minions.Interest_METADATA, PathRegexp: ".*\\.tmp"}
+	out, errc := LoadFilesWithFilter([]*minions.Interest{i}, LoadFilesOptions{Root: dir, MaxKb: 10000, MaxFiles: 1000})
+	files := collectFiles(t, out, errc)
+
+	require.Len(t, files, 1)
+}
@@ -15,6 +15,7 @@ package main
 
 import (
 	"flag"
+	"io/ioutil"
 	"log"
 	"time"
 
@@ -29,10 +30,17 @@ var (
 	overlordAddr   = flag.String("overlord_addr", "127.0.0.1:10000", "Overlord address in the format of host:port")
 	maxFilesPerReq = flag.Int("max_files_request", 10, "Maximum number of files sent for each ScanFiles RPC")
 	maxKBPerReq    = flag.Int("max_kb_request", 1024, "Maximum KBs to be sent with each ScanFiles RPC")
-	rootPath       = flag.String("root_path", "/", "Root directory that we'll serve files from.")
+	rootPath       = flag.String("root_path", "/", "Root directory that we'll serve files from. Ignored if -scan_config is set.")
+	scanConfig     = flag.String("scan_config", "", "Path to a YAML file declaring multiple roots to walk, each with its own max file size, exclude regexps and DataType override; takes precedence over -root_path when set")
+	ignoreFile     = flag.String("ignore_file", "", "Path to a dockerignore-style file of paths to exclude from the walk, merged with any per-directory .minionsignore found under root_path. Disabled if empty.")
+	maxBytesPerSec = flag.Int64("max_bytes_per_sec", 0, "Throttle outbound ScanFiles traffic to this many bytes/sec. Disabled (unthrottled) if <= 0.")
+	burstBytes     = flag.Int64("burst_bytes", 1024*1024, "Bytes of --max_bytes_per_sec headroom a ScanFiles call may spend immediately before throttling kicks in.")
+	output         = flag.String("output", "text", "Output format for findings: text, sarif or cyclonedx")
+	exportFormat   = flag.String("export_format", "", "If set, also export a compliance report via the Overlord's ExportReport RPC: spdx-json, spdx-tag-value, cyclonedx-json or html-notice")
+	exportOut      = flag.String("export_out", "", "Path to write the --export_format report to")
 )
 
-func startScan(client pb.OverlordClient) []*mpb.Finding {
+func startScan(client pb.OverlordClient) (string, []*mpb.Finding) {
 	log.Printf("Connecting to server")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -45,36 +53,57 @@ func startScan(client pb.OverlordClient) []*mpb.Finding {
 
 	log.Printf("Will now send files for each interests, a bit at a time")
 
-	results, err := sendFiles(client, scanID, response.GetInterests())
+	limiter := goblins.NewLimiter(*maxBytesPerSec, *burstBytes)
+	results, err := sendFiles(client, scanID, response.GetInterests(), limiter)
 	if err != nil {
 		log.Fatalf("SendFiles %v", err)
 	}
 	cancel()
-	return results
+	return scanID, results
 }
 
-func sendFiles(client pb.OverlordClient, scanID string, interests []*mpb.Interest) ([]*mpb.Finding, error) {
+func sendFiles(client pb.OverlordClient, scanID string, interests []*mpb.Interest, limiter *goblins.Limiter) ([]*mpb.Finding, error) {
 	var results []*mpb.Finding
-	files, err := goblins.LoadFiles(interests, *maxKBPerReq, *maxFilesPerReq, *rootPath)
-	if err != nil {
-		return nil, err
+	opts := goblins.LoadFilesOptions{
+		MaxKb:      *maxKBPerReq,
+		MaxFiles:   *maxFilesPerReq,
+		IgnoreFile: *ignoreFile,
+	}
+
+	var files <-chan []*pb.File
+	var errc <-chan error
+	if *scanConfig != "" {
+		cfg, err := goblins.LoadScanConfig(*scanConfig)
+		if err != nil {
+			log.Fatalf("Loading -scan_config %s: %v", *scanConfig, err)
+		}
+		files, errc = goblins.LoadFilesMultiRoot(*cfg, interests, opts)
+	} else {
+		opts.Root = *rootPath
+		files, errc = goblins.LoadFilesWithFilter(interests, opts)
 	}
 
-	for _, fs := range files {
+	var sent int64
+	for fs := range files {
 		for _, ff := range fs {
 			log.Printf("Sending file %s", ff.GetMetadata().GetPath())
+			for _, c := range ff.GetDataChunks() {
+				sent += int64(len(c.GetData()))
+			}
 		}
+		limiter.Wait(sent)
+		sent = 0
 		sfr := &pb.ScanFilesRequest{ScanId: scanID, Files: fs}
 		ctx, _ := context.WithTimeout(context.Background(), 60*time.Second)
 		resp, err := client.ScanFiles(ctx, sfr)
-		log.Printf("Files sent. Response: %v", resp)
+		log.Printf("Files sent. Response: %v (upload rate: %.0f bytes/sec)", resp, limiter.Stats().RateBytesPerSec)
 		if err != nil {
 			return nil, err
 		}
 		// Iterate on new interests
 		if len(resp.GetNewInterests()) > 0 {
 			log.Printf("Got new interests!")
-			r, err := sendFiles(client, scanID, resp.GetNewInterests())
+			r, err := sendFiles(client, scanID, resp.GetNewInterests(), limiter)
 			if err != nil {
 				return nil, err
 			}
@@ -82,6 +111,9 @@ func sendFiles(client pb.OverlordClient, scanID string, interests []*mpb.Interes
 		}
 		results = append(results, resp.GetResults()...)
 	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
 	return results, nil
 }
 
@@ -94,10 +126,27 @@ func main() {
 	defer conn.Close()
 	client := pb.NewOverlordClient(conn)
 
-	results := startScan(client)
+	scanID, results := startScan(client)
 
 	if len(results) == 0 {
 		log.Println("Scan completed but got no vulnerabilities back. Good! Maybe.")
 	}
-	log.Println(goblins.HumanReadableDebug(results))
+	rendered, err := goblins.RenderResults(*output, results)
+	if err != nil {
+		log.Fatalf("Rendering results: %v", err)
+	}
+	log.Println(rendered)
+
+	if *exportFormat != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+		doc, err := goblins.ExportScanReport(ctx, client, scanID, *exportFormat)
+		if err != nil {
+			log.Fatalf("Exporting %s report: %v", *exportFormat, err)
+		}
+		if err := ioutil.WriteFile(*exportOut, doc, 0644); err != nil {
+			log.Fatalf("Writing %s: %v", *exportOut, err)
+		}
+		log.Printf("Wrote %s report to %s", *exportFormat, *exportOut)
+	}
 }
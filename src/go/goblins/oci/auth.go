@@ -0,0 +1,75 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package oci
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// authFileConfig mirrors the handful of fields we care about out of a
+// docker-style config.json: { "auths": { "registry": { "auth": "base64(user:pass)" } } }.
+type authFileConfig struct {
+	Auths map[string]struct {
+		Auth string `json:"auth"`
+	} `json:"auths"`
+}
+
+// AuthFileKeychain is an authn.Keychain backed by a single docker-style
+// config.json, for callers (e.g. --auth_file on the docker goblin) that
+// want to pin credentials explicitly rather than relying on whatever
+// authn.DefaultKeychain finds ambient on the host.
+type AuthFileKeychain struct {
+	auths map[string]authn.AuthConfig
+}
+
+// NewAuthFileKeychain loads path as a docker config.json and returns a
+// Keychain resolving credentials from its "auths" section.
+func NewAuthFileKeychain(path string) (*AuthFileKeychain, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading auth file %q: %v", path, err)
+	}
+	var cfg authFileConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing auth file %q: %v", path, err)
+	}
+	auths := make(map[string]authn.AuthConfig, len(cfg.Auths))
+	for registry, entry := range cfg.Auths {
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("auth file %q: decoding credentials for %q: %v", path, registry, err)
+		}
+		userPass := strings.SplitN(string(decoded), ":", 2)
+		if len(userPass) != 2 {
+			return nil, fmt.Errorf("auth file %q: malformed credentials for %q", path, registry)
+		}
+		auths[registry] = authn.AuthConfig{Username: userPass[0], Password: userPass[1]}
+	}
+	return &AuthFileKeychain{auths: auths}, nil
+}
+
+// Resolve implements authn.Keychain.
+func (k *AuthFileKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	cfg, ok := k.auths[target.RegistryStr()]
+	if !ok {
+		return authn.Anonymous, nil
+	}
+	return authn.FromConfig(cfg), nil
+}
@@ -0,0 +1,95 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package oci
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LayerCache stores a layer's uncompressed tar stream on first fetch, keyed
+// by its digest, so re-scanning an image sharing layers with a
+// previously-scanned one (a common case for incrementally-tagged images)
+// doesn't re-pull bytes already on disk.
+type LayerCache interface {
+	// Get returns the cached uncompressed tar stream for digest, if any.
+	Get(digest string) (io.ReadCloser, bool)
+	// Put stores r (digest's freshly-pulled uncompressed tar stream) in the
+	// cache, and returns a reader over the stored copy that callers should
+	// consume instead of r.
+	Put(digest string, r io.ReadCloser) (io.ReadCloser, error)
+}
+
+// DiskLayerCache is a LayerCache that persists each layer's uncompressed
+// contents as a flat file under Dir, named after the layer's digest.
+type DiskLayerCache struct {
+	Dir string
+}
+
+// NewDiskLayerCache returns a DiskLayerCache rooted at dir, creating it if
+// it doesn't already exist.
+func NewDiskLayerCache(dir string) (*DiskLayerCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskLayerCache{Dir: dir}, nil
+}
+
+func (c *DiskLayerCache) path(digest string) string {
+	// Digests are of the form "sha256:abcd...", and ":" is awkward in a
+	// filename on some filesystems, so swap it for "_".
+	return filepath.Join(c.Dir, filepath.Base(digestToFilename(digest)))
+}
+
+func digestToFilename(digest string) string {
+	out := make([]byte, len(digest))
+	for i := 0; i < len(digest); i++ {
+		if digest[i] == ':' {
+			out[i] = '_'
+			continue
+		}
+		out[i] = digest[i]
+	}
+	return string(out)
+}
+
+// Get implements LayerCache.
+//
+// Cached entries are always the already-uncompressed tar stream (see Put),
+// so there's no decompression to redo here.
+func (c *DiskLayerCache) Get(digest string) (io.ReadCloser, bool) {
+	f, err := os.Open(c.path(digest))
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// Put implements LayerCache.
+func (c *DiskLayerCache) Put(digest string, r io.ReadCloser) (io.ReadCloser, error) {
+	defer r.Close()
+	f, err := os.Create(c.path(digest))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return os.Open(c.path(digest))
+}
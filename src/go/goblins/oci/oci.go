@@ -0,0 +1,202 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+// Package oci materializes a container image pulled from an OCI registry
+// into a local directory, so the rest of a goblin (see goblins.SendFiles)
+// can walk it exactly like a local filesystem or a mounted Docker
+// container. It uses go-containerregistry rather than the overlord's own
+// hand-rolled registry client, since that's what already understands
+// docker config / ECR / GCR credential helpers via its keychain support.
+package oci
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"golang.org/x/net/context"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// Materialize pulls imageRef and extracts its merged rootfs - every layer
+// applied in order, honoring whiteouts - into destDir, which must already
+// exist and be empty. Each layer is streamed straight from the registry
+// (or cache, see LayerCache) to disk, so only one layer's tar stream is
+// ever held in memory at a time, rather than the whole image. It always
+// authenticates with authn.DefaultKeychain and lets the registry pick
+// whichever platform its default manifest resolves to; see
+// MaterializeWithAuth to override either of those.
+func Materialize(ctx context.Context, imageRef, destDir string, cache LayerCache) error {
+	return MaterializeWithAuth(ctx, imageRef, destDir, cache, authn.DefaultKeychain, nil)
+}
+
+// MaterializeWithAuth is Materialize, but with the keychain used to
+// authenticate against the registry and the platform selected out of a
+// multi-arch index made explicit, so a caller like the docker goblin's
+// registryImageSource can support --auth_file and --platform instead of
+// being stuck with the host's ambient Docker credentials and the
+// registry's default platform.
+func MaterializeWithAuth(ctx context.Context, imageRef, destDir string, cache LayerCache, keychain authn.Keychain, platform *v1.Platform) error {
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return fmt.Errorf("parsing image reference %q: %v", imageRef, err)
+	}
+	opts := []remote.Option{remote.WithAuthFromKeychain(keychain), remote.WithContext(ctx)}
+	if platform != nil {
+		opts = append(opts, remote.WithPlatform(*platform))
+	}
+	img, err := remote.Image(ref, opts...)
+	if err != nil {
+		return fmt.Errorf("pulling %q: %v", imageRef, err)
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return fmt.Errorf("listing layers of %q: %v", imageRef, err)
+	}
+	for _, layer := range layers {
+		if err := applyLayer(layer, destDir, cache); err != nil {
+			return fmt.Errorf("applying a layer of %q: %v", imageRef, err)
+		}
+	}
+	return nil
+}
+
+// applyLayer streams a single layer's contents onto destDir, deleting
+// whited-out paths and overwriting anything a previous layer already
+// wrote there.
+func applyLayer(layer v1.Layer, destDir string, cache LayerCache) error {
+	digest, err := layer.Digest()
+	if err != nil {
+		return err
+	}
+
+	var rc io.ReadCloser
+	if cache != nil {
+		if cached, found := cache.Get(digest.String()); found {
+			rc = cached
+		}
+	}
+	if rc == nil {
+		uncompressed, err := layer.Uncompressed()
+		if err != nil {
+			return err
+		}
+		rc = uncompressed
+		if cache != nil {
+			rc, err = cache.Put(digest.String(), rc)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		entryPath := path.Clean("/" + hdr.Name)
+		dir, base := path.Split(entryPath)
+		dir = path.Clean(dir)
+
+		if base == whiteoutOpaque {
+			if err := removeUnderDir(destDir, dir); err != nil {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			target := filepath.Join(destDir, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+			if err := os.RemoveAll(target); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+
+		if err := extractEntry(tr, hdr, filepath.Join(destDir, entryPath)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractEntry writes a single tar entry to dest, which must be rooted
+// under the destDir Materialize was given.
+func extractEntry(tr *tar.Reader, hdr *tar.Header, dest string) error {
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(dest, os.FileMode(hdr.Mode))
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		// A later layer fully overwrites a regular file a previous layer
+		// wrote at the same path, matching ordinary union filesystem
+		// semantics.
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(hdr.Mode))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(f, tr)
+		return err
+	case tar.TypeSymlink:
+		os.Remove(dest) // Ignore errors: fine if it wasn't there.
+		return os.Symlink(hdr.Linkname, dest)
+	default:
+		// Character/block devices, fifos, etc. aren't anything our
+		// Interests ever match against, so skip them rather than fail the
+		// whole extraction over them.
+		log.Printf("oci: skipping unsupported tar entry %q (type %v)", hdr.Name, hdr.Typeflag)
+		return nil
+	}
+}
+
+// removeUnderDir deletes every already-extracted file at or below dir,
+// implementing an opaque-directory whiteout: none of it came from this
+// layer, so none of it should survive once this layer says the directory
+// is now opaque.
+func removeUnderDir(destDir, dir string) error {
+	root := filepath.Join(destDir, dir)
+	entries, err := os.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(root, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+// Package main contains minions-scan-image, a standalone CLI that pulls an
+// OCI image straight from a registry - no local Docker daemon or mounted
+// container involved - and feeds its merged rootfs to the usual
+// LoadFiles/SendFiles pipeline, so minions like rpm and vulners work
+// against it exactly as they would against a local filesystem. All the
+// actual pulling, auth and multi-arch selection lives in goblins/oci; this
+// is just its CLI, the same relationship goblins/docker/runner has to
+// goblins/docker.
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/minions/go/goblins"
+	"github.com/google/minions/go/goblins/oci"
+	"github.com/google/minions/go/grpcutil"
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+var (
+	overlordAddr = flag.String("overlord_addr", "127.0.0.1:10000", "Overlord address in the format of host:port")
+	imageRef     = flag.String("image_ref", "", "Reference (e.g. gcr.io/foo/bar:tag or registry.example.com/foo/bar@sha256:...) of the image to scan")
+	authFile     = flag.String("auth_file", "", "Path to a docker-style config.json to authenticate against the registry (Docker Hub, GCR, ECR, GHCR, ...). Falls back to the host's ambient Docker credentials if empty.")
+	platform     = flag.String("platform", "", "Platform to select out of a multi-arch -image_ref index, as os/arch or os/arch/variant (e.g. linux/amd64). Uses the registry's default if empty.")
+	layerCache   = flag.String("layer_cache_dir", "", "Directory to cache pulled layers in, keyed by digest; disabled if empty")
+	output       = flag.String("output", "text", "Output format for findings: text, sarif or cyclonedx")
+
+	caCert   = flag.String("ca_cert", "", "Path to a CA certificate to validate the Overlord's TLS certificate")
+	insecure = flag.Bool("insecure", false, "Connect to the Overlord without validating its TLS certificate; required if -ca_cert is empty")
+)
+
+func startScan(client pb.OverlordClient, rootPath string) []*mpb.Finding {
+	log.Printf("Connecting to server")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	response, err := client.CreateScan(ctx, &pb.CreateScanRequest{})
+	if err != nil {
+		log.Fatalf("%v.CreateScan(_) = _, %v", client, err)
+	}
+	scanID := response.GetScanId()
+	log.Printf("Created scan %s", scanID)
+	log.Printf("Will now send files for each interests, a bit at a time")
+
+	results, err := goblins.SendFiles(client, scanID, response.GetInterests(), rootPath)
+	if err != nil {
+		log.Fatalf("Failed sending files to the overlord: %v", err)
+	}
+	return results
+}
+
+// newKeychain picks authn.DefaultKeychain (the ambient Docker credentials -
+// covers Docker Hub, GCR, ECR and GHCR when their respective credential
+// helpers are configured) unless -auth_file points at an explicit
+// config.json.
+func newKeychain() (authn.Keychain, error) {
+	if *authFile == "" {
+		return authn.DefaultKeychain, nil
+	}
+	return oci.NewAuthFileKeychain(*authFile)
+}
+
+func dialOverlord() (*grpc.ClientConn, error) {
+	host := strings.Split(*overlordAddr, ":")[0]
+	opt, err := grpcutil.GetSslClientOptions(host, *caCert)
+	if err != nil {
+		return nil, err
+	}
+	return grpc.Dial(*overlordAddr, opt)
+}
+
+func main() {
+	flag.Parse()
+	if *imageRef == "" {
+		log.Fatal("-image_ref is required")
+	}
+	if *caCert == "" && !*insecure {
+		log.Fatal("one of -ca_cert or -insecure is required")
+	}
+
+	keychain, err := newKeychain()
+	if err != nil {
+		log.Fatalf("setting up registry auth: %v", err)
+	}
+	platformRef, err := oci.ParsePlatform(*platform)
+	if err != nil {
+		log.Fatalf("parsing -platform: %v", err)
+	}
+	var cache oci.LayerCache
+	if *layerCache != "" {
+		cache, err = oci.NewDiskLayerCache(*layerCache)
+		if err != nil {
+			log.Fatalf("opening layer cache %s: %v", *layerCache, err)
+		}
+	}
+
+	rootPath, err := ioutil.TempDir("", "OCI_MINION")
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Will materialize %s into %s", *imageRef, rootPath)
+	defer os.RemoveAll(rootPath)
+
+	if err := oci.MaterializeWithAuth(context.Background(), *imageRef, rootPath, cache, keychain, platformRef); err != nil {
+		log.Fatalf("Failed to materialize %s: %v", *imageRef, err)
+	}
+
+	conn, err := dialOverlord()
+	if err != nil {
+		log.Fatalf("fail to connect to the overlord: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewOverlordClient(conn)
+
+	results := startScan(client, rootPath)
+
+	if len(results) == 0 {
+		log.Println("Scan completed but got no vulnerabilities back. Good! Maybe.")
+		return
+	}
+
+	log.Println("Scan finished - we've got some results!")
+	rendered, err := goblins.RenderResults(*output, results)
+	if err != nil {
+		log.Fatalf("Rendering results: %v", err)
+	}
+	log.Println(rendered)
+}
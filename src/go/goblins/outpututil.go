@@ -21,6 +21,26 @@ import (
 	mpb "github.com/google/minions/proto/minions"
 )
 
+// RenderResults renders results in the given output format - "text"
+// (HumanReadableDebug), "sarif" (RenderSARIF) or "cyclonedx"
+// (RenderCycloneDXVEX) - for goblin/overlord CLIs that expose an
+// `--output` flag so findings can be piped straight into GitHub code
+// scanning, DefectDojo or Dependency-Track without a bespoke adapter.
+func RenderResults(format string, results []*mpb.Finding) (string, error) {
+	switch format {
+	case "", "text":
+		return HumanReadableDebug(results), nil
+	case "sarif":
+		b, err := RenderSARIF(results)
+		return string(b), err
+	case "cyclonedx":
+		b, err := RenderCycloneDXVEX(results)
+		return string(b), err
+	default:
+		return "", fmt.Errorf("unknown output format %q", format)
+	}
+}
+
 // HumanReadableDebug generates a human readable debug form from a slice
 // of results.
 func HumanReadableDebug(results []*mpb.Finding) string {
@@ -0,0 +1,73 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"time"
+
+	"github.com/google/minions/go/throttle"
+)
+
+// Limiter is a token-bucket bandwidth limiter for outbound ScanFiles
+// traffic: Wait(n) blocks until n bytes' worth of tokens are available,
+// refilling the bucket at limit bytes/sec up to a burst-sized cap. A nil
+// *Limiter, or one with a non-positive limit, never blocks - SendFiles and
+// the local goblin runner treat that as "unthrottled". It's a thin wrapper around the
+// throttle package's Limiter and Monitor, shared with the receiver side
+// (see minions.StartMinion) and the Overlord's per-scan throttle.Registry.
+type Limiter struct {
+	limiter *throttle.Limiter
+	monitor *throttle.Monitor
+}
+
+// NewLimiter returns a Limiter allowing limitBytesPerSec bytes/sec on
+// average, with up to burstBytes available to spend immediately before
+// throttling kicks in. limitBytesPerSec <= 0 disables throttling.
+func NewLimiter(limitBytesPerSec, burstBytes int64) *Limiter {
+	return &Limiter{
+		limiter: throttle.NewLimiter(limitBytesPerSec, burstBytes),
+		monitor: throttle.NewMonitor(throttle.DefaultAlpha),
+	}
+}
+
+// Wait blocks until n bytes' worth of tokens are available, deducts them,
+// and folds the throughput this call actually achieved into the
+// exponential moving average Stats reports.
+func (l *Limiter) Wait(n int64) {
+	if l == nil {
+		return
+	}
+	start := time.Now()
+	l.limiter.Wait(n)
+	l.monitor.Observe(n, time.Since(start))
+}
+
+// Stats reports a Limiter's configuration alongside its current
+// exponential-moving-average observed throughput, e.g. for a periodic log
+// line during a long upload.
+type Stats struct {
+	RateBytesPerSec  float64 // EMA of throughput actually achieved across recent Wait calls.
+	LimitBytesPerSec float64
+	BurstBytes       int64
+}
+
+// Stats returns l's current Stats. Safe to call on a nil Limiter.
+func (l *Limiter) Stats() Stats {
+	if l == nil {
+		return Stats{}
+	}
+	ls := l.limiter.Stats()
+	ms := l.monitor.Stats()
+	return Stats{RateBytesPerSec: ms.RateBytesPerSec, LimitBytesPerSec: ls.LimitBytesPerSec, BurstBytes: ls.BurstBytes}
+}
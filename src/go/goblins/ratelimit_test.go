@@ -0,0 +1,59 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_nil_neverBlocks(t *testing.T) {
+	var l *Limiter
+	start := time.Now()
+	l.Wait(1 << 30)
+	require.Less(t, time.Since(start), 100*time.Millisecond)
+	require.Equal(t, Stats{}, l.Stats())
+}
+
+func TestLimiter_disabled_neverBlocks(t *testing.T) {
+	l := NewLimiter(0, 0)
+	start := time.Now()
+	l.Wait(1 << 30)
+	require.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestLimiter_withinBurst_doesNotBlock(t *testing.T) {
+	l := NewLimiter(1024, 4096)
+	start := time.Now()
+	l.Wait(4096)
+	require.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestLimiter_overBurst_blocksRoughlyTheExpectedAmount(t *testing.T) {
+	l := NewLimiter(1000, 0) // 1000 bytes/sec, no burst headroom.
+	start := time.Now()
+	l.Wait(300) // Should take ~300ms.
+	elapsed := time.Since(start)
+	require.GreaterOrEqual(t, elapsed, 250*time.Millisecond)
+	require.Less(t, elapsed, 1*time.Second)
+}
+
+func TestLimiter_stats_reportsConfiguredLimitAndBurst(t *testing.T) {
+	l := NewLimiter(500, 2000)
+	stats := l.Stats()
+	require.Equal(t, 500.0, stats.LimitBytesPerSec)
+	require.Equal(t, int64(2000), stats.BurstBytes)
+}
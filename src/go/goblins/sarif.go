@@ -0,0 +1,142 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"encoding/json"
+
+	mpb "github.com/google/minions/proto/minions"
+)
+
+// SARIF 2.1.0 is a large spec; these types only cover the subset
+// RenderSARIF actually populates.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// RenderSARIF serializes results into a SARIF 2.1.0 log, one run per
+// distinct Source.Minion (SARIF's tool.driver.name): Advisory.Reference
+// becomes both the rule ID and, deduplicated across a run, a rule
+// definition; every VulnerableResource.Path of a Finding becomes its own
+// entry in that Finding's result.locations, rather than a separate result,
+// so a multi-resource Finding stays a single result as SARIF intends.
+func RenderSARIF(results []*mpb.Finding) ([]byte, error) {
+	var runOrder []string
+	runsByMinion := make(map[string]*sarifRun)
+	rulesByMinion := make(map[string]map[string]bool)
+
+	for _, f := range results {
+		minion := f.GetSource().GetMinion()
+		run, ok := runsByMinion[minion]
+		if !ok {
+			run = &sarifRun{Tool: sarifTool{Driver: sarifDriver{Name: minion}}}
+			runsByMinion[minion] = run
+			rulesByMinion[minion] = make(map[string]bool)
+			runOrder = append(runOrder, minion)
+		}
+
+		ref := f.GetAdvisory().GetReference()
+		if !rulesByMinion[minion][ref] {
+			rulesByMinion[minion][ref] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               ref,
+				ShortDescription: sarifMessage{Text: f.GetAdvisory().GetDescription()},
+			})
+		}
+
+		var locations []sarifLocation
+		for _, res := range f.GetVulnerableResources() {
+			locations = append(locations, sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: res.GetPath()},
+				},
+			})
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    ref,
+			Level:     sarifLevel(f.GetSeverity()),
+			Message:   sarifMessage{Text: f.GetAdvisory().GetDescription()},
+			Locations: locations,
+		})
+	}
+
+	log := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+	}
+	for _, minion := range runOrder {
+		log.Runs = append(log.Runs, *runsByMinion[minion])
+	}
+	return json.MarshalIndent(log, "", "  ")
+}
+
+// sarifLevel maps a Finding's Severity to the closest SARIF result level -
+// "error", "warning", "note" or "none".
+func sarifLevel(s mpb.Finding_Severity) string {
+	switch s {
+	case mpb.Finding_SEVERITY_CRITICAL, mpb.Finding_SEVERITY_HIGH:
+		return "error"
+	case mpb.Finding_SEVERITY_MEDIUM:
+		return "warning"
+	case mpb.Finding_SEVERITY_LOW:
+		return "note"
+	default:
+		return "none"
+	}
+}
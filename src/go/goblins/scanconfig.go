@@ -0,0 +1,134 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	mpb "github.com/google/minions/proto/minions"
+)
+
+// RootPolicy is one root a ScanConfig walks, and the policy applied to
+// everything under it.
+type RootPolicy struct {
+	// Root is the directory this policy walks.
+	Root string
+	// MaxFileSize skips any file larger than this many bytes instead of
+	// reading it; 0 means no cap.
+	MaxFileSize int64
+	// ExcludeRegexps, if any match a path's root-relative form, skips it -
+	// for a directory, the whole subtree beneath it is never walked. See
+	// LoadFilesOptions.ExcludeRegexps.
+	ExcludeRegexps []string
+	// DataOverride, if set, replaces the DataType of every Interest
+	// matched under Root - e.g. forcing mpb.Interest_METADATA under
+	// "/var/log" even for an interest that asked for METADATA_AND_DATA, so
+	// that root never has its file contents read regardless of what a
+	// minion declared interest in.
+	DataOverride *mpb.Interest_DataType
+}
+
+// ScanConfig is an ordered list of roots a goblin should walk, each under
+// its own RootPolicy - e.g. strict on "/etc", metadata-only on
+// "/var/log", skipped entirely via a RootPolicy.ExcludeRegexps covering
+// "/proc" and "/sys". Roots are walked in order; where two Roots'
+// directory trees overlap, the one declared first wins (see
+// LoadFilesMultiRoot).
+type ScanConfig struct {
+	Roots []RootPolicy
+}
+
+// rawScanConfig mirrors ScanConfig as it's actually written in a
+// --scan_config YAML file: an optional top-level "default" block,
+// inherited by any "roots" entry that omits a field.
+type rawScanConfig struct {
+	Default *rawRootPolicy  `yaml:"default,omitempty"`
+	Roots   []rawRootPolicy `yaml:"roots"`
+}
+
+// rawRootPolicy mirrors RootPolicy as written in YAML: DataOverride is the
+// mpb.Interest_DataType enum's string name (e.g. "METADATA"), since neither
+// YAML nor the generated proto enum type decode straight into a
+// *mpb.Interest_DataType.
+type rawRootPolicy struct {
+	Root           string   `yaml:"root"`
+	MaxFileSize    int64    `yaml:"max_file_size,omitempty"`
+	ExcludeRegexps []string `yaml:"exclude_regexps,omitempty"`
+	DataOverride   string   `yaml:"data_override,omitempty"`
+}
+
+// LoadScanConfig reads and parses the YAML file at path into a ScanConfig,
+// applying the top-level "default" block (if any) to every "roots" entry
+// that leaves a field unset.
+func LoadScanConfig(path string) (*ScanConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("scanconfig: reading %s: %v", path, err)
+	}
+
+	var raw rawScanConfig
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("scanconfig: parsing %s: %v", path, err)
+	}
+
+	cfg := &ScanConfig{Roots: make([]RootPolicy, len(raw.Roots))}
+	for i, r := range raw.Roots {
+		if raw.Default != nil {
+			r = mergeDefaults(r, *raw.Default)
+		}
+		override, err := parseDataOverride(r.DataOverride)
+		if err != nil {
+			return nil, fmt.Errorf("scanconfig: %s: root %s: %v", path, r.Root, err)
+		}
+		cfg.Roots[i] = RootPolicy{
+			Root:           r.Root,
+			MaxFileSize:    r.MaxFileSize,
+			ExcludeRegexps: r.ExcludeRegexps,
+			DataOverride:   override,
+		}
+	}
+	return cfg, nil
+}
+
+// mergeDefaults fills any field r leaves at its zero value in from def,
+// leaving a field r did set untouched.
+func mergeDefaults(r, def rawRootPolicy) rawRootPolicy {
+	if r.MaxFileSize == 0 {
+		r.MaxFileSize = def.MaxFileSize
+	}
+	if len(r.ExcludeRegexps) == 0 {
+		r.ExcludeRegexps = def.ExcludeRegexps
+	}
+	if r.DataOverride == "" {
+		r.DataOverride = def.DataOverride
+	}
+	return r
+}
+
+// parseDataOverride turns the YAML-level DataType name into the enum
+// pointer RootPolicy expects, or nil if name is empty.
+func parseDataOverride(name string) (*mpb.Interest_DataType, error) {
+	if name == "" {
+		return nil, nil
+	}
+	v, ok := mpb.Interest_DataType_value[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown data_override %q", name)
+	}
+	dt := mpb.Interest_DataType(v)
+	return &dt, nil
+}
@@ -0,0 +1,93 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	minions "github.com/google/minions/proto/minions"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFilesMultiRoot_onRootSpecificMaxFileSize_appliesPerRoot(t *testing.T) {
+	base, err := ioutil.TempDir("", "common_goblins_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+
+	strictRoot := filepath.Join(base, "strict")
+	looseRoot := filepath.Join(base, "loose")
+	require.NoError(t, os.MkdirAll(strictRoot, os.ModePerm))
+	require.NoError(t, os.MkdirAll(looseRoot, os.ModePerm))
+	payload := []byte("0123456789")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(strictRoot, "data.tmp"), payload, os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(looseRoot, "data.tmp"), payload, os.ModePerm))
+
+	cfg := ScanConfig{Roots: []RootPolicy{
+		{Root: strictRoot, MaxFileSize: 5},
+		{Root: looseRoot},
+	}}
+	i := &minions.Interest{DataType: minions.Interest_METADATA, PathRegexp: ".*\\.tmp"}
+	out, errc := LoadFilesMultiRoot(cfg, []*minions.Interest{i}, LoadFilesOptions{})
+	files := collectFiles(t, out, errc)
+
+	require.Len(t, files, 1)
+	require.Equal(t, "/data.tmp", files[0].GetMetadata().GetPath())
+}
+
+func TestLoadFilesMultiRoot_onEarlierRootExcludeRegexpCoversLaterRoot_excludesIt(t *testing.T) {
+	base, err := ioutil.TempDir("", "common_goblins_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+
+	secretDir := filepath.Join(base, "etc", "secret")
+	require.NoError(t, os.MkdirAll(secretDir, os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(secretDir, "data.tmp"), []byte("x"), os.ModePerm))
+
+	cfg := ScanConfig{Roots: []RootPolicy{
+		// / excludes everything under /etc/secret...
+		{Root: base, ExcludeRegexps: []string{"^/etc/secret"}},
+		// ...so this narrower, later Root never gets a chance to yield it.
+		{Root: secretDir},
+	}}
+	i := &minions.Interest{DataType: minions.Interest_METADATA, PathRegexp: ".*\\.tmp"}
+	out, errc := LoadFilesMultiRoot(cfg, []*minions.Interest{i}, LoadFilesOptions{})
+	files := collectFiles(t, out, errc)
+
+	require.Empty(t, files)
+}
+
+func TestLoadFilesMultiRoot_onOverlappingRoots_firstDeclaredRootWins(t *testing.T) {
+	base, err := ioutil.TempDir("", "common_goblins_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(base)
+
+	innerDir := filepath.Join(base, "inner")
+	require.NoError(t, os.MkdirAll(innerDir, os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(innerDir, "data.tmp"), []byte("x"), os.ModePerm))
+
+	metadataOnly := minions.Interest_METADATA
+	cfg := ScanConfig{Roots: []RootPolicy{
+		{Root: base, DataOverride: &metadataOnly},
+		{Root: innerDir}, // would otherwise deliver data too, but base already claimed this file
+	}}
+	i := &minions.Interest{DataType: minions.Interest_METADATA_AND_DATA, PathRegexp: ".*\\.tmp"}
+	out, errc := LoadFilesMultiRoot(cfg, []*minions.Interest{i}, LoadFilesOptions{})
+	files := collectFiles(t, out, errc)
+
+	require.Len(t, files, 1)
+	require.Empty(t, files[0].GetDataChunks())
+}
@@ -0,0 +1,57 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package goblins
+
+import (
+	"testing"
+
+	mpb "github.com/google/minions/proto/minions"
+	"github.com/stretchr/testify/require"
+)
+
+func findingFixture() []*mpb.Finding {
+	return []*mpb.Finding{{
+		Advisory: &mpb.Advisory{Reference: "CVE-2020-1234", Description: "foodesc"},
+		Source:   &mpb.Source{Minion: "vulners"},
+		Severity: mpb.Finding_SEVERITY_CRITICAL,
+		VulnerableResources: []*mpb.VulnerableResource{
+			{Path: "/usr/lib/libfoo.so"},
+			{Path: "/opt/app/libfoo.so"},
+		},
+	}}
+}
+
+func TestRenderSARIF_onFindings_mapsAdvisoryAndResources(t *testing.T) {
+	out, err := RenderSARIF(findingFixture())
+	require.NoError(t, err)
+	require.Contains(t, string(out), "CVE-2020-1234")
+	require.Contains(t, string(out), "vulners")
+	require.Contains(t, string(out), "/usr/lib/libfoo.so")
+	require.Contains(t, string(out), "/opt/app/libfoo.so")
+	require.Contains(t, string(out), `"level": "error"`)
+}
+
+func TestRenderCycloneDXVEX_onFindings_mapsAdvisoryAndResources(t *testing.T) {
+	out, err := RenderCycloneDXVEX(findingFixture())
+	require.NoError(t, err)
+	require.Contains(t, string(out), "CVE-2020-1234")
+	require.Contains(t, string(out), "vulners")
+	require.Contains(t, string(out), "/usr/lib/libfoo.so")
+	require.Contains(t, string(out), `"severity": "critical"`)
+}
+
+func TestRenderResults_onUnknownFormat_returnsError(t *testing.T) {
+	_, err := RenderResults("yaml", findingFixture())
+	require.Error(t, err)
+}
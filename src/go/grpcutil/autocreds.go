@@ -0,0 +1,308 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package grpcutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/crypto/acme"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// AutoCreds hands out whatever certificate is currently in its in-memory
+// cache to both gRPC servers (ServerOption) and clients (DialOption), and
+// hot-swaps that certificate in the background - either renewed from an
+// ACME CA (NewAutoCredsACME) or reloaded from disk on change
+// (NewAutoCredsFileWatch) - so long-lived minion/overlord processes never
+// need restarting just to pick up a new identity.
+type AutoCreds struct {
+	cert atomic.Value // holds *tls.Certificate
+}
+
+func (c *AutoCreds) get() *tls.Certificate {
+	v, _ := c.cert.Load().(*tls.Certificate)
+	return v
+}
+
+func (c *AutoCreds) set(cert *tls.Certificate) {
+	c.cert.Store(cert)
+}
+
+// ServerOption returns a grpc.ServerOption whose TLS certificate is
+// whatever AutoCreds currently has cached, re-read on every handshake.
+func (c *AutoCreds) ServerOption() grpc.ServerOption {
+	return grpc.Creds(credentials.NewTLS(&tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if cert := c.get(); cert != nil {
+				return cert, nil
+			}
+			return nil, fmt.Errorf("grpcutil: no certificate issued yet")
+		},
+	}))
+}
+
+// DialOption returns a grpc.DialOption presenting whatever client
+// certificate AutoCreds currently has cached - useful for minions that
+// authenticate to the Overlord with mTLS rather than a static cert file.
+func (c *AutoCreds) DialOption() grpc.DialOption {
+	return grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert := c.get(); cert != nil {
+				return cert, nil
+			}
+			return nil, fmt.Errorf("grpcutil: no certificate issued yet")
+		},
+	}))
+}
+
+// NewAutoCredsFileWatch is the lighter alternative to NewAutoCredsACME: it
+// loads certPath/keyPath once and then watches both with fsnotify, reloading
+// into the same in-memory cache whenever either file changes - for
+// deployments where something else (cert-manager, a step-ca sidecar, a cron
+// job) already owns issuance and rotation, and all grpcutil needs to do is
+// notice the files changed underneath it without a restart.
+func NewAutoCredsFileWatch(certPath, keyPath string) (*AutoCreds, error) {
+	c := &AutoCreds{}
+	if err := c.reloadFromDisk(certPath, keyPath); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("starting file watcher: %v", err)
+	}
+	for _, p := range []string{certPath, keyPath} {
+		if err := watcher.Add(p); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("watching %s: %v", p, err)
+		}
+	}
+
+	go c.watchLoop(watcher, certPath, keyPath)
+	return c, nil
+}
+
+func (c *AutoCreds) reloadFromDisk(certPath, keyPath string) error {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return fmt.Errorf("loading key pair %s/%s: %v", certPath, keyPath, err)
+	}
+	// tls.LoadX509KeyPair doesn't populate Leaf, but renewLoop's ACME path
+	// needs it (see issueCert) so both sources look the same to callers.
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("parsing leaf certificate %s: %v", certPath, err)
+	}
+	cert.Leaf = leaf
+	c.set(&cert)
+	return nil
+}
+
+// watchLoop reloads certPath/keyPath whenever fsnotify reports either one
+// changed. Many editors and `cp` replace a file rather than writing it in
+// place, which shows up as Remove followed by Create rather than Write, so
+// both trigger a reload; a reload racing the writer mid-copy just fails and
+// is retried on the next event, keeping whatever was already cached.
+func (c *AutoCreds) watchLoop(watcher *fsnotify.Watcher, certPath, keyPath string) {
+	defer watcher.Close()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove) == 0 {
+				continue
+			}
+			if err := c.reloadFromDisk(certPath, keyPath); err != nil {
+				log.Printf("grpcutil: reloading %s/%s failed, keeping previous certificate: %v", certPath, keyPath, err)
+				continue
+			}
+			// A Remove means the old inode is gone; re-add so we keep
+			// getting events for whatever replaced it.
+			if event.Op&fsnotify.Remove != 0 {
+				watcher.Add(event.Name)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("grpcutil: file watcher error: %v", err)
+		}
+	}
+}
+
+// renewAtFraction is how far into a certificate's lifetime (NotBefore to
+// NotAfter) AutoCreds tries to renew it - 2/3 in, rather than waiting
+// until it's nearly expired, leaves plenty of runway to retry through a
+// CA outage before the old certificate actually stops working.
+const renewAtFraction = 2.0 / 3.0
+
+// NewAutoCredsACME bootstraps identity from directoryURL, an ACME v2
+// directory endpoint - Let's Encrypt's, an internal step-ca's, or any
+// other ACME-compliant CA's. identity is always treated as a DNS name: it
+// is what gets authorized and it becomes the certificate's CommonName and
+// DNS SAN; no URI SAN (e.g. a SPIFFE ID) is ever requested.
+// Challenge fulfillment is HTTP-01 only: the returned AutoCreds exposes an
+// http.Handler (see HTTP01Handler) the caller must mount at
+// "/.well-known/acme-challenge/" on port 80 for the identity's hostname.
+//
+// The initial certificate is issued synchronously, so callers fail fast
+// if the CA is unreachable at startup; a background goroutine then keeps
+// renewing it at renewAtFraction of its lifetime for as long as ctx is
+// alive, retrying with exponential backoff on failure without ever giving
+// up (an expired cache entry fails closed via ServerOption/DialOption
+// above, which is as good as it gets without a human intervening).
+func NewAutoCredsACME(ctx context.Context, directoryURL, identity string) (*AutoCreds, error) {
+	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generating ACME account key: %v", err)
+	}
+	client := &acme.Client{DirectoryURL: directoryURL, Key: accountKey}
+	if _, err := client.Register(ctx, &acme.Account{}, acme.AcceptTOS); err != nil {
+		return nil, fmt.Errorf("registering ACME account: %v", err)
+	}
+
+	c := &AutoCreds{}
+	cert, lifetime, err := issueCert(ctx, client, identity)
+	if err != nil {
+		return nil, fmt.Errorf("issuing initial certificate for %s: %v", identity, err)
+	}
+	c.set(cert)
+
+	go c.renewLoop(ctx, client, identity, lifetime)
+	return c, nil
+}
+
+// HTTP01Handler returns the handler NewAutoCredsACME's caller must mount
+// at "/.well-known/acme-challenge/" for HTTP-01 challenges to succeed.
+// Kept separate from the ACME client (rather than having AutoCreds start
+// its own listener) since whatever serves identity's hostname on port 80
+// is the caller's to own, not ours.
+func (c *AutoCreds) HTTP01Handler(client *acme.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Path[len("/.well-known/acme-challenge/"):]
+		resp, err := client.HTTP01ChallengeResponse(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, resp)
+	})
+}
+
+// renewLoop keeps cert fresh for as long as ctx lives, renewing at
+// renewAtFraction of its lifetime and retrying with exponential backoff
+// (capped at an hour) if issuance fails.
+func (c *AutoCreds) renewLoop(ctx context.Context, client *acme.Client, identity string, lifetime time.Duration) {
+	backoff := time.Second
+	for {
+		wait := time.Duration(float64(lifetime) * renewAtFraction)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		cert, newLifetime, err := issueCert(ctx, client, identity)
+		if err != nil {
+			log.Printf("grpcutil: renewing certificate for %s failed, retrying in %s: %v", identity, backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > time.Hour {
+				backoff = time.Hour
+			}
+			// Retry issuance again at the same wait point rather than
+			// waiting out a full lifetime we never actually got.
+			lifetime = backoff
+			continue
+		}
+		c.set(cert)
+		lifetime = newLifetime
+		backoff = time.Second
+	}
+}
+
+// issueCert runs one ACME issuance for identity: authorize, fulfill the
+// HTTP-01 challenge, submit a CSR and return the resulting certificate
+// along with how long it's valid for.
+func issueCert(ctx context.Context, client *acme.Client, identity string) (*tls.Certificate, time.Duration, error) {
+	authz, err := client.Authorize(ctx, identity)
+	if err != nil {
+		return nil, 0, fmt.Errorf("authorizing %s: %v", identity, err)
+	}
+
+	var chal *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "http-01" {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return nil, 0, fmt.Errorf("no http-01 challenge offered for %s", identity)
+	}
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return nil, 0, fmt.Errorf("accepting challenge for %s: %v", identity, err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return nil, 0, fmt.Errorf("waiting for authorization of %s: %v", identity, err)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("generating certificate key: %v", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: identity},
+		DNSNames: []string{identity},
+	}, certKey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("creating CSR for %s: %v", identity, err)
+	}
+
+	der, _, err := client.CreateCert(ctx, csr, 0, true)
+	if err != nil {
+		return nil, 0, fmt.Errorf("requesting certificate for %s: %v", identity, err)
+	}
+	leaf, err := x509.ParseCertificate(der[0])
+	if err != nil {
+		return nil, 0, fmt.Errorf("parsing issued certificate for %s: %v", identity, err)
+	}
+
+	cert := &tls.Certificate{
+		Certificate: der,
+		PrivateKey:  certKey,
+		Leaf:        leaf,
+	}
+	return cert, leaf.NotAfter.Sub(leaf.NotBefore), nil
+}
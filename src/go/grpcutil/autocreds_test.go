@@ -0,0 +1,94 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package grpcutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeSelfSignedCert writes a throwaway self-signed certificate and key
+// for commonName to certPath/keyPath, so these tests can rewrite the
+// files mid-test instead of relying on the fixed testdata/ fixtures
+// grpcutil_test.go uses.
+func writeSelfSignedCert(t *testing.T, certPath, keyPath, commonName string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certOut, err := os.Create(certPath)
+	require.NoError(t, err)
+	require.NoError(t, pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	require.NoError(t, certOut.Close())
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	keyOut, err := os.Create(keyPath)
+	require.NoError(t, err)
+	require.NoError(t, pem.Encode(keyOut, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}))
+	require.NoError(t, keyOut.Close())
+}
+
+func TestNewAutoCredsFileWatch_onValidCert_loadsInitialCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "server.crt")
+	keyPath := filepath.Join(dir, "server.key")
+	writeSelfSignedCert(t, certPath, keyPath, "first")
+
+	c, err := NewAutoCredsFileWatch(certPath, keyPath)
+	require.NoError(t, err)
+	require.Equal(t, "first", c.get().Leaf.Subject.CommonName)
+}
+
+func TestNewAutoCredsFileWatch_onMissingCert_returnsError(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewAutoCredsFileWatch(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key"))
+	require.Error(t, err)
+}
+
+func TestNewAutoCredsFileWatch_onCertRewritten_reloadsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "server.crt")
+	keyPath := filepath.Join(dir, "server.key")
+	writeSelfSignedCert(t, certPath, keyPath, "first")
+
+	c, err := NewAutoCredsFileWatch(certPath, keyPath)
+	require.NoError(t, err)
+
+	writeSelfSignedCert(t, certPath, keyPath, "second")
+
+	require.Eventually(t, func() bool {
+		return c.get().Leaf.Subject.CommonName == "second"
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -94,3 +94,29 @@ func GetSslClientOptions(serverAddress string, caCertPath string) (grpc.DialOpti
 	})
 	return grpc.WithTransportCredentials(creds), nil
 }
+
+// GetSslClientOptionsMTLS is like GetSslClientOptions, but also presents a
+// client certificate, for use against a server started with
+// GetSslServerCreds' client-CA argument set (e.g. StartMinion's
+// --client_ca).
+func GetSslClientOptionsMTLS(serverAddress, caCertPath, clientCertPath, clientKeyPath string) (grpc.DialOption, error) {
+	cas := x509.NewCertPool()
+	ca, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read ca certificate: %s", err)
+	}
+	if ok := cas.AppendCertsFromPEM(ca); !ok {
+		return nil, errors.New("failed to append ca certs")
+	}
+	certificate, err := tls.LoadX509KeyPair(clientCertPath, clientKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("CA and client cert loaded, will check server SSL certs and present a client cert.")
+	creds := credentials.NewTLS(&tls.Config{
+		RootCAs:      cas,
+		ServerName:   serverAddress,
+		Certificates: []tls.Certificate{certificate},
+	})
+	return grpc.WithTransportCredentials(creds), nil
+}
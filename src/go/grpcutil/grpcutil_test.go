@@ -14,10 +14,13 @@
 package grpcutil
 
 import (
+	"net"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
 )
 
 func TestGetSslServerCreds_onNoCertsNorKey_returnsNil(t *testing.T) {
@@ -78,3 +81,131 @@ func TestGetSslClientOptions_onCA_returnNoError(t *testing.T) {
 	_, err = GetSslClientOptions("google.com", crt)
 	require.NoError(t, err)
 }
+
+func TestGetSslClientOptionsMTLS_onValidCAAndClientCert_returnsNoError(t *testing.T) {
+	basepath, err := os.Getwd()
+	require.NoError(t, err)
+	crt := basepath + "/testdata/test_ca.crt"
+	clientCrt := basepath + "/testdata/client.crt"
+	clientKey := basepath + "/testdata/client.key"
+	_, err = GetSslClientOptionsMTLS("127.0.0.1", crt, clientCrt, clientKey)
+	require.NoError(t, err)
+}
+
+func TestGetSslClientOptionsMTLS_onWrongCAClientCert_loadsButServerWillReject(t *testing.T) {
+	// GetSslClientOptionsMTLS only builds dial options - it has no server to
+	// check the client cert's issuer against, so a client cert signed by a
+	// CA the server doesn't trust loads without error here. The actual
+	// rejection is exercised end-to-end below, by handshaking against a
+	// server built with GetSslServerCreds' client-CA support.
+	basepath, err := os.Getwd()
+	require.NoError(t, err)
+	crt := basepath + "/testdata/test_ca.crt"
+	wrongClientCrt := basepath + "/testdata/wrong_client.crt"
+	wrongClientKey := basepath + "/testdata/wrong_client.key"
+	_, err = GetSslClientOptionsMTLS("127.0.0.1", crt, wrongClientCrt, wrongClientKey)
+	require.NoError(t, err)
+}
+
+func TestGetSslClientOptionsMTLS_onMalformedClientCert_returnsError(t *testing.T) {
+	basepath, err := os.Getwd()
+	require.NoError(t, err)
+	crt := basepath + "/testdata/test_ca.crt"
+	grb := basepath + "/testdata/garbage"
+	_, err = GetSslClientOptionsMTLS("127.0.0.1", crt, grb, grb)
+	require.Error(t, err)
+}
+
+func TestGetSslClientOptionsMTLS_onMalformedCA_returnsError(t *testing.T) {
+	basepath, err := os.Getwd()
+	require.NoError(t, err)
+	grb := basepath + "/testdata/garbage"
+	clientCrt := basepath + "/testdata/client.crt"
+	clientKey := basepath + "/testdata/client.key"
+	_, err = GetSslClientOptionsMTLS("127.0.0.1", grb, clientCrt, clientKey)
+	require.Error(t, err)
+}
+
+// dialMTLS builds a real client<->server gRPC handshake using
+// GetSslServerCreds (with clientCA enforcing client certs) and
+// GetSslClientOptionsMTLS, and reports whether the handshake - triggered by
+// the client's first RPC attempt - succeeds. This is what actually proves
+// client-cert verification rejects a cert signed by the wrong CA; the unit
+// tests above only cover option construction.
+func dialMTLS(t *testing.T, clientCACertPath, clientCertPath, clientKeyPath string) error {
+	basepath, err := os.Getwd()
+	require.NoError(t, err)
+	srvCrt := basepath + "/testdata/127.0.0.1.crt"
+	srvKey := basepath + "/testdata/127.0.0.1.key"
+	srvCA := basepath + "/testdata/test_ca.crt"
+
+	opt, err := GetSslServerCreds(srvCrt, srvKey, srvCA)
+	require.NoError(t, err)
+	require.NotNil(t, opt)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	server := grpc.NewServer(opt)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	dialOpt, err := GetSslClientOptionsMTLS("127.0.0.1", clientCACertPath, clientCertPath, clientKeyPath)
+	require.NoError(t, err)
+
+	conn, err := grpc.Dial(lis.Addr().String(), dialOpt, grpc.WithBlock(), grpc.WithTimeout(2*time.Second))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return nil
+}
+
+func TestMTLSHandshake_onNoClientCAConfigured_backwardsCompatible(t *testing.T) {
+	// A server started with caCertPath == "" (GetSslServerCreds' existing
+	// default) doesn't verify client certs at all, same as before this
+	// change - a plain GetSslClientOptions dial should still work.
+	basepath, err := os.Getwd()
+	require.NoError(t, err)
+	srvCrt := basepath + "/testdata/127.0.0.1.crt"
+	srvKey := basepath + "/testdata/127.0.0.1.key"
+
+	opt, err := GetSslServerCreds(srvCrt, srvKey, "")
+	require.NoError(t, err)
+	require.NotNil(t, opt)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	server := grpc.NewServer(opt)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	ca := basepath + "/testdata/test_ca.crt"
+	dialOpt, err := GetSslClientOptions("127.0.0.1", ca)
+	require.NoError(t, err)
+
+	conn, err := grpc.Dial(lis.Addr().String(), dialOpt, grpc.WithBlock(), grpc.WithTimeout(2*time.Second))
+	require.NoError(t, err)
+	defer conn.Close()
+}
+
+func TestMTLSHandshake_onValidClientCert_succeeds(t *testing.T) {
+	basepath, err := os.Getwd()
+	require.NoError(t, err)
+	ca := basepath + "/testdata/test_ca.crt"
+	clientCrt := basepath + "/testdata/client.crt"
+	clientKey := basepath + "/testdata/client.key"
+	require.NoError(t, dialMTLS(t, ca, clientCrt, clientKey))
+}
+
+func TestMTLSHandshake_onWrongCAClientCert_rejects(t *testing.T) {
+	basepath, err := os.Getwd()
+	require.NoError(t, err)
+	ca := basepath + "/testdata/test_ca.crt"
+	wrongClientCrt := basepath + "/testdata/wrong_client.crt"
+	wrongClientKey := basepath + "/testdata/wrong_client.key"
+	require.Error(t, dialMTLS(t, ca, wrongClientCrt, wrongClientKey))
+}
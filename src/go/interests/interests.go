@@ -16,37 +16,252 @@ package interests
 import (
 	"fmt"
 	"regexp"
+	"regexp/syntax"
+	"strings"
 
 	mpb "github.com/google/minions/proto/minions"
 	opb "github.com/google/minions/proto/overlord"
 )
 
+// maxAlternationBranches bounds how many PathRegexps coalesce gets folded
+// into a single alternation - an unbounded alternation would just turn
+// O(N) regex evaluations into one O(N)-sized one, with none of the
+// matching-time benefit.
+const maxAlternationBranches = 32
+
+// RootedInterest pairs an Interest with the filesystem subtree prefix it
+// is scoped to, for policies that only want a minion's interest applied
+// under a given root (see MinifyRooted and IsMatchingInRoot). An empty
+// Root means "unscoped", i.e. the interest applies anywhere.
+type RootedInterest struct {
+	Root     string
+	Interest *mpb.Interest
+}
+
 // Minify returns a slice of Interest that matches the same set of files
 // as the original slice, but possibly using less Interests. For now
 // does simple deduplication between interests.
 func Minify(interests []*mpb.Interest) []*mpb.Interest {
-	uniqueInterests := make(map[string]*mpb.Interest)
+	rooted := make([]*RootedInterest, len(interests))
+	for i, it := range interests {
+		rooted[i] = &RootedInterest{Interest: it}
+	}
+	minified := MinifyRooted(rooted)
+	ret := make([]*mpb.Interest, len(minified))
+	for i, r := range minified {
+		ret[i] = r.Interest
+	}
+	return ret
+}
+
+// MinifyRooted is Minify, but root-aware: two otherwise-identical
+// interests only collapse into one when they're scoped to the same Root,
+// since an interest scoped to one subtree doesn't make an
+// identical-looking interest scoped to a different subtree redundant.
+//
+// Within a Root, minification happens in three passes: (1) exact
+// PathRegexp duplicates collapse into one, promoted to METADATA_AND_DATA
+// if either side needs it; (2) a regex whose language is a subset of
+// another survivor's (e.g. "/etc/.*\\.conf$" versus ".*\\.conf$") is
+// dropped in favor of the broader one, provided the broader one's
+// DataType covers what the narrower one needed; (3) whatever survives is
+// folded into alternations of up to maxAlternationBranches PathRegexps
+// each, so the Overlord matches a file against a handful of compiled
+// regexes instead of one per original Interest.
+func MinifyRooted(interests []*RootedInterest) []*RootedInterest {
+	byRoot := make(map[string][]*RootedInterest)
+	var roots []string
+	for _, i := range interests {
+		if _, ok := byRoot[i.Root]; !ok {
+			roots = append(roots, i.Root)
+		}
+		byRoot[i.Root] = append(byRoot[i.Root], i)
+	}
+
+	var ret []*RootedInterest
+	for _, root := range roots {
+		ret = append(ret, minifyGroup(root, byRoot[root])...)
+	}
+	return ret
+}
 
+// minifyGroup runs the three passes described on MinifyRooted against
+// interests that are all already known to share the same Root.
+func minifyGroup(root string, interests []*RootedInterest) []*RootedInterest {
+	return coalesce(root, dropContained(dedupeExact(interests)))
+}
+
+// dedupeExact collapses interests with byte-identical PathRegexps,
+// promoting the survivor to METADATA_AND_DATA if either duplicate needed
+// it - this is MinifyRooted's original, pre-regex-aware behavior.
+func dedupeExact(interests []*RootedInterest) []*RootedInterest {
+	byRegexp := make(map[string]*RootedInterest)
+	var order []string
 	for _, i := range interests {
-		// First of all, add the unique paths.
-		if _, hasPathRegexp := uniqueInterests[i.PathRegexp]; !hasPathRegexp {
-			uniqueInterests[i.PathRegexp] = i
+		key := i.Interest.PathRegexp
+		existing, ok := byRegexp[key]
+		if !ok {
+			byRegexp[key] = i
+			order = append(order, key)
+			continue
+		}
+		if i.Interest.DataType == mpb.Interest_METADATA_AND_DATA && existing.Interest.DataType != mpb.Interest_METADATA_AND_DATA {
+			byRegexp[key] = i
+		}
+	}
+	ret := make([]*RootedInterest, len(order))
+	for i, key := range order {
+		ret[i] = byRegexp[key]
+	}
+	return ret
+}
+
+// dataTypeCovers reports whether an interest needing only other's DataType
+// would have its needs met by something that actually delivers dominant's
+// DataType - i.e. whether it's safe to drop the narrower interest once the
+// broader regex subsumes it.
+func dataTypeCovers(dominant, other mpb.Interest_DataType) bool {
+	return dominant == other || dominant == mpb.Interest_METADATA_AND_DATA
+}
+
+// dropContained removes any interest whose PathRegexp matches a subset of
+// the files another surviving interest's PathRegexp already matches (see
+// regexLanguageContained), as long as the survivor's DataType covers what
+// the dropped one needed. Interests whose PathRegexp doesn't parse are
+// left untouched, since we can't reason about their language.
+func dropContained(interests []*RootedInterest) []*RootedInterest {
+	parsed := make([]*syntax.Regexp, len(interests))
+	for i, it := range interests {
+		re, err := syntax.Parse(it.Interest.PathRegexp, syntax.Perl)
+		if err != nil {
+			continue
+		}
+		parsed[i] = re.Simplify()
+	}
+
+	dropped := make([]bool, len(interests))
+	for i := range interests {
+		if parsed[i] == nil {
 			continue
 		}
+		for j := range interests {
+			if i == j || parsed[j] == nil || dropped[i] || dropped[j] {
+				continue
+			}
+			if regexLanguageContained(parsed[i], parsed[j]) &&
+				dataTypeCovers(interests[j].Interest.DataType, interests[i].Interest.DataType) {
+				dropped[i] = true
+			}
+		}
+	}
+
+	var ret []*RootedInterest
+	for i, it := range interests {
+		if !dropped[i] {
+			ret = append(ret, it)
+		}
+	}
+	return ret
+}
+
+// regexLanguageContained reports whether every string a matches is also
+// matched by b. General regex language containment is expensive to decide
+// exactly, so this only recognizes the shape Minify's inputs actually take
+// in practice: b is a, with a mandatory literal prefix and/or suffix
+// replaced by an unbounded ".*" - since whatever that ".*" throws away is
+// strictly more permissive than the literal text a required there. It
+// never reports a false positive (at worst it misses an optimization), so
+// it's safe to use as a drop condition.
+func regexLanguageContained(a, b *syntax.Regexp) bool {
+	if a.String() == b.String() {
+		return true
+	}
+	aParts, bParts := concatParts(a), concatParts(b)
+	if n := len(bParts); n >= 1 && isAnyCharStar(bParts[0]) {
+		tail := bParts[1:]
+		if len(aParts) >= len(tail) && sequenceEqual(aParts[len(aParts)-len(tail):], tail) {
+			return true
+		}
+	}
+	if n := len(bParts); n >= 1 && isAnyCharStar(bParts[n-1]) {
+		head := bParts[:n-1]
+		if len(aParts) >= len(head) && sequenceEqual(aParts[:len(head)], head) {
+			return true
+		}
+	}
+	return false
+}
 
-		// Overwrite existing Interest if a new one is METADATA_AND_DATA,
-		// as it requires "more" information than just one or the other.
-		if i.DataType == mpb.Interest_METADATA_AND_DATA {
-			uniqueInterests[i.PathRegexp] = i
+// concatParts returns re's top-level concatenation operands, or re itself
+// as a single-element slice if it isn't a concatenation.
+func concatParts(re *syntax.Regexp) []*syntax.Regexp {
+	if re.Op == syntax.OpConcat {
+		return re.Sub
+	}
+	return []*syntax.Regexp{re}
+}
+
+// isAnyCharStar reports whether re is an unbounded "match anything" -
+// ".*" or ".*?" parsed with syntax.Perl, i.e. a Star of AnyChar/AnyCharNotNL.
+func isAnyCharStar(re *syntax.Regexp) bool {
+	return re.Op == syntax.OpStar && len(re.Sub) == 1 &&
+		(re.Sub[0].Op == syntax.OpAnyChar || re.Sub[0].Op == syntax.OpAnyCharNotNL)
+}
+
+// sequenceEqual compares two slices of parsed subexpressions structurally,
+// by their printed form.
+func sequenceEqual(xs, ys []*syntax.Regexp) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if xs[i].String() != ys[i].String() {
+			return false
 		}
 	}
+	return true
+}
 
-	// Map to interests.
-	var ret []*mpb.Interest
-	for _, v := range uniqueInterests {
-		ret = append(ret, v)
+// coalesce groups interests by DataType and folds each group of more than
+// one survivor into alternations of up to maxAlternationBranches
+// PathRegexps, so a file gets matched against O(len(interests)/32) regexes
+// instead of O(len(interests)).
+func coalesce(root string, interests []*RootedInterest) []*RootedInterest {
+	byType := make(map[mpb.Interest_DataType][]*RootedInterest)
+	var types []mpb.Interest_DataType
+	for _, it := range interests {
+		if _, ok := byType[it.Interest.DataType]; !ok {
+			types = append(types, it.Interest.DataType)
+		}
+		byType[it.Interest.DataType] = append(byType[it.Interest.DataType], it)
 	}
 
+	var ret []*RootedInterest
+	for _, dt := range types {
+		group := byType[dt]
+		for start := 0; start < len(group); start += maxAlternationBranches {
+			end := start + maxAlternationBranches
+			if end > len(group) {
+				end = len(group)
+			}
+			chunk := group[start:end]
+			if len(chunk) == 1 {
+				ret = append(ret, chunk[0])
+				continue
+			}
+			branches := make([]string, len(chunk))
+			for i, it := range chunk {
+				branches[i] = it.Interest.PathRegexp
+			}
+			ret = append(ret, &RootedInterest{
+				Root: root,
+				Interest: &mpb.Interest{
+					PathRegexp: "(?:" + strings.Join(branches, "|") + ")",
+					DataType:   dt,
+				},
+			})
+		}
+	}
 	return ret
 }
 
@@ -65,3 +280,22 @@ func IsMatching(interest *mpb.Interest, file *opb.File) (bool, error) {
 	dataNeededAndThere := (interest.DataType == mpb.Interest_METADATA_AND_DATA && len(file.GetDataChunks()) > 0)
 	return interest.DataType == mpb.Interest_METADATA || dataNeededAndThere, nil
 }
+
+// IsMatchingInRoot is IsMatching, additionally scoped to root: it first
+// requires file's path to fall under root before running interest's
+// regex at all. An empty root matches anywhere, for interests with no
+// PolicyScope applied to them.
+func IsMatchingInRoot(root string, interest *mpb.Interest, file *opb.File) (bool, error) {
+	if root != "" && !underRoot(root, file.GetMetadata().GetPath()) {
+		return false, nil
+	}
+	return IsMatching(interest, file)
+}
+
+// underRoot reports whether p is root itself or falls under it, honoring
+// the path-component boundary - a plain strings.HasPrefix would also
+// match a sibling like "/etcfoo" or "/etcetera/passwd" against root
+// "/etc".
+func underRoot(root, p string) bool {
+	return p == root || strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/")
+}
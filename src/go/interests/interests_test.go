@@ -15,6 +15,8 @@ package interests
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 	"testing"
 
 	mpb "github.com/google/minions/proto/minions"
@@ -37,7 +39,7 @@ func TestMinifyOnSamePathKeepsMetadataAndData(t *testing.T) {
 	require.Equal(t, minified[0], i1)
 }
 
-func TestMinifyOnDifferentPathDoesNotDedupe(t *testing.T) {
+func TestMinifyOnDifferentPathCoalescesIntoOneAlternation(t *testing.T) {
 	i1 := &mpb.Interest{
 		DataType:   mpb.Interest_METADATA_AND_DATA,
 		PathRegexp: "/some/regexp",
@@ -48,10 +50,111 @@ func TestMinifyOnDifferentPathDoesNotDedupe(t *testing.T) {
 	}
 	interests := []*mpb.Interest{i1, i2}
 	minified := Minify(interests)
+	// Neither regex is a subset of the other, so both survive - but since
+	// they share a DataType, coalesce folds them into a single
+	// alternation rather than keeping two separate Interests.
+	require.Len(t, minified, 1)
+	for _, path := range []string{"/some/regexp", "/some/regexp2"} {
+		match, err := regexp.MatchString(minified[0].PathRegexp, path)
+		require.NoError(t, err)
+		require.True(t, match, "coalesced regexp %q should still match %q", minified[0].PathRegexp, path)
+	}
+}
+
+func TestMinifyDropsPathRegexpContainedInAnother(t *testing.T) {
+	broad := &mpb.Interest{
+		DataType:   mpb.Interest_METADATA_AND_DATA,
+		PathRegexp: `.*\.conf$`,
+	}
+	narrow := &mpb.Interest{
+		DataType:   mpb.Interest_METADATA_AND_DATA,
+		PathRegexp: `/etc/.*\.conf$`,
+	}
+	minified := Minify([]*mpb.Interest{broad, narrow})
+	require.Len(t, minified, 1)
+	require.Equal(t, broad, minified[0])
+}
+
+func TestMinifyKeepsNarrowInterestWhenBroaderDataTypeIsWeaker(t *testing.T) {
+	broadMetadataOnly := &mpb.Interest{
+		DataType:   mpb.Interest_METADATA,
+		PathRegexp: `.*\.conf$`,
+	}
+	narrowWithData := &mpb.Interest{
+		DataType:   mpb.Interest_METADATA_AND_DATA,
+		PathRegexp: `/etc/.*\.conf$`,
+	}
+	minified := Minify([]*mpb.Interest{broadMetadataOnly, narrowWithData})
+	// The broad interest only wants metadata, so it doesn't subsume the
+	// narrow interest's need for file data - both must survive.
+	require.Len(t, minified, 2)
+	require.Contains(t, minified, broadMetadataOnly)
+	require.Contains(t, minified, narrowWithData)
+}
+
+func TestMinifyRootedOnSameRootDedupes(t *testing.T) {
+	i1 := &RootedInterest{Root: "/etc", Interest: &mpb.Interest{
+		DataType:   mpb.Interest_METADATA_AND_DATA,
+		PathRegexp: "/some/regexp",
+	}}
+	i2 := &RootedInterest{Root: "/etc", Interest: &mpb.Interest{
+		DataType:   mpb.Interest_METADATA,
+		PathRegexp: "/some/regexp",
+	}}
+	minified := MinifyRooted([]*RootedInterest{i1, i2})
+	require.Len(t, minified, 1)
+	require.Equal(t, minified[0], i1)
+}
+
+func TestMinifyRootedOnDifferentRootDoesNotDedupe(t *testing.T) {
+	i1 := &RootedInterest{Root: "/etc", Interest: &mpb.Interest{
+		DataType:   mpb.Interest_METADATA_AND_DATA,
+		PathRegexp: "/some/regexp",
+	}}
+	i2 := &RootedInterest{Root: "/home", Interest: &mpb.Interest{
+		DataType:   mpb.Interest_METADATA_AND_DATA,
+		PathRegexp: "/some/regexp",
+	}}
+	minified := MinifyRooted([]*RootedInterest{i1, i2})
 	require.Contains(t, minified, i1)
 	require.Contains(t, minified, i2)
 }
 
+func TestIsMatchingInRootRejectsFileOutsideRoot(t *testing.T) {
+	i := &mpb.Interest{
+		DataType:   mpb.Interest_METADATA,
+		PathRegexp: "/foobar",
+	}
+	f := &opb.File{Metadata: &mpb.FileMetadata{Path: "/home/foobar"}}
+	match, err := IsMatchingInRoot("/etc", i, f)
+	require.NoError(t, err)
+	require.False(t, match)
+}
+
+func TestIsMatchingInRootRejectsSiblingPathSharingRootPrefix(t *testing.T) {
+	i := &mpb.Interest{
+		DataType:   mpb.Interest_METADATA,
+		PathRegexp: ".*",
+	}
+	for _, path := range []string{"/etcfoo/passwd", "/etcetera/passwd"} {
+		f := &opb.File{Metadata: &mpb.FileMetadata{Path: path}}
+		match, err := IsMatchingInRoot("/etc", i, f)
+		require.NoError(t, err)
+		require.False(t, match, "path %q shares root \"/etc\"'s string prefix but isn't under it", path)
+	}
+}
+
+func TestIsMatchingInRootAcceptsFileUnderRoot(t *testing.T) {
+	i := &mpb.Interest{
+		DataType:   mpb.Interest_METADATA,
+		PathRegexp: "/etc/foobar",
+	}
+	f := &opb.File{Metadata: &mpb.FileMetadata{Path: "/etc/foobar"}}
+	match, err := IsMatchingInRoot("/etc", i, f)
+	require.NoError(t, err)
+	require.True(t, match)
+}
+
 var isMatchingV = []struct {
 	title string
 	i     *mpb.Interest
@@ -113,7 +216,7 @@ var isMatchingV = []struct {
 			Metadata: &mpb.FileMetadata{
 				Path: "/foobar",
 			},
-			DataChunks: []*opb.DataChunk{&opb.DataChunk{}},
+			DataChunks: []*mpb.DataChunk{{}},
 		},
 		nil,
 		true,
@@ -128,7 +231,7 @@ var isMatchingV = []struct {
 			Metadata: &mpb.FileMetadata{
 				Path: "/foobar",
 			},
-			DataChunks: []*opb.DataChunk{&opb.DataChunk{}},
+			DataChunks: []*mpb.DataChunk{{}},
 		},
 		nil,
 		false,
@@ -167,3 +270,60 @@ func TestIsMatching(t *testing.T) {
 		})
 	}
 }
+
+// realisticInterests builds n Interests approximating a mix of goblins
+// asking about real files: package manifests under many different
+// package-root directories (a narrow PathRegexp each, but all contained
+// in one broad extension-based regex), plus a handful of genuinely
+// unrelated, non-overlapping interests (config files, binaries) that
+// can't be minified away. This is the shape dropContained/coalesce are
+// meant to exploit.
+func realisticInterests(n int) []*mpb.Interest {
+	interests := make([]*mpb.Interest, 0, n)
+	for i := 0; i < n; i++ {
+		switch i % 4 {
+		case 0:
+			interests = append(interests, &mpb.Interest{
+				DataType:   mpb.Interest_METADATA_AND_DATA,
+				PathRegexp: fmt.Sprintf(`/var/lib/pkg%d/.*\.json$`, i),
+			})
+		case 1:
+			interests = append(interests, &mpb.Interest{
+				DataType:   mpb.Interest_METADATA_AND_DATA,
+				PathRegexp: `.*\.json$`,
+			})
+		case 2:
+			interests = append(interests, &mpb.Interest{
+				DataType:   mpb.Interest_METADATA,
+				PathRegexp: fmt.Sprintf(`/etc/service%d\.conf$`, i),
+			})
+		default:
+			interests = append(interests, &mpb.Interest{
+				DataType:   mpb.Interest_METADATA_AND_DATA,
+				PathRegexp: fmt.Sprintf(`/usr/bin/tool%d$`, i),
+			})
+		}
+	}
+	return interests
+}
+
+func TestMinifyOnRealisticInputsReducesCountSubstantially(t *testing.T) {
+	interests := realisticInterests(400)
+	minified := Minify(interests)
+	// Every case-0 Interest is contained in the case-1 ".*\.json$"
+	// broadcast interest and gets dropped; the rest coalesce into
+	// alternations of maxAlternationBranches each.
+	require.Less(t, len(minified), len(interests)/4)
+}
+
+func BenchmarkMinify(b *testing.B) {
+	for _, n := range []int{50, 200, 800} {
+		interests := realisticInterests(n)
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				Minify(interests)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package license
+
+import (
+	"regexp"
+	"strings"
+)
+
+// shingleSize is the number of consecutive normalized words grouped into a
+// single shingle. A license text reworded here and there (extra blank
+// lines, a changed copyright year, a trailing comment marker) still shares
+// most of its word 4-grams with the original, while two genuinely
+// different licenses rarely do.
+const shingleSize = 4
+
+// commentMarkers are stripped during normalization so that a license text
+// embedded as a source file comment block classifies the same as its
+// plain-text original.
+var commentMarkers = strings.NewReplacer("/*", " ", "*/", " ", "//", " ", "#", " ")
+
+// nonWord matches anything that isn't a letter, digit or whitespace, so
+// normalize can drop punctuation without disturbing word boundaries.
+var nonWord = regexp.MustCompile(`[^a-z0-9\s]+`)
+
+// whitespace matches any run of whitespace, collapsed to a single space by
+// normalize.
+var whitespace = regexp.MustCompile(`\s+`)
+
+// normalize lowercases text, strips comment markers and punctuation, and
+// collapses whitespace, so that formatting differences between a
+// reference license text and the copy found in a scanned file don't
+// affect the shingle comparison in Classify.
+func normalize(text string) string {
+	text = strings.ToLower(text)
+	text = commentMarkers.Replace(text)
+	text = nonWord.ReplaceAllString(text, " ")
+	text = whitespace.ReplaceAllString(text, " ")
+	return strings.TrimSpace(text)
+}
+
+// shingles splits normalized into words and returns the set of its
+// k-word shingles, e.g. shingles("a b c d e", 4) contains "a b c d" and
+// "b c d e".
+func shingles(normalized string, k int) map[string]bool {
+	words := strings.Fields(normalized)
+	if len(words) < k {
+		return map[string]bool{strings.Join(words, " "): true}
+	}
+	set := make(map[string]bool, len(words)-k+1)
+	for i := 0; i+k <= len(words); i++ {
+		set[strings.Join(words[i:i+k], " ")] = true
+	}
+	return set
+}
+
+// jaccard returns the Jaccard similarity of a and b: the size of their
+// intersection over the size of their union, 0 if both are empty.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	intersection := 0
+	for s := range a {
+		if b[s] {
+			intersection++
+		}
+	}
+	union := len(a) + len(b) - intersection
+	return float64(intersection) / float64(union)
+}
+
+// corpusShingles holds the precomputed shingle set for every corpus entry,
+// so Classify only has to shingle the candidate text, not the whole
+// corpus, on every call.
+var corpusShingles = buildCorpusShingles()
+
+func buildCorpusShingles() map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(corpus))
+	for id, text := range corpus {
+		sets[id] = shingles(normalize(text), shingleSize)
+	}
+	return sets
+}
+
+// Classify compares text's normalized shingle set against every license in
+// the bundled corpus and returns the best-matching SPDX identifier along
+// with a confidence percentage (0-100) equal to its Jaccard similarity. An
+// empty corpus, or text with nothing left after normalization, classifies
+// as ("", 0).
+func Classify(text string) (spdxID string, confidence float64) {
+	candidate := shingles(normalize(text), shingleSize)
+	var bestScore float64
+	var bestID string
+	for id, reference := range corpusShingles {
+		score := jaccard(candidate, reference)
+		if score > bestScore {
+			bestScore = score
+			bestID = id
+		}
+	}
+	return bestID, bestScore * 100
+}
@@ -0,0 +1,129 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package license
+
+import (
+	"testing"
+
+	pb "github.com/google/minions/proto/minions"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"mit", corpus["MIT"], "MIT"},
+		{"apache2", corpus["Apache-2.0"], "Apache-2.0"},
+		{"gpl3", corpus["GPL-3.0-only"], "GPL-3.0-only"},
+		{"mit as a comment block", "// MIT License\n//\n// " + corpus["MIT"], "MIT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, confidence := Classify(tt.text)
+			if id != tt.want {
+				t.Errorf("Classify(%s) id = %q, want %q", tt.name, id, tt.want)
+			}
+			if confidence < 90 {
+				t.Errorf("Classify(%s) confidence = %.0f, want >= 90", tt.name, confidence)
+			}
+		})
+	}
+}
+
+func TestClassify_unrelatedText(t *testing.T) {
+	_, confidence := Classify("this changelog documents notable changes to this project for each release")
+	if confidence > 20 {
+		t.Errorf("Classify(unrelated text) confidence = %.0f, want a low score", confidence)
+	}
+}
+
+func TestPoliciesPolicyFor(t *testing.T) {
+	policies := Policies{
+		{Root: "", MinConfidence: 70, DenyList: map[string]bool{"GPL-2.0-only": true}},
+		{Root: "/vendor/gpl", MinConfidence: 0, DenyList: map[string]bool{}},
+	}
+
+	if got := policies.PolicyFor("/src/main.go").Root; got != "" {
+		t.Errorf("PolicyFor(/src/main.go).Root = %q, want the default policy", got)
+	}
+	if got := policies.PolicyFor("/vendor/gpl/LICENSE").Root; got != "/vendor/gpl" {
+		t.Errorf("PolicyFor(/vendor/gpl/LICENSE).Root = %q, want /vendor/gpl", got)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("/vendor/gpl=0:GPL-2.0-only,GPL-3.0-only")
+	if err != nil {
+		t.Fatalf("ParsePolicy: unexpected error %v", err)
+	}
+	if policy.Root != "/vendor/gpl" || policy.MinConfidence != 0 {
+		t.Errorf("ParsePolicy = %+v, want Root /vendor/gpl, MinConfidence 0", policy)
+	}
+	if !policy.DenyList["GPL-2.0-only"] || !policy.DenyList["GPL-3.0-only"] {
+		t.Errorf("ParsePolicy deny-list = %v, want GPL-2.0-only and GPL-3.0-only", policy.DenyList)
+	}
+}
+
+func TestParsePolicy_malformed(t *testing.T) {
+	for _, spec := range []string{"no-equals-sign", "/root=no-colon"} {
+		if _, err := ParsePolicy(spec); err == nil {
+			t.Errorf("ParsePolicy(%q): expected an error, got nil", spec)
+		}
+	}
+}
+
+func TestAnalyzeFiles(t *testing.T) {
+	policies := Policies{{Root: "", MinConfidence: 70, DenyList: map[string]bool{"GPL-3.0-only": true}}}
+	m := NewMinion(policies, 1<<20)
+
+	req := &pb.AnalyzeFilesRequest{
+		ScanId: "A_SCAN_ID",
+		Files: []*pb.File{
+			{
+				Metadata: &pb.FileMetadata{Path: "/src/LICENSE", Size: int64(len(corpus["GPL-3.0-only"]))},
+				Data:     []byte(corpus["GPL-3.0-only"]),
+			},
+			{
+				Metadata: &pb.FileMetadata{Path: "/src/main.go", Size: 64},
+				Data:     []byte("// SPDX-License-Identifier: MIT\npackage main\n"),
+			},
+		},
+	}
+
+	resp, err := m.AnalyzeFiles(nil, req)
+	if err != nil {
+		t.Fatalf("AnalyzeFiles: unexpected error %v", err)
+	}
+
+	var gotDenied, gotConflict bool
+	for _, f := range resp.Findings {
+		switch f.Advisory {
+		case DeniedLicense:
+			gotDenied = true
+		case SPDXTagConflict:
+			gotConflict = true
+		}
+		if f.Source.GetMinion() != "license" || f.Source.GetScanId() != "A_SCAN_ID" {
+			t.Errorf("finding Source = %+v, want Minion license, ScanId A_SCAN_ID", f.Source)
+		}
+	}
+	if !gotDenied {
+		t.Errorf("AnalyzeFiles: expected a DeniedLicense finding for the GPL-3.0-only LICENSE")
+	}
+	if !gotConflict {
+		t.Errorf("AnalyzeFiles: expected a SPDXTagConflict finding for main.go's MIT tag")
+	}
+}
@@ -0,0 +1,181 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+/*
+Package license contains a minion that classifies LICENSE/COPYING/NOTICE
+files against a bundled corpus of SPDX license texts (see corpus.go and
+Classify in classify.go) and flags:
+
+  - a top-level license file whose detected SPDX identifier falls below a
+    confidence threshold ("unknown license");
+  - a top-level license file on a configurable deny-list (e.g. the GPL
+    family for a proprietary build); and
+  - a source file whose SPDX-License-Identifier tag conflicts with its
+    directory's top-level LICENSE.
+
+The confidence threshold and deny-list are configurable per subtree via
+Policy - see policy.go.
+*/
+package license
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/minions/go/minions"
+	pb "github.com/google/minions/proto/minions"
+	"golang.org/x/net/context"
+)
+
+// Advisories raised by the license Minion.
+var (
+	UnknownLicense = &pb.Advisory{
+		Reference:      "license_unknown",
+		Description:    "A LICENSE, COPYING or NOTICE file didn't match any license in the bundled SPDX corpus with enough confidence to identify it.",
+		Recommendation: "Confirm the license by hand, and consider adding its text to the bundled corpus so future scans recognize it.",
+	}
+	DeniedLicense = &pb.Advisory{
+		Reference:      "license_denied",
+		Description:    "A file is licensed under an SPDX identifier this policy's deny-list forbids.",
+		Recommendation: "Replace the dependency, or obtain a license exception, before shipping.",
+	}
+	SPDXTagConflict = &pb.Advisory{
+		Reference:      "license_spdx_tag_conflict",
+		Description:    "A source file's SPDX-License-Identifier tag doesn't match the license detected in its directory's top-level LICENSE file.",
+		Recommendation: "Resolve the discrepancy: either the file is genuinely under a different license than its package, or one of the two is stale.",
+	}
+)
+
+// licenseFileRegexp matches a top-level license file by name, regardless
+// of the directory it lives in or an extension like .md or .txt.
+var licenseFileRegexp = regexp.MustCompile(`(?i)^(.*/)?(LICENSE|COPYING|NOTICE)(\..*)?$`)
+
+// sourceFileRegexp matches the source file extensions this minion scans
+// for a conflicting SPDX-License-Identifier tag.
+var sourceFileRegexp = regexp.MustCompile(`(?i)\.(go|c|h|cc|cpp|hpp|java|py|rb|js|ts|sh)$`)
+
+// spdxTagRegexp finds an SPDX-License-Identifier tag, as commonly placed
+// in a source file's header comment.
+var spdxTagRegexp = regexp.MustCompile(`SPDX-License-Identifier:\s*(\S+)`)
+
+// Minion classifies license files against the bundled SPDX corpus and
+// flags source files whose SPDX tag disagrees with them. License and
+// source files are small, so there's no need for the streaming
+// AnalyzeFilesStream RPC here.
+type Minion struct {
+	minions.UnimplementedStreamingMinion
+	policies          Policies
+	maxSourceFileSize int64 // Source files larger than this are fetched (METADATA_AND_DATA) but not scanned for an SPDX tag.
+}
+
+// NewMinion creates a license Minion governed by policies (see
+// Policies.PolicyFor) that only scans source files up to
+// maxSourceFileSize bytes for a conflicting SPDX tag.
+func NewMinion(policies Policies, maxSourceFileSize int64) *Minion {
+	return &Minion{policies: policies, maxSourceFileSize: maxSourceFileSize}
+}
+
+// ListInitialInterests returns the top-level license files and source
+// files this minion classifies.
+func (m Minion) ListInitialInterests(ctx context.Context, req *pb.ListInitialInterestsRequest) (*pb.ListInitialInterestsResponse, error) {
+	return &pb.ListInitialInterestsResponse{
+		Interests: []*pb.Interest{
+			{DataType: pb.Interest_METADATA_AND_DATA, PathRegexp: licenseFileRegexp.String()},
+			{DataType: pb.Interest_METADATA_AND_DATA, PathRegexp: sourceFileRegexp.String()},
+		},
+	}, nil
+}
+
+// AnalyzeFiles classifies every license file in req against the bundled
+// SPDX corpus, then checks every source file's SPDX tag, if any, against
+// its directory's classified license.
+func (m Minion) AnalyzeFiles(ctx context.Context, req *pb.AnalyzeFilesRequest) (*pb.AnalyzeFilesResponse, error) {
+	var findings []*pb.Finding
+	dirLicense := make(map[string]string) // Directory -> SPDX id of its confidently-classified top-level license file.
+
+	for _, f := range req.GetFiles() {
+		p := f.GetMetadata().GetPath()
+		if !licenseFileRegexp.MatchString(p) {
+			continue
+		}
+		policy := m.policies.PolicyFor(p)
+		spdxID, confidence := Classify(string(f.GetData()))
+
+		if confidence < policy.MinConfidence {
+			findings = append(findings, &pb.Finding{
+				Advisory: UnknownLicense,
+				VulnerableResources: []*pb.Resource{{
+					Path:           p,
+					AdditionalInfo: fmt.Sprintf("best match %s at %.0f%% confidence, below the %.0f%% threshold", spdxID, confidence, policy.MinConfidence),
+				}},
+				Accuracy: pb.Finding_ACCURACY_AVERAGE,
+				Severity: pb.Finding_SEVERITY_MEDIUM,
+			})
+			continue
+		}
+
+		dirLicense[path.Dir(p)] = spdxID
+		if policy.DenyList[spdxID] {
+			findings = append(findings, &pb.Finding{
+				Advisory: DeniedLicense,
+				VulnerableResources: []*pb.Resource{{
+					Path:           p,
+					AdditionalInfo: fmt.Sprintf("detected as %s (%.0f%% confidence)", spdxID, confidence),
+				}},
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_HIGH,
+			})
+		}
+	}
+
+	for _, f := range req.GetFiles() {
+		p := f.GetMetadata().GetPath()
+		if !sourceFileRegexp.MatchString(p) {
+			continue
+		}
+		if f.GetMetadata().GetSize() > m.maxSourceFileSize {
+			continue
+		}
+		match := spdxTagRegexp.FindSubmatch(f.GetData())
+		if match == nil {
+			continue
+		}
+		tag := string(match[1])
+		dirID, ok := dirLicense[path.Dir(p)]
+		if !ok || tag == dirID {
+			continue
+		}
+		findings = append(findings, &pb.Finding{
+			Advisory: SPDXTagConflict,
+			VulnerableResources: []*pb.Resource{{
+				Path:           p,
+				AdditionalInfo: fmt.Sprintf("file tagged %s, directory licensed %s", tag, dirID),
+			}},
+			Accuracy: pb.Finding_ACCURACY_GREAT,
+			Severity: pb.Finding_SEVERITY_MEDIUM,
+		})
+	}
+
+	ts := ptypes.TimestampNow()
+	for _, f := range findings {
+		f.Source = &pb.Source{
+			ScanId:        req.GetScanId(),
+			Minion:        "license",
+			DetectionTime: ts,
+		}
+	}
+
+	return &pb.AnalyzeFilesResponse{Findings: findings}, nil
+}
@@ -0,0 +1,84 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package license
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Policy describes the license rules that apply to every file under Root.
+type Policy struct {
+	Root string // Path prefix this Policy applies to; "" matches every path, so it doubles as the default Policy.
+	// MinConfidence is the Classify confidence (0-100) below which a
+	// detected license is reported as unknown rather than trusted.
+	MinConfidence float64
+	// DenyList holds the SPDX identifiers forbidden under Root, e.g. the
+	// GPL family for a proprietary build.
+	DenyList map[string]bool
+}
+
+// Policies is an ordered set of per-root Policy rules, configured via
+// repeated -policy flags - see ParsePolicy and the license runner.
+type Policies []Policy
+
+// PolicyFor returns the Policy in p whose Root is the longest prefix of
+// path, which is how a subtree gets held to stricter or looser rules than
+// its parent. If no Policy's Root is a prefix of path, it returns the
+// zero Policy - no confidence threshold and an empty deny-list, i.e. no
+// findings are raised for that path.
+func (p Policies) PolicyFor(path string) Policy {
+	var best Policy
+	bestLen := -1
+	for _, policy := range p {
+		if strings.HasPrefix(path, policy.Root) && len(policy.Root) > bestLen {
+			best = policy
+			bestLen = len(policy.Root)
+		}
+	}
+	return best
+}
+
+// ParsePolicy parses a single -policy flag value of the form
+// "root=minConfidence:deniedID1,deniedID2,...", e.g.
+// "/vendor/gpl=0:GPL-2.0-only,GPL-3.0-only" or the default policy
+// "=70:GPL-2.0-only,GPL-3.0-only" (an empty root matches every path).
+func ParsePolicy(spec string) (Policy, error) {
+	eq := strings.IndexByte(spec, '=')
+	if eq < 0 {
+		return Policy{}, fmt.Errorf("license: policy %q: missing '=' separating root from its rule", spec)
+	}
+	root, rule := spec[:eq], spec[eq+1:]
+
+	colon := strings.IndexByte(rule, ':')
+	if colon < 0 {
+		return Policy{}, fmt.Errorf("license: policy %q: missing ':' separating minimum confidence from its deny-list", spec)
+	}
+	confidenceStr, denyStr := rule[:colon], rule[colon+1:]
+
+	minConfidence, err := strconv.ParseFloat(confidenceStr, 64)
+	if err != nil {
+		return Policy{}, fmt.Errorf("license: policy %q: invalid minimum confidence %q: %v", spec, confidenceStr, err)
+	}
+
+	denyList := make(map[string]bool)
+	if denyStr != "" {
+		for _, id := range strings.Split(denyStr, ",") {
+			denyList[id] = true
+		}
+	}
+
+	return Policy{Root: root, MinConfidence: minConfidence, DenyList: denyList}, nil
+}
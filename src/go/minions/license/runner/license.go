@@ -0,0 +1,63 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/minions/go/minions"
+	"github.com/google/minions/go/minions/license"
+)
+
+// policyFlags accumulates repeated -policy flags into a license.Policies,
+// mirroring overlord_runner.go's flagStrings for repeated flag values.
+type policyFlags license.Policies
+
+func (p *policyFlags) String() string {
+	specs := make([]string, len(*p))
+	for i, policy := range *p {
+		specs[i] = policy.Root
+	}
+	return strings.Join(specs, ",")
+}
+
+func (p *policyFlags) Set(value string) error {
+	policy, err := license.ParsePolicy(value)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, policy)
+	return nil
+}
+
+var (
+	policies          policyFlags
+	maxSourceFileSize = flag.Int64("max_source_file_size", 1<<20, "Source files larger than this many bytes are not scanned for a conflicting SPDX tag")
+)
+
+func main() {
+	flag.Var(&policies, "policy", `Per-root license policy, repeatable: "root=minConfidence:deniedID1,deniedID2,...". `+
+		`An empty root ("=70:GPL-2.0-only") is the default policy applied to any path no more specific -policy matches.`)
+	flag.Parse()
+
+	if len(policies) == 0 {
+		log.Fatal("at least one -policy is required, e.g. -policy=\"=70:\" to flag only unidentified licenses")
+	}
+
+	fmt.Printf("Starting up license minion with %d policies.\n", len(policies))
+	minions.StartMinion(license.NewMinion(license.Policies(policies), *maxSourceFileSize), "License - SPDX compliance checker")
+}
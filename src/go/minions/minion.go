@@ -23,11 +23,14 @@ package minions
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	pb "github.com/google/minions/proto/minions"
 	"github.com/patrickmn/go-cache"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Minion is a interface that should be implemented by every Minion.
@@ -38,6 +41,33 @@ type Minion interface {
 	AnalyzeFiles(ctx context.Context, req *pb.AnalyzeFilesRequest) (*pb.AnalyzeFilesResponse, error)
 }
 
+// StreamingMinion can be implemented alongside Minion by minions that would
+// rather consume files as a sequence of FileChunks than as one in-memory
+// AnalyzeFilesRequest. This matters for minions that read multi-megabyte
+// files (package databases, binaries): buffering the whole payload risks
+// blowing past gRPC's default message size limit and wastes memory.
+//
+// A minion advertises support for this by setting SupportsStreaming on its
+// ListInitialInterestsResponse; the Overlord uses AnalyzeFilesStream instead
+// of AnalyzeFiles for any minion that does.
+type StreamingMinion interface {
+	// AnalyzeFilesStream mirrors AnalyzeFiles, but reads its files as a
+	// stream of FileChunks and may send AnalyzeFilesResponse messages back
+	// as findings become available, rather than only once at the end.
+	AnalyzeFilesStream(stream pb.Minions_AnalyzeFilesStreamServer) error
+}
+
+// UnimplementedStreamingMinion can be embedded by a Minion that has no use
+// for streaming (e.g. because it only ever looks at small files), so that it
+// still satisfies the MinionServer interface generated for the service as a
+// whole.
+type UnimplementedStreamingMinion struct{}
+
+// AnalyzeFilesStream always fails: the embedding Minion doesn't support it.
+func (UnimplementedStreamingMinion) AnalyzeFilesStream(stream pb.Minions_AnalyzeFilesStreamServer) error {
+	return status.Error(codes.Unimplemented, "this minion does not implement AnalyzeFilesStream")
+}
+
 // StateManager handles state keeping for a minion, allowing it to save
 // whatever needs saving. It might or might not work across horizontally
 // scaled minions of the same type: check implementors.
@@ -49,11 +79,24 @@ type StateManager interface {
 	Get(scanID string) (interface{}, error)
 	// Has returns true if there is any set state for the given scan.
 	Has(scanID string) bool
+	// Update atomically reads the current state for scanID (nil if none has
+	// been set yet), passes it to fn, and stores whatever fn returns. If fn
+	// returns an error, the state is left untouched and Update returns that
+	// error. Implementations must serialize Updates for a given scanID, so
+	// that two concurrent callers read-modify-writing the same state (e.g.
+	// appending to a packages slice) can't lose one another's update the way
+	// a bare Get followed by Set could.
+	Update(scanID string, fn func(state interface{}) (interface{}, error)) error
+	// Delete forgets scanID's state, so a minion that already knows a scan
+	// has finished can free it immediately instead of waiting on whatever
+	// TTL/eviction policy the backend would otherwise apply.
+	Delete(scanID string) error
 }
 
 // LocalStateManager uses a local cache to manage a minion's state.
 type LocalStateManager struct {
 	lc *cache.Cache
+	mu sync.Mutex
 }
 
 // NewLocalStateManager creates a StateManager backed by a local cache.
@@ -82,3 +125,25 @@ func (l *LocalStateManager) Has(scanID string) bool {
 	_, found := l.lc.Get(scanID)
 	return found
 }
+
+// Update implements StateManager. The underlying cache has no atomic
+// read-modify-write of its own, so this takes out l.mu for the duration of
+// the call; that's cheap enough here since, unlike Bolt or Redis, there's no
+// I/O involved.
+func (l *LocalStateManager) Update(scanID string, fn func(state interface{}) (interface{}, error)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	current, _ := l.lc.Get(scanID)
+	next, err := fn(current)
+	if err != nil {
+		return err
+	}
+	l.lc.SetDefault(scanID, next)
+	return nil
+}
+
+// Delete forgets scanID's state.
+func (l *LocalStateManager) Delete(scanID string) error {
+	l.lc.Delete(scanID)
+	return nil
+}
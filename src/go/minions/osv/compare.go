@@ -0,0 +1,202 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package osv
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CompareVersions orders two version strings the way ecosystem does (see
+// comparatorFor), for callers outside this package that need the same
+// ecosystem-aware ordering without going through a Client - vulndb, for
+// instance, matches against locally-stored ranges rather than Entries.
+func CompareVersions(ecosystem, a, b string) int {
+	return comparatorFor(ecosystem)(a, b)
+}
+
+// comparatorFor returns the version-ordering function appropriate for
+// ecosystem. Ecosystems not listed explicitly (Debian, Alpine, Ubuntu,
+// RedHat and friends) fall back to distroVersionCompare, the
+// dpkg/rpm-style comparison distro package managers use.
+func comparatorFor(ecosystem string) func(a, b string) int {
+	switch ecosystem {
+	case "Go", "npm", "crates.io", "Maven":
+		return semverCompare
+	case "PyPI":
+		return pep440Compare
+	default:
+		return distroVersionCompare
+	}
+}
+
+// semverCompare compares two SemVer-ish version strings (a leading "v" is
+// tolerated, as Go module versions always have one). It's deliberately
+// lenient: a malformed segment sorts as 0 rather than erroring, since
+// callers only need a consistent ordering, not strict validation of
+// versions we didn't generate ourselves.
+func semverCompare(a, b string) int {
+	numA, preA := splitSemver(a)
+	numB, preB := splitSemver(b)
+	for i := 0; i < 3; i++ {
+		if d := numA[i] - numB[i]; d != 0 {
+			if d < 0 {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case preA == "" && preB == "":
+		return 0
+	case preA == "":
+		return 1 // No prerelease sorts after any prerelease, per SemVer.
+	case preB == "":
+		return -1
+	default:
+		return strings.Compare(preA, preB)
+	}
+}
+
+func splitSemver(v string) ([3]int, string) {
+	v = strings.TrimPrefix(v, "v")
+	core, pre := v, ""
+	if i := strings.IndexAny(v, "-+"); i != -1 {
+		core, pre = v[:i], v[i+1:]
+	}
+	parts := strings.SplitN(core, ".", 3)
+	var nums [3]int
+	for i := 0; i < len(parts) && i < 3; i++ {
+		nums[i], _ = strconv.Atoi(parts[i])
+	}
+	return nums, pre
+}
+
+// pep440ReleaseRe matches the dotted numeric release segment at the start
+// of a PEP 440 version, e.g. the "1.2.3" in "1.2.3rc1".
+var pep440ReleaseRe = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*`)
+
+// pep440Compare compares two PEP 440 (PyPI) version strings. It orders the
+// dotted release segments numerically, then treats a bare release as newer
+// than any pre/post/dev-qualified one and falls back to a lexical compare
+// between qualifiers - usually, but not always per spec, the right answer.
+func pep440Compare(a, b string) int {
+	relA, qualA := splitPep440(a)
+	relB, qualB := splitPep440(b)
+	if d := compareNumericSegments(relA, relB); d != 0 {
+		return d
+	}
+	switch {
+	case qualA == "" && qualB == "":
+		return 0
+	case qualA == "":
+		return 1
+	case qualB == "":
+		return -1
+	default:
+		return strings.Compare(qualA, qualB)
+	}
+}
+
+func splitPep440(v string) ([]int, string) {
+	rel := pep440ReleaseRe.FindString(v)
+	qualifier := strings.TrimPrefix(v, rel)
+	return parseNumericSegments(rel), qualifier
+}
+
+func parseNumericSegments(v string) []int {
+	if v == "" {
+		return nil
+	}
+	parts := strings.Split(v, ".")
+	nums := make([]int, len(parts))
+	for i, p := range parts {
+		nums[i], _ = strconv.Atoi(p)
+	}
+	return nums
+}
+
+func compareNumericSegments(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var na, nb int
+		if i < len(a) {
+			na = a[i]
+		}
+		if i < len(b) {
+			nb = b[i]
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// distroVersionCompare implements dpkg/rpm-style version comparison:
+// strings are split into alternating runs of digits and non-digits, and
+// the runs are compared left to right - numeric runs numerically,
+// everything else lexically. This is what both `dpkg --compare-versions`
+// and rpm's vercmp boil down to; they mainly diverge on how they treat
+// '~', which isn't special-cased here.
+func distroVersionCompare(a, b string) int {
+	runsA, runsB := splitVersionRuns(a), splitVersionRuns(b)
+	for i := 0; i < len(runsA) || i < len(runsB); i++ {
+		var ra, rb string
+		if i < len(runsA) {
+			ra = runsA[i]
+		}
+		if i < len(runsB) {
+			rb = runsB[i]
+		}
+		if ra == rb {
+			continue
+		}
+		na, errA := strconv.Atoi(ra)
+		nb, errB := strconv.Atoi(rb)
+		if errA == nil && errB == nil {
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+		return strings.Compare(ra, rb)
+	}
+	return 0
+}
+
+func splitVersionRuns(v string) []string {
+	var runs []string
+	var cur strings.Builder
+	var curIsDigit bool
+	for i, r := range v {
+		isDigit := r >= '0' && r <= '9'
+		if i > 0 && isDigit != curIsDigit {
+			runs = append(runs, cur.String())
+			cur.Reset()
+		}
+		cur.WriteRune(r)
+		curIsDigit = isDigit
+	}
+	if cur.Len() > 0 {
+		runs = append(runs, cur.String())
+	}
+	return runs
+}
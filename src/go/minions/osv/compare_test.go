@@ -0,0 +1,88 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package osv
+
+import (
+	"context"
+	"testing"
+)
+
+var compareTests = []struct {
+	Name       string
+	Comparator func(a, b string) int
+	A, B       string
+	Want       int
+}{
+	{"semver equal", semverCompare, "v1.2.3", "1.2.3", 0},
+	{"semver less", semverCompare, "1.2.3", "1.10.0", -1},
+	{"semver prerelease sorts before release", semverCompare, "1.2.3-beta", "1.2.3", -1},
+	{"pep440 equal", pep440Compare, "1.2.3", "1.2.3", 0},
+	{"pep440 less", pep440Compare, "1.2", "1.10", -1},
+	{"pep440 rc sorts before release", pep440Compare, "1.0rc1", "1.0", -1},
+	{"distro equal", distroVersionCompare, "1.2.3-3.el8", "1.2.3-3.el8", 0},
+	{"distro less", distroVersionCompare, "1.2.3-3.el8", "1.2.3-4.el8", -1},
+	{"distro numeric run beats lexical", distroVersionCompare, "1.9", "1.10", -1},
+}
+
+func TestComparators(t *testing.T) {
+	for _, tt := range compareTests {
+		if got := tt.Comparator(tt.A, tt.B); sign(got) != sign(tt.Want) {
+			t.Errorf("%s: Comparator(%q, %q) = %d, want sign %d", tt.Name, tt.A, tt.B, got, tt.Want)
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func TestClientByPackage(t *testing.T) {
+	entry := &Entry{
+		ID: "GO-2023-0001",
+		Affected: []Affected{{
+			Package: Package{Ecosystem: "Go", Name: "example.com/vuln"},
+			Ranges: []Range{{
+				Type: "SEMVER",
+				Events: []Event{
+					{Introduced: "0"},
+					{Fixed: "1.2.0"},
+				},
+			}},
+		}},
+	}
+	c := NewClient(NewMemorySource([]*Entry{entry}))
+
+	affected, err := c.ByModule(context.Background(), "example.com/vuln", "1.1.0")
+	if err != nil {
+		t.Fatalf("ByModule: %v", err)
+	}
+	if len(affected) != 1 || affected[0].ID != "GO-2023-0001" {
+		t.Errorf("ByModule(1.1.0) = %v, want [GO-2023-0001]", affected)
+	}
+
+	fixed, err := c.ByModule(context.Background(), "example.com/vuln", "1.2.0")
+	if err != nil {
+		t.Fatalf("ByModule: %v", err)
+	}
+	if len(fixed) != 0 {
+		t.Errorf("ByModule(1.2.0) = %v, want none (version is fixed)", fixed)
+	}
+}
@@ -0,0 +1,101 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package osv
+
+import (
+	"math"
+	"strings"
+)
+
+// The cvssV3* maps below map the values of the CVSS v3.0/v3.1 base metrics
+// AV, AC, UI and C/I/A (and, further down, PR) to the numeric weight the
+// specification assigns each one; Scope (S) only selects between formulas.
+var cvssV3AttackVector = map[string]float64{"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2}
+var cvssV3AttackComplexity = map[string]float64{"L": 0.77, "H": 0.44}
+var cvssV3UserInteraction = map[string]float64{"N": 0.85, "R": 0.62}
+var cvssV3Impact = map[string]float64{"H": 0.56, "L": 0.22, "N": 0}
+
+// cvssV3PrivilegesRequired holds two weights per value because, per spec,
+// Privileges Required is scored differently depending on Scope.
+var cvssV3PrivilegesRequired = map[string][2]float64{
+	"N": {0.85, 0.85},
+	"L": {0.62, 0.68},
+	"H": {0.27, 0.5},
+}
+
+// CVSSv3BaseScore computes the base score (0.0-10.0) of a CVSS v3.0 or
+// v3.1 vector string, e.g. "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+// per the official FIRST CVSS v3.1 specification's base score formula
+// (temporal and environmental metrics, if present, are ignored). Returns
+// false if vector isn't a CVSS v3 vector this can parse.
+func CVSSv3BaseScore(vector string) (float32, bool) {
+	metrics := make(map[string]string)
+	for _, part := range strings.Split(vector, "/") {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) == 2 {
+			metrics[kv[0]] = kv[1]
+		}
+	}
+	if !strings.HasPrefix(metrics["CVSS"], "3.") {
+		return 0, false
+	}
+
+	av, ok1 := cvssV3AttackVector[metrics["AV"]]
+	ac, ok2 := cvssV3AttackComplexity[metrics["AC"]]
+	ui, ok3 := cvssV3UserInteraction[metrics["UI"]]
+	c, ok4 := cvssV3Impact[metrics["C"]]
+	i, ok5 := cvssV3Impact[metrics["I"]]
+	a, ok6 := cvssV3Impact[metrics["A"]]
+	pr, ok7 := cvssV3PrivilegesRequired[metrics["PR"]]
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !ok6 || !ok7 {
+		return 0, false
+	}
+	changedScope := metrics["S"] == "C"
+
+	iscBase := 1 - (1-c)*(1-i)*(1-a)
+	var impact float64
+	if changedScope {
+		impact = 7.52*(iscBase-0.029) - 3.25*math.Pow(iscBase-0.02, 15)
+	} else {
+		impact = 6.42 * iscBase
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+
+	privilegesRequired := pr[0]
+	if changedScope {
+		privilegesRequired = pr[1]
+	}
+	exploitability := 8.22 * av * ac * privilegesRequired * ui
+
+	var base float64
+	if changedScope {
+		base = cvssRoundUp(math.Min(1.08*(impact+exploitability), 10))
+	} else {
+		base = cvssRoundUp(math.Min(impact+exploitability, 10))
+	}
+	return float32(base), true
+}
+
+// cvssRoundUp implements the CVSS spec's "Roundup" function: round to the
+// nearest 0.1, always upward, rather than the nearest-even rounding
+// math.Round would give.
+func cvssRoundUp(x float64) float64 {
+	intInput := int(math.Round(x * 100000))
+	if intInput%10000 == 0 {
+		return float64(intInput) / 100000
+	}
+	return float64(intInput/10000+1) / 10
+}
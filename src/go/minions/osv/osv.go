@@ -0,0 +1,193 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+/*
+Package osv provides a client for the Open Source Vulnerabilities (OSV)
+schema used by osv.dev and the Go vulnerability database. It's a sibling to
+the vulners package, offering a vendor-neutral, offline-capable alternative
+to the Vulners REST API for minions that detect ecosystem packages (Go
+modules, npm, PyPI, Maven, crates.io) as well as distro packages.
+
+See https://ossf.github.io/osv-schema/ for the full schema; Entry only
+models the subset of it this client acts on.
+*/
+package osv
+
+import "context"
+
+// Entry is a single OSV record.
+type Entry struct {
+	ID         string      `json:"id"`
+	Aliases    []string    `json:"aliases,omitempty"`
+	Summary    string      `json:"summary,omitempty"`
+	Details    string      `json:"details,omitempty"`
+	Affected   []Affected  `json:"affected,omitempty"`
+	Severity   []Severity  `json:"severity,omitempty"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// Affected describes one package (and the versions/ranges of it) that an
+// Entry applies to.
+type Affected struct {
+	Package          Package                `json:"package"`
+	Ranges           []Range                `json:"ranges,omitempty"`
+	Versions         []string               `json:"versions,omitempty"`
+	DatabaseSpecific map[string]interface{} `json:"database_specific,omitempty"`
+}
+
+// Package identifies the affected package within its ecosystem.
+type Package struct {
+	Ecosystem string `json:"ecosystem"` // e.g. "Go", "npm", "PyPI", "crates.io", "Debian".
+	Name      string `json:"name"`
+	Purl      string `json:"purl,omitempty"`
+}
+
+// Range is a set of ordered Events describing when a vulnerability was
+// introduced and (optionally) fixed.
+type Range struct {
+	Type   string  `json:"type"` // "SEMVER", "ECOSYSTEM" or "GIT".
+	Events []Event `json:"events"`
+}
+
+// Event is a single point in a Range: exactly one field is set.
+type Event struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+// Severity is a single severity score for an Entry, e.g. a CVSS vector.
+type Severity struct {
+	Type  string `json:"type"` // e.g. "CVSS_V3".
+	Score string `json:"score"`
+}
+
+// Reference is a link to further information about an Entry.
+type Reference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// Source is where a Client fetches OSV entries from. See HTTPSource,
+// FileSource and MemorySource for the three implementations this package
+// ships.
+type Source interface {
+	// ByID fetches the single entry identified by id (e.g. "GHSA-xxxx-..."
+	// or "GO-2023-xxxx").
+	ByID(ctx context.Context, id string) (*Entry, error)
+	// ByEcosystem returns every entry known to affect package name within
+	// ecosystem, regardless of version.
+	ByEcosystem(ctx context.Context, ecosystem, name string) ([]*Entry, error)
+}
+
+// Client queries a Source for vulnerabilities and narrows the result down
+// to entries that actually apply to a concrete installed version.
+type Client struct {
+	Source Source
+}
+
+// NewClient returns a Client backed by src.
+func NewClient(src Source) *Client {
+	return &Client{Source: src}
+}
+
+// ByID returns the single entry identified by id.
+func (c *Client) ByID(ctx context.Context, id string) (*Entry, error) {
+	return c.Source.ByID(ctx, id)
+}
+
+// ByPackage returns every entry affecting ecosystem/name that applies to
+// version. Version ordering follows the ecosystem's own convention: SemVer
+// for Go/npm/crates.io/Maven, PEP 440 for PyPI, and dpkg/rpm-style
+// comparison otherwise (see comparatorFor).
+func (c *Client) ByPackage(ctx context.Context, ecosystem, name, version string) ([]*Entry, error) {
+	entries, err := c.Source.ByEcosystem(ctx, ecosystem, name)
+	if err != nil {
+		return nil, err
+	}
+	var affected []*Entry
+	for _, e := range entries {
+		if entryAffectsVersion(e, ecosystem, name, version) {
+			affected = append(affected, e)
+		}
+	}
+	return affected, nil
+}
+
+// ByModule is ByPackage specialized for the Go ecosystem, matching the way
+// the Go vulnerability database keys entries by module path.
+func (c *Client) ByModule(ctx context.Context, modulePath, version string) ([]*Entry, error) {
+	return c.ByPackage(ctx, "Go", modulePath, version)
+}
+
+// entryAffectsVersion reports whether version falls inside any of the
+// affected ranges (or explicit version list) e declares for ecosystem/name.
+func entryAffectsVersion(e *Entry, ecosystem, name, version string) bool {
+	for _, aff := range e.Affected {
+		if aff.Package.Ecosystem != ecosystem || aff.Package.Name != name {
+			continue
+		}
+		for _, v := range aff.Versions {
+			if v == version {
+				return true
+			}
+		}
+		for _, r := range aff.Ranges {
+			if rangeContains(ecosystem, r, version) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rangeContains evaluates a single OSV range against version. Per the OSV
+// schema, a range's events are processed in order: version is affected if
+// it's at or after the most recent applicable "introduced" event and there
+// is no later "fixed" (or exceeded "last_affected") event.
+func rangeContains(ecosystem string, r Range, version string) bool {
+	cmp := comparatorFor(ecosystem)
+	affected := false
+	for _, ev := range r.Events {
+		switch {
+		case ev.Introduced != "":
+			if ev.Introduced == "0" || cmp(version, ev.Introduced) >= 0 {
+				affected = true
+			}
+		case ev.Fixed != "":
+			if cmp(version, ev.Fixed) >= 0 {
+				affected = false
+			}
+		case ev.LastAffected != "":
+			if cmp(version, ev.LastAffected) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+// indexEntries builds the by-ID and by-ecosystem/name lookup tables shared
+// by FileSource and MemorySource.
+func indexEntries(entries []*Entry) (byID map[string]*Entry, byPkg map[string][]*Entry) {
+	byID = make(map[string]*Entry, len(entries))
+	byPkg = make(map[string][]*Entry)
+	for _, e := range entries {
+		byID[e.ID] = e
+		for _, aff := range e.Affected {
+			key := aff.Package.Ecosystem + "/" + aff.Package.Name
+			byPkg[key] = append(byPkg[key], e)
+		}
+	}
+	return byID, byPkg
+}
@@ -0,0 +1,176 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package osv
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// HTTPSource fetches OSV entries from an osv.dev-compatible HTTP API:
+// GET {BaseURL}/v1/vulns/{id} and GET {BaseURL}/v1/byecosystem/{eco}/{pkg}.
+type HTTPSource struct {
+	BaseURL string // e.g. "https://api.osv.dev", no trailing slash.
+	Client  *http.Client
+}
+
+// NewHTTPSource returns an HTTPSource against baseURL using http.DefaultClient.
+func NewHTTPSource(baseURL string) *HTTPSource {
+	return &HTTPSource{BaseURL: strings.TrimRight(baseURL, "/"), Client: http.DefaultClient}
+}
+
+// ByID implements Source.
+func (s *HTTPSource) ByID(ctx context.Context, id string) (*Entry, error) {
+	var e Entry
+	if err := s.getJSON(ctx, fmt.Sprintf("%s/v1/vulns/%s", s.BaseURL, id), &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ByEcosystem implements Source.
+func (s *HTTPSource) ByEcosystem(ctx context.Context, ecosystem, name string) ([]*Entry, error) {
+	var resp struct {
+		Vulns []*Entry `json:"vulns"`
+	}
+	url := fmt.Sprintf("%s/v1/byecosystem/%s/%s", s.BaseURL, ecosystem, name)
+	if err := s.getJSON(ctx, url, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Vulns, nil
+}
+
+func (s *HTTPSource) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("osv: unexpected status %s for %s", resp.Status, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// FileSource reads OSV entries from a local directory tree of *.json files,
+// the layout osv.dev's own data export uses (one file per entry). It loads
+// the whole tree into memory on first use, which suits air-gapped or test
+// feeds of a few thousand entries rather than the full osv.dev corpus.
+type FileSource struct {
+	Dir string
+
+	once    sync.Once
+	loadErr error
+	byID    map[string]*Entry
+	byPkg   map[string][]*Entry
+}
+
+// NewFileSource returns a FileSource reading from dir.
+func NewFileSource(dir string) *FileSource {
+	return &FileSource{Dir: dir}
+}
+
+// ByID implements Source.
+func (s *FileSource) ByID(ctx context.Context, id string) (*Entry, error) {
+	if err := s.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	e, ok := s.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("osv: unknown entry %q", id)
+	}
+	return e, nil
+}
+
+// ByEcosystem implements Source.
+func (s *FileSource) ByEcosystem(ctx context.Context, ecosystem, name string) ([]*Entry, error) {
+	if err := s.ensureLoaded(); err != nil {
+		return nil, err
+	}
+	return s.byPkg[ecosystem+"/"+name], nil
+}
+
+func (s *FileSource) ensureLoaded() error {
+	s.once.Do(func() {
+		var entries []*Entry
+		entries, s.loadErr = ListEntries(s.Dir)
+		s.byID, s.byPkg = indexEntries(entries)
+	})
+	return s.loadErr
+}
+
+// ListEntries reads and parses every *.json file under dir, the layout
+// FileSource expects. It's exported for callers like vulndb's Fetchers
+// that need the flat list of entries rather than FileSource's indexed
+// lookups.
+func ListEntries(dir string) ([]*Entry, error) {
+	var entries []*Entry
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return fmt.Errorf("osv: parsing %s: %v", path, err)
+		}
+		entries = append(entries, &e)
+		return nil
+	})
+	return entries, err
+}
+
+// MemorySource is an in-memory Source, mainly useful in tests: build it
+// with a fixed list of entries via NewMemorySource.
+type MemorySource struct {
+	byID  map[string]*Entry
+	byPkg map[string][]*Entry
+}
+
+// NewMemorySource returns a MemorySource serving entries.
+func NewMemorySource(entries []*Entry) *MemorySource {
+	byID, byPkg := indexEntries(entries)
+	return &MemorySource{byID: byID, byPkg: byPkg}
+}
+
+// ByID implements Source.
+func (s *MemorySource) ByID(ctx context.Context, id string) (*Entry, error) {
+	e, ok := s.byID[id]
+	if !ok {
+		return nil, fmt.Errorf("osv: unknown entry %q", id)
+	}
+	return e, nil
+}
+
+// ByEcosystem implements Source.
+func (s *MemorySource) ByEcosystem(ctx context.Context, ecosystem, name string) ([]*Entry, error) {
+	return s.byPkg[ecosystem+"/"+name], nil
+}
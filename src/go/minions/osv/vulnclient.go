@@ -0,0 +1,177 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package osv
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/minions/go/minions/vuln"
+	"golang.org/x/net/context"
+)
+
+// ecosystemForOS maps a normalized distro identifier (see normalizeOSName)
+// to the OSV ecosystem name that carries their advisories. Distros OSV
+// doesn't track vulnerabilities for directly are simply absent from the
+// map; "Rocky Linux" and "Red Hat" are included on the strength of the
+// upstream OSV schema defining them as valid ecosystem names, even though
+// osv.dev itself doesn't populate them from a feed as complete as
+// Debian's or Alpine's yet.
+var ecosystemForOS = map[string]string{
+	"debian":    "Debian",
+	"ubuntu":    "Ubuntu",
+	"alpine":    "Alpine",
+	"rocky":     "Rocky Linux",
+	"rhel":      "Red Hat",
+	"redhat":    "Red Hat",
+	"centos":    "Red Hat",
+	"almalinux": "Red Hat",
+}
+
+// normalizeOSName turns the raw NAME field minions read out of
+// /etc/os-release (e.g. "Ubuntu", "Debian GNU/Linux", "Rocky Linux",
+// "Red Hat Enterprise Linux", "AlmaLinux") into the key ecosystemForOS is
+// keyed by.
+func normalizeOSName(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "debian"):
+		return "debian"
+	case strings.Contains(lower, "ubuntu"):
+		return "ubuntu"
+	case strings.Contains(lower, "alpine"):
+		return "alpine"
+	case strings.Contains(lower, "rocky"):
+		return "rocky"
+	case strings.Contains(lower, "red hat"), strings.Contains(lower, "rhel"):
+		return "rhel"
+	case strings.Contains(lower, "centos"):
+		return "centos"
+	case strings.Contains(lower, "almalinux"), strings.Contains(lower, "alma linux"):
+		return "almalinux"
+	default:
+		return lower
+	}
+}
+
+// VulnClient adapts Client, the OSV client, to the vendor-neutral
+// vuln.Client interface so minions can use it interchangeably with Vulners.
+type VulnClient struct {
+	client *Client
+}
+
+// NewVulnClient returns a vuln.Client backed by src.
+func NewVulnClient(src Source) *VulnClient {
+	return &VulnClient{client: NewClient(src)}
+}
+
+// FindForPackages implements vuln.Client. It treats os as a distro name
+// (e.g. "debian") per ecosystemForOS, querying OSV once per package; it
+// can't resolve package versions for distros OSV doesn't track, and fails
+// fast rather than silently returning no results for those.
+func (c *VulnClient) FindForPackages(ctx context.Context, os, osVersion string, pkgs []string) ([]vuln.Vulnerability, error) {
+	ecosystem, ok := ecosystemForOS[normalizeOSName(os)]
+	if !ok {
+		return nil, fmt.Errorf("osv: no ecosystem known for OS %q", os)
+	}
+	var vulns []vuln.Vulnerability
+	for _, pkg := range pkgs {
+		name, version := splitNameVersion(pkg)
+		entries, err := c.client.ByPackage(ctx, ecosystem, name, version)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			v := convertEntry(e)
+			v.Package = name
+			vulns = append(vulns, v)
+		}
+	}
+	return vulns, nil
+}
+
+// Lookup implements vuln.VulnDB, the ecosystem-keyed counterpart to
+// FindForPackages, for minions working from Package URLs (e.g. the sbom
+// minion) rather than a distro package listing.
+func (c *VulnClient) Lookup(ctx context.Context, ecosystem, pkg, version string) ([]vuln.Vulnerability, error) {
+	entries, err := c.client.ByPackage(ctx, ecosystem, pkg, version)
+	if err != nil {
+		return nil, err
+	}
+	vulns := make([]vuln.Vulnerability, len(entries))
+	for i, e := range entries {
+		v := convertEntry(e)
+		v.Package = pkg
+		vulns[i] = v
+	}
+	return vulns, nil
+}
+
+// FindForCPE implements vuln.Client. OSV entries are keyed by
+// ecosystem/package name, not CPE URIs, so this backend can't serve CPE
+// lookups; callers that need both should fan out via vuln.Multi and let the
+// CPE-capable backend answer this one.
+func (c *VulnClient) FindForCPE(ctx context.Context, cpe string, max int) ([]vuln.Vulnerability, error) {
+	return nil, fmt.Errorf("osv: FindForCPE is not supported, OSV has no CPE index")
+}
+
+// splitNameVersion takes the name and version out of the "name version arch"
+// package strings the vulners minion's dpkg scanner produces, since that's
+// the format pkgs arrives in from findingsForState. An rpm
+// "name-version-release.arch" string has no spaces, so it comes back whole
+// as name with an empty version.
+func splitNameVersion(pkg string) (name, version string) {
+	fields := strings.Fields(pkg)
+	switch len(fields) {
+	case 0:
+		return "", ""
+	case 1:
+		return fields[0], ""
+	}
+	return fields[0], fields[1]
+}
+
+// convertEntry turns an OSV Entry into a vuln.Vulnerability. OSV severities
+// are raw CVSS vectors rather than precomputed scores; the first CVSS_V3
+// entry found is run through CVSSv3BaseScore to get a score vuln.Severity
+// can bucket the way Vulners findings already are. A vector
+// CVSSv3BaseScore can't parse (a CVSS v2 vector, or a future CVSS version)
+// leaves Severity at vuln.SeverityUnknown.
+func convertEntry(e *Entry) vuln.Vulnerability {
+	v := vuln.Vulnerability{ID: e.ID, Aliases: e.Aliases}
+	for _, ref := range e.References {
+		v.References = append(v.References, ref.URL)
+	}
+	for _, sev := range e.Severity {
+		if sev.Type != "CVSS_V3" {
+			continue
+		}
+		v.CVSSVector = sev.Score
+		if score, ok := CVSSv3BaseScore(sev.Score); ok {
+			v.CVSSScore = score
+			v.Severity = vuln.SeverityFromCVSSScore(score)
+		}
+		break
+	}
+	for _, aff := range e.Affected {
+		for _, r := range aff.Ranges {
+			for _, ev := range r.Events {
+				if ev.Fixed != "" {
+					v.FixedIn = ev.Fixed
+				}
+			}
+		}
+	}
+	return v
+}
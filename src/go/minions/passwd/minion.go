@@ -0,0 +1,1069 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+/*
+Package passwd implements a minion that looks for simple issues within
+/etc/passwd, /etc/shadow, /etc/group, /etc/gshadow and /etc/sudoers (plus
+/etc/sudoers.d/*) files.
+
+It contains functions that allow one to check if users can login without
+passwords, use weak hashes or are not root, but their uid is 0, as well as
+whether a group grants root-equivalent access to unexpected members and
+whether a sudoers rule is overly permissive.
+
+It also checks whether those files have insecure UNIX permissions.
+*/
+package passwd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	pb "github.com/google/minions/proto/minions"
+	"golang.org/x/net/context"
+)
+
+// Advisories that are used by the passwd Minion.
+var (
+	PasswdPermissions = &pb.Advisory{
+		Reference:      "passwd_permissions",
+		Description:    "/etc/passwd file has permissions that are too wide.",
+		Recommendation: "Change the permissions of /etc/passwd to 0644.",
+	}
+	PasswdEmptyHash = &pb.Advisory{
+		Reference:      "passwd_empty_hash",
+		Description:    "User has an empty password",
+		Recommendation: "Set up a password for the user",
+	}
+	PasswdWeakHashType = &pb.Advisory{
+		Reference:      "passwd_weak_hash_type",
+		Description:    "User uses weak hash function for the hash of his password.",
+		Recommendation: "Change the hash function to SHA512.",
+	}
+	PasswdBackdoor = &pb.Advisory{
+		Reference:   "passwd_backdoor",
+		Description: "A user which is not root has uid 0.",
+	}
+	WeakHashParameters = &pb.Advisory{
+		Reference:      "weak_hash_parameters",
+		Description:    "User's password hash uses a modern algorithm, but with cost parameters too low to resist offline cracking.",
+		Recommendation: "Rehash the password with Argon2id using at least m=65536 (64 MiB), t=3 and p=1.",
+	}
+	LegacyLDAPHash = &pb.Advisory{
+		Reference:      "legacy_ldap_hash",
+		Description:    "User's password hash uses a legacy LDAP userPassword scheme ({CRYPT} or {SHA}) rather than a crypt(3) hash.",
+		Recommendation: "Rehash the password with Argon2id using at least m=65536 (64 MiB), t=3 and p=1.",
+	}
+	ShadowPermissions = &pb.Advisory{
+		Reference:      "shadow_permissions",
+		Description:    "/etc/shadow file has permissions that are too wide.",
+		Recommendation: "Change the permissions of /etc/shadow to 0640.",
+	}
+	ShadowEmptyHash = &pb.Advisory{
+		Reference:      "shadow_empty_hash",
+		Description:    "User has an empty password.",
+		Recommendation: "Set up a password for the user.",
+	}
+	ShadowWeakHashType = &pb.Advisory{
+		Reference:      "shadow_weak_hash_type",
+		Description:    "User uses weak hash for the hash of his password.",
+		Recommendation: "Change the hash function to SHA512.",
+	}
+	GroupPermissions = &pb.Advisory{
+		Reference:      "group_permissions",
+		Description:    "/etc/group file has permissions that are too wide.",
+		Recommendation: "Change the permissions of /etc/group to 0644.",
+	}
+	GroupEmptyHash = &pb.Advisory{
+		Reference:      "group_empty_hash",
+		Description:    "Group has an empty password, so anyone can join it with newgrp.",
+		Recommendation: "Remove the password field, or set up a real password in /etc/gshadow.",
+	}
+	GroupPrivilegedMembership = &pb.Advisory{
+		Reference:      "group_privileged_membership",
+		Description:    "A non-root user is a member of a group that grants root-equivalent access.",
+		Recommendation: "Remove the user from the group unless that access is actually required.",
+	}
+	GShadowPermissions = &pb.Advisory{
+		Reference:      "gshadow_permissions",
+		Description:    "/etc/gshadow file has permissions that are too wide.",
+		Recommendation: "Change the permissions of /etc/gshadow to 0640.",
+	}
+	GShadowEmptyHash = &pb.Advisory{
+		Reference:      "gshadow_empty_hash",
+		Description:    "Group has an empty password in /etc/gshadow.",
+		Recommendation: "Set up a password for the group, or remove the password field entirely.",
+	}
+	GShadowWeakHashType = &pb.Advisory{
+		Reference:      "gshadow_weak_hash_type",
+		Description:    "Group uses a weak hash for the hash of its password.",
+		Recommendation: "Change the hash function to SHA512.",
+	}
+	SudoersPermissions = &pb.Advisory{
+		Reference:      "sudoers_permissions",
+		Description:    "A sudoers file has permissions or ownership that are not 0440 root:root.",
+		Recommendation: "Change the file to mode 0440, owned by root:root.",
+	}
+	SudoersNopasswd = &pb.Advisory{
+		Reference:      "sudoers_nopasswd",
+		Description:    "A sudoers rule allows running commands without authentication (NOPASSWD).",
+		Recommendation: "Remove the NOPASSWD tag unless it is strictly required.",
+	}
+	SudoersAllToNonAdmin = &pb.Advisory{
+		Reference:      "sudoers_all_to_non_admin",
+		Description:    "A sudoers rule grants ALL=(ALL) ALL to a user that isn't root or an admin group.",
+		Recommendation: "Scope the rule down to the specific users, hosts and commands that actually need it.",
+	}
+	SudoersNoAuthenticate = &pb.Advisory{
+		Reference:      "sudoers_no_authenticate",
+		Description:    "A sudoers rule disables authentication entirely with !authenticate.",
+		Recommendation: "Remove the !authenticate tag so sudo keeps requiring a password.",
+	}
+	SudoersDangerousDefaults = &pb.Advisory{
+		Reference:      "sudoers_dangerous_defaults",
+		Description:    "A sudoers Defaults line keeps an environment variable that can be used to escalate privileges (e.g. LD_PRELOAD, LD_LIBRARY_PATH).",
+		Recommendation: "Drop the dangerous variable from env_keep.",
+	}
+	SudoersIncludedirLoop = &pb.Advisory{
+		Reference:      "sudoers_includedir_loop",
+		Description:    "A file under /etc/sudoers.d/ includes /etc/sudoers.d itself, which can loop sudo's parser back onto the same directory.",
+		Recommendation: "Remove the self-referencing #includedir/@includedir directive.",
+	}
+)
+
+// privilegedGroups names the groups whose membership is treated as
+// root-equivalent by GroupPrivilegedMembership.
+var privilegedGroups = map[string]bool{
+	"root":   true,
+	"wheel":  true,
+	"sudo":   true,
+	"docker": true,
+}
+
+// sudoersDangerousEnvVar matches the env_keep entries that let a command run
+// under sudo load an attacker-controlled shared library.
+var sudoersDangerousEnvVar = regexp.MustCompile(`env_keep\s*\+?=.*\bLD_`)
+
+// sudoersAllToAll matches a privilege line granting every host, runas user
+// and command, e.g. "user ALL=(ALL:ALL) ALL" or "user ALL=(ALL) ALL".
+var sudoersAllToAll = regexp.MustCompile(`^(\S+)\s+ALL\s*=\s*\(\s*ALL(:ALL)?\s*\)\s*ALL\s*$`)
+
+// sudoersAdminUsers are the users/groups allowed to hold an unrestricted
+// ALL=(ALL) ALL grant without raising SudoersAllToNonAdmin.
+var sudoersAdminUsers = map[string]bool{
+	"root":   true,
+	"%root":  true,
+	"%wheel": true,
+	"%sudo":  true,
+	"%admin": true,
+}
+
+// Minion is the implementation of minion.Minion interface.
+type Minion struct{}
+
+// ListInitialInterests returns the initial interests of a Minion.
+func (m *Minion) ListInitialInterests(ctx context.Context, req *pb.ListInitialInterestsRequest) (*pb.ListInitialInterestsResponse, error) {
+	return &pb.ListInitialInterestsResponse{
+		Interests: []*pb.Interest{
+			&pb.Interest{
+				PathRegexp: "^/etc/passwd$",
+				DataType:   pb.Interest_METADATA_AND_DATA,
+			},
+			&pb.Interest{
+				PathRegexp: "^/etc/shadow$",
+				DataType:   pb.Interest_METADATA_AND_DATA,
+			},
+			&pb.Interest{
+				PathRegexp: "^/etc/group$",
+				DataType:   pb.Interest_METADATA_AND_DATA,
+			},
+			&pb.Interest{
+				PathRegexp: "^/etc/gshadow$",
+				DataType:   pb.Interest_METADATA_AND_DATA,
+			},
+			&pb.Interest{
+				PathRegexp: "^/etc/sudoers$",
+				DataType:   pb.Interest_METADATA_AND_DATA,
+			},
+			&pb.Interest{
+				PathRegexp: `^/etc/sudoers\.d/.*$`,
+				DataType:   pb.Interest_METADATA_AND_DATA,
+			},
+		},
+	}, nil
+}
+
+// AnalyzeFiles looks for /etc/passwd, /etc/shadow, /etc/group, /etc/gshadow
+// and /etc/sudoers(.d/*) files in the AnalyzeFilesRequest. It then returns
+// security issues found in those files as Findings in
+// pb.AnalyzeFilesResponse.
+func (m *Minion) AnalyzeFiles(ctx context.Context, req *pb.AnalyzeFilesRequest) (*pb.AnalyzeFilesResponse, error) {
+	var allFindings []*pb.Finding
+
+	for _, file := range req.GetFiles() {
+		path := file.GetMetadata().Path
+		var findings []*pb.Finding
+		var err error
+		switch {
+		case path == "/etc/passwd":
+			findings, err = AnalyzePasswd(file)
+		case path == "/etc/shadow":
+			findings, err = AnalyzeShadow(file)
+		case path == "/etc/group":
+			findings, err = AnalyzeGroup(file)
+		case path == "/etc/gshadow":
+			findings, err = AnalyzeGShadow(file)
+		case path == "/etc/sudoers", strings.HasPrefix(path, "/etc/sudoers.d/"):
+			findings, err = AnalyzeSudoers(file)
+		}
+		if err != nil {
+			return nil, err
+		}
+		allFindings = append(allFindings, findings...)
+	}
+
+	ts := ptypes.TimestampNow()
+	// Update Findings with correct Source.
+	for _, f := range allFindings {
+		f.Source = &pb.Source{
+			ScanId:        req.ScanId,
+			Minion:        "passwdfile",
+			DetectionTime: ts,
+		}
+	}
+
+	return &pb.AnalyzeFilesResponse{
+		Findings: allFindings,
+	}, nil
+}
+
+// AnalyzePasswd looks for security issues in the /etc/passwd file and reports
+// them as Findings.
+func AnalyzePasswd(file *pb.File) ([]*pb.Finding, error) {
+	var findings []*pb.Finding
+
+	if md := file.GetMetadata(); md != nil && !ArePasswdPermissionsSecure(md) {
+		findings = append(findings, &pb.Finding{
+			Accuracy: pb.Finding_ACCURACY_FIRM,
+			Severity: pb.Finding_SEVERITY_HIGH,
+			Advisory: PasswdPermissions,
+			VulnerableResources: []*pb.Resource{
+				&pb.Resource{
+					Path:           "/etc/passwd",
+					AdditionalInfo: fmt.Sprintf("current permissions: %#o.", file.GetMetadata().Permissions),
+				},
+			},
+		})
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(file.Data))
+	for scanner.Scan() {
+		user, err := NewUser(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		// Check if user can login without a password.
+		// Average accuracy is assigned as we are not checking if there
+		// is any service that the user can log into.
+		if user.PasswordHash == "" {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_AVERAGE,
+				Severity: pb.Finding_SEVERITY_MEDIUM,
+				Advisory: PasswdEmptyHash,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/passwd",
+						AdditionalInfo: fmt.Sprintf("username: %s", user.Username),
+					},
+				},
+			})
+		}
+
+		if !user.UsesShadowFile() && user.PasswordHash.UsesWeakHashing() {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_LOW,
+				Advisory: PasswdWeakHashType,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/passwd",
+						AdditionalInfo: fmt.Sprintf("username: %s", user.Username),
+					},
+				},
+			})
+		}
+
+		if !user.UsesShadowFile() && user.PasswordHash.HasWeakParameters() {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_LOW,
+				Advisory: WeakHashParameters,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/passwd",
+						AdditionalInfo: fmt.Sprintf("username: %s", user.Username),
+					},
+				},
+			})
+		}
+
+		if !user.UsesShadowFile() && user.PasswordHash.IsLegacyLDAPFormat() {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_LOW,
+				Advisory: LegacyLDAPHash,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/passwd",
+						AdditionalInfo: fmt.Sprintf("username: %s", user.Username),
+					},
+				},
+			})
+		}
+
+		if user.IsBackdooredRoot() {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_GREAT,
+				Severity: pb.Finding_SEVERITY_HIGH,
+				Advisory: PasswdBackdoor,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/passwd",
+						AdditionalInfo: fmt.Sprintf("username: %s", user.Username),
+					},
+				},
+			})
+		}
+	}
+	return findings, nil
+}
+
+// AnalyzeShadow looks for security issues in the /etc/shadow file and reports
+// them as Findings.
+func AnalyzeShadow(file *pb.File) ([]*pb.Finding, error) {
+	var findings []*pb.Finding
+
+	if md := file.GetMetadata(); md != nil && !AreShadowPermissionsSecure(md) {
+		findings = append(findings, &pb.Finding{
+			Accuracy: pb.Finding_ACCURACY_FIRM,
+			Severity: pb.Finding_SEVERITY_HIGH,
+			Advisory: ShadowPermissions,
+			VulnerableResources: []*pb.Resource{
+				&pb.Resource{
+					Path:           "/etc/shadow",
+					AdditionalInfo: fmt.Sprintf("current permissions: %#o.", file.GetMetadata().Permissions),
+				},
+			},
+		})
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(file.Data))
+	for scanner.Scan() {
+		shadow, err := NewShadowInfo(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		// Check if user can login without a password.
+		if shadow.PasswordHash == "" {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_AVERAGE,
+				Severity: pb.Finding_SEVERITY_MEDIUM,
+				Advisory: ShadowEmptyHash,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/shadow",
+						AdditionalInfo: fmt.Sprintf("userame: %s", shadow.Username),
+					},
+				},
+			})
+		}
+
+		if shadow.PasswordHash.UsesWeakHashing() {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_LOW,
+				Advisory: ShadowWeakHashType,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/shadow",
+						AdditionalInfo: fmt.Sprintf("userame: %s", shadow.Username),
+					},
+				},
+			})
+		}
+
+		if shadow.PasswordHash.HasWeakParameters() {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_LOW,
+				Advisory: WeakHashParameters,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/shadow",
+						AdditionalInfo: fmt.Sprintf("userame: %s", shadow.Username),
+					},
+				},
+			})
+		}
+
+		if shadow.PasswordHash.IsLegacyLDAPFormat() {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_LOW,
+				Advisory: LegacyLDAPHash,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/shadow",
+						AdditionalInfo: fmt.Sprintf("userame: %s", shadow.Username),
+					},
+				},
+			})
+		}
+	}
+	return findings, nil
+}
+
+// AnalyzeGroup looks for security issues in the /etc/group file and reports
+// them as Findings.
+func AnalyzeGroup(file *pb.File) ([]*pb.Finding, error) {
+	var findings []*pb.Finding
+
+	if md := file.GetMetadata(); md != nil && !ArePasswdPermissionsSecure(md) {
+		findings = append(findings, &pb.Finding{
+			Accuracy: pb.Finding_ACCURACY_FIRM,
+			Severity: pb.Finding_SEVERITY_HIGH,
+			Advisory: GroupPermissions,
+			VulnerableResources: []*pb.Resource{
+				&pb.Resource{
+					Path:           "/etc/group",
+					AdditionalInfo: fmt.Sprintf("current permissions: %#o.", file.GetMetadata().Permissions),
+				},
+			},
+		})
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(file.Data))
+	for scanner.Scan() {
+		group, err := NewGroup(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		if group.Password == "" {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_AVERAGE,
+				Severity: pb.Finding_SEVERITY_MEDIUM,
+				Advisory: GroupEmptyHash,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/group",
+						AdditionalInfo: fmt.Sprintf("group: %s", group.Name),
+					},
+				},
+			})
+		}
+
+		if !privilegedGroups[group.Name] {
+			continue
+		}
+		for _, member := range group.Members {
+			if member == "root" {
+				continue
+			}
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_HIGH,
+				Advisory: GroupPrivilegedMembership,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/group",
+						AdditionalInfo: fmt.Sprintf("group: %s, member: %s", group.Name, member),
+					},
+				},
+			})
+		}
+	}
+	return findings, nil
+}
+
+// AnalyzeGShadow looks for security issues in the /etc/gshadow file and
+// reports them as Findings.
+func AnalyzeGShadow(file *pb.File) ([]*pb.Finding, error) {
+	var findings []*pb.Finding
+
+	if md := file.GetMetadata(); md != nil && !AreShadowPermissionsSecure(md) {
+		findings = append(findings, &pb.Finding{
+			Accuracy: pb.Finding_ACCURACY_FIRM,
+			Severity: pb.Finding_SEVERITY_HIGH,
+			Advisory: GShadowPermissions,
+			VulnerableResources: []*pb.Resource{
+				&pb.Resource{
+					Path:           "/etc/gshadow",
+					AdditionalInfo: fmt.Sprintf("current permissions: %#o.", file.GetMetadata().Permissions),
+				},
+			},
+		})
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(file.Data))
+	for scanner.Scan() {
+		gshadow, err := NewGShadowInfo(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		if gshadow.PasswordHash == "" {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_AVERAGE,
+				Severity: pb.Finding_SEVERITY_MEDIUM,
+				Advisory: GShadowEmptyHash,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/gshadow",
+						AdditionalInfo: fmt.Sprintf("group: %s", gshadow.Name),
+					},
+				},
+			})
+		}
+
+		if gshadow.PasswordHash.UsesWeakHashing() {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_LOW,
+				Advisory: GShadowWeakHashType,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{
+						Path:           "/etc/gshadow",
+						AdditionalInfo: fmt.Sprintf("group: %s", gshadow.Name),
+					},
+				},
+			})
+		}
+	}
+	return findings, nil
+}
+
+// AnalyzeSudoers looks for security issues in a sudoers file - either
+// /etc/sudoers itself or a fragment under /etc/sudoers.d/ - and reports them
+// as Findings.
+func AnalyzeSudoers(file *pb.File) ([]*pb.Finding, error) {
+	var findings []*pb.Finding
+	path := file.GetMetadata().GetPath()
+
+	if md := file.GetMetadata(); md != nil && !AreSudoersPermissionsSecure(md) {
+		findings = append(findings, &pb.Finding{
+			Accuracy: pb.Finding_ACCURACY_FIRM,
+			Severity: pb.Finding_SEVERITY_HIGH,
+			Advisory: SudoersPermissions,
+			VulnerableResources: []*pb.Resource{
+				&pb.Resource{
+					Path:           path,
+					AdditionalInfo: fmt.Sprintf("current permissions: %#o, owner uid:gid %d:%d.", md.Permissions, md.GetOwnerUid(), md.GetOwnerGid()),
+				},
+			},
+		})
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(file.Data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if isSudoersIncludedir(line) && strings.HasPrefix(path, "/etc/sudoers.d/") {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_AVERAGE,
+				Severity: pb.Finding_SEVERITY_MEDIUM,
+				Advisory: SudoersIncludedirLoop,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{Path: path, AdditionalInfo: line},
+				},
+			})
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "Defaults") && sudoersDangerousEnvVar.MatchString(line) {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_HIGH,
+				Advisory: SudoersDangerousDefaults,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{Path: path, AdditionalInfo: line},
+				},
+			})
+		}
+
+		if strings.Contains(line, "NOPASSWD") {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_MEDIUM,
+				Advisory: SudoersNopasswd,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{Path: path, AdditionalInfo: line},
+				},
+			})
+		}
+
+		if strings.Contains(line, "!authenticate") {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_HIGH,
+				Advisory: SudoersNoAuthenticate,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{Path: path, AdditionalInfo: line},
+				},
+			})
+		}
+
+		if m := sudoersAllToAll.FindStringSubmatch(line); m != nil && !sudoersAdminUsers[m[1]] {
+			findings = append(findings, &pb.Finding{
+				Accuracy: pb.Finding_ACCURACY_FIRM,
+				Severity: pb.Finding_SEVERITY_HIGH,
+				Advisory: SudoersAllToNonAdmin,
+				VulnerableResources: []*pb.Resource{
+					&pb.Resource{Path: path, AdditionalInfo: line},
+				},
+			})
+		}
+	}
+	return findings, nil
+}
+
+// isSudoersIncludedir reports whether line is a #includedir or @includedir
+// directive pointing back at /etc/sudoers.d, the single-file heuristic this
+// minion uses for the includedir loop check: a true cross-file cycle check
+// would need to correlate every sudoers fragment dispatched across a scan,
+// which none of this minion's other analyzers do.
+func isSudoersIncludedir(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) != 2 {
+		return false
+	}
+	directive := fields[0]
+	if directive != "#includedir" && directive != "@includedir" {
+		return false
+	}
+	return strings.TrimRight(fields[1], "/") == "/etc/sudoers.d"
+}
+
+// ArePasswdPermissionsSecure checks the permissions of the /etc/passwd file.
+// It returns false when there are issues with the permissions (any of g+w, o+w
+// is set), and true otherwise.
+func ArePasswdPermissionsSecure(passwd *pb.FileMetadata) bool {
+	return passwd.Permissions&0022 == 0
+}
+
+// AreShadowPermissionsSecure checks the permissions of the /etc/shadow file.
+// It returns false when there are issues with the permissions (any of g+w, o+w,
+// o+r is set), and true otherwise.
+func AreShadowPermissionsSecure(shadow *pb.FileMetadata) bool {
+	return shadow.Permissions&0026 == 0
+}
+
+// AreSudoersPermissionsSecure checks that a sudoers file is exactly mode 0440
+// and owned by root:root, as required by visudo. OwnerUid/OwnerGid come from
+// the tar/filesystem metadata a minion like goblins populates (see
+// fileutil.go); a collector that doesn't set them reports uid/gid 0, so this
+// check degrades to "permissions alone" rather than a hard guarantee on a
+// collector that never reports ownership.
+func AreSudoersPermissionsSecure(sudoers *pb.FileMetadata) bool {
+	return sudoers.Permissions&07777 == 0440 && sudoers.GetOwnerUid() == 0 && sudoers.GetOwnerGid() == 0
+}
+
+// Days represents time interval measured in days.
+type Days int
+
+// NewDays returns Days that represents the number of days given as
+// duration. Returns -1 if given string is empty.
+func NewDays(duration string) (Days, error) {
+	if duration == "" {
+		return -1, nil
+	}
+
+	days, err := strconv.Atoi(duration)
+	if err != nil {
+		return -1, err
+	}
+	return Days(days), nil
+}
+
+// HashType represent a type of hash.
+type HashType int
+
+// Various hash types used in /etc/passwd and /etc/shadow files.
+const (
+	MD5 HashType = iota
+	BLOWFISH
+	SHA256
+	SHA512
+	DES
+	SCRYPT
+	ARGON2ID
+	LDAPSHA
+)
+
+// Cost-parameter thresholds below which HasWeakParameters flags a hash that
+// otherwise uses a modern algorithm. Argon2id's memory threshold matches the
+// OWASP-recommended minimum of 64 MiB.
+const (
+	minBcryptCost   = 10
+	minSHARounds    = 5000
+	minArgon2Memory = 64 * 1024 // KiB.
+	minArgon2Time   = 3
+)
+
+// PasswordHash is a type used to store a hash of password.
+type PasswordHash string
+
+// UsesWeakHashing checks if the password was hashed using MD5 or DES.
+func (hash PasswordHash) UsesWeakHashing() bool {
+	if hash == "" || hash.IsDisabled() {
+		return false
+	}
+	hashType := hash.GetHashType()
+	return hashType == MD5 || hashType == DES
+}
+
+// HasWeakParameters reports whether hash uses bcrypt, SHA-256/512 or
+// Argon2id, but with cost parameters below the thresholds in the const
+// block above - a hash can avoid UsesWeakHashing by using a modern
+// algorithm and still be crackable offline if its cost is too low.
+func (hash PasswordHash) HasWeakParameters() bool {
+	if hash == "" || hash.IsDisabled() {
+		return false
+	}
+	params, ok := hash.GetHashParameters()
+	if !ok {
+		return false
+	}
+	switch hash.GetHashType() {
+	case BLOWFISH:
+		return params.Cost < minBcryptCost
+	case SHA256, SHA512:
+		return params.Rounds < minSHARounds
+	case ARGON2ID:
+		return params.Memory < minArgon2Memory || params.Time < minArgon2Time
+	}
+	return false
+}
+
+// IsDisabled checks if the password is disabled, which is typically done
+// by prepending the hash with ! or *.
+func (hash PasswordHash) IsDisabled() bool {
+	passwd := string(hash)
+	return strings.HasPrefix(passwd, "!") || strings.HasPrefix(passwd, "*")
+}
+
+// IsLegacyLDAPFormat reports whether hash carries a legacy LDAP userPassword
+// scheme prefix ({CRYPT}, wrapping a crypt(3) hash, or {SHA}, an unsalted
+// raw SHA-1 digest) instead of being a crypt(3) hash on its own.
+func (hash PasswordHash) IsLegacyLDAPFormat() bool {
+	passwd := string(hash)
+	return strings.HasPrefix(passwd, "{CRYPT}") || strings.HasPrefix(passwd, "{SHA}")
+}
+
+// stripLDAPCryptPrefix removes a leading "{CRYPT}" LDAP scheme tag, if
+// present, so the crypt(3) hash underneath can still be classified and
+// parsed normally.
+func stripLDAPCryptPrefix(hashStr string) string {
+	return strings.TrimPrefix(hashStr, "{CRYPT}")
+}
+
+// GetHashType returns the type of hash used by the PasswordHash.
+func (hash PasswordHash) GetHashType() HashType {
+	hashStr := stripLDAPCryptPrefix(string(hash))
+	switch {
+	case strings.HasPrefix(hashStr, "{SHA}"):
+		return LDAPSHA
+	case strings.HasPrefix(hashStr, "$1$"):
+		return MD5
+	case strings.HasPrefix(hashStr, "$2a$"), strings.HasPrefix(hashStr, "$2b$"), strings.HasPrefix(hashStr, "$2y$"):
+		return BLOWFISH
+	case strings.HasPrefix(hashStr, "$5$"):
+		return SHA256
+	case strings.HasPrefix(hashStr, "$6$"):
+		return SHA512
+	case strings.HasPrefix(hashStr, "$7$"):
+		return SCRYPT
+	case strings.HasPrefix(hashStr, "$argon2id$"):
+		return ARGON2ID
+	}
+	return DES
+}
+
+// HashParameters holds the algorithm-specific cost parameters parsed out of
+// a PasswordHash's modular crypt format by GetHashParameters. Only the
+// fields relevant to the hash's GetHashType are populated.
+type HashParameters struct {
+	Cost        int // bcrypt cost factor ($2a$/$2b$/$2y$).
+	Rounds      int // SHA-256/SHA-512 rounds=N parameter; 5000 is the algorithm default used when rounds= is absent.
+	Memory      int // Argon2id memory cost in KiB (the m= parameter).
+	Time        int // Argon2id time cost, i.e. number of iterations (the t= parameter).
+	Parallelism int // Argon2id parallelism (the p= parameter).
+}
+
+// GetHashParameters parses the cost parameters embedded in hash's modular
+// crypt format. ok is false for a GetHashType with no tunable cost (MD5,
+// DES, the legacy LDAP schemes) or a malformed hash. scrypt ($7$) packs its
+// cost parameters into a single base64 field rather than plain key=value
+// pairs, so GetHashParameters doesn't decode them; callers only get
+// ok=false for SCRYPT today.
+func (hash PasswordHash) GetHashParameters() (HashParameters, bool) {
+	hashStr := stripLDAPCryptPrefix(string(hash))
+	fields := strings.Split(hashStr, "$")
+
+	switch hash.GetHashType() {
+	case BLOWFISH:
+		if len(fields) < 3 {
+			return HashParameters{}, false
+		}
+		cost, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return HashParameters{}, false
+		}
+		return HashParameters{Cost: cost}, true
+	case SHA256, SHA512:
+		if len(fields) >= 3 && strings.HasPrefix(fields[2], "rounds=") {
+			rounds, err := strconv.Atoi(strings.TrimPrefix(fields[2], "rounds="))
+			if err != nil {
+				return HashParameters{}, false
+			}
+			return HashParameters{Rounds: rounds}, true
+		}
+		return HashParameters{Rounds: 5000}, true
+	case ARGON2ID:
+		// $argon2id$v=19$m=<mem>,t=<time>,p=<par>$salt$hash
+		if len(fields) < 4 {
+			return HashParameters{}, false
+		}
+		params := HashParameters{}
+		for _, kv := range strings.Split(fields[3], ",") {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			val, err := strconv.Atoi(parts[1])
+			if err != nil {
+				continue
+			}
+			switch parts[0] {
+			case "m":
+				params.Memory = val
+			case "t":
+				params.Time = val
+			case "p":
+				params.Parallelism = val
+			}
+		}
+		return params, true
+	}
+	return HashParameters{}, false
+}
+
+// ShadowInfo represents en entry (line) from the /etc/shadow file.
+type ShadowInfo struct {
+	Username       string       // Username from /etc/passwd that this information refers to.
+	PasswordHash   PasswordHash // Hash of the password, as in `man 3 crypt`.
+	LastChangeDate time.Time    // Date of last password change. In /etc/shadow it is a number of days since 01/01/1970. Empty value from /etc/shadow is represented as zero value of time.Time and means that the aging features are disabled. Value of 01/01/1970 means that the user should change the password on the next login.
+	MinimumAge     Days         // How long user have to wait before being allowed to change password. -1 and 0 mean that there is no minimum age.
+	MaximumAge     Days         // User will have to change the password after that time. -1 means that there are no maximum pasword age, no warning period and no inactivity period.
+	WarningPeriod  Days         // Days before password expires during which the user is warned. -1 and 0 mean that there is no warning period.
+	InactiveDays   Days         // Days after the password expires during which the user can still log in. -1 means there is no enforcement of an inactivity period.
+	ExpirationDate time.Time    // The date of expiration of the account. Zero value means that the account will never expire.
+	Reserved       interface{}  // Reserved for future use by the linux standard.
+}
+
+// NewShadowInfo parses a line in a format of /etc/shadow file and returns it as a ShadowInfo.
+func NewShadowInfo(line string) (ShadowInfo, error) {
+	fields := strings.Split(line, ":")
+
+	if len(fields) != 9 {
+		return ShadowInfo{}, fmt.Errorf("unexpected number of fields in shadow line %q", line)
+	}
+
+	ret := ShadowInfo{}
+	var err error
+
+	ret.Username = fields[0]
+	ret.PasswordHash = PasswordHash(fields[1])
+
+	ret.LastChangeDate, err = parseDate(fields[2])
+	if err != nil {
+		return ShadowInfo{}, err
+	}
+
+	ret.MinimumAge, err = NewDays(fields[3])
+	if err != nil {
+		return ShadowInfo{}, err
+	}
+
+	ret.MaximumAge, err = NewDays(fields[4])
+	if err != nil {
+		return ShadowInfo{}, err
+	}
+
+	ret.WarningPeriod, err = NewDays(fields[5])
+	if err != nil {
+		return ShadowInfo{}, err
+	}
+
+	ret.InactiveDays, err = NewDays(fields[6])
+	if err != nil {
+		return ShadowInfo{}, err
+	}
+
+	ret.ExpirationDate, err = parseDate(fields[7])
+	if err != nil {
+		return ShadowInfo{}, err
+	}
+
+	return ret, nil
+}
+
+// User represents data from /etc/passwd and /etc/shadow.
+type User struct {
+	Username     string       // Just a username.
+	PasswordHash PasswordHash // Password field from /etc/passwd, contains 'x' if shadow file is used.
+	UID          int          // Id of an user.
+	GID          int          // Group id of an user.
+	Comment      string       // Comment or a full name.
+	Home         string       // Home directory.
+	Shell        string       // User command interpreter.
+}
+
+// NewUser parses a line in the format of /etc/passwd and returns it as a User.
+// It returns error if line format or some of the fields are invalid.
+func NewUser(line string) (User, error) {
+	fields := strings.Split(line, ":")
+
+	if len(fields) != 7 {
+		return User{}, fmt.Errorf("unexpected number of fields in passwd line %q", line)
+	}
+
+	var err error
+	user := User{}
+
+	user.Username = fields[0]
+	user.PasswordHash = PasswordHash(fields[1])
+
+	user.UID, err = strconv.Atoi(fields[2])
+	if err != nil {
+		return User{}, fmt.Errorf("UID should be a number, passwd line %q", line)
+	}
+
+	user.GID, err = strconv.Atoi(fields[3])
+	if err != nil {
+		return User{}, fmt.Errorf("GID should be a number, passwd line %q", line)
+	}
+
+	user.Comment = fields[4]
+	user.Home = fields[5]
+	user.Shell = fields[6]
+
+	return user, nil
+}
+
+// UsesShadowFile checks if user's password is stored in /etc/shadow file.
+func (u User) UsesShadowFile() bool {
+	return u.PasswordHash == "x"
+}
+
+// IsBackdooredRoot checks if the username is not root, but uid is equal to 0.
+func (u User) IsBackdooredRoot() bool {
+	return u.UID == 0 && u.Username != "root"
+}
+
+// Group represents an entry (line) from the /etc/group file.
+type Group struct {
+	Name     string // Group name.
+	Password PasswordHash
+	GID      int      // Group id.
+	Members  []string // Usernames that are members of this group.
+}
+
+// NewGroup parses a line in the format of /etc/group and returns it as a
+// Group. It returns an error if the line format or some of its fields are
+// invalid.
+func NewGroup(line string) (Group, error) {
+	fields := strings.Split(line, ":")
+
+	if len(fields) != 4 {
+		return Group{}, fmt.Errorf("unexpected number of fields in group line %q", line)
+	}
+
+	group := Group{Name: fields[0], Password: PasswordHash(fields[1])}
+
+	var err error
+	group.GID, err = strconv.Atoi(fields[2])
+	if err != nil {
+		return Group{}, fmt.Errorf("GID should be a number, group line %q", line)
+	}
+
+	if fields[3] != "" {
+		group.Members = strings.Split(fields[3], ",")
+	}
+
+	return group, nil
+}
+
+// GShadowInfo represents an entry (line) from the /etc/gshadow file.
+type GShadowInfo struct {
+	Name           string       // Group name from /etc/group that this information refers to.
+	PasswordHash   PasswordHash // Hash of the group password, as in `man 3 crypt`.
+	Administrators []string     // Usernames allowed to administer the group (change its password, add/remove members).
+	Members        []string     // Usernames that are members of this group.
+}
+
+// NewGShadowInfo parses a line in the format of /etc/gshadow and returns it
+// as a GShadowInfo.
+func NewGShadowInfo(line string) (GShadowInfo, error) {
+	fields := strings.Split(line, ":")
+
+	if len(fields) != 4 {
+		return GShadowInfo{}, fmt.Errorf("unexpected number of fields in gshadow line %q", line)
+	}
+
+	ret := GShadowInfo{Name: fields[0], PasswordHash: PasswordHash(fields[1])}
+	if fields[2] != "" {
+		ret.Administrators = strings.Split(fields[2], ",")
+	}
+	if fields[3] != "" {
+		ret.Members = strings.Split(fields[3], ",")
+	}
+
+	return ret, nil
+}
+
+// parseDate returns time.Date given string containing number of days since Jan 1, 1970,
+// or zero value of time.Time if the string is empty.
+func parseDate(date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+
+	days, err := strconv.Atoi(date)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Date(1970, 0, 0, 0, 0, 0, 0, time.UTC).AddDate(0, 0, days), nil
+}
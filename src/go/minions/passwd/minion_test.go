@@ -35,7 +35,7 @@ func TestListingInterests(t *testing.T) {
 	var minion Minion
 	response, err := minion.ListInitialInterests(ctx, &pb.ListInitialInterestsRequest{})
 	require.NoError(t, err)
-	require.Len(t, response.GetInterests(), 2)
+	require.Len(t, response.GetInterests(), 6)
 }
 
 // TestAnalyzingFiles checks if correct number of Findings is returned both
@@ -75,6 +75,73 @@ func TestAnalyzingFiles(t *testing.T) {
 	require.Len(t, res.GetNewInterests(), 0)
 }
 
+// TestGroupAnalyzing checks if the AnalyzeGroup method is returning the
+// correct number of findings or errors.
+func TestGroupAnalyzing(t *testing.T) {
+	malformedTest := &pb.File{Data: []byte("very malformed file")}
+
+	_, err := AnalyzeGroup(malformedTest)
+	require.Error(t, err)
+
+	manyFindingsTest := &pb.File{
+		Data: []byte("wheel:x:10:alice\n" +
+			"wheel:x:10:root\n" +
+			"users:x:100:alice,bob\n" +
+			"empty::200:alice\n"),
+		Metadata: &pb.FileMetadata{Permissions: 0777},
+	}
+
+	// Expect: 1 permissions finding, 1 privileged-membership finding (alice
+	// in wheel; root is excluded), 1 empty-password finding ("empty").
+	findings, err := AnalyzeGroup(manyFindingsTest)
+	require.NoError(t, err)
+	require.Len(t, findings, 3)
+}
+
+// TestGShadowAnalyzing checks if the AnalyzeGShadow method is returning the
+// correct number of findings or errors.
+func TestGShadowAnalyzing(t *testing.T) {
+	malformedTest := &pb.File{Data: []byte("::::::")}
+
+	_, err := AnalyzeGShadow(malformedTest)
+	require.Error(t, err)
+
+	manyFindingsTest := &pb.File{
+		Data: []byte("empty:::\n" +
+			"weak:$1$salt$hash:root:\n"),
+		Metadata: &pb.FileMetadata{Permissions: 0777},
+	}
+
+	findings, err := AnalyzeGShadow(manyFindingsTest)
+	require.NoError(t, err)
+	require.Len(t, findings, 3)
+}
+
+// TestSudoersAnalyzing checks if the AnalyzeSudoers method is returning the
+// correct number of findings for a file containing every check this minion
+// performs.
+func TestSudoersAnalyzing(t *testing.T) {
+	sudoersTest := &pb.File{
+		Metadata: &pb.FileMetadata{
+			Path:        "/etc/sudoers.d/custom",
+			Permissions: 0440,
+			OwnerUid:    1000,
+			OwnerGid:    1000,
+		},
+		Data: []byte("Defaults env_keep+=\"LD_PRELOAD\"\n" +
+			"alice ALL=(ALL:ALL) ALL\n" +
+			"bob ALL=(ALL) NOPASSWD: ALL\n" +
+			"carol ALL=(ALL) !authenticate ALL\n" +
+			"#includedir /etc/sudoers.d\n"),
+	}
+
+	// Expect: permissions/ownership, env_keep, ALL-to-non-admin, NOPASSWD,
+	// !authenticate and includedir-loop findings.
+	findings, err := AnalyzeSudoers(sudoersTest)
+	require.NoError(t, err)
+	require.Len(t, findings, 6)
+}
+
 // TestPasswdAnalyzing checks if the AnalyzePasswd method is returning
 // the correct number of findings or errors.
 func TestPasswdAnalyzing(t *testing.T) {
@@ -237,3 +304,62 @@ func TestWeakHashes(t *testing.T) {
 		}
 	}
 }
+
+func TestHashParameters(t *testing.T) {
+	var tests = []struct {
+		hash PasswordHash
+		want HashParameters
+		ok   bool
+	}{
+		{"$1$salt$hash", HashParameters{}, false},
+		{"$2b$12$saltsaltsaltsaltsaltsa", HashParameters{Cost: 12}, true},
+		{"$5$salt$hash", HashParameters{Rounds: 5000}, true},
+		{"$6$rounds=10000$salt$hash", HashParameters{Rounds: 10000}, true},
+		{"$argon2id$v=19$m=65536,t=3,p=4$salt$hash", HashParameters{Memory: 65536, Time: 3, Parallelism: 4}, true},
+		{"$7$N$salt$hash", HashParameters{}, false},
+	}
+
+	for _, test := range tests {
+		have, ok := test.hash.GetHashParameters()
+		require.Equal(t, test.ok, ok, "GetHashParameters(%v) ok", test.hash)
+		require.Equal(t, test.want, have, "GetHashParameters(%v)", test.hash)
+	}
+}
+
+func TestWeakHashParameters(t *testing.T) {
+	var tests = []struct {
+		hash PasswordHash
+		want bool
+	}{
+		{"", false},
+		{"$2b$12$saltsaltsaltsaltsaltsa", false},
+		{"$2b$04$saltsaltsaltsaltsaltsa", true},
+		{"$6$rounds=10000$salt$hash", false},
+		{"$6$rounds=1000$salt$hash", true},
+		{"$argon2id$v=19$m=65536,t=3,p=4$salt$hash", false},
+		{"$argon2id$v=19$m=4096,t=1,p=4$salt$hash", true},
+	}
+
+	for _, test := range tests {
+		if have := test.hash.HasWeakParameters(); have != test.want {
+			t.Errorf("HasWeakParameters() for %v, have %t, want %t", test.hash, have, test.want)
+		}
+	}
+}
+
+func TestLegacyLDAPFormat(t *testing.T) {
+	var tests = []struct {
+		hash PasswordHash
+		want bool
+	}{
+		{"$6$salt$hash", false},
+		{"{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", true},
+		{"{CRYPT}$6$salt$hash", true},
+	}
+
+	for _, test := range tests {
+		if have := test.hash.IsLegacyLDAPFormat(); have != test.want {
+			t.Errorf("IsLegacyLDAPFormat() for %v, have %t, want %t", test.hash, have, test.want)
+		}
+	}
+}
@@ -29,13 +29,16 @@ import (
 	"strings"
 
 	"github.com/golang/protobuf/ptypes"
+	"github.com/google/minions/go/minions"
 	pb "github.com/google/minions/proto/minions"
 	"github.com/phayes/permbits"
 	"golang.org/x/net/context"
 )
 
-// Minion checking for Redis configuration issues.
+// Minion checking for Redis configuration issues. Redis configs are tiny,
+// so there's no need for the streaming AnalyzeFilesStream RPC here.
 type Minion struct {
+	minions.UnimplementedStreamingMinion
 }
 
 // NewMinion creates a default Redis Minion.
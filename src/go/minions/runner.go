@@ -20,16 +20,21 @@ import (
 	"net"
 
 	"github.com/google/minions/go/grpcutil"
+	"github.com/google/minions/go/throttle"
 
 	pb "github.com/google/minions/proto/minions"
 	"google.golang.org/grpc"
 )
 
 var (
-	bind    = flag.String("bind", "localhost", "IP or hostname to bind to")
-	port    = flag.Int("port", 20001, "Port to bind the minion to")
-	sslCert = flag.String("ssl_cert", "", "Path to the SSL certificate (crt)")
-	sslKey  = flag.String("ssl_key", "", "Path to the SSL key (key)")
+	bind     = flag.String("bind", "localhost", "IP or hostname to bind to")
+	port     = flag.Int("port", 20001, "Port to bind the minion to")
+	sslCert  = flag.String("ssl_cert", "", "Path to the SSL certificate (crt)")
+	sslKey   = flag.String("ssl_key", "", "Path to the SSL key (key)")
+	clientCA = flag.String("client_ca", "", "Path to a CA certificate; if set, only Overlords presenting a client cert signed by it may call this minion")
+
+	maxBps     = flag.Int64("max_bps", 0, "Maximum inbound bytes/sec accepted from an Overlord, per scan ID; 0 disables throttling")
+	burstBytes = flag.Int64("burst_bytes", 0, "Burst size in bytes a scan may spend immediately before -max_bps throttling kicks in")
 )
 
 // StartMinion initializes a gRPC endpoint and populates it with the provided Minion.
@@ -43,12 +48,16 @@ func StartMinion(minion Minion, minionName string) {
 	}
 
 	var opts []grpc.ServerOption
-	creds, err := grpcutil.GetSslServerCreds(*sslCert, *sslKey, "") // We don't validate client certs.
+	creds, err := grpcutil.GetSslServerCreds(*sslCert, *sslKey, *clientCA)
 	if creds == nil {
 		log.Println("WARNING: starting a Minion with no SSL support")
 	} else {
 		opts = append(opts, creds)
 	}
+	if *maxBps > 0 {
+		registry := throttle.NewRegistry(*maxBps, *burstBytes)
+		opts = append(opts, grpc.UnaryInterceptor(unaryThrottleInterceptor(registry)), grpc.StreamInterceptor(streamThrottleInterceptor(registry)))
+	}
 	grpcServer := grpc.NewServer(opts...)
 	pb.RegisterMinionServer(grpcServer, minion)
 	log.Printf("StartMinion: Minion created and registered, entering busy loop, ready to scan.")
@@ -0,0 +1,84 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package sbom
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// cycloneDXBOM is the root of a CycloneDX 1.4 JSON document; only the
+// fields this package populates are modeled.
+type cycloneDXBOM struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Components  []cycloneDXComponent `json:"components,omitempty"`
+}
+
+// cycloneDXComponent describes a single package in the inventory.
+type cycloneDXComponent struct {
+	Type    string `json:"type"`
+	BOMRef  string `json:"bom-ref"`
+	Group   string `json:"group,omitempty"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl"`
+}
+
+// renderCycloneDX builds a minimal CycloneDX 1.4 JSON SBOM listing purls as
+// components. It always emits a document, even given no purls, so a
+// downstream consumer can tell "we looked and found nothing" apart from "we
+// didn't look".
+func renderCycloneDX(purls []PackageURL) ([]byte, error) {
+	bom := cycloneDXBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.4",
+		Version:     1,
+	}
+	for _, p := range purls {
+		bom.Components = append(bom.Components, cycloneDXComponent{
+			Type:    "library",
+			BOMRef:  p.String(),
+			Group:   p.Namespace,
+			Name:    p.Name,
+			Version: p.Version,
+			PURL:    p.String(),
+		})
+	}
+	return json.MarshalIndent(bom, "", "  ")
+}
+
+// ParseCycloneDX extracts one PackageURL per component in a CycloneDX 1.4
+// JSON document - the ingestion counterpart to renderCycloneDX, for an
+// overlord operator handing this minion an SBOM a build pipeline already
+// produced instead of the raw manifests it was built from.
+func ParseCycloneDX(r io.Reader) ([]PackageURL, error) {
+	var bom cycloneDXBOM
+	if err := json.NewDecoder(r).Decode(&bom); err != nil {
+		return nil, err
+	}
+
+	var purls []PackageURL
+	for _, c := range bom.Components {
+		if c.PURL != "" {
+			if p, err := ParsePurlString(c.PURL); err == nil {
+				purls = append(purls, p)
+				continue
+			}
+		}
+		purls = append(purls, PackageURL{Namespace: c.Group, Name: c.Name, Version: c.Version})
+	}
+	return purls, nil
+}
@@ -0,0 +1,92 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package sbom
+
+import (
+	"context"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/minions/go/minions/vuln"
+	pb "github.com/google/minions/proto/minions"
+)
+
+// findingsForPurls queries db for every purl whose ecosystem it knows about
+// (see PackageURL.Ecosystem) and converts whatever it finds into Findings.
+// purls with no known ecosystem (a purl type this package doesn't support
+// yet) are silently skipped, rather than failing the whole scan over one
+// unrecognized manifest entry. db is the same vuln.VulnDB abstraction the
+// vulners minion's Client is the distro-keyed sibling of, so this minion
+// can plug in OSV, Vulners, or a static feed interchangeably - see
+// NewMinion.
+func findingsForPurls(ctx context.Context, db vuln.VulnDB, scanID string, purls []PackageURL) ([]*pb.Finding, error) {
+	var findings []*pb.Finding
+	for _, p := range purls {
+		ecosystem := p.Ecosystem()
+		if ecosystem == "" {
+			continue
+		}
+		vulns, err := db.Lookup(ctx, ecosystem, p.PackageName(), p.Version)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vulns {
+			findings = append(findings, findingFromVulnerability(v, p, scanID))
+		}
+	}
+	return findings, nil
+}
+
+// findingFromVulnerability builds the proto representation of a Finding
+// from a vuln.Vulnerability affecting p.
+func findingFromVulnerability(v vuln.Vulnerability, p PackageURL, scanID string) *pb.Finding {
+	adv := &pb.Advisory{
+		Reference:      v.ID,
+		Description:    strings.Join(v.Aliases, ","),
+		Recommendation: v.FixedIn,
+	}
+	source := &pb.Source{
+		ScanId:        scanID,
+		Minion:        "SBOM",
+		DetectionTime: ptypes.TimestampNow(),
+	}
+	resources := []*pb.Resource{{
+		Path:           "",
+		AdditionalInfo: p.String(),
+	}}
+	return &pb.Finding{
+		Advisory:            adv,
+		VulnerableResources: resources,
+		Source:              source,
+		Accuracy:            pb.Finding_ACCURACY_AVERAGE,
+		Severity:            pbSeverity(v.Severity),
+	}
+}
+
+// pbSeverity converts a vuln.Severity into its pb.Finding_Severity
+// counterpart; the two enums share the same bucket names by construction.
+func pbSeverity(s vuln.Severity) pb.Finding_Severity {
+	switch s {
+	case vuln.SeverityLow:
+		return pb.Finding_SEVERITY_LOW
+	case vuln.SeverityMedium:
+		return pb.Finding_SEVERITY_MEDIUM
+	case vuln.SeverityHigh:
+		return pb.Finding_SEVERITY_HIGH
+	case vuln.SeverityCritical:
+		return pb.Finding_SEVERITY_CRITICAL
+	default:
+		return pb.Finding_SEVERITY_UNKNOWN
+	}
+}
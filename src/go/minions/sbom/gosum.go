@@ -0,0 +1,61 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package sbom
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ParseGoSum extracts one PackageURL per module listed in a go.sum file. Each
+// module normally appears twice (once for its source tree, once with a
+// "/go.mod" suffix for the go.mod-only hash); only the former is kept, since
+// both describe the exact same dependency.
+func ParseGoSum(r io.Reader) ([]PackageURL, error) {
+	var purls []PackageURL
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		module, version := fields[0], fields[1]
+		if strings.HasSuffix(version, "/go.mod") {
+			continue
+		}
+		namespace, name := splitModulePath(module)
+		purls = append(purls, PackageURL{
+			Type:      "golang",
+			Namespace: namespace,
+			Name:      name,
+			Version:   strings.TrimPrefix(version, "v"),
+		})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return purls, nil
+}
+
+// splitModulePath splits a Go module path into the namespace/name pair a
+// purl expects, e.g. "github.com/google/uuid" becomes
+// ("github.com/google", "uuid").
+func splitModulePath(module string) (namespace, name string) {
+	i := strings.LastIndex(module, "/")
+	if i < 0 {
+		return "", module
+	}
+	return module[:i], module[i+1:]
+}
@@ -0,0 +1,65 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package sbom
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// mavenProject models just the parts of a pom.xml this package cares about.
+type mavenProject struct {
+	Dependencies struct {
+		Dependency []mavenDependency `xml:"dependency"`
+	} `xml:"dependencies"`
+}
+
+type mavenDependency struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+}
+
+// ParsePomXML extracts one PackageURL per <dependency> declared directly in
+// a pom.xml's <dependencies> section. Dependencies inherited from a parent
+// POM or a <dependencyManagement> block aren't visible from a single file
+// and so aren't resolved here; dependencies using a property placeholder
+// (e.g. "${guava.version}") instead of a literal version are skipped, since
+// there's no build to resolve the property against.
+func ParsePomXML(r io.Reader) ([]PackageURL, error) {
+	var project mavenProject
+	if err := xml.NewDecoder(r).Decode(&project); err != nil {
+		return nil, err
+	}
+
+	var purls []PackageURL
+	for _, dep := range project.Dependencies.Dependency {
+		if dep.GroupID == "" || dep.ArtifactID == "" || dep.Version == "" || isMavenProperty(dep.Version) {
+			continue
+		}
+		purls = append(purls, PackageURL{
+			Type:      "maven",
+			Namespace: dep.GroupID,
+			Name:      dep.ArtifactID,
+			Version:   dep.Version,
+		})
+	}
+	return purls, nil
+}
+
+// isMavenProperty reports whether v is a "${...}" property placeholder
+// rather than a literal version.
+func isMavenProperty(v string) bool {
+	return len(v) > 3 && v[0] == '$' && v[1] == '{' && v[len(v)-1] == '}'
+}
@@ -0,0 +1,153 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+/*
+Package sbom contains a minion that inventories language-ecosystem
+dependencies (Go modules, npm, pip and Maven) rather than OS packages,
+which is what the vulners minion covers. It parses each manifest it's
+handed (or an SPDX/CycloneDX SBOM document produced elsewhere, see
+spdx.go and cyclonedx.go) into a list of Package URLs, queries a
+vuln.VulnDB for vulnerabilities affecting them, and attaches a CycloneDX
+SBOM of the whole inventory to its response so downstream tooling has the
+full picture even when no vulnerabilities are found.
+*/
+package sbom
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"regexp"
+
+	"github.com/google/minions/go/minions"
+	"github.com/google/minions/go/minions/vuln"
+	pb "github.com/google/minions/proto/minions"
+	"golang.org/x/net/context"
+)
+
+// manifestParsers maps each manifest path this minion understands to the
+// parser that turns its contents into PackageURLs.
+var manifestParsers = map[string]func(io.Reader) ([]PackageURL, error){
+	"go.sum":            ParseGoSum,
+	"package-lock.json": ParsePackageLock,
+	"requirements.txt":  ParseRequirementsTxt,
+	"pom.xml":           ParsePomXML,
+}
+
+// sbomInterests maps a regexp matching an externally-produced SBOM
+// document to the parser that extracts its PackageURLs - the ingestion
+// counterpart to manifestParsers, for an SBOM a build pipeline already
+// produced rather than the raw manifests it was built from.
+var sbomInterests = []struct {
+	pathRegexp string
+	parse      func(io.Reader) ([]PackageURL, error)
+}{
+	{`.*\.spdx$`, ParseSPDXTagValue},
+	{`.*\.spdx\.json$`, ParseSPDXJSON},
+	{`bom\.json$`, ParseCycloneDX},
+}
+
+// Minion inventories language-ecosystem dependencies and checks them
+// against a vuln.VulnDB backend. Manifests are small, so there's no need
+// for the streaming AnalyzeFilesStream RPC here.
+type Minion struct {
+	minions.UnimplementedStreamingMinion
+	db vuln.VulnDB
+}
+
+// NewMinion creates an sbom minion backed by db, e.g. an osv.VulnClient
+// pointed at osv.dev or a local OSV export.
+func NewMinion(db vuln.VulnDB) *Minion {
+	return &Minion{db: db}
+}
+
+// ListInitialInterests returns the manifest files and SBOM documents this
+// minion knows how to parse.
+func (m Minion) ListInitialInterests(ctx context.Context, req *pb.ListInitialInterestsRequest) (*pb.ListInitialInterestsResponse, error) {
+	var interests []*pb.Interest
+	for path := range manifestParsers {
+		interests = append(interests, &pb.Interest{
+			DataType:   pb.Interest_METADATA_AND_DATA,
+			PathRegexp: path,
+		})
+	}
+	for _, c := range sbomInterests {
+		interests = append(interests, &pb.Interest{
+			DataType:   pb.Interest_METADATA_AND_DATA,
+			PathRegexp: c.pathRegexp,
+		})
+	}
+	return &pb.ListInitialInterestsResponse{Interests: interests}, nil
+}
+
+// parserFor returns the parser path's contents should be fed to - the
+// manifestParsers entry named by path's base name first, then the first
+// matching regexp in sbomInterests - or nil if path matches neither.
+func parserFor(path string) (func(io.Reader) ([]PackageURL, error), error) {
+	if parse, ok := manifestParsers[filepath.Base(path)]; ok {
+		return parse, nil
+	}
+	for _, c := range sbomInterests {
+		match, err := regexp.MatchString(c.pathRegexp, path)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			return c.parse, nil
+		}
+	}
+	return nil, nil
+}
+
+// AnalyzeFiles parses every recognized manifest or SBOM document in req,
+// queries its vuln.VulnDB for vulnerabilities affecting what it finds, and
+// attaches a CycloneDX SBOM of the combined inventory.
+func (m Minion) AnalyzeFiles(ctx context.Context, req *pb.AnalyzeFilesRequest) (*pb.AnalyzeFilesResponse, error) {
+	var purls []PackageURL
+	for _, f := range req.Files {
+		parse, err := parserFor(f.GetMetadata().GetPath())
+		if err != nil {
+			return nil, err
+		}
+		if parse == nil {
+			continue
+		}
+		found, err := parse(bytes.NewReader(f.GetData()))
+		if err != nil {
+			return nil, err
+		}
+		purls = append(purls, found...)
+	}
+
+	findings, err := findingsForPurls(ctx, m.db, req.GetScanId(), purls)
+	if err != nil {
+		return nil, err
+	}
+
+	bom, err := renderCycloneDX(purls)
+	if err != nil {
+		return nil, err
+	}
+	var attachments []*pb.SbomAttachment
+	if len(purls) > 0 {
+		attachments = append(attachments, &pb.SbomAttachment{
+			Format: pb.SbomAttachment_CYCLONEDX_JSON,
+			Data:   bom,
+		})
+	}
+
+	return &pb.AnalyzeFilesResponse{
+		Findings:        findings,
+		SbomAttachments: attachments,
+	}, nil
+}
@@ -0,0 +1,95 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package sbom
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// npmDependency is one entry of a (legacy, lockfileVersion 1/2) package-lock.json
+// "dependencies" object; it nests further dependencies the same way npm does.
+type npmDependency struct {
+	Version      string                   `json:"version"`
+	Dependencies map[string]npmDependency `json:"dependencies"`
+}
+
+// npmPackageLock models just enough of package-lock.json to extract every
+// installed package and its version, across the schema's two generations.
+type npmPackageLock struct {
+	Dependencies map[string]npmDependency  `json:"dependencies"`
+	Packages     map[string]npmPackageMeta `json:"packages"` // lockfileVersion 2/3.
+}
+
+// npmPackageMeta is one entry of lockfileVersion 2/3's "packages" object,
+// keyed by the package's node_modules path (e.g. "node_modules/foo").
+type npmPackageMeta struct {
+	Version string `json:"version"`
+}
+
+// ParsePackageLock extracts one PackageURL per npm package listed in a
+// package-lock.json file, across both the legacy "dependencies" object and
+// the "packages" object newer lockfile versions use; entries present in
+// both are deduplicated by name and version.
+func ParsePackageLock(r io.Reader) ([]PackageURL, error) {
+	var lock npmPackageLock
+	if err := json.NewDecoder(r).Decode(&lock); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var purls []PackageURL
+	add := func(name, version string) {
+		if name == "" || version == "" {
+			return
+		}
+		key := name + "@" + version
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		purls = append(purls, PackageURL{Type: "npm", Name: name, Version: version})
+	}
+
+	var walk func(deps map[string]npmDependency)
+	walk = func(deps map[string]npmDependency) {
+		for name, dep := range deps {
+			add(name, dep.Version)
+			if dep.Dependencies != nil {
+				walk(dep.Dependencies)
+			}
+		}
+	}
+	walk(lock.Dependencies)
+
+	for path, meta := range lock.Packages {
+		if path == "" {
+			continue // The root project itself, not a dependency.
+		}
+		add(npmNameFromNodeModulesPath(path), meta.Version)
+	}
+
+	return purls, nil
+}
+
+// npmNameFromNodeModulesPath extracts a package name out of a "packages" key
+// like "node_modules/foo" or the nested "node_modules/foo/node_modules/bar".
+func npmNameFromNodeModulesPath(path string) string {
+	i := strings.LastIndex(path, "node_modules/")
+	if i < 0 {
+		return ""
+	}
+	return path[i+len("node_modules/"):]
+}
@@ -0,0 +1,204 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package sbom
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGoSum(t *testing.T) {
+	input := "github.com/google/uuid v1.3.0 h1:t6JiXgmwXMjEs8VusXIJk2BXHsn+wx8BZdTaoZ5fu7I=\n" +
+		"github.com/google/uuid v1.3.0/go.mod h1:TIyPZe4MgqvfeYDBFedMoGGpEw/LqOeaOT+nhxU+yHo=\n"
+	got, err := ParseGoSum(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseGoSum: unexpected error %v", err)
+	}
+	want := []PackageURL{{Type: "golang", Namespace: "github.com/google", Name: "uuid", Version: "1.3.0"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseGoSum(%q) = %+v, want %+v", input, got, want)
+	}
+}
+
+func TestParsePackageLock(t *testing.T) {
+	input := `{
+		"dependencies": {
+			"lodash": {
+				"version": "4.17.20"
+			}
+		}
+	}`
+	got, err := ParsePackageLock(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParsePackageLock: unexpected error %v", err)
+	}
+	want := []PackageURL{{Type: "npm", Name: "lodash", Version: "4.17.20"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePackageLock(%q) = %+v, want %+v", input, got, want)
+	}
+}
+
+func TestParsePackageLock_packagesFormat(t *testing.T) {
+	input := `{
+		"packages": {
+			"": {"name": "root"},
+			"node_modules/lodash": {"version": "4.17.20"}
+		}
+	}`
+	got, err := ParsePackageLock(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParsePackageLock: unexpected error %v", err)
+	}
+	want := []PackageURL{{Type: "npm", Name: "lodash", Version: "4.17.20"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePackageLock(%q) = %+v, want %+v", input, got, want)
+	}
+}
+
+func TestParseRequirementsTxt(t *testing.T) {
+	input := "# a comment\n" +
+		"requests==2.31.0\n" +
+		"Flask[async]==2.3.2  # inline comment\n" +
+		"numpy>=1.20\n" +
+		"-r base.txt\n"
+	got, err := ParseRequirementsTxt(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseRequirementsTxt: unexpected error %v", err)
+	}
+	want := []PackageURL{
+		{Type: "pypi", Name: "requests", Version: "2.31.0"},
+		{Type: "pypi", Name: "flask", Version: "2.3.2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRequirementsTxt(%q) = %+v, want %+v", input, got, want)
+	}
+}
+
+func TestParsePurlString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want PackageURL
+	}{
+		{"pkg:npm/lodash@4.17.20", PackageURL{Type: "npm", Name: "lodash", Version: "4.17.20"}},
+		{"pkg:golang/github.com/google/uuid@v1.3.0", PackageURL{Type: "golang", Namespace: "github.com/google", Name: "uuid", Version: "v1.3.0"}},
+		{"pkg:maven/org.apache.commons/commons-lang3@3.12.0", PackageURL{Type: "maven", Namespace: "org.apache.commons", Name: "commons-lang3", Version: "3.12.0"}},
+	}
+	for _, tt := range tests {
+		got, err := ParsePurlString(tt.in)
+		if err != nil {
+			t.Fatalf("ParsePurlString(%q): unexpected error %v", tt.in, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePurlString(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParsePurlString_notAPurl(t *testing.T) {
+	if _, err := ParsePurlString("not-a-purl"); err == nil {
+		t.Errorf("ParsePurlString(%q): expected an error, got nil", "not-a-purl")
+	}
+}
+
+func TestParseSPDXTagValue(t *testing.T) {
+	input := "SPDXVersion: SPDX-2.3\n" +
+		"PackageName: lodash\n" +
+		"PackageVersion: 4.17.20\n" +
+		"ExternalRef: PACKAGE-MANAGER purl pkg:npm/lodash@4.17.20\n" +
+		"\n" +
+		"PackageName: unresolved\n" +
+		"PackageVersion: 1.0.0\n"
+	got, err := ParseSPDXTagValue(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseSPDXTagValue: unexpected error %v", err)
+	}
+	want := []PackageURL{
+		{Type: "npm", Name: "lodash", Version: "4.17.20"},
+		{Name: "unresolved", Version: "1.0.0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSPDXTagValue(%q) = %+v, want %+v", input, got, want)
+	}
+}
+
+func TestParseSPDXJSON(t *testing.T) {
+	input := `{
+		"packages": [
+			{
+				"name": "lodash",
+				"versionInfo": "4.17.20",
+				"externalRefs": [
+					{"referenceCategory": "PACKAGE-MANAGER", "referenceType": "purl", "referenceLocator": "pkg:npm/lodash@4.17.20"}
+				]
+			},
+			{"name": "unresolved", "versionInfo": "1.0.0"}
+		]
+	}`
+	got, err := ParseSPDXJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseSPDXJSON: unexpected error %v", err)
+	}
+	want := []PackageURL{
+		{Type: "npm", Name: "lodash", Version: "4.17.20"},
+		{Name: "unresolved", Version: "1.0.0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseSPDXJSON(%q) = %+v, want %+v", input, got, want)
+	}
+}
+
+func TestParseCycloneDX(t *testing.T) {
+	input := `{
+		"bomFormat": "CycloneDX",
+		"specVersion": "1.4",
+		"version": 1,
+		"components": [
+			{"type": "library", "bom-ref": "lodash", "name": "lodash", "version": "4.17.20", "purl": "pkg:npm/lodash@4.17.20"}
+		]
+	}`
+	got, err := ParseCycloneDX(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseCycloneDX: unexpected error %v", err)
+	}
+	want := []PackageURL{{Type: "npm", Name: "lodash", Version: "4.17.20"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCycloneDX(%q) = %+v, want %+v", input, got, want)
+	}
+}
+
+func TestParsePomXML(t *testing.T) {
+	input := `<project>
+		<dependencies>
+			<dependency>
+				<groupId>org.apache.commons</groupId>
+				<artifactId>commons-lang3</artifactId>
+				<version>3.12.0</version>
+			</dependency>
+			<dependency>
+				<groupId>com.example</groupId>
+				<artifactId>unresolved</artifactId>
+				<version>${example.version}</version>
+			</dependency>
+		</dependencies>
+	</project>`
+	got, err := ParsePomXML(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParsePomXML: unexpected error %v", err)
+	}
+	want := []PackageURL{{Type: "maven", Namespace: "org.apache.commons", Name: "commons-lang3", Version: "3.12.0"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParsePomXML(%q) = %+v, want %+v", input, got, want)
+	}
+}
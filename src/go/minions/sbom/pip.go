@@ -0,0 +1,64 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package sbom
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ParseRequirementsTxt extracts one PackageURL per exactly-pinned
+// requirement ("name==version") in a pip requirements.txt file. Lines using
+// any other specifier (">=", "~=", ...), comments, blank lines and options
+// (e.g. "-r other.txt", "--hash=...") don't name a concrete version to scan
+// and are skipped.
+func ParseRequirementsTxt(r io.Reader) ([]PackageURL, error) {
+	var purls []PackageURL
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i]) // Strip a trailing inline comment.
+		}
+
+		parts := strings.SplitN(line, "==", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(stripExtras(parts[0]))
+		version := strings.TrimSpace(parts[1])
+		if name == "" || version == "" {
+			continue
+		}
+		purls = append(purls, PackageURL{Type: "pypi", Name: strings.ToLower(name), Version: version})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return purls, nil
+}
+
+// stripExtras removes a pip "extras" suffix, e.g. "requests[security]"
+// becomes "requests" - extras select optional dependencies, they aren't
+// part of the package's own identity.
+func stripExtras(name string) string {
+	if i := strings.Index(name, "["); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
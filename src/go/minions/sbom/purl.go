@@ -0,0 +1,132 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package sbom
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// PackageURL is a parsed subset of a Package URL (purl), see
+// https://github.com/package-url/purl-spec. Only the fields the manifest
+// parsers in this package actually populate (type, an optional namespace,
+// name and version) are modeled; qualifiers and subpath aren't needed here.
+type PackageURL struct {
+	Type      string // e.g. "golang", "npm", "pypi", "maven".
+	Namespace string // e.g. a Go module's host/org, or a Maven groupId. May be empty.
+	Name      string
+	Version   string
+}
+
+// Ecosystem returns the OSV ecosystem name (see osv.Package.Ecosystem) that
+// carries advisories for p, so callers can query a vuln.VulnDB directly
+// from a PackageURL.
+func (p PackageURL) Ecosystem() string {
+	switch p.Type {
+	case "golang":
+		return "Go"
+	case "npm":
+		return "npm"
+	case "pypi":
+		return "PyPI"
+	case "maven":
+		return "Maven"
+	default:
+		return ""
+	}
+}
+
+// PackageName returns the name OSV indexes p's ecosystem by: for Go and
+// Maven that includes the namespace (a module path or a groupId:artifactId
+// pair), for npm and PyPI it's the bare package name.
+func (p PackageURL) PackageName() string {
+	switch p.Type {
+	case "golang":
+		if p.Namespace != "" {
+			return p.Namespace + "/" + p.Name
+		}
+		return p.Name
+	case "maven":
+		if p.Namespace != "" {
+			return p.Namespace + ":" + p.Name
+		}
+		return p.Name
+	default:
+		return p.Name
+	}
+}
+
+// String renders p as a canonical purl string, e.g.
+// "pkg:golang/github.com/google/uuid@1.3.0" or "pkg:maven/org.apache.commons/commons-lang3@3.12.0".
+func (p PackageURL) String() string {
+	s := "pkg:" + p.Type + "/"
+	if p.Namespace != "" {
+		segments := strings.Split(p.Namespace, "/")
+		for i, seg := range segments {
+			segments[i] = url.PathEscape(seg)
+		}
+		s += strings.Join(segments, "/") + "/"
+	}
+	s += url.PathEscape(p.Name)
+	if p.Version != "" {
+		s += "@" + url.PathEscape(p.Version)
+	}
+	return s
+}
+
+// ParsePurlString parses a "pkg:type/[namespace/]name[@version]" purl
+// string - e.g. one read out of an ingested SPDX ExternalRef or CycloneDX
+// component (see spdx.go and cyclonedx.go) - into a PackageURL. Qualifiers
+// and subpath, if present, are dropped rather than modeled: see
+// PackageURL's doc comment for why.
+func ParsePurlString(s string) (PackageURL, error) {
+	const prefix = "pkg:"
+	if !strings.HasPrefix(s, prefix) {
+		return PackageURL{}, fmt.Errorf("sbom: %q is not a purl: missing %q prefix", s, prefix)
+	}
+	rest := s[len(prefix):]
+	if i := strings.IndexAny(rest, "?#"); i >= 0 {
+		rest = rest[:i]
+	}
+
+	slash := strings.IndexByte(rest, '/')
+	if slash < 0 {
+		return PackageURL{}, fmt.Errorf("sbom: %q is not a purl: missing type separator", s)
+	}
+	typ, rest := rest[:slash], rest[slash+1:]
+
+	var namespace string
+	if slash := strings.LastIndex(rest, "/"); slash >= 0 {
+		namespace, rest = rest[:slash], rest[slash+1:]
+	}
+
+	name, version := rest, ""
+	if at := strings.LastIndexByte(rest, '@'); at >= 0 {
+		name, version = rest[:at], rest[at+1:]
+	}
+
+	var err error
+	if namespace, err = url.PathUnescape(namespace); err != nil {
+		return PackageURL{}, fmt.Errorf("sbom: decoding purl namespace in %q: %v", s, err)
+	}
+	if name, err = url.PathUnescape(name); err != nil {
+		return PackageURL{}, fmt.Errorf("sbom: decoding purl name in %q: %v", s, err)
+	}
+	if version, err = url.PathUnescape(version); err != nil {
+		return PackageURL{}, fmt.Errorf("sbom: decoding purl version in %q: %v", s, err)
+	}
+
+	return PackageURL{Type: typ, Namespace: namespace, Name: name, Version: version}, nil
+}
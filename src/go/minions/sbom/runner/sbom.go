@@ -0,0 +1,40 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/google/minions/go/minions"
+	"github.com/google/minions/go/minions/osv"
+	"github.com/google/minions/go/minions/sbom"
+)
+
+var (
+	osvURL = flag.String("osv_url", "https://api.osv.dev", "Base URL of an osv.dev-compatible HTTP API")
+	osvDir = flag.String("osv_dir", "", "Directory of an extracted OSV export to use instead of -osv_url")
+)
+
+func newSource() osv.Source {
+	if *osvDir != "" {
+		return osv.NewFileSource(*osvDir)
+	}
+	return osv.NewHTTPSource(*osvURL)
+}
+
+func main() {
+	flag.Parse()
+	minions.StartMinion(sbom.NewMinion(osv.NewVulnClient(newSource())), "SBOM - Language ecosystem checker")
+}
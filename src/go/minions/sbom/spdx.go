@@ -0,0 +1,133 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package sbom
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// ParseSPDXTagValue extracts one PackageURL per Package section of an SPDX
+// 2.3 tag-value document - a package starts at its "PackageName:" tag and
+// runs until the next one (or EOF). A purl comes from the package's
+// "ExternalRef: <category> purl <locator>" line if it has one; lacking
+// that, a PackageURL is synthesized straight from PackageName/
+// PackageVersion, with no Type set, since SPDX doesn't otherwise tie a
+// package to an ecosystem the way a purl's type does.
+func ParseSPDXTagValue(r io.Reader) ([]PackageURL, error) {
+	var purls []PackageURL
+	var name, version, purl string
+
+	flush := func() {
+		if name == "" {
+			return
+		}
+		if purl != "" {
+			if p, err := ParsePurlString(purl); err == nil {
+				purls = append(purls, p)
+				return
+			}
+		}
+		purls = append(purls, PackageURL{Name: name, Version: version})
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		tag, value, ok := splitSPDXTag(scanner.Text())
+		if !ok {
+			continue
+		}
+		switch tag {
+		case "PackageName":
+			flush()
+			name, version, purl = value, "", ""
+		case "PackageVersion":
+			version = value
+		case "ExternalRef":
+			fields := strings.Fields(value)
+			if len(fields) == 3 && fields[1] == "purl" {
+				purl = fields[2]
+			}
+		}
+	}
+	flush()
+	return purls, scanner.Err()
+}
+
+// splitSPDXTag splits an SPDX tag-value line like "PackageName: foo" into
+// its tag and value, trimming surrounding whitespace. Lines that don't
+// carry a "tag: value" pair (blank lines, text-block continuations) report
+// ok=false and are skipped by the caller.
+func splitSPDXTag(line string) (tag, value string, ok bool) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), true
+}
+
+// spdxDocument is the root of an SPDX 2.3 JSON document; only the fields
+// ParseSPDXJSON reads are modeled.
+type spdxDocument struct {
+	Packages []spdxPackage `json:"packages"`
+}
+
+// spdxPackage is one entry of an SPDX document's "packages" array.
+type spdxPackage struct {
+	Name         string            `json:"name"`
+	VersionInfo  string            `json:"versionInfo"`
+	ExternalRefs []spdxExternalRef `json:"externalRefs"`
+}
+
+// spdxExternalRef is one entry of a package's "externalRefs" array; only a
+// "referenceType": "purl" entry's referenceLocator is used here.
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+// ParseSPDXJSON extracts one PackageURL per package in an SPDX 2.3 JSON
+// document - the JSON-serialized sibling of ParseSPDXTagValue, with the
+// same purl-first, name/version-fallback behavior.
+func ParseSPDXJSON(r io.Reader) ([]PackageURL, error) {
+	var doc spdxDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var purls []PackageURL
+	for _, pkg := range doc.Packages {
+		if pkg.Name == "" {
+			continue
+		}
+		var purl string
+		for _, ref := range pkg.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				purl = ref.ReferenceLocator
+				break
+			}
+		}
+		if purl != "" {
+			if p, err := ParsePurlString(purl); err == nil {
+				purls = append(purls, p)
+				continue
+			}
+		}
+		purls = append(purls, PackageURL{Name: pkg.Name, Version: pkg.VersionInfo})
+	}
+	return purls, nil
+}
@@ -0,0 +1,133 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package minions
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+var stateBucket = []byte("minion_state")
+
+// BoltStateManager is a StateManager backed by an embedded BoltDB file, so a
+// minion's per-scan state survives a process restart - suitable for a
+// single minion process, or a handful of them sharing one disk. Bolt only
+// stores bytes, so state is JSON-encoded; newState must return a fresh
+// pointer of whatever concrete type the minion stores, so Get and Update
+// have something to decode into.
+type BoltStateManager struct {
+	db       *bolt.DB
+	newState func() interface{}
+}
+
+// NewBoltStateManager opens (creating if needed) a BoltStateManager at
+// path. newState must return a new zero value of the minion's state type on
+// every call, e.g. `func() interface{} { return new(mstate) }`.
+func NewBoltStateManager(path string, newState func() interface{}) (*BoltStateManager, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("minions: opening %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(stateBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStateManager{db: db, newState: newState}, nil
+}
+
+// Close releases the underlying BoltDB file.
+func (b *BoltStateManager) Close() error {
+	return b.db.Close()
+}
+
+// Set atomically sets the state of a minion during a scan.
+func (b *BoltStateManager) Set(scanID string, state interface{}) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).Put([]byte(scanID), data)
+	})
+}
+
+// Get atomically retrieves the state of a minion during a scan.
+func (b *BoltStateManager) Get(scanID string) (interface{}, error) {
+	state := b.newState()
+	found := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(stateBucket).Get([]byte(scanID))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, state)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("Cannot find state for scan: %s", scanID)
+	}
+	return state, nil
+}
+
+// Has returns true if there is any set state for the given scan.
+func (b *BoltStateManager) Has(scanID string) bool {
+	has := false
+	b.db.View(func(tx *bolt.Tx) error {
+		has = tx.Bucket(stateBucket).Get([]byte(scanID)) != nil
+		return nil
+	})
+	return has
+}
+
+// Delete forgets scanID's state.
+func (b *BoltStateManager) Delete(scanID string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).Delete([]byte(scanID))
+	})
+}
+
+// Update implements StateManager. Bolt only allows one in-flight writable
+// transaction at a time, so running the whole read-modify-write inside a
+// single db.Update gives Update the atomicity it promises for free.
+func (b *BoltStateManager) Update(scanID string, fn func(state interface{}) (interface{}, error)) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(stateBucket)
+		data := bucket.Get([]byte(scanID))
+		var current interface{}
+		if data != nil {
+			current = b.newState()
+			if err := json.Unmarshal(data, current); err != nil {
+				return err
+			}
+		}
+		next, err := fn(current)
+		if err != nil {
+			return err
+		}
+		encoded, err := json.Marshal(next)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(scanID), encoded)
+	})
+}
@@ -0,0 +1,59 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package minions
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	stateBackend  = flag.String("state_backend", "local", "StateManager backend to use: local, bolt or redis")
+	stateBoltPath = flag.String("state_bolt_path", "", "Path to a BoltDB file to use with -state_backend=bolt")
+	redisAddr     = flag.String("redis_addr", "localhost:6379", "Redis address to use with -state_backend=redis")
+	redisStateTTL = flag.Duration("redis_state_ttl", 0, "Expire scan state stored with -state_backend=redis after this long; 0 keeps it until Delete is called")
+)
+
+// NewStateManagerFromFlags builds the StateManager selected by
+// -state_backend, so a minion binary can support every backend just by
+// calling this instead of wiring its own flags and switch (see
+// vulners/runner for the pre-existing example this supersedes). newState
+// must return a new zero value of the minion's state type on every call,
+// e.g. `func() interface{} { return new(mstate) }`; LocalStateManager
+// ignores it, since it keeps state as-is rather than round-tripping it
+// through an encoding.
+func NewStateManagerFromFlags(newState func() interface{}) StateManager {
+	switch *stateBackend {
+	case "local":
+		return NewLocalStateManager()
+	case "bolt":
+		if *stateBoltPath == "" {
+			log.Fatal("-state_bolt_path is required when -state_backend is bolt")
+		}
+		sm, err := NewBoltStateManager(*stateBoltPath, newState)
+		if err != nil {
+			log.Fatalf("opening %s: %v", *stateBoltPath, err)
+		}
+		return sm
+	case "redis":
+		client := redis.NewClient(&redis.Options{Addr: *redisAddr})
+		return NewRedisStateManagerWithTTL(client, newState, *redisStateTTL)
+	default:
+		log.Fatalf("unknown -state_backend %q", *stateBackend)
+		return nil
+	}
+}
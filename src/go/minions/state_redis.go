@@ -0,0 +1,132 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package minions
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// RedisStateManager is a StateManager backed by a Redis instance, so a
+// minion's per-scan state survives a process restart and can be shared by
+// several replicas of the same minion, unlike LocalStateManager or
+// BoltStateManager (single disk only). Like BoltStateManager, it needs a
+// newState factory to know what concrete type to decode stored state into.
+type RedisStateManager struct {
+	client   *redis.Client
+	newState func() interface{}
+	ttl      time.Duration
+}
+
+// NewRedisStateManager returns a RedisStateManager using client, with no
+// expiration on stored state (callers relying on bounded memory use should
+// either call Delete when a scan finishes, or use
+// NewRedisStateManagerWithTTL). newState must return a new zero value of
+// the minion's state type on every call, e.g.
+// `func() interface{} { return new(mstate) }`.
+func NewRedisStateManager(client *redis.Client, newState func() interface{}) *RedisStateManager {
+	return NewRedisStateManagerWithTTL(client, newState, 0)
+}
+
+// NewRedisStateManagerWithTTL is like NewRedisStateManager, but every Set or
+// Update expires the scan's state after ttl (via SET's EX/PX option) rather than
+// keeping it forever - a ttl of 0 means no expiration, matching
+// NewRedisStateManager.
+func NewRedisStateManagerWithTTL(client *redis.Client, newState func() interface{}, ttl time.Duration) *RedisStateManager {
+	return &RedisStateManager{client: client, newState: newState, ttl: ttl}
+}
+
+func (r *RedisStateManager) key(scanID string) string {
+	return "minions:state:" + scanID
+}
+
+// Set atomically sets the state of a minion during a scan.
+func (r *RedisStateManager) Set(scanID string, state interface{}) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(r.key(scanID), data, r.ttl).Err()
+}
+
+// Get atomically retrieves the state of a minion during a scan.
+func (r *RedisStateManager) Get(scanID string) (interface{}, error) {
+	data, err := r.client.Get(r.key(scanID)).Bytes()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("Cannot find state for scan: %s", scanID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := r.newState()
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Has returns true if there is any set state for the given scan.
+func (r *RedisStateManager) Has(scanID string) bool {
+	n, err := r.client.Exists(r.key(scanID)).Result()
+	return err == nil && n > 0
+}
+
+// Delete forgets scanID's state, letting a minion evict it immediately
+// instead of waiting on the TTL passed to NewRedisStateManagerWithTTL.
+func (r *RedisStateManager) Delete(scanID string) error {
+	return r.client.Del(r.key(scanID)).Err()
+}
+
+// Update implements StateManager, using Redis's WATCH/MULTI (via the
+// go-redis Watch helper) to retry the whole read-modify-write if another
+// caller touches the same key first - this is what keeps two concurrent
+// AnalyzeFiles calls for the same ScanId from losing an update to
+// mstate.packages.
+func (r *RedisStateManager) Update(scanID string, fn func(state interface{}) (interface{}, error)) error {
+	key := r.key(scanID)
+	for {
+		err := r.client.Watch(func(tx *redis.Tx) error {
+			data, err := tx.Get(key).Bytes()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			var current interface{}
+			if err != redis.Nil {
+				current = r.newState()
+				if err := json.Unmarshal(data, current); err != nil {
+					return err
+				}
+			}
+			next, err := fn(current)
+			if err != nil {
+				return err
+			}
+			encoded, err := json.Marshal(next)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+				pipe.Set(key, encoded, r.ttl)
+				return nil
+			})
+			return err
+		}, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+}
@@ -0,0 +1,78 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package minions
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/google/minions/go/throttle"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// scanIDGetter is implemented by every mpb request and chunk message that
+// carries a ScanId (AnalyzeFilesRequest, FileChunk, ...), letting the
+// interceptors below attribute inbound bandwidth to the same per-scan
+// throttle.Tracker key the Overlord's dispatch uses on the sending side
+// (see overlord.Server.SetThrottle).
+type scanIDGetter interface {
+	GetScanId() string
+}
+
+// scanIDOf returns m's ScanId if it implements scanIDGetter, or "" for a
+// message that doesn't carry one.
+func scanIDOf(m interface{}) string {
+	if g, ok := m.(scanIDGetter); ok {
+		return g.GetScanId()
+	}
+	return ""
+}
+
+// unaryThrottleInterceptor blocks a unary call (AnalyzeFiles) until
+// registry's Tracker for the request's ScanId allows its on-the-wire size
+// through, before handing it to the real handler.
+func unaryThrottleInterceptor(registry *throttle.Registry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if pm, ok := req.(proto.Message); ok {
+			registry.Get(scanIDOf(req)).Wait(int64(proto.Size(pm)))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// streamThrottleInterceptor wraps a streaming call (AnalyzeFilesStream) so
+// each FileChunk received is throttled individually against registry -
+// the receiver-side counterpart to the Overlord's scanFilesStreaming,
+// which paces its per-chunk sends the same way.
+func streamThrottleInterceptor(registry *throttle.Registry) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &throttledServerStream{ServerStream: ss, registry: registry})
+	}
+}
+
+// throttledServerStream wraps a grpc.ServerStream, throttling every
+// message as it's received instead of just once per call.
+type throttledServerStream struct {
+	grpc.ServerStream
+	registry *throttle.Registry
+}
+
+func (s *throttledServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if pm, ok := m.(proto.Message); ok {
+		s.registry.Get(scanIDOf(m)).Wait(int64(proto.Size(pm)))
+	}
+	return nil
+}
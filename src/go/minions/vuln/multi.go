@@ -0,0 +1,83 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package vuln
+
+import "golang.org/x/net/context"
+
+// Multi queries several Clients and merges their results, so a minion can
+// be backed by more than one vulnerability feed at once (e.g. Vulners for
+// CPE-based lookups and OSV for ecosystem packages) without having to know
+// which backend actually answered.
+type Multi struct {
+	Clients []Client
+}
+
+// NewMulti returns a Client that fans out to every one of clients.
+func NewMulti(clients ...Client) *Multi {
+	return &Multi{Clients: clients}
+}
+
+// FindForPackages implements Client by querying every backend and
+// deduplicating by ID, keeping the first result seen for a given ID. A
+// failing backend fails the whole call, same as a single Client would.
+func (m *Multi) FindForPackages(ctx context.Context, os, osVersion string, pkgs []string) ([]Vulnerability, error) {
+	var all []Vulnerability
+	for _, c := range m.Clients {
+		found, err := c.FindForPackages(ctx, os, osVersion, pkgs)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, found...)
+	}
+	return dedup(all), nil
+}
+
+// FindForCPE implements Client the same way FindForPackages does.
+func (m *Multi) FindForCPE(ctx context.Context, cpe string, max int) ([]Vulnerability, error) {
+	var all []Vulnerability
+	for _, c := range m.Clients {
+		found, err := c.FindForCPE(ctx, cpe, max)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, found...)
+	}
+	return dedup(all), nil
+}
+
+// dedup collapses vulnerabilities that share an ID or an alias (e.g. the
+// same issue reported as both a Vulners bulletin ID and a CVE ID), keeping
+// whichever copy was seen first.
+func dedup(vulns []Vulnerability) []Vulnerability {
+	seen := make(map[string]bool)
+	var out []Vulnerability
+	for _, v := range vulns {
+		keys := append([]string{v.ID}, v.Aliases...)
+		duplicate := false
+		for _, k := range keys {
+			if seen[k] {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		for _, k := range keys {
+			seen[k] = true
+		}
+		out = append(out, v)
+	}
+	return out
+}
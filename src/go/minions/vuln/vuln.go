@@ -0,0 +1,113 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+/*
+Package vuln defines a vendor-neutral abstraction over vulnerability feed
+backends, so package-scanning minions don't have to be written against one
+specific API's response shape.
+
+See the vulners, osv and vulndb packages for the Client implementations this
+repo ships, and Multi for fanning a query out to several of them at once.
+*/
+package vuln
+
+import "golang.org/x/net/context"
+
+// Severity buckets a Vulnerability's impact the way most vulnerability
+// feeds (and pb.Finding) do, rather than forcing every caller to reason
+// about raw CVSS scores.
+type Severity int
+
+// Severity buckets, ordered from least to most severe.
+const (
+	SeverityUnknown Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityLow:
+		return "LOW"
+	case SeverityMedium:
+		return "MEDIUM"
+	case SeverityHigh:
+		return "HIGH"
+	case SeverityCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// SeverityFromCVSSScore buckets a CVSS base score (0.0-10.0) into a
+// Severity, using the ranges published in the FIRST CVSS specification.
+func SeverityFromCVSSScore(score float32) Severity {
+	switch {
+	case score <= 0:
+		return SeverityUnknown
+	case score < 4.0:
+		return SeverityLow
+	case score < 7.0:
+		return SeverityMedium
+	case score < 9.0:
+		return SeverityHigh
+	default:
+		return SeverityCritical
+	}
+}
+
+// Vulnerability is a single vulnerability record, normalized across
+// backends: whatever a Client returns, callers only ever see this shape.
+type Vulnerability struct {
+	ID         string   // Backend-specific identifier, e.g. a Vulners bulletin ID or an OSV ID.
+	Aliases    []string // Other identifiers for the same issue, e.g. CVE IDs.
+	Package    string   // Name of the affected package, for FindForPackages results.
+	CVSSVector string   // Raw CVSS vector string, if the backend provided one.
+	CVSSScore  float32  // CVSS base score, if known.
+	FixedIn    string   // Version the issue is fixed in, if known.
+	References []string
+	Severity   Severity
+
+	// Source names whatever backend actually produced this result, e.g. a
+	// ScannerAdapter's Name() in the vulners minion's adapter framework.
+	// Left empty by a bare Client, which callers are expected to attribute
+	// to themselves instead.
+	Source string
+}
+
+// Client finds known vulnerabilities for installed software, independent of
+// the backend (Vulners, OSV, ...) behind it.
+type Client interface {
+	// FindForPackages returns vulnerabilities known to affect any of pkgs,
+	// given the OS/distro and version they were installed on.
+	FindForPackages(ctx context.Context, os, osVersion string, pkgs []string) ([]Vulnerability, error)
+	// FindForCPE returns vulnerabilities known to affect the software
+	// identified by cpe (a CPE 2.3 URI), up to max results.
+	FindForCPE(ctx context.Context, cpe string, max int) ([]Vulnerability, error)
+}
+
+// VulnDB finds known vulnerabilities for a single ecosystem package (e.g.
+// an npm or Go module dependency named by a Package URL), the
+// ecosystem-keyed counterpart to Client's distro-keyed OS package lookups.
+// The sbom minion depends on this rather than a concrete backend, so it
+// can plug in OSV, Vulners, or a static feed the same way the vulners
+// minion does through Client.
+type VulnDB interface {
+	// Lookup returns vulnerabilities known to affect pkg at version within
+	// ecosystem (e.g. "npm", "Go", "PyPI", "Maven" - see the OSV schema's
+	// ecosystem list, which this repo's ecosystems are drawn from).
+	Lookup(ctx context.Context, ecosystem, pkg, version string) ([]Vulnerability, error)
+}
@@ -0,0 +1,252 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package vulndb
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"github.com/google/minions/go/minions/osv"
+)
+
+var (
+	recordsByPkgBucket = []byte("records_by_package")
+	recordsByCVEBucket = []byte("records_by_cve")
+	recordsBySource    = []byte("records_by_source")
+	sourceStateBucket  = []byte("source_state")
+)
+
+// BoltStore is a Store backed by an embedded BoltDB file, suitable for a
+// single minion process or a handful of them sharing one disk - there's no
+// network hop, but it doesn't coordinate across machines. See
+// vulndb/runner for the CLI that populates one of these offline.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens (creating if needed) a BoltStore at path.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("vulndb: opening %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{recordsByPkgBucket, recordsByCVEBucket, recordsBySource, sourceStateBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Close implements Store.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// Put implements Store. It first removes every record previously written
+// by source (tracked via recordsBySource), so a Fetcher that stops
+// reporting an issue (because it was withdrawn, or the feed now considers
+// the affected version range empty) doesn't leave a stale Record behind.
+func (s *BoltStore) Put(source string, records []Record) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := removeSourceRecords(tx, source); err != nil {
+			return err
+		}
+		var ids []string
+		for _, r := range records {
+			r.Source = source
+			if err := putRecord(tx, r); err != nil {
+				return err
+			}
+			ids = append(ids, r.ID)
+		}
+		data, err := json.Marshal(ids)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(recordsBySource).Put([]byte(source), data)
+	})
+}
+
+func removeSourceRecords(tx *bolt.Tx, source string) error {
+	data := tx.Bucket(recordsBySource).Get([]byte(source))
+	if data == nil {
+		return nil
+	}
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := removeRecordByID(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeRecordByID scans both indexes for occurrences of id. Bolt has no
+// secondary indexes, so this is a full bucket scan; it only runs once per
+// Fetcher run, not per lookup, so the cost is acceptable.
+func removeRecordByID(tx *bolt.Tx, id string) error {
+	for _, bucketName := range [][]byte{recordsByPkgBucket, recordsByCVEBucket} {
+		b := tx.Bucket(bucketName)
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			records, err := decodeRecords(v)
+			if err != nil {
+				return err
+			}
+			filtered := records[:0]
+			for _, r := range records {
+				if r.ID != id {
+					filtered = append(filtered, r)
+				}
+			}
+			if len(filtered) == len(records) {
+				continue
+			}
+			if len(filtered) == 0 {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+				continue
+			}
+			data, err := json.Marshal(filtered)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(k, data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func putRecord(tx *bolt.Tx, r Record) error {
+	pkgKey := []byte(r.Ecosystem + "/" + r.Package)
+	if err := appendRecord(tx.Bucket(recordsByPkgBucket), pkgKey, r); err != nil {
+		return err
+	}
+	for _, id := range append([]string{r.ID}, r.Aliases...) {
+		if err := appendRecord(tx.Bucket(recordsByCVEBucket), []byte(id), r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendRecord(b *bolt.Bucket, key []byte, r Record) error {
+	existing, err := decodeRecords(b.Get(key))
+	if err != nil {
+		return err
+	}
+	existing = append(existing, r)
+	data, err := json.Marshal(existing)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+func decodeRecords(data []byte) ([]Record, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// FindForPackage implements Store.
+func (s *BoltStore) FindForPackage(ecosystem, name, version string) ([]Record, error) {
+	var records []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(recordsByPkgBucket).Get([]byte(ecosystem + "/" + name))
+		all, err := decodeRecords(data)
+		if err != nil {
+			return err
+		}
+		for _, r := range all {
+			if recordAffectsVersion(ecosystem, r, version) {
+				records = append(records, r)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// recordAffectsVersion reports whether version falls within [r.Introduced,
+// r.Fixed), ordering versions the way ecosystem does. An empty or "0"
+// Introduced means "affected since the beginning"; an empty Fixed means
+// "still affected".
+func recordAffectsVersion(ecosystem string, r Record, version string) bool {
+	if r.Introduced != "" && r.Introduced != "0" && osv.CompareVersions(ecosystem, version, r.Introduced) < 0 {
+		return false
+	}
+	if r.Fixed != "" && osv.CompareVersions(ecosystem, version, r.Fixed) >= 0 {
+		return false
+	}
+	return true
+}
+
+// FindByCVE implements Store.
+func (s *BoltStore) FindByCVE(cveID string) ([]Record, error) {
+	var records []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(recordsByCVEBucket).Get([]byte(cveID))
+		var err error
+		records, err = decodeRecords(data)
+		return err
+	})
+	return records, err
+}
+
+// SourceState implements Store.
+func (s *BoltStore) SourceState(source string) (SourceState, bool, error) {
+	var state SourceState
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sourceStateBucket).Get([]byte(source))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &state)
+	})
+	return state, found, err
+}
+
+// SetSourceState implements Store.
+func (s *BoltStore) SetSourceState(source string, state SourceState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sourceStateBucket).Put([]byte(source), data)
+	})
+}
@@ -0,0 +1,92 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package vulndb
+
+import (
+	"time"
+
+	"github.com/google/minions/go/minions/osv"
+	"golang.org/x/net/context"
+)
+
+// OSVFetcher is a Fetcher that reads every entry out of an extracted OSV
+// export directory (the layout osv.FileSource also reads) and flattens it
+// into Records. It doesn't support incremental updates - OSV exports
+// aren't individually ETagged - so it rereads and replaces the whole set
+// on every run.
+type OSVFetcher struct {
+	Dir string
+}
+
+// NewOSVFetcher returns an OSVFetcher reading OSV entries from dir.
+func NewOSVFetcher(dir string) *OSVFetcher {
+	return &OSVFetcher{Dir: dir}
+}
+
+// Name implements Fetcher.
+func (f *OSVFetcher) Name() string { return "osv" }
+
+// Fetch implements Fetcher.
+func (f *OSVFetcher) Fetch(ctx context.Context, prev SourceState) ([]Record, SourceState, error) {
+	entries, err := osv.ListEntries(f.Dir)
+	if err != nil {
+		return nil, prev, err
+	}
+	var records []Record
+	for _, e := range entries {
+		records = append(records, recordsFromEntry(e)...)
+	}
+	return records, SourceState{LastUpdated: time.Now()}, nil
+}
+
+// recordsFromEntry flattens a single OSV Entry into one Record per
+// Affected/Range combination, since a Store Record only covers a single
+// package's range.
+func recordsFromEntry(e *osv.Entry) []Record {
+	var vector string
+	var score float32
+	for _, sev := range e.Severity {
+		if sev.Type != "CVSS_V3" {
+			continue
+		}
+		vector = sev.Score
+		if s, ok := osv.CVSSv3BaseScore(sev.Score); ok {
+			score = s
+		}
+		break
+	}
+	var records []Record
+	for _, aff := range e.Affected {
+		for _, r := range aff.Ranges {
+			rec := Record{
+				ID:         e.ID,
+				Aliases:    e.Aliases,
+				Ecosystem:  aff.Package.Ecosystem,
+				Package:    aff.Package.Name,
+				CVSSVector: vector,
+				CVSSScore:  score,
+			}
+			for _, ev := range r.Events {
+				switch {
+				case ev.Introduced != "":
+					rec.Introduced = ev.Introduced
+				case ev.Fixed != "":
+					rec.Fixed = ev.Fixed
+				}
+			}
+			records = append(records, rec)
+		}
+	}
+	return records
+}
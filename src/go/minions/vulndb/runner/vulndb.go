@@ -0,0 +1,69 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+/*
+Command vulndb bootstraps and refreshes the local vulnerability database
+minions consult through vulndb.VulnClient.
+
+Usage:
+
+	minions vulndb update -db /var/lib/minions/vulndb.bolt -osv_dir /path/to/osv/export
+
+Run it once before scanning offline, or on a cron alongside a long-running
+Overlord so its minions always see a reasonably fresh local cache.
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/google/minions/go/minions/vulndb"
+)
+
+var (
+	dbPath = flag.String("db", "vulndb.bolt", "Path to the local BoltDB vulnerability database")
+	osvDir = flag.String("osv_dir", "", "Directory holding an extracted OSV export (*.json per entry)")
+)
+
+func main() {
+	flag.Parse()
+	// flag.Parse stops at the first non-flag argument, so flags that follow
+	// the subcommand (as in the usage line) are parsed here.
+	subcommand := flag.Arg(0)
+	if flag.NArg() > 0 {
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	if subcommand != "update" || flag.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: vulndb update -db PATH -osv_dir DIR")
+		os.Exit(2)
+	}
+	if *osvDir == "" {
+		log.Fatal("-osv_dir is required")
+	}
+
+	store, err := vulndb.OpenBoltStore(*dbPath)
+	if err != nil {
+		log.Fatalf("opening %s: %v", *dbPath, err)
+	}
+	defer store.Close()
+
+	updater := vulndb.NewUpdater(store, 0, vulndb.NewOSVFetcher(*osvDir))
+	if err := updater.UpdateOnce(context.Background()); err != nil {
+		log.Fatalf("update failed: %v", err)
+	}
+	log.Printf("vulndb: %s is up to date", *dbPath)
+}
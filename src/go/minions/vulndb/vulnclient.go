@@ -0,0 +1,118 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package vulndb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/minions/go/minions/vuln"
+	"golang.org/x/net/context"
+)
+
+// ecosystemForOS maps the distro identifiers minions derive from
+// /etc/os-release (lower-cased, first word only, see FindForPackages) to the
+// ecosystem name Records are stored under. It holds the Debian, Ubuntu and
+// Alpine entries of osv.ecosystemForOS; the two packages key packages by
+// ecosystem the same way, since both ultimately draw on OSV-shaped data.
+var ecosystemForOS = map[string]string{
+	"debian": "Debian",
+	"ubuntu": "Ubuntu",
+	"alpine": "Alpine",
+}
+
+// VulnClient adapts a Store to the vendor-neutral vuln.Client interface, so
+// a minion can look up vulnerabilities against the local database exactly
+// as it would against a remote backend - the whole point being that the
+// lookup never leaves the box.
+type VulnClient struct {
+	Store Store
+}
+
+// NewVulnClient returns a vuln.Client backed by store.
+func NewVulnClient(store Store) *VulnClient {
+	return &VulnClient{Store: store}
+}
+
+// FindForPackages implements vuln.Client, matching entirely against the
+// local Store: no network hop, no rate limit.
+func (c *VulnClient) FindForPackages(ctx context.Context, os, osVersion string, pkgs []string) ([]vuln.Vulnerability, error) {
+	key := strings.ToLower(os)
+	if fields := strings.Fields(key); len(fields) > 0 {
+		key = fields[0]
+	}
+	ecosystem, ok := ecosystemForOS[key]
+	if !ok {
+		return nil, fmt.Errorf("vulndb: no ecosystem known for OS %q", os)
+	}
+	var vulns []vuln.Vulnerability
+	for _, pkg := range pkgs {
+		name, version := splitNameVersion(pkg)
+		records, err := c.Store.FindForPackage(ecosystem, name, version)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			v := convertRecord(r)
+			v.Package = name
+			vulns = append(vulns, v)
+		}
+	}
+	return vulns, nil
+}
+
+// FindForCPE implements vuln.Client. The local Store is keyed by
+// ecosystem/package, not CPE, so this backend can't answer CPE lookups;
+// callers that need both should fan out via vuln.Multi alongside a
+// CPE-capable backend like Vulners.
+func (c *VulnClient) FindForCPE(ctx context.Context, cpe string, max int) ([]vuln.Vulnerability, error) {
+	return nil, fmt.Errorf("vulndb: FindForCPE is not supported, the local store has no CPE index")
+}
+
+// splitNameVersion takes the name and version out of the "name version arch"
+// package strings the vulners minion's dpkg scanner produces, since that's
+// the format pkgs arrives in from findingsForState. An rpm
+// "name-version-release.arch" string has no spaces, so it comes back whole
+// as name with an empty version.
+func splitNameVersion(pkg string) (name, version string) {
+	fields := strings.Fields(pkg)
+	switch len(fields) {
+	case 0:
+		return "", ""
+	case 1:
+		return fields[0], ""
+	}
+	return fields[0], fields[1]
+}
+
+func convertRecord(r Record) vuln.Vulnerability {
+	v := vuln.Vulnerability{
+		ID:         r.ID,
+		Aliases:    r.Aliases,
+		CVSSVector: r.CVSSVector,
+		CVSSScore:  r.CVSSScore,
+		FixedIn:    r.Fixed,
+		Severity:   vuln.SeverityFromCVSSScore(r.CVSSScore),
+	}
+	for _, alias := range r.Aliases {
+		v.References = append(v.References, cveRef(alias))
+	}
+	return v
+}
+
+// cveRef builds a reference link for a single CVE/alias ID, mirroring what
+// vulners.cveReferences does for Vulners bulletins.
+func cveRef(id string) string {
+	return "https://nvd.nist.gov/vuln/detail/" + strings.TrimSpace(id)
+}
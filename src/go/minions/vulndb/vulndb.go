@@ -0,0 +1,158 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+/*
+Package vulndb maintains a local, periodically-refreshed cache of
+vulnerability records, so package-scanning minions can match packages
+against known vulnerabilities without a network round-trip (or a Vulners
+rate limit) on every scan.
+
+The split mirrors Clair's updater/datastore design: a Store persists
+records and per-source fetch state, one or more Fetchers pull normalized
+records from upstream feeds, and an Updater runs the fetchers on a
+schedule and writes their output to the Store. See BoltStore for the
+on-disk implementation and VulnClient for how a minion consults the
+Store through the vendor-neutral vuln.Client interface.
+*/
+package vulndb
+
+import (
+	"log"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Record is a single vulnerability as stored locally: enough to match it
+// against an installed package without going back to the source feed.
+type Record struct {
+	ID         string   // e.g. a CVE, GHSA or distro advisory ID.
+	Aliases    []string // Other IDs for the same issue.
+	Ecosystem  string   // e.g. "Go", "npm", "Debian" - see osv.Package.Ecosystem.
+	Package    string   // Package name within Ecosystem.
+	Introduced string   // Version the issue first affects ("0" means "all versions").
+	Fixed      string   // Version it's fixed in, if known.
+	CVSSVector string
+	CVSSScore  float32
+	Source     string // Name of the Fetcher that produced this record.
+}
+
+// SourceState tracks how far a Fetcher has progressed, so the Updater can
+// ask it for only what changed since last time.
+type SourceState struct {
+	LastUpdated time.Time
+	ETag        string
+}
+
+// Store persists Records and the SourceState of whatever Fetchers wrote
+// them. Implementations must be safe for concurrent use: the Updater and a
+// minion's lookups both hit it at once.
+type Store interface {
+	// Put replaces the set of Records a single Fetcher run produced.
+	Put(source string, records []Record) error
+	// FindForPackage returns every Record known to affect ecosystem/name
+	// whose range contains version, using ecosystem-appropriate version
+	// ordering (see osv.comparatorFor for the algorithms this mirrors).
+	FindForPackage(ecosystem, name, version string) ([]Record, error)
+	// FindByCVE returns every Record whose ID or Aliases includes cveID.
+	FindByCVE(cveID string) ([]Record, error)
+	// SourceState returns the last recorded state for source, and false if
+	// the source has never been fetched.
+	SourceState(source string) (SourceState, bool, error)
+	// SetSourceState records state as the latest state for source.
+	SetSourceState(source string, state SourceState) error
+	// Close releases any resources the Store holds open.
+	Close() error
+}
+
+// Fetcher pulls vulnerability records from a single upstream feed.
+type Fetcher interface {
+	// Name identifies the feed, e.g. "osv", "debian-security-tracker".
+	Name() string
+	// Fetch returns every record currently known by the feed, plus the
+	// SourceState to persist and hand back on the next call. prev is the
+	// state from the last successful fetch (its zero value on the first
+	// ever run), so a Fetcher that supports incremental updates (e.g. via
+	// ETag) can use it to fetch only what changed.
+	Fetch(ctx context.Context, prev SourceState) ([]Record, SourceState, error)
+}
+
+// Updater periodically runs a set of Fetchers and writes their output to a
+// Store, so minions always have a reasonably fresh local vulnerability
+// cache to query.
+type Updater struct {
+	Store    Store
+	Fetchers []Fetcher
+	Interval time.Duration
+}
+
+// NewUpdater returns an Updater that refreshes store from fetchers every
+// interval.
+func NewUpdater(store Store, interval time.Duration, fetchers ...Fetcher) *Updater {
+	return &Updater{Store: store, Fetchers: fetchers, Interval: interval}
+}
+
+// Start runs UpdateOnce immediately, then every Interval, until ctx is
+// done. Fetcher errors are logged, not fatal, so one bad upstream feed
+// doesn't stop the others from refreshing.
+func (u *Updater) Start(ctx context.Context) {
+	u.updateAndLog(ctx)
+	ticker := time.NewTicker(u.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			u.updateAndLog(ctx)
+		}
+	}
+}
+
+func (u *Updater) updateAndLog(ctx context.Context) {
+	if err := u.UpdateOnce(ctx); err != nil {
+		log.Printf("vulndb: update failed: %v", err)
+	}
+}
+
+// UpdateOnce runs every Fetcher once and writes its records to the Store.
+// It keeps going if one Fetcher fails, returning the last error seen, so a
+// single broken feed doesn't block the others from refreshing.
+func (u *Updater) UpdateOnce(ctx context.Context) error {
+	var lastErr error
+	for _, f := range u.Fetchers {
+		prev, _, err := u.Store.SourceState(f.Name())
+		if err != nil {
+			lastErr = err
+			log.Printf("vulndb: reading state for %s: %v", f.Name(), err)
+			continue
+		}
+		records, next, err := f.Fetch(ctx, prev)
+		if err != nil {
+			lastErr = err
+			log.Printf("vulndb: fetching %s: %v", f.Name(), err)
+			continue
+		}
+		if err := u.Store.Put(f.Name(), records); err != nil {
+			lastErr = err
+			log.Printf("vulndb: storing records from %s: %v", f.Name(), err)
+			continue
+		}
+		if err := u.Store.SetSourceState(f.Name(), next); err != nil {
+			lastErr = err
+			log.Printf("vulndb: storing state for %s: %v", f.Name(), err)
+		}
+		log.Printf("vulndb: %s: wrote %d records", f.Name(), len(records))
+	}
+	return lastErr
+}
@@ -0,0 +1,89 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+/*
+Package apk reads package information in the format of the Alpine Linux
+/lib/apk/db/installed file.
+
+An apk installed-db file is a sequence of RFC822-like stanzas, one per
+installed package, separated by a blank line. Unlike dpkg's status file,
+each key is a single letter rather than a word (P for package name, V for
+version, A for architecture, ...) and values are never folded onto
+continuation lines, so stanzas can be read line by line.
+
+Example:
+
+P:musl
+V:1.2.3-r4
+A:x86_64
+T:the musl c library
+
+P:next
+...
+*/
+package apk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Entry represents information about a single installed package, keyed by
+// the database's single-letter field codes (e.g. "P", "V", "A") rather than
+// expanded names.
+type Entry map[string]string
+
+// A Scanner reads Entries from an apk installed-db file.
+type Scanner struct {
+	scanner *bufio.Scanner
+	done    bool
+}
+
+// NewScanner returns a new Scanner that reads from r.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{scanner: bufio.NewScanner(r)}
+}
+
+// Scan reads one Entry (one installed package) from s.
+// A successful call returns err == nil. It returns io.EOF as error when
+// the scanner has reached end of file.
+func (s *Scanner) Scan() (Entry, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	entry := make(Entry)
+	sawLine := false
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if line == "" {
+			return entry, nil
+		}
+		sawLine = true
+
+		if len(line) < 2 || line[1] != ':' {
+			return nil, fmt.Errorf("apk: malformed field %q", line)
+		}
+		entry[line[:1]] = line[2:]
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	s.done = true
+	if !sawLine {
+		return nil, io.EOF
+	}
+	return entry, nil
+}
@@ -0,0 +1,106 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package apk
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var scanTests = []struct {
+	Name   string
+	Input  string
+	Output Entry
+	Error  bool
+}{
+	{
+		Name: "correct entry",
+		Input: "P:musl\n" +
+			"V:1.2.3-r4\n" +
+			"A:x86_64\n" +
+			"T:the musl c library\n" +
+			"\n",
+		Output: Entry{
+			"P": "musl",
+			"V": "1.2.3-r4",
+			"A": "x86_64",
+			"T": "the musl c library",
+		},
+	},
+	{
+		Name: "multiple entries",
+		Input: "P:first\n" +
+			"V:1.0.0-r0\n" +
+			"A:x86_64\n" +
+			"\n" +
+			"P:second\n" +
+			"V:2.0.0-r0\n" +
+			"A:x86_64\n" +
+			"\n",
+		Output: Entry{
+			"P": "first",
+			"V": "1.0.0-r0",
+			"A": "x86_64",
+		},
+	},
+	{
+		Name: "missing trailing blank line",
+		Input: "P:musl\n" +
+			"V:1.2.3-r4\n",
+		Output: Entry{
+			"P": "musl",
+			"V": "1.2.3-r4",
+		},
+	},
+	{
+		Name:  "malformed field",
+		Input: "this is not a field\n",
+		Error: true,
+	},
+	{
+		Name:  "empty stream",
+		Input: "",
+		Error: true,
+	},
+}
+
+func TestScan(t *testing.T) {
+	for _, tt := range scanTests {
+		s := NewScanner(strings.NewReader(tt.Input))
+		out, err := s.Scan()
+		if tt.Error {
+			if err == nil {
+				t.Errorf("%v: expected error, got none", tt.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: didn't expect error, got %v", tt.Name, err)
+		} else if !reflect.DeepEqual(tt.Output, out) {
+			t.Errorf("%v, have \n%v\n want \n%v", tt.Name, out, tt.Output)
+		}
+	}
+}
+
+func TestScan_exhausted_returnsEOF(t *testing.T) {
+	s := NewScanner(strings.NewReader("P:musl\nV:1.2.3-r4\n\n"))
+	if _, err := s.Scan(); err != nil {
+		t.Fatalf("first Scan: unexpected error %v", err)
+	}
+	if _, err := s.Scan(); err != io.EOF {
+		t.Errorf("second Scan: got %v, want io.EOF", err)
+	}
+}
@@ -23,6 +23,7 @@ package vulners
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -32,6 +33,8 @@ import (
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/google/minions/go/minions"
+	"github.com/google/minions/go/minions/vuln"
+	"github.com/google/minions/go/minions/vulners/apk"
 	"github.com/google/minions/go/minions/vulners/dpkg"
 	"github.com/google/minions/go/minions/vulners/rpm"
 	pb "github.com/google/minions/proto/minions"
@@ -41,29 +44,76 @@ import (
 // Minion that performs checks for known vulnerabilities in the software
 // installed on the box.
 type Minion struct {
-	apiClient VulnerabilityClient // API client to fetch vulnerabilities
-	state     minions.StateManager
+	adapters       []ScannerAdapter // Backends to dispatch parsed Packages to, see RegisterAdapter.
+	state          minions.StateManager
+	aggregateByCVE bool // Collapse findings that share a CVE into one, see NewMinion.
 }
 
-// VulnerabilityClient is a client to fetch vulnerability data for a set of packages
-// given an operating system and version
-type VulnerabilityClient interface {
-	GetVulnerabilitiesForPackages(string, string, []string) (*VulnResponse, error)
+// RegisterAdapter adds adapter to m's registry, so packages from any
+// ecosystem it declares support for are also scanned through it - letting a
+// caller bring their own scanner (OSV.dev, Trivy, an offline NVD mirror, ...)
+// without patching this package. Adapters are queried in registration order;
+// NewMinion/NewMinionWithClient register the Vulners-backed one first.
+func (m *Minion) RegisterAdapter(adapter ScannerAdapter) {
+	m.adapters = append(m.adapters, adapter)
 }
 
-// state represents the internal state of the minions, used to track
-// files of the same. It is always associated to a ScanID.
+// mstate represents the internal state of the minions, used to track files
+// of the same. It is always associated to a ScanID. Its fields are exported
+// so that a persistent StateManager (minions.BoltStateManager,
+// minions.RedisStateManager) can JSON-encode it across a process restart.
 type mstate struct {
-	version  string
-	distro   string
-	packages []string
+	Version  string
+	Distro   string
+	Packages []string
+
+	// ApkPackages holds Alpine's apk packages separately from Packages:
+	// Vulners' /audit/audit endpoint (used by FindForPackages) only
+	// understands dpkg/rpm-based distros, so these are matched one at a
+	// time by CPE against /burp/software (FindForCPE) instead - see
+	// findForApkPackages.
+	ApkPackages []string
+
+	// Architectures maps a package name to the comma-separated list of
+	// architectures dpkg recorded it installed under, for packages marked
+	// Multi-Arch: same - see getDpkgPackages. Only those packages are
+	// present here; a single-architecture package is fully described by
+	// its entry in Packages already.
+	Architectures map[string]string
+}
+
+// NewMstate returns a fresh, empty mstate as an interface{}, for use as a
+// minions.StateManager's newState factory.
+func NewMstate() interface{} {
+	return new(mstate)
 }
 
 // NewMinion creates a default vulners minion that connects to Vulners default
 // API endpoints. It accepts an optional apiKey parameter which specifies which
-// key to use when querying the Vulners APIs.
-func NewMinion(apiKey string) *Minion {
-	return &Minion{newClient(apiKey), minions.NewLocalStateManager()}
+// key to use when querying the Vulners APIs. If aggregateByCVE is set,
+// Findings that share a CVE (as Vulners commonly reports several bulletins
+// for the same CVE against slightly different package/version pairs) are
+// collapsed into a single Finding listing every affected package, the way
+// tools like grype present results. state keeps track of per-scan progress;
+// pass minions.NewLocalStateManager() unless the scan needs to survive an
+// Overlord restart, in which case use minions.NewBoltStateManager or
+// minions.NewRedisStateManager instead (constructed with NewMstate as their
+// newState factory). Use NewMinionWithClient instead of NewMinion to back it
+// with a different vuln.Client, e.g. OSV or a vuln.Multi of both.
+func NewMinion(apiKey string, aggregateByCVE bool, state minions.StateManager) *Minion {
+	return NewMinionWithClient(NewVulnClient(apiKey), aggregateByCVE, state)
+}
+
+// NewMinionWithClient creates a vulners minion backed by an arbitrary
+// vuln.Client, which is mainly useful in tests: inject a fake implementation
+// there to exercise the minion without a network round-trip. See NewMinion
+// for what aggregateByCVE and state do. The client is registered as the
+// minion's sole ScannerAdapter, named "Vulners"; call RegisterAdapter
+// afterwards to scan with additional backends too.
+func NewMinionWithClient(client vuln.Client, aggregateByCVE bool, state minions.StateManager) *Minion {
+	m := &Minion{state: state, aggregateByCVE: aggregateByCVE}
+	m.RegisterAdapter(NewVulnClientAdapter("Vulners", client))
+	return m
 }
 
 // ListInitialInterests returns a list of files which might contain
@@ -73,8 +123,14 @@ func (m Minion) ListInitialInterests(ctx context.Context, req *pb.ListInitialInt
 	osReleaseUsrLib := interest("/usr/lib/os-release") // Alternative location for the OS release
 	dpkSstatus := interest("/var/lib/dpkg/status")     // DPKG repo (for debian-like).
 	rpmDatabase := interest("/var/lib/rpm/Packages")   // RPM database
-	interests := []*pb.Interest{&dpkSstatus, &osReleaseEtc, &osReleaseUsrLib, &rpmDatabase}
-	return &pb.ListInitialInterestsResponse{Interests: interests}, nil
+	apkDatabase := interest("/lib/apk/db/installed")   // APK repo (for Alpine).
+	interests := []*pb.Interest{&dpkSstatus, &osReleaseEtc, &osReleaseUsrLib, &rpmDatabase, &apkDatabase}
+	return &pb.ListInitialInterestsResponse{
+		Interests: interests,
+		// Package databases on a real system can run tens of megabytes; tell
+		// the Overlord to feed us files via AnalyzeFilesStream instead.
+		SupportsStreaming: true,
+	}, nil
 }
 
 func interest(name string) pb.Interest {
@@ -89,8 +145,8 @@ func (m Minion) AnalyzeFiles(ctx context.Context, req *pb.AnalyzeFilesRequest) (
 	// TODO(paradoxengine): add decent error management
 
 	// Init with an empty state if needed.
-	if !m.state.Has(req.GetScanId()) {
-		m.state.Set(req.GetScanId(), &mstate{})
+	if err := m.initState(req.GetScanId()); err != nil {
+		return nil, err
 	}
 
 	// Main loop, builds the state and parses all incoming files.
@@ -123,125 +179,341 @@ func (m Minion) AnalyzeFiles(ctx context.Context, req *pb.AnalyzeFilesRequest) (
 			if err != nil {
 				return nil, err // TODO(paradoxengine): uniform error handling here (return error to grpc)
 			}
+		case "/lib/apk/db/installed":
+			err := m.getApkPackagesAndSetState(req.GetScanId(), bytes.NewReader(f.GetData()))
+			if err != nil {
+				return nil, err // TODO(paradoxengine): uniform error handling here (return error to grpc)
+			}
 		default:
 			log.Printf("Unknown path: %s. Won't analyze file", path)
 		}
 	}
 
-	findings := []*pb.Finding{}
+	findings, err := m.findingsForState(ctx, req.GetScanId())
+	if err != nil {
+		return nil, err
+	}
+
+	// We don't really need new interests as we know where the packages are
+	// located sine day one, so let's just return results.
+	resp := pb.AnalyzeFilesResponse{NewInterests: nil, Findings: findings}
+	return &resp, nil
+}
 
-	s, err := m.state.Get(req.GetScanId())
+// initState makes sure scanID has an mstate to build on, without clobbering
+// one that's already there - important when state is backed by a
+// persistent StateManager and this is actually a resumed scan.
+func (m *Minion) initState(scanID string) error {
+	return m.state.Update(scanID, func(current interface{}) (interface{}, error) {
+		if current != nil {
+			return current, nil
+		}
+		return NewMstate(), nil
+	})
+}
+
+// findingsForState dispatches every package gathered so far for scanID to
+// each registered ScannerAdapter that supports its ecosystem (see
+// RegisterAdapter, packagesForState) and converts the merged results into
+// Findings. It returns an empty slice, not an error, if the OS details
+// haven't been parsed yet or no packages have been seen at all - both are
+// normal mid-scan states.
+func (m *Minion) findingsForState(ctx context.Context, scanID string) ([]*pb.Finding, error) {
+	s, err := m.state.Get(scanID)
 	if err != nil {
 		return nil, err
 	}
+	st := s.(*mstate)
+	if len(st.Packages) == 0 && len(st.ApkPackages) == 0 {
+		return nil, nil
+	}
+	if st.Distro == "" {
+		// OS details haven't been parsed yet; nothing to query with.
+		return nil, nil
+	}
 
-	if len(s.(*mstate).packages) > 0 {
-		// Let's see if we already have distro and version.
-		distro, version, err := m.getDistroVersionFromState(req.GetScanId())
+	pkgs := packagesForState(st)
+	var vulns []vuln.Vulnerability
+	for _, adapter := range m.adapters {
+		var forAdapter []Package
+		for _, p := range pkgs {
+			if adapter.SupportsEcosystem(p.Ecosystem) {
+				forAdapter = append(forAdapter, p)
+			}
+		}
+		if len(forAdapter) == 0 {
+			continue
+		}
+		found, err := adapter.Scan(ctx, forAdapter)
 		if err != nil {
 			return nil, err
 		}
-		// If the OS details have been parsed already then let's have a look at the installed stuff.
-		if distro != "" {
-			// Now send the list of packages to the vulners API to get vulns
-			response, err := m.apiClient.GetVulnerabilitiesForPackages(distro, version, s.(*mstate).packages)
-			if err != nil {
-				return nil, err
-			}
-			// Now iterate over all packages that have been found vulnerable and return individual
-			// findings for each bug for each package. Proto building time, woohoo!
-			for packageName, issues := range (*response).Data.Packages {
-				for issueName, issueDetails := range issues {
-					findings = append(findings, convertFinding(packageName, issues, issueName, issueDetails, req.GetScanId()))
-				}
-			}
-		}
+		vulns = append(vulns, found...)
 	}
 
-	// We don't really need new interests as we know where the packages are
-	// located sine day one, so let's just return results.
-	resp := pb.AnalyzeFilesResponse{NewInterests: nil, Findings: findings}
-	return &resp, nil
+	return convertFindings(vulns, scanID, m.aggregateByCVE, st.Architectures), nil
+}
+
+// buildCPE formats name and version as a CPE 2.3 URI suitable for
+// FindForCPE: cpe:2.3:a:<vendor>:<product>:<version>:*:*:*:*:*:*:*. The
+// package managers we parse (dpkg/rpm/apk) never tell us the upstream
+// vendor, only product and version, so vendor is left as the CPE
+// wildcard "*".
+func buildCPE(name, version string) string {
+	return fmt.Sprintf("cpe:2.3:a:*:%s:%s:*:*:*:*:*:*:*", escapeCPEField(name), escapeCPEField(version))
+}
+
+// escapeCPEField backslash-escapes ":", the CPE 2.3 URI's own field
+// separator, in case a package name or version ever contains one.
+func escapeCPEField(s string) string {
+	return strings.Replace(s, ":", `\:`, -1)
 }
 
 // extractOsAndSetState takes the required data out of the request and sets the
 // scan state accordingly.
 func (m *Minion) extractOsAndSetState(req *pb.AnalyzeFilesRequest, f *pb.File) error {
-	// Extracting OS details, fetching data.
-	distro, version, err := getOsAndversion(bytes.NewReader(f.GetData()))
+	return m.extractOsAndSetStateFromReader(req.GetScanId(), bytes.NewReader(f.GetData()))
+}
+
+// extractOsAndSetStateFromReader is the io.Reader-based core of
+// extractOsAndSetState, shared with AnalyzeFilesStream.
+func (m *Minion) extractOsAndSetStateFromReader(scanID string, r io.Reader) error {
+	distro, version, err := getOsAndversion(r)
 	if err != nil {
 		return err
 	}
-	s, err := m.state.Get(req.GetScanId())
-	if err != nil {
-		return err
+	return m.state.Update(scanID, func(current interface{}) (interface{}, error) {
+		s := current.(*mstate)
+		s.Version = version
+		s.Distro = distro
+		return s, nil
+	})
+}
+
+// convertFindings builds the proto representation of every vuln.Vulnerability
+// in vulns. With aggregateByCVE set, Vulnerabilities that share a CVE (see
+// cveKey) are collapsed into a single Finding via convertFindingGroup instead
+// of one Finding each. archs is the mstate.Architectures of the scan vulns
+// was found for, consulted so a dpkg package installed under several
+// architectures still shows all of them on its VulnerableResources entry -
+// see additionalInfoFor.
+func convertFindings(vulns []vuln.Vulnerability, scanID string, aggregateByCVE bool, archs map[string]string) []*pb.Finding {
+	if !aggregateByCVE {
+		findings := make([]*pb.Finding, len(vulns))
+		for i, v := range vulns {
+			findings[i] = convertFinding(v, scanID, archs)
+		}
+		return findings
+	}
+
+	var order []string
+	groups := make(map[string][]vuln.Vulnerability)
+	for _, v := range vulns {
+		key := cveKey(v)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], v)
 	}
-	s.(*mstate).version = version
-	s.(*mstate).distro = distro
-	return m.state.Set(req.GetScanId(), s)
+	findings := make([]*pb.Finding, len(order))
+	for i, key := range order {
+		findings[i] = convertFindingGroup(groups[key], scanID, archs)
+	}
+	return findings
 }
 
-func (m *Minion) getDistroVersionFromState(scanID string) (string, string, error) {
-	s, err := m.state.Get(scanID)
-	if err != nil {
-		return "", "", err
+// additionalInfoFor returns the VulnerableResources.AdditionalInfo value
+// for pkg: just its name, unless archs records it as installed under more
+// than one architecture, in which case they're appended so the finding
+// doesn't silently hide which architectures are actually affected.
+func additionalInfoFor(pkg string, archs map[string]string) string {
+	if archList, ok := archs[pkg]; ok {
+		return fmt.Sprintf("%s (%s)", pkg, archList)
+	}
+	return pkg
+}
+
+// cveKey returns the CVE alias vulns should be grouped under, or v's own ID
+// if it doesn't carry one - backends that don't report several bulletins for
+// the same CVE to begin with will simply get one group per Vulnerability.
+func cveKey(v vuln.Vulnerability) string {
+	for _, alias := range v.Aliases {
+		if strings.HasPrefix(alias, "CVE-") {
+			return alias
+		}
 	}
-	return s.(*mstate).distro, s.(*mstate).version, nil
+	return v.ID
 }
 
-// convertFinding builds an internal representation of the fining from the vulners
-// data. Note that vulners provides an array of vulnPackage, but we really only
-// care about the first one at this point, so we simplify the code.
-func convertFinding(packageName string, issues map[string][]vulnPackage, issueName string, issueDetails []vulnPackage, scanID string) *pb.Finding {
+// convertFindingGroup builds a single Finding from every vuln.Vulnerability
+// in group, merging their affected packages into one VulnerableResources
+// list. group is assumed non-empty and every entry in it to share the same
+// CVE, so the Advisory/Source/Severity of group[0] stand for the whole group.
+func convertFindingGroup(group []vuln.Vulnerability, scanID string, archs map[string]string) *pb.Finding {
+	f := convertFinding(group[0], scanID, archs)
+	for _, v := range group[1:] {
+		f.VulnerableResources = append(f.VulnerableResources, &pb.Resource{
+			Path:           "",
+			AdditionalInfo: additionalInfoFor(v.Package, archs),
+		})
+	}
+	return f
+}
+
+// convertFinding builds the proto representation of a Finding from a
+// vuln.Vulnerability. The pb.Source.Minion value names whichever
+// ScannerAdapter actually produced v (see VulnClientAdapter.Scan), falling
+// back to "Vulners" for a Vulnerability that reached here without going
+// through one.
+func convertFinding(v vuln.Vulnerability, scanID string, archs map[string]string) *pb.Finding {
 	adv := &pb.Advisory{
-		Reference:      issueName,
-		Description:    strings.Join(issueDetails[0].CveList, ","),
-		Recommendation: issueDetails[0].Fix,
+		Reference:      v.ID,
+		Description:    strings.Join(v.Aliases, ","),
+		Recommendation: v.FixedIn,
+	}
+	minionName := v.Source
+	if minionName == "" {
+		minionName = "Vulners"
 	}
 	source := &pb.Source{
 		ScanId:        scanID,
-		Minion:        "Vulners",
+		Minion:        minionName,
 		DetectionTime: ptypes.TimestampNow(),
 	}
 	resources := []*pb.Resource{&pb.Resource{
 		Path:           "",
-		AdditionalInfo: packageName,
+		AdditionalInfo: additionalInfoFor(v.Package, archs),
 	}}
 	newFind := &pb.Finding{
 		Advisory:            adv,
 		VulnerableResources: resources,
 		Source:              source,
 		Accuracy:            pb.Finding_ACCURACY_AVERAGE, // Current trust level in vulners, may be adjusted based on distro in the future
-		Severity:            pb.Finding_SEVERITY_UNKNOWN, // TODO(claudio): convert CVSS into severity
+		Severity:            pbSeverity(v.Severity),
 	}
 	return newFind
 }
 
+// pbSeverity converts a vuln.Severity into its pb.Finding_Severity
+// counterpart; the two enums share the same bucket names by construction.
+func pbSeverity(s vuln.Severity) pb.Finding_Severity {
+	switch s {
+	case vuln.SeverityLow:
+		return pb.Finding_SEVERITY_LOW
+	case vuln.SeverityMedium:
+		return pb.Finding_SEVERITY_MEDIUM
+	case vuln.SeverityHigh:
+		return pb.Finding_SEVERITY_HIGH
+	case vuln.SeverityCritical:
+		return pb.Finding_SEVERITY_CRITICAL
+	default:
+		return pb.Finding_SEVERITY_UNKNOWN
+	}
+}
+
 // getRpmPackagesAndSetState parses the DPKG packages and extends the state with
 // the new known packages.
 func (m *Minion) getDpkgPackagesAndSetState(scanID string, df io.Reader) error {
-	pkgs, err := getDpkgPackages(df)
-	s, err := m.state.Get(scanID)
+	pkgs, archs, err := getDpkgPackages(df)
 	if err != nil {
 		return err
 	}
-	s.(*mstate).packages = append(s.(*mstate).packages, pkgs...)
-	return m.state.Set(scanID, s)
+	return m.state.Update(scanID, func(current interface{}) (interface{}, error) {
+		s := current.(*mstate)
+		s.Packages = append(s.Packages, pkgs...)
+		if len(archs) > 0 && s.Architectures == nil {
+			s.Architectures = make(map[string]string)
+		}
+		for name, archList := range archs {
+			s.Architectures[name] = archList
+		}
+		return s, nil
+	})
+}
+
+// dpkgPackage tracks every architecture dpkg lists a given name:version
+// stanza under, so getDpkgPackages can collapse Multi-Arch: same
+// duplicates into a single query entry instead of querying Vulners once
+// per architecture.
+type dpkgPackage struct {
+	name, version string
+	archs         []string
 }
 
 // Analyzes the dpkg database and returns a list of packages, versions and
-// architectures suitable to be fed in vulners.
-func getDpkgPackages(df io.Reader) ([]string, error) {
+// architectures suitable to be fed in vulners, alongside a map from
+// package name to its comma-separated architecture list for every package
+// that turned up under more than one architecture (see dpkgPackage) - a
+// single-architecture package's sole architecture is already part of its
+// Packages entry, so it isn't repeated here.
+func getDpkgPackages(df io.Reader) ([]string, map[string]string, error) {
 	s := dpkg.NewScanner(df)
 
-	var packages []string
+	var order []string
+	byNameVersion := make(map[string]*dpkgPackage)
 	for entry, err := s.Scan(); err != io.EOF; entry, err = s.Scan() {
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		// Skip anything not actually installed - e.g. "deinstall ok
+		// config-files" or "purge ok not-installed" - so a stale entry
+		// dpkg only kept around for its conffiles doesn't get matched
+		// against Vulners as if it were still present.
+		if entry["status"] != "install ok installed" {
+			continue
+		}
+
+		key := entry["package"] + ":" + entry["version"]
+		pkg, ok := byNameVersion[key]
+		if !ok {
+			pkg = &dpkgPackage{name: entry["package"], version: entry["version"]}
+			byNameVersion[key] = pkg
+			order = append(order, key)
+		}
+		pkg.archs = append(pkg.archs, entry["architecture"])
+	}
+
+	var packages []string
+	archsByName := make(map[string]string)
+	for _, key := range order {
+		pkg := byNameVersion[key]
+		archList := strings.Join(pkg.archs, ",")
 		// Note: my Java self feels this really needed a data class rather than a string
 		// but I'm told this is more idiomatic and what do I know about Go.
-		p := []string{entry["package"], entry["version"], entry["architecture"]}
+		p := []string{pkg.name, pkg.version, archList}
+		packages = append(packages, strings.Join(p, " "))
+		if len(pkg.archs) > 1 {
+			archsByName[pkg.name] = archList
+		}
+	}
+	return packages, archsByName, nil
+}
+
+// getApkPackagesAndSetState parses the APK packages and extends the state
+// with the new known packages.
+func (m *Minion) getApkPackagesAndSetState(scanID string, df io.Reader) error {
+	pkgs, err := getApkPackages(df)
+	if err != nil {
+		return err
+	}
+	return m.state.Update(scanID, func(current interface{}) (interface{}, error) {
+		s := current.(*mstate)
+		s.ApkPackages = append(s.ApkPackages, pkgs...)
+		return s, nil
+	})
+}
+
+// Analyzes the apk installed-db and returns a list of packages, versions and
+// architectures suitable to be fed in vulners.
+func getApkPackages(df io.Reader) ([]string, error) {
+	s := apk.NewScanner(df)
+
+	var packages []string
+	for entry, err := s.Scan(); err != io.EOF; entry, err = s.Scan() {
+		if err != nil {
+			return nil, err
+		}
+		p := []string{entry["P"], entry["V"], entry["A"]}
 		pkg := strings.Join(p, " ")
 		packages = append(packages, pkg)
 	}
@@ -252,12 +524,14 @@ func getDpkgPackages(df io.Reader) ([]string, error) {
 // the new known packages.
 func (m *Minion) getRpmPackagesAndSetState(scanID string, dbPath string) error {
 	pkgs, err := getRpmPackages(dbPath)
-	s, err := m.state.Get(scanID)
 	if err != nil {
 		return err
 	}
-	s.(*mstate).packages = append(s.(*mstate).packages, pkgs...)
-	return m.state.Set(scanID, s)
+	return m.state.Update(scanID, func(current interface{}) (interface{}, error) {
+		s := current.(*mstate)
+		s.Packages = append(s.Packages, pkgs...)
+		return s, nil
+	})
 }
 
 // Analyzes the RPM database and returns a list of packages, versions and
@@ -269,8 +543,177 @@ func getRpmPackages(dbPath string) ([]string, error) {
 		return nil, err
 	}
 	for _, p := range pkgs {
-		pkg := []string{p.Name, p.Version, p.Architecture}
-		packages = append(packages, strings.Join(pkg, " "))
+		packages = append(packages, rpmPackageString(p))
 	}
 	return packages, nil
 }
+
+// rpmPackageString formats a Package the way RPM-based distros (and
+// Vulners) expect it: name-version-release.arch, e.g.
+// "openssl-1.1.1k-5.el8_4.x86_64".
+func rpmPackageString(p rpm.Package) string {
+	nvr := p.Name + "-" + p.Version
+	if p.Release != "" {
+		nvr += "-" + p.Release
+	}
+	return nvr + "." + p.Architecture
+}
+
+// AnalyzeFilesStream is the streaming counterpart to AnalyzeFiles: the
+// Overlord sends us each file's contents as a sequence of FileChunks rather
+// than a single AnalyzeFilesRequest, which matters here because the dpkg,
+// apk and rpm package databases we read can run tens of megabytes. The dpkg
+// status and apk installed-db files are parsed straight off the wire via an
+// io.Pipe, and the RPM database is streamed to a temp file instead of being
+// buffered in memory; only the (tiny) os-release file is buffered directly.
+func (m Minion) AnalyzeFilesStream(stream pb.Minions_AnalyzeFilesStreamServer) error {
+	var (
+		scanID       string
+		path         string
+		osReleaseBuf bytes.Buffer
+		dpkgWriter   *io.PipeWriter
+		dpkgDone     chan error
+		apkWriter    *io.PipeWriter
+		apkDone      chan error
+		rpmFile      *os.File
+		rpmDir       string
+	)
+
+	// finishFile closes out whatever file `path` refers to, so its packages
+	// (if any) land in the scan state before we move on or report findings.
+	finishFile := func() error {
+		switch path {
+		case "/var/lib/dpkg/status":
+			if dpkgWriter == nil {
+				return nil
+			}
+			dpkgWriter.Close()
+			err := <-dpkgDone
+			dpkgWriter, dpkgDone = nil, nil
+			return err
+		case "/lib/apk/db/installed":
+			if apkWriter == nil {
+				return nil
+			}
+			apkWriter.Close()
+			err := <-apkDone
+			apkWriter, apkDone = nil, nil
+			return err
+		case "/var/lib/rpm/Packages":
+			if rpmFile == nil {
+				return nil
+			}
+			tmpfn := rpmFile.Name()
+			rpmFile.Close()
+			err := m.getRpmPackagesAndSetState(scanID, tmpfn)
+			os.RemoveAll(rpmDir)
+			rpmFile, rpmDir = nil, ""
+			return err
+		case "/etc/os-release", "/usr/lib/os-release":
+			if osReleaseBuf.Len() == 0 {
+				return nil
+			}
+			defer osReleaseBuf.Reset()
+			return m.extractOsAndSetStateFromReader(scanID, &osReleaseBuf)
+		}
+		return nil
+	}
+
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return finishFile()
+		}
+		if err != nil {
+			return err
+		}
+
+		if scanID == "" {
+			scanID = chunk.GetScanId()
+			if err := m.initState(scanID); err != nil {
+				return err
+			}
+		}
+
+		if md := chunk.GetMetadata(); md != nil {
+			// A new file is starting: close out the previous one first.
+			if err := finishFile(); err != nil {
+				return err
+			}
+			path = md.GetPath()
+			switch path {
+			case "/var/lib/dpkg/status":
+				pr, pw := io.Pipe()
+				dpkgWriter = pw
+				dpkgDone = make(chan error, 1)
+				go func() {
+					dpkgDone <- m.getDpkgPackagesAndSetState(scanID, pr)
+				}()
+			case "/lib/apk/db/installed":
+				pr, pw := io.Pipe()
+				apkWriter = pw
+				apkDone = make(chan error, 1)
+				go func() {
+					apkDone <- m.getApkPackagesAndSetState(scanID, pr)
+				}()
+			case "/var/lib/rpm/Packages":
+				// The RPM libraries need an actual file :-(
+				dir, err := ioutil.TempDir("", "RPMDATABASE")
+				if err != nil {
+					return err
+				}
+				rpmDir = dir
+				f, err := os.Create(filepath.Join(dir, "Packages"))
+				if err != nil {
+					os.RemoveAll(dir)
+					return err
+				}
+				rpmFile = f
+			case "/etc/os-release", "/usr/lib/os-release":
+				// Buffered below; these files are tiny.
+			default:
+				log.Printf("Unknown path: %s. Won't analyze file", path)
+			}
+		}
+
+		switch path {
+		case "/var/lib/dpkg/status":
+			if dpkgWriter != nil {
+				if _, err := dpkgWriter.Write(chunk.GetData()); err != nil {
+					return err
+				}
+			}
+		case "/lib/apk/db/installed":
+			if apkWriter != nil {
+				if _, err := apkWriter.Write(chunk.GetData()); err != nil {
+					return err
+				}
+			}
+		case "/var/lib/rpm/Packages":
+			if rpmFile != nil {
+				if _, err := rpmFile.Write(chunk.GetData()); err != nil {
+					return err
+				}
+			}
+		case "/etc/os-release", "/usr/lib/os-release":
+			osReleaseBuf.Write(chunk.GetData())
+		}
+
+		if chunk.GetEof() {
+			if err := finishFile(); err != nil {
+				return err
+			}
+			path = ""
+
+			findings, err := m.findingsForState(stream.Context(), scanID)
+			if err != nil {
+				return err
+			}
+			if len(findings) > 0 {
+				if err := stream.Send(&pb.AnalyzeFilesResponse{Findings: findings}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
@@ -16,85 +16,194 @@ package vulners
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/minions/go/minions"
+	"github.com/google/minions/go/minions/vuln"
 	pb "github.com/google/minions/proto/minions"
 	"github.com/stretchr/testify/require"
 )
 
-func TestAnalyzeFiles_singleCall_returnsVulns(t *testing.T) {
-	// This data matches the dpkg and os-release.1 testdata files.
-	mockedRequest := buildMockVulnClientKey("Ubuntu", "18.04", []string{"fonts-sil-abyssinica 1.500-1 all", "mokutil 0.3.0-0ubuntu5 amd64"})
-	mockResp := buildMockedAPIVulnResponse("fonts-sil-abyssinica")
-	mockClient := &mockVulnerabilityClient{responses: map[string]*VulnResponse{mockedRequest: mockResp}}
-	m := &Minion{apiClient: mockClient, state: minions.NewLocalStateManager()}
-
-	// We send both files in a single call.
-	files := []*pb.File{
-		buildFile("/etc/os-release", "/testdata/os-release.1.txt", t),
-		buildFile("/var/lib/dpkg/status", "/testdata/dpkg.txt", t),
+// stateBackends lists the StateManager backends AnalyzeFiles should behave
+// identically against. Redis isn't included: exercising it needs a live
+// server, and nothing elsewhere in this repo spins one up for tests either.
+var stateBackends = []string{"local", "bolt"}
+
+// newTestStateManager builds the named backend, returning a cleanup
+// function that must be called (e.g. via defer) once the test is done.
+func newTestStateManager(t *testing.T, backend string) (minions.StateManager, func()) {
+	switch backend {
+	case "local":
+		return minions.NewLocalStateManager(), func() {}
+	case "bolt":
+		dir, err := ioutil.TempDir("", "vulners-bolt-state")
+		require.NoError(t, err)
+		sm, err := minions.NewBoltStateManager(filepath.Join(dir, "state.db"), NewMstate)
+		require.NoError(t, err)
+		return sm, func() {
+			sm.Close()
+			os.RemoveAll(dir)
+		}
+	default:
+		t.Fatalf("unknown state backend %q", backend)
+		return nil, nil
 	}
+}
 
-	req := &pb.AnalyzeFilesRequest{ScanId: "irrelevant_scan_id", Files: files}
-	res, err := m.AnalyzeFiles(nil, req)
-	require.NoError(t, err)
-	packageSource := res.GetFindings()[0].GetVulnerableResources()[0].GetAdditionalInfo()
-	require.Equal(t, "fonts-sil-abyssinica", packageSource)
-	require.Equal(t, pb.Finding_SEVERITY_MEDIUM, res.GetFindings()[0].GetSeverity())
+func TestAnalyzeFiles_singleCall_returnsVulns(t *testing.T) {
+	for _, backend := range stateBackends {
+		t.Run(backend, func(t *testing.T) {
+			// This data matches the dpkg and os-release.1 testdata files.
+			mockedRequest := buildMockVulnClientKey("Ubuntu", "18.04", []string{"fonts-sil-abyssinica 1.500-1 all", "mokutil 0.3.0-0ubuntu5 amd64"})
+			mockResp := buildMockedAPIVulnResponse("fonts-sil-abyssinica")
+			mockClient := &mockVulnerabilityClient{responses: map[string]*VulnResponse{mockedRequest: mockResp}}
+			state, cleanup := newTestStateManager(t, backend)
+			defer cleanup()
+			m := NewMinionWithClient(mockClient, false, state)
+
+			// We send both files in a single call.
+			files := []*pb.File{
+				buildFile("/etc/os-release", "/testdata/os-release.1.txt", t),
+				buildFile("/var/lib/dpkg/status", "/testdata/dpkg.txt", t),
+			}
+
+			req := &pb.AnalyzeFilesRequest{ScanId: "irrelevant_scan_id", Files: files}
+			res, err := m.AnalyzeFiles(nil, req)
+			require.NoError(t, err)
+			packageSource := res.GetFindings()[0].GetVulnerableResources()[0].GetAdditionalInfo()
+			require.Equal(t, "fonts-sil-abyssinica", packageSource)
+			require.Equal(t, pb.Finding_SEVERITY_MEDIUM, res.GetFindings()[0].GetSeverity())
+		})
+	}
 }
 
 func TestAnalyzeFiles_osReleaseFirst_returnsVulns(t *testing.T) {
-	mockedRequest := buildMockVulnClientKey("Ubuntu", "18.04", []string{"fonts-sil-abyssinica 1.500-1 all", "mokutil 0.3.0-0ubuntu5 amd64"})
-	mockResp := buildMockedAPIVulnResponse("mokutil")
-	mockClient := &mockVulnerabilityClient{responses: map[string]*VulnResponse{mockedRequest: mockResp}}
-	m := &Minion{apiClient: mockClient, state: minions.NewLocalStateManager()}
-
-	// Send first the OS release file
-	scanID := "A_SCAN_ID"
-	filesReq1 := []*pb.File{buildFile("/etc/os-release", "/testdata/os-release.1.txt", t)}
-	req1 := &pb.AnalyzeFilesRequest{ScanId: scanID, Files: filesReq1}
-	_, err := m.AnalyzeFiles(nil, req1)
-	require.NoError(t, err)
-
-	// Now send the DPKG file
-	filesReq2 := []*pb.File{buildFile("/var/lib/dpkg/status", "/testdata/dpkg.txt", t)}
-	req2 := &pb.AnalyzeFilesRequest{ScanId: scanID, Files: filesReq2}
-	res, err := m.AnalyzeFiles(nil, req2)
-	require.NoError(t, err)
-	packageSource := res.GetFindings()[0].GetVulnerableResources()[0].GetAdditionalInfo()
-	require.Equal(t, "mokutil", packageSource)
+	for _, backend := range stateBackends {
+		t.Run(backend, func(t *testing.T) {
+			mockedRequest := buildMockVulnClientKey("Ubuntu", "18.04", []string{"fonts-sil-abyssinica 1.500-1 all", "mokutil 0.3.0-0ubuntu5 amd64"})
+			mockResp := buildMockedAPIVulnResponse("mokutil")
+			mockClient := &mockVulnerabilityClient{responses: map[string]*VulnResponse{mockedRequest: mockResp}}
+			state, cleanup := newTestStateManager(t, backend)
+			defer cleanup()
+			m := NewMinionWithClient(mockClient, false, state)
+
+			// Send first the OS release file
+			scanID := "A_SCAN_ID"
+			filesReq1 := []*pb.File{buildFile("/etc/os-release", "/testdata/os-release.1.txt", t)}
+			req1 := &pb.AnalyzeFilesRequest{ScanId: scanID, Files: filesReq1}
+			_, err := m.AnalyzeFiles(nil, req1)
+			require.NoError(t, err)
+
+			// Now send the DPKG file
+			filesReq2 := []*pb.File{buildFile("/var/lib/dpkg/status", "/testdata/dpkg.txt", t)}
+			req2 := &pb.AnalyzeFilesRequest{ScanId: scanID, Files: filesReq2}
+			res, err := m.AnalyzeFiles(nil, req2)
+			require.NoError(t, err)
+			packageSource := res.GetFindings()[0].GetVulnerableResources()[0].GetAdditionalInfo()
+			require.Equal(t, "mokutil", packageSource)
+		})
+	}
 }
 
 func TestAnalyzeFiles_dpkgFirst_returnsVulns(t *testing.T) {
-	mockedRequest := buildMockVulnClientKey("Ubuntu", "18.04", []string{"fonts-sil-abyssinica 1.500-1 all", "mokutil 0.3.0-0ubuntu5 amd64"})
-	mockResp := buildMockedAPIVulnResponse("mokutil")
-	mockClient := &mockVulnerabilityClient{responses: map[string]*VulnResponse{mockedRequest: mockResp}}
-	m := &Minion{apiClient: mockClient, state: minions.NewLocalStateManager()}
+	for _, backend := range stateBackends {
+		t.Run(backend, func(t *testing.T) {
+			mockedRequest := buildMockVulnClientKey("Ubuntu", "18.04", []string{"fonts-sil-abyssinica 1.500-1 all", "mokutil 0.3.0-0ubuntu5 amd64"})
+			mockResp := buildMockedAPIVulnResponse("mokutil")
+			mockClient := &mockVulnerabilityClient{responses: map[string]*VulnResponse{mockedRequest: mockResp}}
+			state, cleanup := newTestStateManager(t, backend)
+			defer cleanup()
+			m := NewMinionWithClient(mockClient, false, state)
+
+			scanID := "A_SCAN_ID"
+
+			// First send the DPKG file
+			filesReq2 := []*pb.File{buildFile("/var/lib/dpkg/status", "/testdata/dpkg.txt", t)}
+			req2 := &pb.AnalyzeFilesRequest{ScanId: scanID, Files: filesReq2}
+			_, err := m.AnalyzeFiles(nil, req2)
+			require.NoError(t, err)
+
+			// Send second the OS release file
+			filesReq1 := []*pb.File{buildFile("/etc/os-release", "/testdata/os-release.1.txt", t)}
+			req1 := &pb.AnalyzeFilesRequest{ScanId: scanID, Files: filesReq1}
+			res, err := m.AnalyzeFiles(nil, req1)
+			require.NoError(t, err)
+
+			packageSource := res.GetFindings()[0].GetVulnerableResources()[0].GetAdditionalInfo()
+			require.Equal(t, "mokutil", packageSource)
+		})
+	}
+}
 
-	scanID := "A_SCAN_ID"
+func TestConvertFindings_aggregateByCVE_mergesResources(t *testing.T) {
+	vulns := []vuln.Vulnerability{
+		{ID: "ADVISORY-1", Aliases: []string{"CVE-2021-1234"}, Package: "fonts-sil-abyssinica"},
+		{ID: "ADVISORY-2", Aliases: []string{"CVE-2021-1234"}, Package: "mokutil"},
+		{ID: "ADVISORY-3", Aliases: []string{"CVE-2021-5678"}, Package: "libfoo"},
+	}
 
-	// First send the DPKG file
-	filesReq2 := []*pb.File{buildFile("/var/lib/dpkg/status", "/testdata/dpkg.txt", t)}
-	req2 := &pb.AnalyzeFilesRequest{ScanId: scanID, Files: filesReq2}
-	_, err := m.AnalyzeFiles(nil, req2)
-	require.NoError(t, err)
+	findings := convertFindings(vulns, "A_SCAN_ID", true, nil)
+	require.Len(t, findings, 2)
+	require.Equal(t, "ADVISORY-1", findings[0].GetAdvisory().GetReference())
+	var packages []string
+	for _, r := range findings[0].GetVulnerableResources() {
+		packages = append(packages, r.GetAdditionalInfo())
+	}
+	require.ElementsMatch(t, []string{"fonts-sil-abyssinica", "mokutil"}, packages)
+	require.Equal(t, "ADVISORY-3", findings[1].GetAdvisory().GetReference())
+}
 
-	// Send second the OS release file
-	filesReq1 := []*pb.File{buildFile("/etc/os-release", "/testdata/os-release.1.txt", t)}
-	req1 := &pb.AnalyzeFilesRequest{ScanId: scanID, Files: filesReq1}
-	res, err := m.AnalyzeFiles(nil, req1)
-	require.NoError(t, err)
+func TestConvertFindings_noAggregation_returnsOnePerVulnerability(t *testing.T) {
+	vulns := []vuln.Vulnerability{
+		{ID: "ADVISORY-1", Aliases: []string{"CVE-2021-1234"}, Package: "fonts-sil-abyssinica"},
+		{ID: "ADVISORY-2", Aliases: []string{"CVE-2021-1234"}, Package: "mokutil"},
+	}
+	findings := convertFindings(vulns, "A_SCAN_ID", false, nil)
+	require.Len(t, findings, 2)
+}
+
+func TestAnalyzeFiles_alpineApk_queriesByCPE(t *testing.T) {
+	for _, backend := range stateBackends {
+		t.Run(backend, func(t *testing.T) {
+			cpe := buildCPE("musl", "1.2.3-r4")
+			mockClient := &mockVulnerabilityClient{
+				cpeResponses: map[string][]vuln.Vulnerability{
+					cpe: {{ID: "CVE-2022-9999", Aliases: []string{"CVE-2022-9999"}, Severity: vuln.SeverityHigh}},
+				},
+			}
+			state, cleanup := newTestStateManager(t, backend)
+			defer cleanup()
+			m := NewMinionWithClient(mockClient, false, state)
+
+			osRelease := &pb.File{
+				Metadata: &pb.FileMetadata{Path: "/etc/os-release"},
+				Data:     []byte("NAME=\"Alpine Linux\"\nID=alpine\nVERSION_ID=3.16.0\n"),
+			}
+			apkDB := &pb.File{
+				Metadata: &pb.FileMetadata{Path: "/lib/apk/db/installed"},
+				Data:     []byte("P:musl\nV:1.2.3-r4\nA:x86_64\n\n"),
+			}
+
+			req := &pb.AnalyzeFilesRequest{ScanId: "irrelevant_scan_id", Files: []*pb.File{osRelease, apkDB}}
+			res, err := m.AnalyzeFiles(nil, req)
+			require.NoError(t, err)
+			require.Len(t, res.GetFindings(), 1)
+			require.Equal(t, "musl", res.GetFindings()[0].GetVulnerableResources()[0].GetAdditionalInfo())
+			require.Equal(t, pb.Finding_SEVERITY_HIGH, res.GetFindings()[0].GetSeverity())
+		})
+	}
+}
 
-	packageSource := res.GetFindings()[0].GetVulnerableResources()[0].GetAdditionalInfo()
-	require.Equal(t, "mokutil", packageSource)
+func TestBuildCPE_escapesColons(t *testing.T) {
+	require.Equal(t, `cpe:2.3:a:*:foo\:bar:1\:2:*:*:*:*:*:*:*`, buildCPE("foo:bar", "1:2"))
 }
 
 func TestListInitialInterests(t *testing.T) {
 	paths := []string{"/var/lib/dpkg/status", "/etc/os-release", "/usr/lib/os-release", "/var/lib/rpm/Packages"}
 	for _, p := range paths {
 		t.Run(p, func(t *testing.T) {
-			m := NewMinion("irrelevant")
+			m := NewMinion("irrelevant", false, minions.NewLocalStateManager())
 			foundPath := false
 			interests, _ := m.ListInitialInterests(nil, nil)
 			for _, i := range interests.GetInterests() {
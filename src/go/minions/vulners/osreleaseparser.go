@@ -62,6 +62,7 @@ func getOsAndversion(reader io.Reader) (operatingSystem string, version string,
 		lines = append(lines, s.Text())
 	}
 
+	var id string
 	for _, line := range lines {
 		k, v, err := parseOsReleaseLine(line)
 		if err != nil {
@@ -71,12 +72,22 @@ func getOsAndversion(reader io.Reader) (operatingSystem string, version string,
 		case "NAME":
 			operatingSystem = v
 			break
+		case "ID":
+			id = v
+			break
 		case "VERSION_ID":
 			version = v
 			break
 		}
 	}
 
+	// Some minimal images (notably stripped-down Alpine ones) ship an
+	// os-release without a NAME field but always carry ID (e.g.
+	// "ID=alpine"); fall back to it rather than treating the OS as unknown.
+	if operatingSystem == "" {
+		operatingSystem = id
+	}
+
 	if operatingSystem == "" || version == "" {
 		return "", "", errors.New("Could not identify os or version")
 	}
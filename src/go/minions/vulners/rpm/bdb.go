@@ -0,0 +1,222 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package rpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// RPM header tag numbers we care about. See rpm's lib/rpmtag.h.
+const (
+	tagName    = 1000
+	tagVersion = 1001
+	tagRelease = 1002
+	tagEpoch   = 1003
+	tagArch    = 1022
+)
+
+// RPM header value types. See rpm's lib/rpmtypes.h.
+const (
+	typeChar    = 1
+	typeInt8    = 2
+	typeInt16   = 3
+	typeInt32   = 4
+	typeInt64   = 5
+	typeString  = 6
+	typeBin     = 7
+	typeStrArr  = 8
+	typeI18NStr = 9
+)
+
+const (
+	bdbPageSize = 4096 // Default page size used by rpm's Berkeley DB.
+	bdbHashMeta = 8    // P_HASHMETA page type.
+	bdbHash     = 13   // P_HASH page type.
+)
+
+// ReadPackagesPureGo parses an rpm Berkeley DB "Packages" file directly,
+// without cgo or the system's rpmlib. It understands enough of the BDB hash
+// access method to walk every bucket page and extract the RPM header blob
+// stored as each entry's value, which it then decodes into a Package.
+//
+// This is intentionally narrower than librpm: it only supports the classic
+// BDB hash backend (not the sqlite or ndb backends used by Fedora 36+, nor
+// BDB's overflow/duplicate-key pages), which is sufficient for the vast
+// majority of RHEL/CentOS systems still in the field. Use ReadDbAndCleanup
+// (which shells out to rpmlib via cgo) when full fidelity is required.
+func ReadPackagesPureGo(data []byte) ([]Package, error) {
+	if len(data) < bdbPageSize {
+		return nil, errors.New("rpm: file too small to be a BDB Packages database")
+	}
+
+	pageType := data[bdbPageTypeOffset(0)]
+	if pageType != bdbHashMeta {
+		return nil, fmt.Errorf("rpm: unexpected BDB meta page type %d", pageType)
+	}
+
+	var pkgs []Package
+	numPages := len(data) / bdbPageSize
+	for i := 1; i < numPages; i++ {
+		page := data[i*bdbPageSize : (i+1)*bdbPageSize]
+		if page[bdbPageTypeOffsetInPage] != bdbHash {
+			continue
+		}
+		for _, blob := range hashPageValues(page) {
+			pkg, err := parseRPMHeader(blob)
+			if err != nil {
+				// Corrupt or unsupported entry (e.g. an index rather than a
+				// header record); skip it rather than failing the whole scan.
+				continue
+			}
+			if pkg.Name == "gpg-pubkey" || pkg.Name == "" {
+				continue
+			}
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, nil
+}
+
+// ReadPackagesFilePureGo reads path and parses it via ReadPackagesPureGo.
+func ReadPackagesFilePureGo(path string) ([]Package, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ReadPackagesPureGo(data)
+}
+
+// bdbPageTypeOffset and bdbPageTypeOffsetInPage both point at the
+// single-byte "page type" field of a standard BDB page header, which sits
+// 25 bytes into the page (after LSN, pgno, prev/next pgno, entries count,
+// hf_offset and level).
+func bdbPageTypeOffset(page int) int { return page*bdbPageSize + 25 }
+
+const bdbPageTypeOffsetInPage = 25
+
+// hashPageValues extracts the value byte-slices stored in a BDB hash page.
+// Each page has a fixed-size header followed by an array of 2-byte index
+// offsets (growing forward from just after the header) and the actual
+// key/value pairs (growing backward from the end of the page). Entries
+// alternate key, value; we only care about values, which for a Packages
+// database are raw RPM header blobs.
+func hashPageValues(page []byte) [][]byte {
+	if len(page) < 26 {
+		return nil
+	}
+	numEntries := int(binary.LittleEndian.Uint16(page[20:22]))
+	var values [][]byte
+	const indexStart = 26
+	for i := 0; i+1 < numEntries; i += 2 {
+		offIdx := indexStart + (i+1)*2
+		if offIdx+2 > len(page) {
+			break
+		}
+		valueOffset := int(binary.LittleEndian.Uint16(page[offIdx : offIdx+2]))
+		if valueOffset <= 0 || valueOffset >= len(page) {
+			continue
+		}
+		// Each on-page item is itself prefixed by a 1-byte length/type
+		// header (B_KEYDATA); the length is only accurate for small
+		// inline items, but RPM headers are stored as overflow items in
+		// practice, so we conservatively read to the end of the page and
+		// let parseRPMHeader validate the content via its own length
+		// fields instead of trusting this one.
+		values = append(values, page[valueOffset:])
+	}
+	return values
+}
+
+// parseRPMHeader decodes the subset of the RPM header format (a count of
+// index entries, a count of data bytes, an array of (tag, type, offset,
+// count) index entries, and a trailing data store) that we need: name,
+// version, release, epoch and arch.
+func parseRPMHeader(blob []byte) (Package, error) {
+	if len(blob) < 16 {
+		return Package{}, errors.New("rpm: header blob too short")
+	}
+	r := bytes.NewReader(blob)
+	var il, dl int32
+	if err := binary.Read(r, binary.BigEndian, &il); err != nil {
+		return Package{}, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &dl); err != nil {
+		return Package{}, err
+	}
+	if il <= 0 || il > 1024 || dl <= 0 || int(dl) > len(blob) {
+		return Package{}, errors.New("rpm: implausible header entry/data counts")
+	}
+
+	type indexEntry struct {
+		tag, typ, offset, count int32
+	}
+	entries := make([]indexEntry, 0, il)
+	for i := int32(0); i < il; i++ {
+		var e indexEntry
+		if err := binary.Read(r, binary.BigEndian, &e.tag); err != nil {
+			return Package{}, err
+		}
+		if err := binary.Read(r, binary.BigEndian, &e.typ); err != nil {
+			return Package{}, err
+		}
+		if err := binary.Read(r, binary.BigEndian, &e.offset); err != nil {
+			return Package{}, err
+		}
+		if err := binary.Read(r, binary.BigEndian, &e.count); err != nil {
+			return Package{}, err
+		}
+		entries = append(entries, e)
+	}
+
+	dataStoreStart := 8 + int(il)*16
+	if dataStoreStart+int(dl) > len(blob) {
+		return Package{}, errors.New("rpm: header data store out of bounds")
+	}
+	dataStore := blob[dataStoreStart : dataStoreStart+int(dl)]
+
+	pkg := Package{}
+	for _, e := range entries {
+		if int(e.offset) >= len(dataStore) {
+			continue
+		}
+		switch e.tag {
+		case tagName:
+			pkg.Name = readRPMString(dataStore[e.offset:])
+		case tagVersion:
+			pkg.Version = readRPMString(dataStore[e.offset:])
+		case tagRelease:
+			pkg.Release = readRPMString(dataStore[e.offset:])
+		case tagArch:
+			pkg.Architecture = readRPMString(dataStore[e.offset:])
+		case tagEpoch:
+			if e.typ == typeInt32 && int(e.offset)+4 <= len(dataStore) {
+				pkg.Epoch = fmt.Sprintf("%d", binary.BigEndian.Uint32(dataStore[e.offset:e.offset+4]))
+			}
+		}
+	}
+	return pkg, nil
+}
+
+// readRPMString reads a single NUL-terminated string, as used by
+// typeString/typeI18NStr header entries.
+func readRPMString(data []byte) string {
+	if i := bytes.IndexByte(data, 0); i != -1 {
+		return string(data[:i])
+	}
+	return string(data)
+}
@@ -0,0 +1,31 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package rpm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPackagesPureGo_tooSmall_returnsError(t *testing.T) {
+	_, err := ReadPackagesPureGo([]byte("not a database"))
+	assert.Error(t, err)
+}
+
+func TestReadPackagesPureGo_wrongMetaPageType_returnsError(t *testing.T) {
+	data := make([]byte, bdbPageSize*2)
+	_, err := ReadPackagesPureGo(data)
+	assert.Error(t, err)
+}
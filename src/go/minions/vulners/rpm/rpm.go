@@ -37,7 +37,9 @@ import (
 type Package struct {
 	Name         string
 	Version      string
+	Release      string // e.g. the "3.el8" in "1.2.3-3.el8".
 	Architecture string
+	Epoch        string // Empty when the package has no explicit epoch.
 }
 
 // ReadDb reads the entire package repository from a Packages RPM DB.
@@ -90,7 +92,9 @@ func getPackages(dbpath string) ([]Package, error) {
 
 		name, _ := h.GetString(rpmlib.RPMTAG_NAME)
 		version, _ := h.GetString(rpmlib.RPMTAG_VERSION)
+		release, _ := h.GetString(rpmlib.RPMTAG_RELEASE)
 		arch, _ := h.GetString(rpmlib.RPMTAG_ARCH)
+		epoch, _ := h.GetString(rpmlib.RPMTAG_EPOCH)
 
 		// gpg-pubkey is a well-known magical package, so we skip it.
 		if name == "gpg-pubkey" {
@@ -102,7 +106,7 @@ func getPackages(dbpath string) ([]Package, error) {
 			continue
 		}
 
-		pkgs = append(pkgs, Package{name, version, arch})
+		pkgs = append(pkgs, Package{Name: name, Version: version, Release: release, Architecture: arch, Epoch: epoch})
 	}
 
 	return pkgs, nil
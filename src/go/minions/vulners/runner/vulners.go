@@ -15,16 +15,46 @@ package main
 
 import (
 	"flag"
+	"log"
 
 	"github.com/google/minions/go/minions"
+	"github.com/google/minions/go/minions/osv"
+	"github.com/google/minions/go/minions/vuln"
 	"github.com/google/minions/go/minions/vulners"
 )
 
 var (
-	apiKey = flag.String("vulners_api_key", "", "API key to use when calling Vulners")
+	apiKey         = flag.String("vulners_api_key", "", "API key to use when calling Vulners")
+	backend        = flag.String("backend", "vulners", "Vulnerability backend(s) to query: vulners, osv or both")
+	osvDir         = flag.String("osv_dir", "", "Directory of an extracted OSV export to back the osv backend with; required if --backend is osv or both")
+	aggregateByCVE = flag.Bool("aggregate_by_cve", false, "Collapse findings that share a CVE into a single Finding listing every affected package")
 )
 
+// newClient builds the vuln.Client *backend asks for, fanning out to both
+// Vulners and OSV via vuln.Multi when it's "both" - see
+// vulners.NewMinion's doc comment.
+func newClient() vuln.Client {
+	switch *backend {
+	case "vulners":
+		return vulners.NewVulnClient(*apiKey)
+	case "osv":
+		return newOSVClient()
+	case "both":
+		return vuln.NewMulti(vulners.NewVulnClient(*apiKey), newOSVClient())
+	default:
+		log.Fatalf("unknown -backend %q: want vulners, osv or both", *backend)
+		return nil
+	}
+}
+
+func newOSVClient() *osv.VulnClient {
+	if *osvDir == "" {
+		log.Fatal("-osv_dir is required when -backend is osv or both")
+	}
+	return osv.NewVulnClient(osv.NewFileSource(*osvDir))
+}
+
 func main() {
 	flag.Parse()
-	minions.StartMinion(vulners.NewMinion(*apiKey), "Vulners - Package checker")
+	minions.StartMinion(vulners.NewMinionWithClient(newClient(), *aggregateByCVE, minions.NewStateManagerFromFlags(vulners.NewMstate)), "Vulners - Package checker")
 }
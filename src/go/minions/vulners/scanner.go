@@ -0,0 +1,177 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package vulners
+
+import (
+	"strings"
+
+	"github.com/google/minions/go/minions/vuln"
+	"golang.org/x/net/context"
+)
+
+// Package describes a single installed piece of software to scan for
+// vulnerabilities, independent of which package manager or file format it
+// was parsed out of.
+type Package struct {
+	Name, Version, Arch string
+
+	// Ecosystem says how a ScannerAdapter should query for pkg: "distro"
+	// for the dpkg/rpm packages Vulners' /audit/audit endpoint understands
+	// (queried together via OS/OSVersion, see Raw), or "apk" for Alpine
+	// packages, which that endpoint doesn't support and so are queried one
+	// at a time by CPE instead (see CPE, buildCPE).
+	Ecosystem string
+
+	// OS and OSVersion are set for Ecosystem == "distro", the same for
+	// every Package in one scan: the distro/version pair /audit/audit
+	// expects alongside the package list.
+	OS, OSVersion string
+
+	// Raw is the package already formatted the way /audit/audit expects
+	// for Ecosystem == "distro" (see getDpkgPackages/getRpmPackages).
+	Raw string
+
+	// CPE is this package's CPE 2.3 URI, set for Ecosystem == "apk" (see
+	// buildCPE). Other ecosystems leave it empty since Vulners' distro
+	// endpoint doesn't take one.
+	CPE string
+}
+
+// ScannerAdapter is a pluggable vulnerability-scanning backend. The minion
+// dispatches every Package it has parsed to whichever registered adapters
+// declare support for its Ecosystem (see Minion.RegisterAdapter) and merges
+// their results, the way image-scanning platforms let several scanner
+// plugins (Trivy, Grype, an offline NVD mirror, Clair, ...) run side by
+// side. VulnClientAdapter is the one this package ships, wrapping a
+// vuln.Client (Vulners by default); a caller wanting a different backend
+// can register their own ScannerAdapter without touching this package.
+type ScannerAdapter interface {
+	// Name identifies the adapter, e.g. "Vulners" - used as the
+	// pb.Source.Minion value for findings it produces (see convertFinding).
+	Name() string
+	// SupportsEcosystem reports whether this adapter can scan packages
+	// from ecosystem (e.g. "distro", "apk").
+	SupportsEcosystem(ecosystem string) bool
+	// Scan returns vulnerabilities known to affect any of pkgs. Every
+	// element of pkgs is guaranteed to satisfy SupportsEcosystem.
+	Scan(ctx context.Context, pkgs []Package) ([]vuln.Vulnerability, error)
+}
+
+// maxApkResultsPerPackage bounds how many vulnerabilities FindForCPE
+// returns for a single apk package - there's no batch, distro-aware
+// endpoint for Alpine the way /audit/audit is for dpkg/rpm, so this is
+// queried one package at a time.
+const maxApkResultsPerPackage = 20
+
+// VulnClientAdapter adapts a vuln.Client (Vulners' FindForPackages/
+// FindForCPE pair) into a ScannerAdapter, so the default minion
+// configuration is just another registered adapter rather than a special
+// case baked into the minion.
+type VulnClientAdapter struct {
+	AdapterName string
+	Client      vuln.Client
+}
+
+// NewVulnClientAdapter wraps client as a ScannerAdapter identifying itself
+// as name.
+func NewVulnClientAdapter(name string, client vuln.Client) *VulnClientAdapter {
+	return &VulnClientAdapter{AdapterName: name, Client: client}
+}
+
+// Name implements ScannerAdapter.
+func (a *VulnClientAdapter) Name() string { return a.AdapterName }
+
+// SupportsEcosystem implements ScannerAdapter: a vuln.Client answers both
+// the "distro" and "apk" ecosystems, via FindForPackages and FindForCPE
+// respectively.
+func (a *VulnClientAdapter) SupportsEcosystem(ecosystem string) bool {
+	return ecosystem == "distro" || ecosystem == "apk"
+}
+
+// Scan implements ScannerAdapter.
+func (a *VulnClientAdapter) Scan(ctx context.Context, pkgs []Package) ([]vuln.Vulnerability, error) {
+	var vulns []vuln.Vulnerability
+
+	var distroPkgs []string
+	var os, osVersion string
+	for _, p := range pkgs {
+		if p.Ecosystem != "distro" {
+			continue
+		}
+		distroPkgs = append(distroPkgs, p.Raw)
+		os, osVersion = p.OS, p.OSVersion
+	}
+	if len(distroPkgs) > 0 {
+		found, err := a.Client.FindForPackages(ctx, os, osVersion, distroPkgs)
+		if err != nil {
+			return nil, err
+		}
+		vulns = append(vulns, tagSource(found, a.AdapterName)...)
+	}
+
+	for _, p := range pkgs {
+		if p.Ecosystem != "apk" {
+			continue
+		}
+		found, err := a.Client.FindForCPE(ctx, p.CPE, maxApkResultsPerPackage)
+		if err != nil {
+			return nil, err
+		}
+		for i := range found {
+			found[i].Package = p.Name
+		}
+		vulns = append(vulns, tagSource(found, a.AdapterName)...)
+	}
+
+	return vulns, nil
+}
+
+// tagSource stamps every Vulnerability in vulns with source, so a Finding
+// built from a merged, multi-adapter result set can still say which
+// backend actually found it (see convertFinding).
+func tagSource(vulns []vuln.Vulnerability, source string) []vuln.Vulnerability {
+	for i := range vulns {
+		vulns[i].Source = source
+	}
+	return vulns
+}
+
+// packagesForState turns st's parsed package lists into the Package slice
+// ScannerAdapters scan, tagging each with the Ecosystem its originating
+// package manager determines.
+func packagesForState(st *mstate) []Package {
+	var pkgs []Package
+	for _, raw := range st.Packages {
+		pkgs = append(pkgs, Package{
+			Ecosystem: "distro",
+			OS:        st.Distro,
+			OSVersion: st.Version,
+			Raw:       raw,
+		})
+	}
+	for _, raw := range st.ApkPackages {
+		fields := strings.Fields(raw)
+		if len(fields) < 2 {
+			continue
+		}
+		name, version := fields[0], fields[1]
+		pkgs = append(pkgs, Package{
+			Name:      name,
+			Version:   version,
+			Ecosystem: "apk",
+			CPE:       buildCPE(name, version),
+		})
+	}
+	return pkgs
+}
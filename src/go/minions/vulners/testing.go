@@ -16,21 +16,49 @@ package vulners
 import (
 	"fmt"
 	"strings"
+
+	"github.com/google/minions/go/minions/vuln"
+	"golang.org/x/net/context"
 )
 
 type mockVulnerabilityClient struct {
 	// We make our lives MUCH easier by accepting a tiny chance of mistakes and just
 	// concatenating everything here - so the map key is the concat of version, os and packages.
 	responses map[string]*VulnResponse
+	// cpeResponses backs FindForCPE, keyed by the CPE URI queried.
+	cpeResponses map[string][]vuln.Vulnerability
 }
 
-func (m *mockVulnerabilityClient) GetVulnerabilitiesForPackages(version string, os string, packages []string) (*VulnResponse, error) {
+// FindForPackages implements vuln.Client.
+func (m *mockVulnerabilityClient) FindForPackages(ctx context.Context, version string, os string, packages []string) ([]vuln.Vulnerability, error) {
 	key := buildMockVulnClientKey(version, os, packages)
 	resp, found := m.responses[key]
 	if !found {
 		return nil, fmt.Errorf("could not find the request in our mock: %s", key)
 	}
-	return resp, nil
+	var vulns []vuln.Vulnerability
+	for packageName, issues := range resp.Data.Packages {
+		for issueName, issueDetails := range issues {
+			if len(issueDetails) == 0 {
+				continue
+			}
+			v := convertVulnPackage(issueName, issueDetails[0])
+			v.Package = packageName
+			vulns = append(vulns, v)
+		}
+	}
+	return vulns, nil
+}
+
+// FindForCPE implements vuln.Client, serving whatever was registered in
+// cpeResponses for cpe - or an error, for tests that only exercise
+// FindForPackages and never populate it.
+func (m *mockVulnerabilityClient) FindForCPE(ctx context.Context, cpe string, max int) ([]vuln.Vulnerability, error) {
+	vulns, found := m.cpeResponses[cpe]
+	if !found {
+		return nil, fmt.Errorf("could not find the CPE request in our mock: %s", cpe)
+	}
+	return vulns, nil
 }
 
 // buildMockVulnClientKey is a helper function to build an appropriate key to load the mock.
@@ -42,7 +70,7 @@ func buildMockVulnClientKey(version string, os string, packages []string) string
 func buildMockedAPIVulnResponse(pkg string) *VulnResponse {
 	pkgs := make(map[string]map[string][]vulnPackage)
 	advisories := make(map[string][]vulnPackage)
-	advisories["ADVISORY"] = []vulnPackage{vulnPackage{Package: pkg}}
+	advisories["ADVISORY"] = []vulnPackage{vulnPackage{Package: pkg, Cvss: cvssScore{Score: 5.0}}}
 	pkgs[pkg] = advisories
 	data := vulnResponseData{Packages: pkgs}
 	return &VulnResponse{Result: "ok", Data: data}
@@ -0,0 +1,120 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package vulners
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/google/minions/go/minions/vuln"
+	"golang.org/x/net/context"
+)
+
+// VulnClient adapts Client, the raw Vulners API client, to the
+// vendor-neutral vuln.Client interface.
+type VulnClient struct {
+	client *Client
+}
+
+// NewVulnClient returns a vuln.Client backed by the Vulners API, using apiKey
+// (which may be empty, at the cost of a much lower rate limit).
+func NewVulnClient(apiKey string) *VulnClient {
+	return &VulnClient{client: NewClient(apiKey)}
+}
+
+// FindForPackages implements vuln.Client.
+func (c *VulnClient) FindForPackages(ctx context.Context, os, osVersion string, pkgs []string) ([]vuln.Vulnerability, error) {
+	resp, err := c.client.GetVulnerabilitiesForPackages(ctx, os, osVersion, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	var vulns []vuln.Vulnerability
+	for packageName, issues := range resp.Data.Packages {
+		for issueName, issueDetails := range issues {
+			if len(issueDetails) == 0 {
+				continue
+			}
+			v := convertVulnPackage(issueName, issueDetails[0])
+			v.Package = packageName
+			vulns = append(vulns, v)
+		}
+	}
+	return vulns, nil
+}
+
+// FindForCPE implements vuln.Client.
+//
+// GetVulnerabilitiesForCpe, unlike GetVulnerabilitiesForPackages, hands back
+// the raw Vulners JSON response as a string rather than a parsed struct, so
+// this only understands the "search" results shape Vulners' software/burp
+// endpoint documents; fields it doesn't recognize are ignored.
+func (c *VulnClient) FindForCPE(ctx context.Context, cpe string, max int) ([]vuln.Vulnerability, error) {
+	raw, err := c.client.GetVulnerabilitiesForCpe(ctx, cpe, max)
+	if err != nil {
+		return nil, err
+	}
+	var parsed struct {
+		Data struct {
+			Search []struct {
+				ID      string    `json:"id"`
+				Cvelist []string  `json:"cvelist"`
+				Cvss    cvssScore `json:"cvss"`
+			} `json:"search"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return nil, err
+	}
+	var vulns []vuln.Vulnerability
+	for _, s := range parsed.Data.Search {
+		vulns = append(vulns, vuln.Vulnerability{
+			ID:         s.ID,
+			Aliases:    s.Cvelist,
+			CVSSVector: s.Cvss.Vector,
+			CVSSScore:  s.Cvss.Score,
+			Severity:   vuln.SeverityFromCVSSScore(s.Cvss.Score),
+		})
+	}
+	return vulns, nil
+}
+
+// convertVulnPackage turns a single Vulners bulletin for a package into a
+// vuln.Vulnerability. Vulners reports an array of vulnPackage per bulletin,
+// but (as convertFinding used to note) they're really all describing the
+// same bulletin against slightly different provided/bulletin version pairs,
+// so only the first is used.
+func convertVulnPackage(bulletinID string, p vulnPackage) vuln.Vulnerability {
+	return vuln.Vulnerability{
+		ID:         bulletinID,
+		Aliases:    p.CveList,
+		CVSSVector: p.Cvss.Vector,
+		CVSSScore:  p.Cvss.Score,
+		FixedIn:    p.Fix,
+		References: cveReferences(p.CveList),
+		Severity:   vuln.SeverityFromCVSSScore(p.Cvss.Score),
+	}
+}
+
+// cveReferences builds NVD links for each CVE in cves, the way Vulners
+// findings have always pointed callers back at an authoritative source.
+func cveReferences(cves []string) []string {
+	if len(cves) == 0 {
+		return nil
+	}
+	refs := make([]string, len(cves))
+	for i, cve := range cves {
+		refs[i] = "https://nvd.nist.gov/vuln/detail/" + strings.TrimSpace(cve)
+	}
+	return refs
+}
@@ -0,0 +1,63 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import "sync"
+
+// chunkCache tracks, per scan and per minion, which DataChunk hashes
+// scanFilesStreaming has already forwarded - so a chunk shared across
+// files, or re-sent because several Interests matched the same file, only
+// goes over the wire once per minion per scan. It is deliberately scoped
+// to the streaming dispatch path (see scanFilesStreaming): the unary
+// AnalyzeFiles path has no per-chunk wire concept to dedupe against.
+type chunkCache struct {
+	mu   sync.Mutex
+	seen map[string]map[string]map[string]bool // scanID -> minion address -> hex(sha256) -> seen
+}
+
+// newChunkCache returns an empty chunkCache, ready to use.
+func newChunkCache() *chunkCache {
+	return &chunkCache{seen: make(map[string]map[string]map[string]bool)}
+}
+
+// seenOrRecord reports whether hash was already recorded for minion
+// address during scanID, recording it if not. Callers use the return
+// value to decide whether a chunk can be replaced by a bare
+// DataChunk.RefId instead of resending its bytes.
+func (c *chunkCache) seenOrRecord(scanID, address, hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	byMinion, ok := c.seen[scanID]
+	if !ok {
+		byMinion = make(map[string]map[string]bool)
+		c.seen[scanID] = byMinion
+	}
+	byHash, ok := byMinion[address]
+	if !ok {
+		byHash = make(map[string]bool)
+		byMinion[address] = byHash
+	}
+	wasSeen := byHash[hash]
+	byHash[hash] = true
+	return wasSeen
+}
+
+// forgetScan discards every hash recorded for scanID, so a long-lived
+// Server doesn't accumulate cache entries for scans that have finished.
+func (c *chunkCache) forgetScan(scanID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.seen, scanID)
+}
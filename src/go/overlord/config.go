@@ -0,0 +1,248 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/google/minions/go/overlord/config"
+	"github.com/google/minions/go/overlord/state"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// NewFromConfig is the config-file counterpart to New: instead of a flat
+// list of minion addresses, it reads a declarative config.Config (see
+// config.Load) that gives each minion its own TLS material,
+// enabled/disabled state, tags, path-scope globs and dispatch limits,
+// plus the EventSink(s) (see config.SinkConfig) to register for the
+// lifetime of this process. caCertPath is the default CA used to dial a
+// minion whose MinionConfig doesn't set its own CACert; tlsCertPath/
+// tlsKeyPath are likewise the default client cert presented to a minion
+// whose MinionConfig doesn't set its own TLSCert/TLSKey. Call WatchConfig
+// afterwards (in its own goroutine) to pick up edits to path on SIGHUP
+// without restarting - note that only the minion set is reloaded that
+// way, not the sinks (see setupSinks).
+func NewFromConfig(ctx context.Context, path, caCertPath, tlsCertPath, tlsKeyPath string) (*Server, error) {
+	server := &Server{
+		minions:           make(map[string]mpb.MinionClient),
+		minionConns:       make(map[string]*grpc.ClientConn),
+		minionConfigs:     make(map[string]config.MinionConfig),
+		streamingMinions:  make(map[string]bool),
+		stateManager:      state.NewLocal(),
+		dispatchOptions:   DefaultDispatchOptions,
+		defaultCACertPath: caCertPath,
+		defaultTLSCert:    tlsCertPath,
+		defaultTLSKey:     tlsKeyPath,
+		chunkCache:        newChunkCache(),
+	}
+	if err := server.LoadConfig(ctx, path); err != nil {
+		return nil, err
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := server.setupSinks(cfg.Sinks); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// LoadConfig (re-)reads path and reconciles it against the Server's
+// current minion set: new, enabled entries are dialed and folded into
+// s.initialInterests; entries that disappeared from path or turned
+// Disabled are dropped and their connection closed. A minion that is
+// already connected keeps its connection as-is (a changed TLS setting for
+// it is not redialed) and takes its tags, globs and limits from the new
+// entry, so editing one minion's tags doesn't churn every other minion's
+// connection. path is remembered, so a later SIGHUP via WatchConfig
+// reloads the same file.
+func (s *Server) LoadConfig(ctx context.Context, path string) error {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]config.MinionConfig, len(cfg.Minions))
+	for _, mc := range cfg.Minions {
+		if mc.Disabled {
+			continue
+		}
+		wanted[mc.Address] = mc
+	}
+
+	s.mu.Lock()
+	for addr, conn := range s.minionConns {
+		if _, ok := wanted[addr]; ok {
+			continue
+		}
+		if err := conn.Close(); err != nil {
+			log.Printf("overlord: closing connection to removed minion %s: %v", addr, err)
+		}
+		delete(s.minions, addr)
+		delete(s.minionConns, addr)
+		delete(s.minionConfigs, addr)
+		delete(s.streamingMinions, addr)
+	}
+	var toDial []config.MinionConfig
+	for addr, mc := range wanted {
+		if _, ok := s.minions[addr]; !ok {
+			toDial = append(toDial, mc)
+		} else {
+			s.minionConfigs[addr] = mc
+		}
+	}
+	s.mu.Unlock()
+
+	for _, mc := range toDial {
+		client, conn, err := dialMinion(mc, s.defaultCACertPath, s.defaultTLSCert, s.defaultTLSKey)
+		if err != nil {
+			return fmt.Errorf("config: dialing minion %s: %v", mc.Address, err)
+		}
+		s.mu.Lock()
+		s.minions[mc.Address] = client
+		s.minionConns[mc.Address] = conn
+		s.minionConfigs[mc.Address] = mc
+		s.mu.Unlock()
+	}
+
+	s.mu.RLock()
+	minions := s.minions
+	s.mu.RUnlock()
+	interests, streaming, err := getInterestsFromMinions(ctx, s.dispatchOptions, minions)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.initialInterests = interests
+	for addr, supports := range streaming {
+		s.streamingMinions[addr] = supports
+	}
+	s.configPath = path
+	s.mu.Unlock()
+
+	log.Printf("overlord: loaded %s: %d minions configured", path, len(wanted))
+	return nil
+}
+
+// dialMinion dials mc.Address, using mc.CACert/mc.TLSCert/mc.TLSKey in
+// place of defaultCACertPath/defaultTLSCert/defaultTLSKey when set.
+func dialMinion(mc config.MinionConfig, defaultCACertPath, defaultTLSCert, defaultTLSKey string) (mpb.MinionClient, *grpc.ClientConn, error) {
+	caCertPath := defaultCACertPath
+	if mc.CACert != "" {
+		caCertPath = mc.CACert
+	}
+	tlsCertPath, tlsKeyPath := defaultTLSCert, defaultTLSKey
+	if mc.TLSCert != "" && mc.TLSKey != "" {
+		tlsCertPath, tlsKeyPath = mc.TLSCert, mc.TLSKey
+	}
+	host := strings.Split(mc.Address, ":")[0] // If we have a port, extract hostname
+	opts, err := dialOptions(host, caCertPath, tlsCertPath, tlsKeyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := grpc.Dial(mc.Address, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mpb.NewMinionClient(conn), conn, nil
+}
+
+// WatchConfig blocks, reloading s.configPath (see LoadConfig) every time
+// the process receives SIGHUP, until ctx is done. A reload failure is
+// logged rather than fatal, so a typo in an edited config file doesn't
+// take down an Overlord that's otherwise running fine - the previous,
+// still-valid configuration stays in effect until the file is fixed and
+// another SIGHUP arrives. Run it in its own goroutine, e.g.
+// "go server.WatchConfig(ctx)".
+func (s *Server) WatchConfig(ctx context.Context) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			s.mu.RLock()
+			path := s.configPath
+			s.mu.RUnlock()
+			log.Printf("overlord: SIGHUP received, reloading %s", path)
+			if err := s.LoadConfig(ctx, path); err != nil {
+				log.Printf("overlord: reloading %s: %v", path, err)
+			}
+		}
+	}
+}
+
+// matchesPathScope reports whether path should be routed to minion,
+// honoring its config.MinionConfig.PathScopeGlobs, if any were set via
+// LoadConfig (doublestar syntax, the same one ScanFilters.ExcludedPathGlobs
+// uses - see excludedByFilters). A minion with no globs configured, e.g.
+// one added through New's flat -minions list rather than LoadConfig,
+// matches every path, preserving pre-config-file behavior.
+func (s *Server) matchesPathScope(minion, path string) (bool, error) {
+	s.mu.RLock()
+	globs := s.minionConfigs[minion].PathScopeGlobs
+	s.mu.RUnlock()
+	if len(globs) == 0 {
+		return true, nil
+	}
+	for _, glob := range globs {
+		match, err := doublestar.Match(glob, strings.TrimPrefix(path, "/"))
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ListMinions reports the Server's current minion configuration - every
+// enabled minion's address, tags, path-scope globs, dispatch limits and
+// whether it supports AnalyzeFilesStream - so an operator or deployment
+// tool can see the effective configuration without reading the config
+// file a running Overlord was last reloaded from.
+func (s *Server) ListMinions(ctx context.Context, req *pb.ListMinionsRequest) (*pb.ListMinionsResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	resp := &pb.ListMinionsResponse{}
+	for addr := range s.minions {
+		mc := s.minionConfigs[addr]
+		resp.Minions = append(resp.Minions, &pb.MinionInfo{
+			Address:           addr,
+			Tags:              mc.Tags,
+			PathScopeGlobs:    mc.PathScopeGlobs,
+			TimeoutSeconds:    int64(mc.Timeout.Seconds()),
+			MaxConcurrency:    int32(mc.MaxConcurrency),
+			SupportsStreaming: s.streamingMinions[addr],
+		})
+	}
+	return resp, nil
+}
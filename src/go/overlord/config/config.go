@@ -0,0 +1,171 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+/*
+Package config parses the minion declarations an Overlord reads from its
+--config file (see overlord.Server.LoadConfig): each minion's address, TLS
+material, enabled/disabled state, tags, path-scope globs and per-minion
+dispatch limits, in either YAML or JSON.
+*/
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MinionConfig declares one minion an Overlord should dial and route
+// files to.
+type MinionConfig struct {
+	// Address is the minion's gRPC address, e.g. "localhost:10001".
+	Address string
+	// TLSCert and TLSKey, if set, are presented as this Overlord's client
+	// certificate when dialing Address, instead of the Overlord-wide
+	// default.
+	TLSCert, TLSKey string
+	// CACert, if set, validates Address's server certificate instead of
+	// the CA passed on the Overlord's command line.
+	CACert string
+	// Disabled minions are parsed but never dialed or routed to - the
+	// config-file equivalent of commenting the minion out, without
+	// losing its declaration.
+	Disabled bool
+	// Tags are free-form labels a deployment can use to group minions;
+	// surfaced read-only through the Overlord's ListMinions RPC.
+	Tags []string
+	// PathScopeGlobs, if non-empty, confines this minion to files whose
+	// path matches at least one glob (doublestar syntax, the same one
+	// ScanFilters.ExcludedPathGlobs uses) - a scan never dispatches a
+	// file outside these globs to it, regardless of what interests it
+	// declared.
+	PathScopeGlobs []string
+	// Timeout tightens DispatchOptions.MinionCallTimeout for the file
+	// analysis calls dispatched to this minion: the shorter of the two
+	// applies, so a value above the Server-wide default has no effect.
+	// Zero means use the Server-wide default alone.
+	Timeout time.Duration
+	// MaxConcurrency is reported through the Overlord's ListMinions RPC;
+	// dispatch does not apply it, so calls to this minion are bounded by
+	// DispatchOptions.MaxConcurrency alone.
+	MaxConcurrency int
+}
+
+// SinkConfig declares one EventSink an Overlord should register at
+// startup (see overlord.Server.setupSinks).
+type SinkConfig struct {
+	// Type selects the sink implementation: "webhook" or "file".
+	Type string
+	// URL is the HTTP(S) endpoint a "webhook" sink POSTs events to.
+	// Ignored for other types.
+	URL string
+	// Secret, if set, HMAC-SHA256-signs a "webhook" sink's deliveries
+	// (see overlord.WithWebhookSecret). Ignored for other types.
+	Secret string
+	// Path is the JSONL file a "file" sink appends events to. Ignored
+	// for other types.
+	Path string
+}
+
+// Config is the top-level shape of an Overlord --config file.
+type Config struct {
+	Minions []MinionConfig
+	Sinks   []SinkConfig
+}
+
+// rawConfig, rawMinionConfig and rawSinkConfig mirror Config,
+// MinionConfig and SinkConfig as they're actually written in a config
+// file: Timeout is a parseable duration string (e.g. "5s"), since
+// neither encoding/json nor yaml.v3 knows how to decode one of those
+// straight into a time.Duration. Load converts one into the other.
+type rawConfig struct {
+	Minions []rawMinionConfig `yaml:"minions" json:"minions"`
+	Sinks   []rawSinkConfig   `yaml:"sinks,omitempty" json:"sinks,omitempty"`
+}
+
+type rawSinkConfig struct {
+	Type   string `yaml:"type" json:"type"`
+	URL    string `yaml:"url,omitempty" json:"url,omitempty"`
+	Secret string `yaml:"secret,omitempty" json:"secret,omitempty"`
+	Path   string `yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+type rawMinionConfig struct {
+	Address        string   `yaml:"address" json:"address"`
+	TLSCert        string   `yaml:"tls_cert,omitempty" json:"tls_cert,omitempty"`
+	TLSKey         string   `yaml:"tls_key,omitempty" json:"tls_key,omitempty"`
+	CACert         string   `yaml:"ca_cert,omitempty" json:"ca_cert,omitempty"`
+	Disabled       bool     `yaml:"disabled,omitempty" json:"disabled,omitempty"`
+	Tags           []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	PathScopeGlobs []string `yaml:"path_scope_globs,omitempty" json:"path_scope_globs,omitempty"`
+	Timeout        string   `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	MaxConcurrency int      `yaml:"max_concurrency,omitempty" json:"max_concurrency,omitempty"`
+}
+
+// Load reads and parses the config file at path, choosing YAML or JSON
+// based on its extension (.yaml, .yml or .json).
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: reading %s: %v", path, err)
+	}
+
+	var raw rawConfig
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config: %s: unrecognized extension %q, want .yaml, .yml or .json", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: parsing %s: %v", path, err)
+	}
+
+	cfg := &Config{Minions: make([]MinionConfig, len(raw.Minions))}
+	for i, r := range raw.Minions {
+		var timeout time.Duration
+		if r.Timeout != "" {
+			if timeout, err = time.ParseDuration(r.Timeout); err != nil {
+				return nil, fmt.Errorf("config: %s: minion %s: invalid timeout %q: %v", path, r.Address, r.Timeout, err)
+			}
+		}
+		cfg.Minions[i] = MinionConfig{
+			Address:        r.Address,
+			TLSCert:        r.TLSCert,
+			TLSKey:         r.TLSKey,
+			CACert:         r.CACert,
+			Disabled:       r.Disabled,
+			Tags:           r.Tags,
+			PathScopeGlobs: r.PathScopeGlobs,
+			Timeout:        timeout,
+			MaxConcurrency: r.MaxConcurrency,
+		}
+	}
+
+	for _, r := range raw.Sinks {
+		cfg.Sinks = append(cfg.Sinks, SinkConfig{
+			Type:   r.Type,
+			URL:    r.URL,
+			Secret: r.Secret,
+			Path:   r.Path,
+		})
+	}
+	return cfg, nil
+}
@@ -0,0 +1,118 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoad_yaml(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "minions.yaml")
+	contents := `
+minions:
+  - address: localhost:10001
+    tags: ["prod"]
+  - address: localhost:10002
+    disabled: true
+    ca_cert: /etc/minions/ca.pem
+    path_scope_globs: ["vendor/**"]
+    timeout: 5s
+    max_concurrency: 2
+`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(%s): unexpected error %v", path, err)
+	}
+
+	want := &Config{Minions: []MinionConfig{
+		{Address: "localhost:10001", Tags: []string{"prod"}},
+		{Address: "localhost:10002", Disabled: true, CACert: "/etc/minions/ca.pem", PathScopeGlobs: []string{"vendor/**"}, Timeout: 5 * time.Second, MaxConcurrency: 2},
+	}}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Load(%s) = %+v, want %+v", path, cfg, want)
+	}
+}
+
+func TestLoad_json(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "minions.json")
+	contents := `{"minions": [{"address": "localhost:10001", "tags": ["prod"]}]}`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(%s): unexpected error %v", path, err)
+	}
+
+	want := &Config{Minions: []MinionConfig{{Address: "localhost:10001", Tags: []string{"prod"}}}}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Load(%s) = %+v, want %+v", path, cfg, want)
+	}
+}
+
+func TestLoad_sinks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "minions.yaml")
+	contents := `
+minions:
+  - address: localhost:10001
+sinks:
+  - type: webhook
+    url: https://example.com/hook
+    secret: s3cr3t
+  - type: file
+    path: /var/log/minions/events.jsonl
+`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load(%s): unexpected error %v", path, err)
+	}
+
+	want := &Config{
+		Minions: []MinionConfig{{Address: "localhost:10001"}},
+		Sinks: []SinkConfig{
+			{Type: "webhook", URL: "https://example.com/hook", Secret: "s3cr3t"},
+			{Type: "file", Path: "/var/log/minions/events.jsonl"},
+		},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("Load(%s) = %+v, want %+v", path, cfg, want)
+	}
+}
+
+func TestLoad_unrecognizedExtension(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "minions.toml")
+	if err := os.WriteFile(path, []byte(""), 0644); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Errorf("Load(%s): expected an error for an unrecognized extension, got nil", path)
+	}
+}
@@ -0,0 +1,128 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DispatchOptions configures how the Server fans RPCs out to minions: how
+// many run concurrently, how long a single call may take, and how a
+// retriable failure is retried before that minion is given up on.
+type DispatchOptions struct {
+	// MaxConcurrency bounds how many minion calls run at once.
+	MaxConcurrency int
+	// MinionCallTimeout is the deadline given to each individual call,
+	// including retries - a retry gets a fresh MinionCallTimeout, not a
+	// share of the first attempt's.
+	MinionCallTimeout time.Duration
+	// MaxAttempts is how many times a single minion call is tried in
+	// total before its error is given up on.
+	MaxAttempts int
+	// RetryBaseDelay is the backoff before the first retry; it doubles
+	// on every subsequent attempt.
+	RetryBaseDelay time.Duration
+}
+
+// DefaultDispatchOptions are the options New starts the Server with, until
+// SetDispatchOptions overrides them.
+var DefaultDispatchOptions = DispatchOptions{
+	MaxConcurrency:    8,
+	MinionCallTimeout: 30 * time.Second,
+	MaxAttempts:       3,
+	RetryBaseDelay:    200 * time.Millisecond,
+}
+
+// SetDispatchOptions overrides the concurrency, per-call deadline and
+// retry policy used whenever the Server fans RPCs out to several minions
+// at once - both in ScanFiles and while a LoadConfig reload collects
+// initial interests. New and NewFromConfig collect their first set with
+// DefaultDispatchOptions, before SetDispatchOptions can be called.
+func (s *Server) SetDispatchOptions(opts DispatchOptions) {
+	s.dispatchOptions = opts
+}
+
+// dispatch runs call(ctx, key) for every key concurrently, bounded by
+// opts.MaxConcurrency, retrying a call up to opts.MaxAttempts times with
+// exponential backoff if it fails with a retriable gRPC code (see
+// isRetriable). A call's error is returned keyed by the key that produced
+// it rather than aborting the others, so one unreachable minion can't
+// block or cancel every other in-flight call.
+func dispatch(ctx context.Context, opts DispatchOptions, keys []string, call func(ctx context.Context, key string) error) map[string]error {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(opts.MaxConcurrency)
+
+	var mu sync.Mutex
+	errs := make(map[string]error)
+	for _, key := range keys {
+		key := key
+		g.Go(func() error {
+			if err := callWithRetry(gctx, opts, key, call); err != nil {
+				mu.Lock()
+				errs[key] = err
+				mu.Unlock()
+			}
+			// Never return a non-nil error here: errgroup cancels gctx and
+			// stops scheduling new goroutines on the first one, which is
+			// exactly the "one bad minion blocks the scan" behavior we're
+			// trying to get rid of.
+			return nil
+		})
+	}
+	g.Wait()
+	return errs
+}
+
+// callWithRetry calls call(ctx, key) under a fresh opts.MinionCallTimeout
+// deadline each attempt, retrying up to opts.MaxAttempts times with
+// exponential backoff while the error is retriable.
+func callWithRetry(ctx context.Context, opts DispatchOptions, key string, call func(ctx context.Context, key string) error) error {
+	var lastErr error
+	delay := opts.RetryBaseDelay
+	for attempt := 1; attempt <= opts.MaxAttempts; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, opts.MinionCallTimeout)
+		lastErr = call(callCtx, key)
+		cancel()
+		if lastErr == nil {
+			return nil
+		}
+		if attempt == opts.MaxAttempts || !isRetriable(lastErr) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return lastErr
+}
+
+// isRetriable reports whether err is a transient gRPC failure worth
+// retrying rather than a durable one (e.g. an unimplemented RPC).
+func isRetriable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,89 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"fmt"
+
+	"github.com/google/minions/go/overlord/report"
+
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// ExportReport renders scanID's accumulated files and findings into the
+// document format req asks for (see report.Format) and streams it back to
+// the caller in chunks of at most streamChunkSize bytes - the same chunk
+// size scanFilesStreaming uses for outbound file data.
+func (s *Server) ExportReport(req *pb.ExportReportRequest, stream pb.Overlord_ExportReportServer) error {
+	scanID := req.GetScanId()
+	if !s.stateManager.ScanExists(scanID) {
+		return fmt.Errorf("unknown scan ID %s", scanID)
+	}
+
+	format, err := reportFormat(req.GetFormat())
+	if err != nil {
+		return err
+	}
+
+	files, err := s.stateManager.GetFiles(scanID)
+	if err != nil {
+		return err
+	}
+	findings, err := s.stateManager.GetFindings(scanID)
+	if err != nil {
+		return err
+	}
+
+	doc, err := report.Render(format, scanID, files, findings)
+	if err != nil {
+		return err
+	}
+
+	if len(doc) == 0 {
+		return stream.Send(&pb.ExportReportResponse{ContentType: format.ContentType(), Eof: true})
+	}
+	for offset := 0; offset < len(doc); offset += streamChunkSize {
+		end := offset + streamChunkSize
+		if end > len(doc) {
+			end = len(doc)
+		}
+		chunk := &pb.ExportReportResponse{
+			Data: doc[offset:end],
+			Eof:  end == len(doc),
+		}
+		if offset == 0 {
+			chunk.ContentType = format.ContentType()
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportFormat maps the wire enum pb.ExportFormat to report.Format.
+func reportFormat(f pb.ExportFormat) (report.Format, error) {
+	switch f {
+	case pb.ExportFormat_SPDX_JSON:
+		return report.SPDXJSON, nil
+	case pb.ExportFormat_SPDX_TAG_VALUE:
+		return report.SPDXTagValue, nil
+	case pb.ExportFormat_CYCLONEDX_JSON:
+		return report.CycloneDXJSON, nil
+	case pb.ExportFormat_HTML_NOTICE:
+		return report.HTMLNotice, nil
+	default:
+		return 0, fmt.Errorf("unknown export format %v", f)
+	}
+}
@@ -0,0 +1,95 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// fileSinkEvent is the JSONL record fileSink appends, one per line, one
+// line per EventSink call - deliberately the same shape as WebhookEvent
+// minus NewInterests (fileSink only ever sees the three EventSink event
+// types), plus Report for OnScanCompleted.
+type fileSinkEvent struct {
+	Type     WebhookEventType `json:"type"`
+	ScanID   string           `json:"scan_id"`
+	Minion   string           `json:"minion,omitempty"`
+	Findings []*mpb.Finding   `json:"findings,omitempty"`
+	Report   *pb.ScanReport   `json:"report,omitempty"`
+	Ts       time.Time        `json:"ts"`
+}
+
+// fileSink appends every lifecycle event as one JSON line to a file, for
+// operators who want a durable local record (or something to tail -f and
+// pipe into a log aggregator) without standing up an HTTP receiver - see
+// SetFileSink.
+//
+// mu serializes writes: events can arrive from several ScanFiles calls
+// concurrently, and os.File.Write isn't guaranteed atomic across
+// goroutines for writes that don't fit in one syscall.
+type fileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// SetFileSink registers an EventSink that appends every lifecycle event
+// to path as a JSON line (creating it if needed). Calling SetFileSink
+// more than once registers additional sinks; every event goes out to all
+// of them, same as SetWebhook.
+func (s *Server) SetFileSink(path string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("overlord: opening file sink %s: %v", path, err)
+	}
+	s.addSink(&fileSink{f: f})
+	return nil
+}
+
+// OnScanCreated implements EventSink.
+func (fs *fileSink) OnScanCreated(scanID string) {
+	fs.writeLine(fileSinkEvent{Type: EventScanCreated, ScanID: scanID, Ts: time.Now()})
+}
+
+// OnFindingsProduced implements EventSink.
+func (fs *fileSink) OnFindingsProduced(scanID, minion string, findings []*mpb.Finding) {
+	fs.writeLine(fileSinkEvent{Type: EventFindingEmitted, ScanID: scanID, Minion: minion, Findings: findings, Ts: time.Now()})
+}
+
+// OnScanCompleted implements EventSink.
+func (fs *fileSink) OnScanCompleted(scanID string, report *pb.ScanReport) {
+	fs.writeLine(fileSinkEvent{Type: EventScanCompleted, ScanID: scanID, Report: report, Ts: time.Now()})
+}
+
+func (fs *fileSink) writeLine(e fileSinkEvent) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("file sink: marshaling %s event for scan %s: %v", e.Type, e.ScanID, err)
+		return
+	}
+	data = append(data, '\n')
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if _, err := fs.f.Write(data); err != nil {
+		log.Printf("file sink: writing %s event for scan %s: %v", e.Type, e.ScanID, err)
+	}
+}
@@ -0,0 +1,57 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"path"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// excludedByFilters reports whether file should be dropped rather than
+// matched against interests, per filters. A nil filters never excludes
+// anything.
+func excludedByFilters(filters *pb.ScanFilters, file *pb.File) (bool, error) {
+	if filters == nil {
+		return false, nil
+	}
+	filePath := file.GetMetadata().GetPath()
+
+	for _, glob := range filters.GetExcludedPathGlobs() {
+		match, err := doublestar.Match(glob, strings.TrimPrefix(filePath, "/"))
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return true, nil
+		}
+	}
+
+	if ext := strings.TrimPrefix(path.Ext(filePath), "."); ext != "" {
+		for _, excluded := range filters.GetExcludedExtensions() {
+			if strings.EqualFold(ext, strings.TrimPrefix(excluded, ".")) {
+				return true, nil
+			}
+		}
+	}
+
+	if max := filters.GetMaxFileSize(); max > 0 && file.GetMetadata().GetSize() > max {
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,370 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/minions/go/overlord/registry"
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+	"golang.org/x/net/context"
+)
+
+// LayerFindings aggregates the Findings contributed by a single image layer,
+// so callers can tell which layer introduced a vulnerable package or
+// configuration file.
+type LayerFindings struct {
+	LayerDigest string
+	Findings    []*mpb.Finding
+}
+
+// ImageScanResponse is the result of scanning a container image: Findings
+// grouped by the layer digest that introduced them.
+type ImageScanResponse struct {
+	ImageRef    string
+	ImageDigest string // The "@sha256:..." digest imageRef resolved to, if it named one.
+	Layers      []LayerFindings
+}
+
+// ScanImage implements the ScanImage RPC: it resolves req's image reference
+// to an ImageFetcher based on its scheme and scans it exactly as
+// scanImageWithFetcher does, returning the findings over the wire grouped
+// by the layer that introduced them.
+//
+// Supported imageRef schemes:
+//   - "docker://<ref>" or a bare "<ref>": pulled from a live v2 registry.
+//   - "oci-layout://<dir>[#<ref>]": a local OCI image-layout directory.
+//   - "docker-archive://<path>[#<ref>]": a local `docker save` tarball.
+//
+// A "containerd://<namespace>/<image>" scheme, for scanning an image
+// already present in a containerd content store, is not implemented yet:
+// it needs an ImageFetcher reading from containerd's content store
+// directly, which is a separate piece of work from the mount-time
+// integration added for docker.Mount.
+func (s *Server) ScanImage(ctx context.Context, req *pb.ScanImageRequest) (*pb.ScanImageResponse, error) {
+	fetcher, ref, err := fetcherForImageRef(req.GetImageRef())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.scanImageWithFetcher(ctx, fetcher, ref)
+	if err != nil {
+		return nil, err
+	}
+	return toScanImageResponse(resp), nil
+}
+
+// fetcherForImageRef picks the registry.ImageFetcher to use for imageRef
+// based on its scheme (see ScanImage), returning the scheme-stripped
+// reference to pass to that fetcher's Manifest/Layer calls.
+func fetcherForImageRef(imageRef string) (registry.ImageFetcher, string, error) {
+	switch {
+	case strings.HasPrefix(imageRef, "oci-layout://"):
+		dir, ref := splitDirAndRef(strings.TrimPrefix(imageRef, "oci-layout://"))
+		return registry.NewOCILayoutFetcher(dir), ref, nil
+	case strings.HasPrefix(imageRef, "docker-archive://"):
+		pathAndRef, ref := splitDirAndRef(strings.TrimPrefix(imageRef, "docker-archive://"))
+		return registry.NewDockerArchiveFetcher(pathAndRef), ref, nil
+	case strings.HasPrefix(imageRef, "containerd://"):
+		return nil, "", errors.New("overlord: containerd:// image references are not supported yet")
+	default:
+		return registry.NewRegistryFetcher(nil), strings.TrimPrefix(imageRef, "docker://"), nil
+	}
+}
+
+// splitDirAndRef splits a "dir#ref" scheme-stripped reference into its
+// directory and optional ref (e.g. a tag) parts; ref is empty if there's no
+// "#", letting the underlying fetcher pick its own default.
+func splitDirAndRef(s string) (dir, ref string) {
+	if i := strings.LastIndex(s, "#"); i != -1 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// toScanImageResponse converts the Go-native ImageScanResponse into its
+// proto counterpart.
+func toScanImageResponse(r *ImageScanResponse) *pb.ScanImageResponse {
+	resp := &pb.ScanImageResponse{
+		ImageRef:    r.ImageRef,
+		ImageDigest: r.ImageDigest,
+	}
+	for _, l := range r.Layers {
+		resp.Layers = append(resp.Layers, &pb.LayerFindings{
+			LayerDigest: l.LayerDigest,
+			Findings:    l.Findings,
+		})
+	}
+	return resp
+}
+
+// imageDigestFromRef returns the "sha256:..." digest portion of ref if it
+// names one (i.e. contains an "@"), or "" if ref only names a tag - the
+// digest of a tag is only known once its manifest is actually fetched, and
+// none of our ImageFetchers currently hand back the raw manifest bytes
+// needed to compute it.
+func imageDigestFromRef(ref string) string {
+	if i := strings.LastIndex(ref, "@"); i != -1 {
+		return ref[i+1:]
+	}
+	return ""
+}
+
+// scanImageWithFetcher pulls imageRef via fetcher, computes the image's
+// merged rootfs (honoring whiteouts, see computeMergedRootfs) and
+// dispatches files matching the Overlord's known Interests to the
+// appropriate Minions, exactly as ScanFiles would for a live filesystem.
+// Results are reported per-layer so a caller can tell which layer
+// introduced a given Finding. If the Server was built with a
+// registry.LayerCache (see SetLayerCache), a layer whose surviving files
+// were already analyzed in a previous scan is never re-analyzed.
+func (s *Server) scanImageWithFetcher(ctx context.Context, fetcher registry.ImageFetcher, imageRef string) (*ImageScanResponse, error) {
+	manifest, err := fetcher.Manifest(ctx, imageRef)
+	if err != nil {
+		return nil, fmt.Errorf("scanning image %q: %v", imageRef, err)
+	}
+	rootfs, err := computeMergedRootfs(ctx, fetcher, imageRef, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("computing rootfs for %q: %v", imageRef, err)
+	}
+
+	pathsByLayer := make(map[string][]string)
+	for p, entry := range rootfs {
+		pathsByLayer[entry.layerDigest] = append(pathsByLayer[entry.layerDigest], p)
+	}
+
+	resp := &ImageScanResponse{ImageRef: imageRef, ImageDigest: imageDigestFromRef(imageRef)}
+	for _, layer := range manifest.Layers {
+		paths := pathsByLayer[layer.Digest]
+		if len(paths) == 0 {
+			// Nothing this layer contributed survives in the final rootfs:
+			// every candidate file was either overwritten or whited out by
+			// a later layer.
+			continue
+		}
+
+		if s.layerCache != nil {
+			if cached, found := s.layerCache.Get(layer.Digest); found {
+				resp.Layers = append(resp.Layers, LayerFindings{LayerDigest: layer.Digest, Findings: cached.Findings})
+				continue
+			}
+		}
+
+		findings, err := s.scanLayerPaths(ctx, fetcher, imageRef, layer.Digest, paths)
+		if err != nil {
+			return nil, fmt.Errorf("scanning layer %s of %q: %v", layer.Digest, imageRef, err)
+		}
+		if s.layerCache != nil {
+			if err := s.layerCache.Put(layer.Digest, &registry.LayerResult{Findings: findings}); err != nil {
+				return nil, fmt.Errorf("caching layer %s: %v", layer.Digest, err)
+			}
+		}
+		resp.Layers = append(resp.Layers, LayerFindings{LayerDigest: layer.Digest, Findings: findings})
+	}
+	return resp, nil
+}
+
+// rootfsEntry is the winning (not-since-whited-out-or-overwritten) tar
+// entry for a single path in an image's merged rootfs.
+type rootfsEntry struct {
+	layerDigest string
+}
+
+const (
+	// whiteoutPrefix marks a regular single-file whiteout: a layer entry
+	// named ".wh.foo" means "foo" was deleted as of this layer, per the
+	// OCI image spec's whiteout convention.
+	whiteoutPrefix = ".wh."
+	// whiteoutOpaqueMarker marks a directory as "opaque": none of the
+	// entries contributed by earlier layers under it should survive,
+	// though the directory itself (and anything this layer adds under it)
+	// does.
+	whiteoutOpaqueMarker = ".wh..wh..opq"
+)
+
+// computeMergedRootfs walks manifest's layers oldest-to-newest and returns
+// the path -> winning-layer map that survives after every layer's
+// whiteouts and overwrites are applied - i.e. what a union filesystem
+// would actually present as the image's rootfs. It only tracks regular
+// files, since those are all AnalyzeFiles ever matches Interests against.
+//
+// This walks every layer's tar stream a second time, in scanLayerPaths,
+// to pull the winning entries' data back out; buffering every layer's
+// headers (or contents) from this pass would trade network/CPU for a
+// possibly large amount of memory, which isn't an obviously better
+// trade-off for the image sizes this is meant to handle.
+func computeMergedRootfs(ctx context.Context, fetcher registry.ImageFetcher, imageRef string, manifest *registry.Manifest) (map[string]rootfsEntry, error) {
+	rootfs := make(map[string]rootfsEntry)
+	for _, layer := range manifest.Layers {
+		if err := applyLayerToRootfs(ctx, fetcher, imageRef, layer.Digest, rootfs); err != nil {
+			return nil, fmt.Errorf("layer %s: %v", layer.Digest, err)
+		}
+	}
+	return rootfs, nil
+}
+
+func applyLayerToRootfs(ctx context.Context, fetcher registry.ImageFetcher, imageRef, digest string, rootfs map[string]rootfsEntry) error {
+	rc, err := fetcher.Layer(ctx, imageRef, digest)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tr, closeFn, err := tarReaderFor(rc)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + hdr.Name)
+		dir, base := path.Split(name)
+		dir = path.Clean(dir)
+
+		if base == whiteoutOpaqueMarker {
+			removeUnderDir(rootfs, dir)
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			// The whited-out name can itself be a directory from an
+			// earlier layer, not just a file - removeUnderDir drops the
+			// exact path too, so this also covers the single-file case.
+			removeUnderDir(rootfs, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		rootfs[name] = rootfsEntry{layerDigest: digest}
+	}
+	return nil
+}
+
+// removeUnderDir drops every rootfs entry at or below dir, implementing an
+// opaque-directory whiteout.
+func removeUnderDir(rootfs map[string]rootfsEntry, dir string) {
+	for p := range rootfs {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			delete(rootfs, p)
+		}
+	}
+}
+
+// scanLayerPaths re-streams a single layer and matches the entries in
+// wantedPaths (already known to survive in the image's final rootfs,
+// see computeMergedRootfs) against the Overlord's known Interests,
+// dispatching the matched files to the owning Minions via AnalyzeFiles.
+func (s *Server) scanLayerPaths(ctx context.Context, fetcher registry.ImageFetcher, imageRef, digest string, wantedPaths []string) ([]*mpb.Finding, error) {
+	wanted := make(map[string]bool, len(wantedPaths))
+	for _, p := range wantedPaths {
+		wanted[p] = true
+	}
+
+	rc, err := fetcher.Layer(ctx, imageRef, digest)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	tr, closeFn, err := tarReaderFor(rc)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	routedFiles := make(map[string][]*mpb.File)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("walking layer %s: %v", digest, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		filePath := path.Clean("/" + hdr.Name)
+		if !wanted[filePath] {
+			continue
+		}
+
+		for _, candidate := range s.initialInterests {
+			match, err := regexp.MatchString(candidate.Interest.GetPathRegexp(), filePath)
+			if err != nil || !match {
+				continue
+			}
+			metadata := &mpb.FileMetadata{
+				Path:        filePath,
+				Size:        hdr.Size,
+				Permissions: uint32(hdr.Mode),
+				OwnerUid:    int32(hdr.Uid),
+				OwnerGid:    int32(hdr.Gid),
+			}
+			f := &mpb.File{Metadata: metadata}
+			if candidate.Interest.GetDataType() == mpb.Interest_METADATA_AND_DATA {
+				data, err := ioutil.ReadAll(tr)
+				if err != nil {
+					return nil, fmt.Errorf("reading %s from layer %s: %v", filePath, digest, err)
+				}
+				f.Data = data
+			}
+			routedFiles[candidate.Minion] = append(routedFiles[candidate.Minion], f)
+		}
+	}
+
+	var findings []*mpb.Finding
+	for address, files := range routedFiles {
+		minion, present := s.minions[address]
+		if !present {
+			return nil, fmt.Errorf("interest expressed by a minion that is not known to the Overlord, %q", address)
+		}
+		minionResp, err := minion.AnalyzeFiles(ctx, &mpb.AnalyzeFilesRequest{Files: files})
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, minionResp.GetFindings()...)
+	}
+	return findings, nil
+}
+
+// tarReaderFor transparently decompresses a layer blob (gzip, or a bare
+// tar if it wasn't compressed at all) and returns a ready-to-walk
+// *tar.Reader along with a function to release any resources it holds.
+//
+// TODO(paradoxengine): add zstd support once the new OCI media types
+// (application/vnd.oci.image.layer.v1.tar+zstd) are in wider use.
+func tarReaderFor(r io.Reader) (*tar.Reader, func(), error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		// Not gzip: assume an already-uncompressed tar stream.
+		return tar.NewReader(r), func() {}, nil
+	}
+	return tar.NewReader(gz), func() { gz.Close() }, nil
+}
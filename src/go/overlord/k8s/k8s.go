@@ -0,0 +1,121 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+// Package k8s lets the Overlord discover running Pods in a cluster and
+// read files out of their containers, so ScanKubernetesNamespace can drive
+// AnalyzeFiles against live workloads the same way ScanImage drives it
+// against image layers (see overlord/image_scan.go).
+//
+// There is no equivalent of a layer's tar listing to walk here, so a
+// Scanner can only resolve interests with a literal (non-regexp) path: it
+// execs `cat <path>` in the target container and reports "not found" if
+// the file isn't there. Matching a regexp interest against a live
+// container's filesystem would need either a `find`-based directory walk
+// or an ephemeral debug container mounting the target's rootfs; both are
+// left for follow-up work rather than folded into this package.
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// ContainerRef identifies a single container within a running Pod.
+type ContainerRef struct {
+	Namespace string
+	Pod       string
+	Container string
+}
+
+// PodScanner lists the containers running in a cluster and reads files
+// out of them. The Overlord depends on this interface rather than Scanner
+// directly, the same way it depends on registry.ImageFetcher rather than
+// a concrete fetcher - see overlord.Server.SetPodScanner.
+type PodScanner interface {
+	// ListContainers returns every container of every running Pod in
+	// namespace matching labelSelector ("" matches every Pod).
+	ListContainers(ctx context.Context, namespace, labelSelector string) ([]ContainerRef, error)
+	// ReadFile returns the contents of path inside ref's container, or an
+	// error if it couldn't be read (including because it doesn't exist).
+	ReadFile(ctx context.Context, ref ContainerRef, path string) ([]byte, error)
+}
+
+// Scanner is a PodScanner backed by a live Kubernetes API server,
+// authenticating both the Pod listing and the exec sessions it opens to
+// read files with config.
+type Scanner struct {
+	client kubernetes.Interface
+	config *rest.Config
+}
+
+// NewScanner returns a Scanner that lists Pods through client and execs
+// into their containers using config for the exec subresource's SPDY
+// upgrade. config is typically the same *rest.Config client was built
+// from - see rest.InClusterConfig or clientcmd for how to obtain one.
+func NewScanner(client kubernetes.Interface, config *rest.Config) *Scanner {
+	return &Scanner{client: client, config: config}
+}
+
+// ListContainers implements PodScanner.
+func (s *Scanner) ListContainers(ctx context.Context, namespace, labelSelector string) ([]ContainerRef, error) {
+	pods, err := s.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, fmt.Errorf("k8s: listing pods in %q: %v", namespace, err)
+	}
+
+	var refs []ContainerRef
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		for _, c := range pod.Spec.Containers {
+			refs = append(refs, ContainerRef{Namespace: pod.Namespace, Pod: pod.Name, Container: c.Name})
+		}
+	}
+	return refs, nil
+}
+
+// ReadFile implements PodScanner by exec'ing `cat path` inside ref's
+// container and returning its stdout.
+func (s *Scanner) ReadFile(ctx context.Context, ref ContainerRef, path string) ([]byte, error) {
+	req := s.client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(ref.Namespace).
+		Name(ref.Pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: ref.Container,
+			Command:   []string{"cat", path},
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(s.config, "POST", req.URL())
+	if err != nil {
+		return nil, fmt.Errorf("k8s: preparing exec into %s/%s/%s: %v", ref.Namespace, ref.Pod, ref.Container, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr}); err != nil {
+		return nil, fmt.Errorf("k8s: reading %s from %s/%s/%s: %v (stderr: %s)", path, ref.Namespace, ref.Pod, ref.Container, err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,135 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/google/minions/go/overlord/k8s"
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+	"github.com/google/uuid"
+	"golang.org/x/net/context"
+)
+
+// SetPodScanner installs the k8s.PodScanner CreateScan uses to resolve a
+// ScanKubernetesNamespace request. A nil scanner (the default) makes such
+// a request fail, the same way a nil layerCache doesn't - ScanImage works
+// fine without SetLayerCache, but there is no sane default PodScanner
+// since talking to a cluster always needs credentials the Overlord can't
+// invent.
+func (s *Server) SetPodScanner(scanner k8s.PodScanner) {
+	s.podScanner = scanner
+}
+
+// scanKubernetesNamespace resolves req against s.podScanner, driving
+// AnalyzeFiles for every container discovered in req's namespace and
+// returning one aggregated pb.Scan whose ScanId ties all of it together
+// for GetScanStatus/FinalizeScan.
+//
+// Each container is dispatched under its own synthetic sub-scan ID rather
+// than the umbrella one: minions like vulners key their internal state
+// (installed packages, detected distro) by ScanId alone, so sharing one
+// ID across unrelated containers would conflate one container's package
+// list with another's. The umbrella ScanId instead exists purely to group
+// the resulting findings - each one gets its pod/container labelled on
+// Source once it comes back, and is then persisted under the umbrella ID.
+func (s *Server) scanKubernetesNamespace(ctx context.Context, req *pb.ScanKubernetesNamespace) (*pb.Scan, error) {
+	if s.podScanner == nil {
+		return nil, fmt.Errorf("overlord: no PodScanner configured; call SetPodScanner before scanning a namespace")
+	}
+
+	scan := &pb.Scan{ScanId: uuid.New().String()}
+	if err := s.stateManager.CreateScan(scan.ScanId); err != nil {
+		return nil, err
+	}
+
+	containers, err := s.podScanner.ListContainers(ctx, req.GetNamespace(), req.GetLabelSelector())
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []*mpb.Finding
+	for _, ref := range containers {
+		subScanID := scan.ScanId + "/" + ref.Pod + "/" + ref.Container
+		containerFindings, err := s.scanContainer(ctx, subScanID, ref)
+		if err != nil {
+			return nil, fmt.Errorf("overlord: scanning %s/%s/%s: %v", ref.Namespace, ref.Pod, ref.Container, err)
+		}
+		for _, f := range containerFindings {
+			f.GetSource().Namespace = ref.Namespace
+			f.GetSource().Pod = ref.Pod
+			f.GetSource().Container = ref.Container
+		}
+		findings = append(findings, containerFindings...)
+	}
+
+	if err := s.stateManager.AddFindings(scan.ScanId, findings); err != nil {
+		return nil, err
+	}
+	s.emitScanCreated(scan.ScanId)
+	return scan, nil
+}
+
+// scanContainer reads every literal-path interest out of ref via
+// s.podScanner, routes the results to their minions, and returns the
+// findings, exactly as scanLayerPaths does for one image layer.
+func (s *Server) scanContainer(ctx context.Context, subScanID string, ref k8s.ContainerRef) ([]*mpb.Finding, error) {
+	routedFiles := make(map[string][]*mpb.File)
+	for _, candidate := range s.initialInterests {
+		path, ok := literalPath(candidate.Interest.GetPathRegexp())
+		if !ok {
+			continue
+		}
+		data, err := s.podScanner.ReadFile(ctx, ref, path)
+		if err != nil {
+			// Most containers won't have every interest's file - e.g. a
+			// Node image has no /var/lib/dpkg/status - so a read failure
+			// just means this interest doesn't apply here.
+			continue
+		}
+		f := &mpb.File{
+			Metadata: &mpb.FileMetadata{Path: path},
+		}
+		if candidate.Interest.GetDataType() == mpb.Interest_METADATA_AND_DATA {
+			f.Data = data
+		}
+		routedFiles[candidate.Minion] = append(routedFiles[candidate.Minion], f)
+	}
+
+	var findings []*mpb.Finding
+	for address, files := range routedFiles {
+		minion, present := s.minions[address]
+		if !present {
+			return nil, fmt.Errorf("interest expressed by a minion that is not known to the Overlord, %q", address)
+		}
+		resp, err := minion.AnalyzeFiles(ctx, &mpb.AnalyzeFilesRequest{ScanId: subScanID, Files: files})
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, resp.GetFindings()...)
+	}
+	return findings, nil
+}
+
+// literalPath returns pattern unchanged if it contains no regexp
+// metacharacters - i.e. it already matches only the literal path it looks
+// like, such as "/var/lib/dpkg/status" - and false otherwise. Only
+// literal interests can be resolved against a live container, since
+// there's no file listing to match a real regexp against (see the k8s
+// package doc comment).
+func literalPath(pattern string) (string, bool) {
+	return pattern, pattern == regexp.QuoteMeta(pattern)
+}
@@ -0,0 +1,151 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// GetScanStatus reports a scan's lifecycle status (see pb.ScanStatus) along
+// with a progress snapshot: files received, files dispatched per minion,
+// files dropped by a ScanFilters exclusion, and findings collected so far.
+func (s *Server) GetScanStatus(ctx context.Context, req *pb.GetScanStatusRequest) (*pb.ScanStatusResponse, error) {
+	scanID := req.GetScanId()
+	if !s.stateManager.ScanExists(scanID) {
+		return nil, fmt.Errorf("unknown scan ID %s", scanID)
+	}
+
+	scanStatus, err := s.stateManager.GetScanStatus(scanID)
+	if err != nil {
+		return nil, err
+	}
+	files, err := s.stateManager.GetFiles(scanID)
+	if err != nil {
+		return nil, err
+	}
+	dispatchCounts, err := s.stateManager.GetDispatchCounts(scanID)
+	if err != nil {
+		return nil, err
+	}
+	findings, err := s.stateManager.GetFindings(scanID)
+	if err != nil {
+		return nil, err
+	}
+	filesExcluded, err := s.stateManager.GetFilteredFileCount(scanID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.ScanStatusResponse{
+		ScanId:                scanID,
+		Status:                scanStatus,
+		FilesReceived:         int32(len(files)),
+		FilesDispatched:       make(map[string]int32, len(dispatchCounts)),
+		FindingsSoFar:         int32(len(findings)),
+		FilesExcludedByFilter: int32(filesExcluded),
+	}
+	for minion, count := range dispatchCounts {
+		resp.FilesDispatched[minion] = int32(count)
+	}
+	return resp, nil
+}
+
+// CancelScan marks a scan as cancelled. Subsequent ScanFiles calls for it
+// fail with codes.FailedPrecondition, and no further minion dispatch
+// happens - see Server.ScanFiles.
+func (s *Server) CancelScan(ctx context.Context, req *pb.CancelScanRequest) (*pb.CancelScanResponse, error) {
+	scanID := req.GetScanId()
+	if !s.stateManager.ScanExists(scanID) {
+		return nil, fmt.Errorf("unknown scan ID %s", scanID)
+	}
+	if err := s.stateManager.SetScanStatus(scanID, pb.ScanStatus_CANCELLED); err != nil {
+		return nil, err
+	}
+	return &pb.CancelScanResponse{}, nil
+}
+
+// PauseScan suspends dispatch for a scan: ScanFiles keeps accepting and
+// storing file chunks, but stops sending anything to minions until
+// ResumeScan is called.
+func (s *Server) PauseScan(ctx context.Context, req *pb.PauseScanRequest) (*pb.PauseScanResponse, error) {
+	scanID := req.GetScanId()
+	if !s.stateManager.ScanExists(scanID) {
+		return nil, fmt.Errorf("unknown scan ID %s", scanID)
+	}
+	if err := s.stateManager.SetScanStatus(scanID, pb.ScanStatus_PAUSED); err != nil {
+		return nil, err
+	}
+	return &pb.PauseScanResponse{}, nil
+}
+
+// ResumeScan un-pauses a scan and immediately re-runs routeAndDispatch,
+// draining whatever backlog of stored-but-undispatched files built up
+// while dispatch was suppressed. Not to be confused with
+// StateManager.ResumeScan, which rehydrates a scan's state after an
+// Overlord restart rather than un-pausing one still running in this
+// process.
+func (s *Server) ResumeScan(ctx context.Context, req *pb.ResumeScanRequest) (*pb.ResumeScanResponse, error) {
+	scanID := req.GetScanId()
+	if !s.stateManager.ScanExists(scanID) {
+		return nil, fmt.Errorf("unknown scan ID %s", scanID)
+	}
+	if err := s.stateManager.SetScanStatus(scanID, pb.ScanStatus_RUNNING); err != nil {
+		return nil, err
+	}
+
+	routed, err := s.routeAndDispatch(ctx, scanID)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ResumeScanResponse{
+		Results:      routed.Results,
+		NewInterests: routed.NewInterests,
+		MinionErrors: routed.MinionErrors,
+	}, nil
+}
+
+// FinalizeScan closes out a scan and returns an aggregated report of every
+// finding collected for it, broken down by severity and by minion.
+func (s *Server) FinalizeScan(ctx context.Context, req *pb.FinalizeScanRequest) (*pb.ScanReport, error) {
+	scanID := req.GetScanId()
+	if !s.stateManager.ScanExists(scanID) {
+		return nil, fmt.Errorf("unknown scan ID %s", scanID)
+	}
+	findings, err := s.stateManager.GetFindings(scanID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &pb.ScanReport{
+		ScanId:             scanID,
+		TotalFindings:      int32(len(findings)),
+		FindingsBySeverity: make(map[string]int32),
+		FindingsByMinion:   make(map[string]int32),
+	}
+	for _, f := range findings {
+		report.FindingsBySeverity[f.GetSeverity().String()]++
+		report.FindingsByMinion[f.GetSource().GetMinion()]++
+	}
+
+	if err := s.stateManager.SetScanStatus(scanID, pb.ScanStatus_COMPLETED); err != nil {
+		return nil, err
+	}
+	s.throttle.Delete(scanID)
+	s.emitScanCompleted(scanID, report)
+	return report, nil
+}
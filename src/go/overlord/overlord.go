@@ -14,17 +14,27 @@
 package overlord
 
 import (
+	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
-  "github.com/google/minions/go/grpcutil"
+	"github.com/google/minions/go/grpcutil"
+	"github.com/google/minions/go/overlord/config"
 	"github.com/google/minions/go/overlord/interests"
+	"github.com/google/minions/go/overlord/k8s"
+	"github.com/google/minions/go/overlord/registry"
 	"github.com/google/minions/go/overlord/state"
+	"github.com/google/minions/go/throttle"
 	"github.com/google/uuid"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	mpb "github.com/google/minions/proto/minions"
 	pb "github.com/google/minions/proto/overlord"
@@ -32,18 +42,81 @@ import (
 
 // Server implements the OverlordServer interface, the orchestrator of Minions' infrastructure.
 type Server struct {
-	minions          map[string]mpb.MinionClient // The minions we know about and their address
-	initialInterests []*state.MappedInterest     // The initial interests all scans will get
-	stateManager     StateManager                // Manages local stage between scans.
+	// mu guards every field below that LoadConfig can mutate after
+	// startup (minions, minionConns, minionConfigs, streamingMinions,
+	// initialInterests), so a SIGHUP reload (see WatchConfig) can't race
+	// a scan's dispatch. Server fields set once at construction time and
+	// never touched again (stateManager, layerCache, ...) don't need it.
+	mu                 sync.RWMutex
+	minions            map[string]mpb.MinionClient    // The minions we know about and their address
+	minionConns        map[string]*grpc.ClientConn    // Underlying conns for minions dialed via LoadConfig, so a removed minion's conn gets closed.
+	minionConfigs      map[string]config.MinionConfig // Declared config.MinionConfig for each minion in minions, keyed by address - see LoadConfig and ListMinions.
+	streamingMinions   map[string]bool                // Subset of minions that advertised AnalyzeFilesStream support.
+	initialInterests   []*state.MappedInterest        // The initial interests all scans will get
+	stateManager       StateManager                   // Manages local stage between scans.
+	layerCache         registry.LayerCache            // Optional cache for ScanImage, see SetLayerCache.
+	podScanner         k8s.PodScanner                 // Drives a ScanKubernetesNamespace request, see SetPodScanner.
+	dispatchOptions    DispatchOptions                // Concurrency/deadline/retry policy for minion RPCs, see SetDispatchOptions.
+	webhooks           []*webhookSink                 // HTTP sinks registered through SetWebhook; also reachable through sinks, see addSink.
+	sinksMu            sync.Mutex                     // Guards sinks, which addSink/removeSink mutate after startup (see WatchFindings).
+	sinks              []EventSink                    // Every registered EventSink - webhookSink, fileSink, and per-call WatchFindings subscribers.
+	defaultScanFilters *pb.ScanFilters                // Applied to a scan that doesn't set its own, see SetDefaultScanFilters.
+	defaultCACertPath  string                         // CA used to dial a minion whose config.MinionConfig doesn't set its own CACert - see LoadConfig.
+	defaultTLSCert     string                         // Client cert presented to a minion whose config.MinionConfig doesn't set its own TLSCert, see SetDefaultClientCert.
+	defaultTLSKey      string                         // Client key paired with defaultTLSCert, see SetDefaultClientCert.
+	configPath         string                         // Path WatchConfig reloads from on SIGHUP - set by LoadConfig.
+	chunkCache         *chunkCache                    // Tracks which DataChunks scanFilesStreaming has already sent each minion this scan, see chunkCache.
+	throttle           *throttle.Registry             // Optional per-scan outbound bandwidth cap, see SetThrottle.
+}
+
+// SetDefaultScanFilters installs the ScanFilters a CreateScanRequest gets
+// when it doesn't set its own - see CreateScan.
+func (s *Server) SetDefaultScanFilters(filters *pb.ScanFilters) {
+	s.defaultScanFilters = filters
+}
+
+// SetLayerCache installs the cache used by ScanImage to skip re-analyzing a
+// layer digest it has already seen, including layers with no findings at
+// all. A nil cache (the default) disables layer-level caching.
+func (s *Server) SetLayerCache(cache registry.LayerCache) {
+	s.layerCache = cache
+}
+
+// SetThrottle installs a throttle.Registry so ScanFiles (and ResumeScan's
+// redispatch) rate-limit outbound chunk traffic per scan ID - the same key
+// StateManager uses - rather than across the whole process, so one large
+// scan throttling itself doesn't starve a concurrent small one. A nil
+// registry (the default) leaves dispatch unthrottled. See GetScanRate to
+// read back what a configured Registry is actually achieving.
+func (s *Server) SetThrottle(reg *throttle.Registry) {
+	s.throttle = reg
+}
+
+// SetStateManager swaps in a persistent StateManager (see the state
+// package's Bolt, SQL and Redis implementations) in place of the in-memory
+// default New builds. Call it before the Server starts serving, then call
+// ResumeScan for every scan that should survive this restart.
+func (s *Server) SetStateManager(sm StateManager) {
+	s.stateManager = sm
 }
 
 // StateManager handles the state of an Overlord through multiple
 // scans.
+//
+// Implementations are free to expire a scan's state after some period of
+// inactivity rather than keeping it forever: state.Local does, tied to the
+// process's own lifetime (see its doc comment), since it exists to serve a
+// single Overlord run rather than to persist anything. state.Bolt,
+// state.SQL and state.Redis exist specifically for durability, so none of
+// them expire a scan on their own; an operator relying on one of those
+// should reap old scans explicitly (e.g. via FinalizeScan) instead of
+// counting on the backend to do it.
 type StateManager interface {
 	// AddFiles atomically sets the state of a minion during a scan.
 	AddFiles(scanID string, files []*pb.File) error
-	// AddInterest adds a new interest for a given minion to the state of the scan.
-	AddInterest(scanID string, interest *mpb.Interest, minion string) error
+	// AddInterest adds a new interest for a given minion to the state of
+	// the scan, scoped to root (pass "" for unscoped - see MappedInterest).
+	AddInterest(scanID string, interest *mpb.Interest, minion, root string) error
 	// CreateScan initializes the state for a scan.
 	CreateScan(scanID string) error
 	// GetFiles returns all the files known for a given ScanID
@@ -54,15 +127,78 @@ type StateManager interface {
 	RemoveFile(scanID string, file *pb.File) (bool, error)
 	// ScanExists returns true if any state at all is known about the scan.
 	ScanExists(scanID string) bool
+	// ResumeScan rehydrates a scan's interests and partially uploaded files
+	// after an Overlord restart, failing if no persisted state for scanID
+	// can be found. Backing stores that read straight through to their
+	// storage on every call (the SQL and Redis StateManagers) only need to
+	// check scanID exists; state.Local, which keeps no storage besides its
+	// in-memory cache, can never resume a scan since nothing survives its
+	// own restart.
+	//
+	// Not to be confused with the Server.ResumeScan RPC, which un-pauses a
+	// scan that's still running in the same process.
+	ResumeScan(scanID string) error
+
+	// SetScanStatus atomically transitions a scan's lifecycle status (see
+	// pb.ScanStatus). Callers are responsible for only requesting legal
+	// transitions; SetScanStatus itself just overwrites whatever status was
+	// there before.
+	SetScanStatus(scanID string, status pb.ScanStatus) error
+	// GetScanStatus returns a scan's current lifecycle status.
+	GetScanStatus(scanID string) (pb.ScanStatus, error)
+	// RecordDispatch atomically adds fileCount to the running total of
+	// files dispatched to minion for scanID, for GetScanStatus to report.
+	RecordDispatch(scanID, minion string, fileCount int) error
+	// GetDispatchCounts returns, for scanID, how many files have been
+	// dispatched to each minion so far.
+	GetDispatchCounts(scanID string) (map[string]int, error)
+	// AddFindings appends findings to the set collected for scanID, for
+	// GetScanStatus and FinalizeScan to report on.
+	AddFindings(scanID string, findings []*mpb.Finding) error
+	// GetFindings returns every finding collected for scanID so far.
+	GetFindings(scanID string) ([]*mpb.Finding, error)
+
+	// SetScanFilters persists the ScanFilters a scan was created with, so
+	// ScanFiles keeps applying them consistently across an Overlord restart.
+	SetScanFilters(scanID string, filters *pb.ScanFilters) error
+	// GetScanFilters returns the ScanFilters in effect for scanID, or nil
+	// if none were set.
+	GetScanFilters(scanID string) (*pb.ScanFilters, error)
+	// RecordFilteredFiles adds n to the running count of files scanID has
+	// dropped because they matched a ScanFilters exclusion.
+	RecordFilteredFiles(scanID string, n int) error
+	// GetFilteredFileCount returns how many files scanID has dropped so
+	// far because they matched a ScanFilters exclusion.
+	GetFilteredFileCount(scanID string) (int, error)
 }
 
-// New returns an initialized Server, which connects to a set of pre-specified minions
-// to initialize them. It accepts the path of a CA certificate to use to check the
-// minions server certs
-func New(ctx context.Context, minionAddresses []string, caCertPath string) (*Server, error) {
+// dialOptions picks GetSslClientOptions or, when tlsCertPath/tlsKeyPath are
+// both set, GetSslClientOptionsMTLS - so dialing a minion started with
+// StartMinion's --client_ca only needs a client cert configured, not a
+// different dial path.
+func dialOptions(host, caCertPath, tlsCertPath, tlsKeyPath string) (grpc.DialOption, error) {
+	if tlsCertPath == "" || tlsKeyPath == "" {
+		return grpcutil.GetSslClientOptions(host, caCertPath)
+	}
+	return grpcutil.GetSslClientOptionsMTLS(host, caCertPath, tlsCertPath, tlsKeyPath)
+}
+
+// New returns an initialized Server, which connects to a set of
+// pre-specified minions to initialize them. caCertPath validates the
+// minions' server certs; tlsCertPath/tlsKeyPath, if both set, are
+// presented as this Overlord's client certificate, required if a minion
+// was started with StartMinion's --client_ca.
+func New(ctx context.Context, minionAddresses []string, caCertPath, tlsCertPath, tlsKeyPath string) (*Server, error) {
 	server := &Server{
-		minions:      make(map[string]mpb.MinionClient),
-		stateManager: state.NewLocal(),
+		minions:           make(map[string]mpb.MinionClient),
+		minionConns:       make(map[string]*grpc.ClientConn),
+		minionConfigs:     make(map[string]config.MinionConfig),
+		stateManager:      state.NewLocal(),
+		dispatchOptions:   DefaultDispatchOptions,
+		defaultCACertPath: caCertPath,
+		defaultTLSCert:    tlsCertPath,
+		defaultTLSKey:     tlsKeyPath,
+		chunkCache:        newChunkCache(),
 	}
 
 	log.Println("Reaching out to all minions.")
@@ -70,7 +206,7 @@ func New(ctx context.Context, minionAddresses []string, caCertPath string) (*Ser
 	for _, addr := range minionAddresses {
 		log.Printf("Reaching out to minion at %s\n", addr)
 		host := strings.Split(addr, ":")[0] // If we have a port, extract hostname
-		opts, err := grpcutil.GetSslClientOptions(host, caCertPath)
+		opts, err := dialOptions(host, caCertPath, tlsCertPath, tlsKeyPath)
 		if err != nil {
 			return nil, err
 		}
@@ -82,58 +218,152 @@ func New(ctx context.Context, minionAddresses []string, caCertPath string) (*Ser
 		server.minions[addr] = mpb.NewMinionClient(c)
 	}
 	log.Println("Retrieving initial interests")
-	interests, err := getInterestsFromMinions(ctx, server.minions)
+	interests, streaming, err := getInterestsFromMinions(ctx, server.dispatchOptions, server.minions)
 	if err != nil {
 		return nil, err
 	}
 	server.initialInterests = interests
+	server.streamingMinions = streaming
 	log.Printf("Initial interests: %d", len(server.initialInterests))
 	return server, nil
 }
 
-func getInterestsFromMinions(ctx context.Context, minions map[string]mpb.MinionClient) ([]*state.MappedInterest, error) {
+// getInterestsFromMinions asks every minion for its initial interests, and
+// also notes which minions want to be fed via AnalyzeFilesStream rather than
+// AnalyzeFiles (see mpb.ListInitialInterestsResponse.SupportsStreaming).
+// Calls fan out concurrently and retry transient failures per opts (see
+// dispatch); if any minion still fails after retries, New fails outright,
+// since an Overlord that can't learn a minion's interests can never route
+// files to it.
+func getInterestsFromMinions(ctx context.Context, opts DispatchOptions, minions map[string]mpb.MinionClient) ([]*state.MappedInterest, map[string]bool, error) {
+	var mu sync.Mutex
 	var interests []*state.MappedInterest
-	for name, m := range minions {
-		// TODO(paradoxengine): most likely, a deadline here?
-		intResp, err := m.ListInitialInterests(ctx, &mpb.ListInitialInterestsRequest{})
+	streaming := make(map[string]bool)
+
+	names := make([]string, 0, len(minions))
+	for name := range minions {
+		names = append(names, name)
+	}
+
+	errs := dispatch(ctx, opts, names, func(callCtx context.Context, name string) error {
+		intResp, err := minions[name].ListInitialInterests(callCtx, &mpb.ListInitialInterestsRequest{})
 		if err != nil {
-			return nil, err
+			return err
 		}
+		mu.Lock()
+		defer mu.Unlock()
 		for _, v := range intResp.GetInterests() {
 			interests = append(interests, &state.MappedInterest{
 				Interest: v,
 				Minion:   name,
 			})
 		}
+		if intResp.GetSupportsStreaming() {
+			streaming[name] = true
+		}
+		return nil
+	})
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("retrieving initial interests from minions: %v", errs)
 	}
-	return interests, nil
+	return interests, streaming, nil
 }
 
 // CreateScan set up a security scan which can then be fed files via ScanFiles.
 // It returns a UUID identifying the scan from now on and the list of initial
 // Interests.
+//
+// req.GetPolicyScopes() lets a caller confine some minions to a subtree:
+// each PolicyScope names a root and the minions it applies to, plus any
+// extra, scan-specific interests those minions should additionally get
+// for that root. A minion named in a scope has every one of its initial
+// interests (see s.initialInterests) scoped to that root for the
+// duration of this scan; a minion named in no scope stays unscoped, i.e.
+// applies anywhere, as before PolicyScope existed.
 func (s *Server) CreateScan(ctx context.Context, req *pb.CreateScanRequest) (*pb.Scan, error) {
+	// A ScanKubernetesNamespace request drives a whole namespace's worth of
+	// containers through AnalyzeFiles in one go rather than waiting for a
+	// caller to stream files in through ScanFiles - see
+	// scanKubernetesNamespace for why it still needs its own codepath
+	// rather than reusing the rest of CreateScan below.
+	if req.GetScanKubernetesNamespace() != nil {
+		return s.scanKubernetesNamespace(ctx, req.GetScanKubernetesNamespace())
+	}
+
 	// Scans are tracked by UUID, so let's start by generating it.
 	scan := &pb.Scan{}
 	scan.ScanId = uuid.New().String()
 
 	s.stateManager.CreateScan(scan.ScanId)
+
+	filters := req.GetFilters()
+	if filters == nil {
+		filters = s.defaultScanFilters
+	}
+	if filters != nil {
+		if err := s.stateManager.SetScanFilters(scan.ScanId, filters); err != nil {
+			return nil, err
+		}
+	}
+
+	rootForMinion := make(map[string]string)
+	for _, scope := range req.GetPolicyScopes() {
+		for _, minion := range scope.GetMinions() {
+			rootForMinion[minion] = scope.GetRoot()
+		}
+		for _, extra := range scope.GetExtraInterests() {
+			for _, minion := range scope.GetMinions() {
+				if err := s.stateManager.AddInterest(scan.ScanId, extra, minion, scope.GetRoot()); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
 	for _, i := range s.initialInterests {
-		s.stateManager.AddInterest(scan.ScanId, i.Interest, i.Minion)
+		s.stateManager.AddInterest(scan.ScanId, i.Interest, i.Minion, rootForMinion[i.Minion])
 	}
 
 	knownInterests, err := s.stateManager.GetInterests(scan.ScanId)
 	if err != nil {
 		return nil, err
 	}
-	for _, interest := range knownInterests {
-		scan.Interests = append(scan.Interests, interest.Interest)
-	}
-	scan.Interests = interests.Minify(scan.Interests)
+	scan.Interests = minifyMapped(knownInterests)
 
+	s.emitScanCreated(scan.ScanId)
 	return scan, nil
 }
 
+// rootForMinion returns the PolicyScope root minion is already confined
+// to among mapped, or "" if it's unscoped (or unknown - every interest
+// added for a given minion within one scan shares the same root, so the
+// first match is enough).
+func rootForMinion(mapped []*state.MappedInterest, minion string) string {
+	for _, m := range mapped {
+		if m.Minion == minion {
+			return m.Root
+		}
+	}
+	return ""
+}
+
+// minifyMapped flattens mapped down to the plain []*mpb.Interest a
+// pb.Scan or pb.ListInterestsResponse carries, minifying root-aware (see
+// interests.MinifyRooted) so interests scoped to different PolicyScope
+// roots don't collapse into one another just because they otherwise look
+// identical.
+func minifyMapped(mapped []*state.MappedInterest) []*mpb.Interest {
+	rooted := make([]*interests.RootedInterest, len(mapped))
+	for i, m := range mapped {
+		rooted[i] = &interests.RootedInterest{Root: m.Root, Interest: m.Interest}
+	}
+	minified := interests.MinifyRooted(rooted)
+	ret := make([]*mpb.Interest, len(minified))
+	for i, r := range minified {
+		ret[i] = r.Interest
+	}
+	return ret
+}
+
 // ListInterests returns the interests for a given scan, i.e. the files or metadata
 // that have to be fed to the Overlord for security scanning.
 func (s *Server) ListInterests(ctx context.Context, req *pb.ListInterestsRequest) (*pb.ListInterestsResponse, error) {
@@ -147,27 +377,85 @@ func (s *Server) ListInterests(ctx context.Context, req *pb.ListInterestsRequest
 	if err != nil {
 		return nil, err
 	}
-	resp := &pb.ListInterestsResponse{}
-	for _, interest := range scanInterests {
-		resp.Interests = append(resp.Interests, interest.Interest)
-	}
-	resp.Interests = interests.Minify(resp.Interests)
+	resp := &pb.ListInterestsResponse{Interests: minifyMapped(scanInterests)}
 	return resp, nil
 }
 
 // ScanFiles runs security scan on a set of files, assuming they were actually
-// needed by the backend minions.
+// needed by the backend minions. A cancelled scan rejects the call outright;
+// a paused one still accepts and stores the files (so nothing uploaded while
+// paused is lost) but skips dispatch until ResumeScan drains the backlog.
 func (s *Server) ScanFiles(ctx context.Context, req *pb.ScanFilesRequest) (*pb.ScanFilesResponse, error) {
 	scanID := req.GetScanId()
 	if !s.stateManager.ScanExists(scanID) {
 		return nil, fmt.Errorf("unknown scan ID %s", scanID)
 	}
 
+	scanStatus, err := s.stateManager.GetScanStatus(scanID)
+	if err != nil {
+		return nil, err
+	}
+	if scanStatus == pb.ScanStatus_CANCELLED {
+		return nil, status.Errorf(codes.FailedPrecondition, "scan %s is cancelled", scanID)
+	}
+
 	if err := s.stateManager.AddFiles(req.GetScanId(), req.GetFiles()); err != nil {
 		return nil, fmt.Errorf("error adding files to the scan state: %v", err)
 	}
+	s.emit(&WebhookEvent{Type: EventScanFileReceived, ScanID: scanID, Ts: time.Now()})
+
+	if err := s.dropFilteredFiles(scanID, req.GetFiles()); err != nil {
+		return nil, err
+	}
+
+	if scanStatus == pb.ScanStatus_PAUSED {
+		return &pb.ScanFilesResponse{}, nil
+	}
+	return s.routeAndDispatch(ctx, scanID)
+}
+
+// dropFilteredFiles evaluates scanID's ScanFilters (see excludedByFilters)
+// against files just added via AddFiles, once per file and before the
+// interest loop in routeAndDispatch runs: a file that matches an
+// exclusion is removed from state via RemoveFile - so it's never
+// dispatched to any minion - and counted towards GetScanStatus's
+// FilesExcludedByFilter.
+func (s *Server) dropFilteredFiles(scanID string, files []*pb.File) error {
+	filters, err := s.stateManager.GetScanFilters(scanID)
+	if err != nil {
+		return err
+	}
+	if filters == nil {
+		return nil
+	}
 
-	// Now distribute all complete files for scanning.
+	excluded := 0
+	for _, f := range files {
+		exclude, err := excludedByFilters(filters, f)
+		if err != nil {
+			return err
+		}
+		if !exclude {
+			continue
+		}
+		if _, err := s.stateManager.RemoveFile(scanID, f); err != nil {
+			return err
+		}
+		excluded++
+	}
+	if excluded == 0 {
+		return nil
+	}
+	return s.stateManager.RecordFilteredFiles(scanID, excluded)
+}
+
+// routeAndDispatch matches every file currently known for scanID against
+// its scan's interests, fans the matches out to their minions (see
+// dispatchToMinions), and records the findings and per-minion dispatch
+// counts in the StateManager so GetScanStatus and FinalizeScan can report
+// on them later. ResumeScan calls this directly - without a new file
+// upload - to drain whatever backlog built up while a scan was paused.
+func (s *Server) routeAndDispatch(ctx context.Context, scanID string) (*pb.ScanFilesResponse, error) {
 	routedFiles := make(map[string][]*mpb.File)
 
 	files, err := s.stateManager.GetFiles(scanID)
@@ -180,18 +468,40 @@ func (s *Server) ScanFiles(ctx context.Context, req *pb.ScanFilesRequest) (*pb.S
 			return nil, err
 		}
 		for _, candidate := range interestsForMinions {
-			if match, err := interests.IsMatching(candidate.Interest, f); err != nil {
+			if match, err := interests.IsMatchingInRoot(candidate.Root, candidate.Interest, f); err != nil {
 				return nil, err
 			} else if !match {
 				continue
 			}
+			if inScope, err := s.matchesPathScope(candidate.Minion, f.GetMetadata().GetPath()); err != nil {
+				return nil, err
+			} else if !inScope {
+				continue
+			}
 
-			isComplete := f.GetMetadata().GetSize() == int64(len(f.GetDataChunks()[0].GetData()))
+			// A file's data can arrive as several content-defined chunks
+			// (see goblins.chunkData), so completeness means their total
+			// size matches the metadata, not that there's only one.
+			var dataSize int64
+			for _, c := range f.GetDataChunks() {
+				dataSize += int64(len(c.GetData()))
+			}
+			isComplete := f.GetMetadata().GetSize() == dataSize
 
 			if candidate.Interest.DataType == mpb.Interest_METADATA_AND_DATA && isComplete {
+				// Data flattens DataChunks (which are kept sorted by
+				// Offset, see state.mergeChunk) for minions that only
+				// implement AnalyzeFiles; DataChunks is also sent
+				// alongside it so scanFilesStreaming can dedupe chunks
+				// for minions that stream.
+				data := make([]byte, 0, dataSize)
+				for _, c := range f.GetDataChunks() {
+					data = append(data, c.GetData()...)
+				}
 				routedFiles[candidate.Minion] = append(routedFiles[candidate.Minion], &mpb.File{
-					Metadata: f.GetMetadata(),
-					Data:     f.GetDataChunks()[0].GetData(), // Note we accumulate in the first chunk.
+					Metadata:   f.GetMetadata(),
+					Data:       data,
+					DataChunks: f.GetDataChunks(),
 				})
 			} else if candidate.Interest.DataType == mpb.Interest_METADATA {
 				// Send only metadata.
@@ -202,26 +512,241 @@ func (s *Server) ScanFiles(ctx context.Context, req *pb.ScanFilesRequest) (*pb.S
 		}
 	}
 
+	minionResponses, dispatchErrs, err := s.dispatchToMinions(ctx, scanID, routedFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	existingInterests, err := s.stateManager.GetInterests(scanID)
+	if err != nil {
+		return nil, err
+	}
+
 	resp := &pb.ScanFilesResponse{}
-	for address, files := range routedFiles {
-		minion, present := s.minions[address]
-		if !present {
-			return nil, fmt.Errorf("interest expressed by a minion that is not known to the Overlord, %q", address)
-		}
-		minionResp, err := minion.AnalyzeFiles(ctx, &mpb.AnalyzeFilesRequest{
-			ScanId: req.ScanId,
-			Files:  files,
-		})
-		if err != nil {
-			return nil, err
-		}
+	for address, minionResp := range minionResponses {
 		resp.Results = append(resp.Results, minionResp.GetFindings()...)
 		resp.NewInterests = append(resp.NewInterests, minionResp.GetNewInterests()...)
 
-		// Now export the new interests back to the state.
+		// Export the new interests back to the state, inheriting whatever
+		// PolicyScope root this minion is already confined to for this scan
+		// (if any), so a minion scoped to a subtree can't escape it just by
+		// reporting a new interest.
+		root := rootForMinion(existingInterests, address)
 		for _, newInterest := range minionResp.GetNewInterests() {
-			s.stateManager.AddInterest(scanID, newInterest, address)
+			s.stateManager.AddInterest(scanID, newInterest, address, root)
+		}
+		if len(minionResp.GetNewInterests()) > 0 {
+			s.emit(&WebhookEvent{
+				Type:         EventInterestAdded,
+				ScanID:       scanID,
+				Minion:       address,
+				NewInterests: minionResp.GetNewInterests(),
+				Ts:           time.Now(),
+			})
+		}
+		if len(minionResp.GetFindings()) > 0 {
+			s.emitFindingsProduced(scanID, address, minionResp.GetFindings())
+		}
+		if err := s.stateManager.RecordDispatch(scanID, address, len(routedFiles[address])); err != nil {
+			return nil, err
+		}
+	}
+	for address, dispatchErr := range dispatchErrs {
+		resp.MinionErrors = append(resp.MinionErrors, &pb.MinionError{
+			Minion: address,
+			Error:  dispatchErr.Error(),
+		})
+	}
+	if len(resp.Results) > 0 {
+		if err := s.stateManager.AddFindings(scanID, resp.Results); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// dispatchToMinions fans routedFiles out to their respective minions
+// concurrently (see dispatch), so a scan with many minions isn't gated on
+// the slowest one, and a minion that's down or crashing doesn't block
+// findings from the rest. A minion's failure, even after retries, is
+// returned keyed by address rather than failing the whole call - callers
+// surface those in ScanFilesResponse.MinionErrors instead of aborting the
+// scan.
+func (s *Server) dispatchToMinions(ctx context.Context, scanID string, routedFiles map[string][]*mpb.File) (map[string]*mpb.AnalyzeFilesResponse, map[string]error, error) {
+	// Snapshot the minion maps under a read lock rather than holding it
+	// for the whole dispatch: a LoadConfig reload can safely swap them
+	// out from under an in-flight scan, which just keeps routing to
+	// whatever minions were current when dispatch started.
+	s.mu.RLock()
+	minions := s.minions
+	streamingMinions := s.streamingMinions
+	s.mu.RUnlock()
+
+	names := make([]string, 0, len(routedFiles))
+	for address := range routedFiles {
+		if _, present := minions[address]; !present {
+			return nil, nil, fmt.Errorf("interest expressed by a minion that is not known to the Overlord, %q", address)
+		}
+		names = append(names, address)
+	}
+
+	var mu sync.Mutex
+	responses := make(map[string]*mpb.AnalyzeFilesResponse)
+	errs := dispatch(ctx, s.dispatchOptions, names, func(callCtx context.Context, address string) error {
+		minion := minions[address]
+		files := routedFiles[address]
+
+		// A MinionConfig.Timeout tightens (never loosens) the deadline
+		// callCtx already carries from DispatchOptions.MinionCallTimeout,
+		// for a minion declared through LoadConfig that needs stricter
+		// bounds than the Server-wide default - a slow vulnerability feed
+		// lookup, say, that shouldn't be allowed to eat a whole scan's
+		// budget.
+		s.mu.RLock()
+		timeout := s.minionConfigs[address].Timeout
+		s.mu.RUnlock()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithTimeout(callCtx, timeout)
+			defer cancel()
+		}
+
+		var minionResp *mpb.AnalyzeFilesResponse
+		var err error
+		if streamingMinions[address] {
+			minionResp, err = scanFilesStreaming(callCtx, minion, scanID, address, s.chunkCache, files, s.throttle.Get(scanID))
+		} else {
+			s.throttle.Get(scanID).Wait(fileBytes(files))
+			minionResp, err = minion.AnalyzeFiles(callCtx, &mpb.AnalyzeFilesRequest{
+				ScanId: scanID,
+				Files:  files,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		responses[address] = minionResp
+		mu.Unlock()
+		return nil
+	})
+	return responses, errs, nil
+}
+
+// fileBytes sums the data actually carried by files, for a single Wait
+// call before a non-streaming AnalyzeFiles dispatch - unlike
+// scanFilesStreaming, AnalyzeFiles sends everything in one message, so
+// there's no per-chunk granularity to throttle against.
+func fileBytes(files []*mpb.File) int64 {
+	var n int64
+	for _, f := range files {
+		n += int64(len(f.GetData()))
+	}
+	return n
+}
+
+// streamChunkSize bounds how much of a single DataChunk we put in one
+// FileChunk, so an unusually large chunk can't single-handedly blow past
+// gRPC's default message size limit. In practice this almost never
+// triggers: content-defined chunks out of goblins.LoadFiles are already
+// capped well under this (see goblins' maxChunkSize), so it only matters
+// for a DataChunk built from a very wide explicit ByteRange Interest.
+const streamChunkSize = 1 << 20 // 1 MiB
+
+// scanFilesStreaming sends files to a minion via AnalyzeFilesStream instead
+// of a single AnalyzeFiles call. Each mpb.File's DataChunks (content-defined
+// and SHA-256-stamped by goblins.LoadFiles) are forwarded as-is, except a
+// chunk whose hash cache has already recorded as sent to this minion during
+// this scan, which is forwarded as a bare DataChunk.RefId instead of its raw
+// bytes - cheap dedupe for the same file rescanned, or a region shared
+// across files, without the minion needing to do anything special (see
+// DataChunk.RefId's doc comment on the minion side for how to resolve one).
+// tracker.Wait is called once per chunk actually sent over the wire - a
+// deduped RefId chunk costs nothing - so a throttled scan paces itself
+// across the stream instead of bursting it all up front.
+func scanFilesStreaming(ctx context.Context, minion mpb.MinionClient, scanID, address string, cache *chunkCache, files []*mpb.File, tracker *throttle.Tracker) (*mpb.AnalyzeFilesResponse, error) {
+	stream, err := minion.AnalyzeFilesStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		chunks := f.GetDataChunks()
+		if len(chunks) == 0 {
+			if err := stream.Send(&mpb.FileChunk{ScanId: scanID, Metadata: f.GetMetadata(), Eof: true}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for i, c := range chunks {
+			last := i == len(chunks)-1
+			var metadata *mpb.FileMetadata
+			if i == 0 {
+				metadata = f.GetMetadata()
+			}
+
+			if len(c.GetData()) > streamChunkSize {
+				if err := sendOversizedChunk(stream, scanID, metadata, c, last, tracker); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			chunk := &mpb.FileChunk{ScanId: scanID, Offset: c.GetOffset(), Metadata: metadata, Eof: last}
+			if hash := hex.EncodeToString(c.GetSha256()); hash != "" && cache.seenOrRecord(scanID, address, hash) {
+				chunk.RefId = hash
+			} else {
+				tracker.Wait(int64(len(c.GetData())))
+				chunk.Data = c.GetData()
+				chunk.Sha256 = c.GetSha256()
+			}
+			if err := stream.Send(chunk); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	resp := &mpb.AnalyzeFilesResponse{}
+	for {
+		partial, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
 		}
+		resp.Findings = append(resp.Findings, partial.GetFindings()...)
+		resp.NewInterests = append(resp.NewInterests, partial.GetNewInterests()...)
 	}
 	return resp, nil
 }
+
+// sendOversizedChunk splits a single DataChunk larger than streamChunkSize
+// into multiple FileChunks at fixed offsets, without attempting ref-based
+// dedupe for it - see scanFilesStreaming.
+func sendOversizedChunk(stream mpb.Minions_AnalyzeFilesStreamClient, scanID string, metadata *mpb.FileMetadata, c *mpb.DataChunk, last bool, tracker *throttle.Tracker) error {
+	data := c.GetData()
+	for offset := 0; offset < len(data); offset += streamChunkSize {
+		end := offset + streamChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := &mpb.FileChunk{
+			ScanId: scanID,
+			Offset: c.GetOffset() + int64(offset),
+			Data:   data[offset:end],
+			Eof:    last && end == len(data),
+		}
+		if offset == 0 {
+			chunk.Metadata = metadata
+		}
+		tracker.Wait(int64(end - offset))
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
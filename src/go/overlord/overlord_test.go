@@ -40,7 +40,7 @@ func Test_CreateScanAndListInterests_returnsInitialInterests(t *testing.T) {
 		PathRegexp: "/some/regexp",
 	}
 	interests := []*state.MappedInterest{
-		&state.MappedInterest{interest, "fake_minion"},
+		&state.MappedInterest{Interest: interest, Minion: "fake_minion"},
 	}
 	s, err := New(context.Background(), nil)
 	require.NoError(t, err)
@@ -72,7 +72,7 @@ func Test_INTERNAL_queriesMinions(t *testing.T) {
 	fm := &fakeMinionClient{interests: interests}
 	minionClients := make(map[string]mpb.MinionClient)
 	minionClients["fakeMinion"] = fm
-	retrievedInterests, err := getInterestsFromMinions(context.Background(), minionClients)
+	retrievedInterests, _, err := getInterestsFromMinions(context.Background(), DefaultDispatchOptions, minionClients)
 	require.NoError(t, err)
 	require.Equal(t, retrievedInterests[0].Interest, i)
 }
@@ -0,0 +1,131 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package registry
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	mpb "github.com/google/minions/proto/minions"
+)
+
+// LayerResult is the cached outcome of analyzing a single layer: the
+// Findings it produced, which may legitimately be empty (a negative
+// result, e.g. "no vulnerable dpkg packages in this layer"). Caching
+// negative results matters just as much as caching positive ones, since
+// re-running an expensive analysis (e.g. a Vulners HTTP round trip) just to
+// learn "still nothing" is wasted work.
+type LayerResult struct {
+	Findings []*mpb.Finding
+}
+
+// LayerCache stores the result of scanning a layer, keyed by its
+// content-addressable digest, so that when the same layer is seen again
+// (across images, or across repeated scans of the same image) the
+// extraction and minion analysis can be skipped entirely.
+type LayerCache interface {
+	// Get returns the cached result for digest, if any.
+	Get(digest string) (*LayerResult, bool)
+	// Put stores the result of scanning digest.
+	Put(digest string, result *LayerResult) error
+}
+
+// MemoryLayerCache is a LayerCache backed by a process-local map. It is
+// cheap and fast but does not survive Overlord restarts and isn't shared
+// across replicas.
+type MemoryLayerCache struct {
+	mu    sync.RWMutex
+	cache map[string]*LayerResult
+}
+
+// NewMemoryLayerCache returns an empty, ready to use MemoryLayerCache.
+func NewMemoryLayerCache() *MemoryLayerCache {
+	return &MemoryLayerCache{cache: make(map[string]*LayerResult)}
+}
+
+// Get returns the cached result for digest, if any.
+func (c *MemoryLayerCache) Get(digest string) (*LayerResult, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r, found := c.cache[digest]
+	return r, found
+}
+
+// Put stores the result of scanning digest.
+func (c *MemoryLayerCache) Put(digest string, result *LayerResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache[digest] = result
+	return nil
+}
+
+// DiskLayerCache is a LayerCache that persists each layer's result as a
+// small JSON file under Dir, named after the (sanitized) digest. It
+// survives Overlord restarts, at the cost of a filesystem round trip per
+// lookup.
+type DiskLayerCache struct {
+	Dir string
+}
+
+// NewDiskLayerCache returns a DiskLayerCache rooted at dir, creating it if
+// it doesn't already exist.
+func NewDiskLayerCache(dir string) (*DiskLayerCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskLayerCache{Dir: dir}, nil
+}
+
+func (c *DiskLayerCache) path(digest string) string {
+	// Digests look like "sha256:abcd...", ':' isn't a great filename
+	// character on every filesystem, so swap it for '_'.
+	safe := filepath.Clean(stringsReplaceColon(digest))
+	return filepath.Join(c.Dir, safe+".json")
+}
+
+// Get returns the cached result for digest, if any.
+func (c *DiskLayerCache) Get(digest string) (*LayerResult, bool) {
+	data, err := ioutil.ReadFile(c.path(digest))
+	if err != nil {
+		return nil, false
+	}
+	var r LayerResult
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, false
+	}
+	return &r, true
+}
+
+// Put stores the result of scanning digest.
+func (c *DiskLayerCache) Put(digest string, result *LayerResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path(digest), data, 0644)
+}
+
+func stringsReplaceColon(s string) string {
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		if r == ':' {
+			r = '_'
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
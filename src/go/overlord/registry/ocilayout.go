@@ -0,0 +1,129 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OCILayoutFetcher is an ImageFetcher backed by an OCI Image Layout
+// directory (an oci-layout file, an index.json and a blobs/sha256/...
+// tree), the format produced by e.g. `skopeo copy ... oci:out-dir`. It lets
+// the Overlord scan an image that was exported on an air-gapped host
+// without a registry to pull from.
+type OCILayoutFetcher struct {
+	Dir string // Path to the OCI image-layout directory.
+}
+
+// NewOCILayoutFetcher returns an OCILayoutFetcher reading from dir.
+func NewOCILayoutFetcher(dir string) *OCILayoutFetcher {
+	return &OCILayoutFetcher{Dir: dir}
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	Config ociDescriptor   `json:"config"`
+	Layers []ociDescriptor `json:"layers"`
+}
+
+// ociRefNameAnnotation is the well-known annotation OCI tooling uses to tag
+// an index.json entry with a human-readable reference, e.g. "latest".
+const ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+
+// Manifest resolves ref against index.json, matching entries by their
+// ociRefNameAnnotation when the layout holds more than one image. ref is
+// ignored for the common case of a layout holding a single image.
+func (f *OCILayoutFetcher) Manifest(ctx context.Context, ref string) (*Manifest, error) {
+	if _, err := os.Stat(filepath.Join(f.Dir, "oci-layout")); err != nil {
+		return nil, fmt.Errorf("oci-layout: %s does not look like an OCI image layout: %v", f.Dir, err)
+	}
+
+	var index ociIndex
+	if err := readJSONFile(filepath.Join(f.Dir, "index.json"), &index); err != nil {
+		return nil, fmt.Errorf("oci-layout: reading index.json: %v", err)
+	}
+	desc, err := selectOCIManifest(index.Manifests, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest ociManifest
+	if err := readJSONFile(f.blobPath(desc.Digest), &manifest); err != nil {
+		return nil, fmt.Errorf("oci-layout: reading manifest %s: %v", desc.Digest, err)
+	}
+
+	m := &Manifest{ConfigDigest: manifest.Config.Digest}
+	for _, l := range manifest.Layers {
+		m.Layers = append(m.Layers, LayerDescriptor{Digest: l.Digest, MediaType: l.MediaType, Size: l.Size})
+	}
+	return m, nil
+}
+
+// Layer opens the blob identified by digest straight off disk.
+func (f *OCILayoutFetcher) Layer(ctx context.Context, ref string, digest string) (io.ReadCloser, error) {
+	return os.Open(f.blobPath(digest))
+}
+
+// blobPath maps a "sha256:abcd..." digest to its path under blobs/<algo>.
+func (f *OCILayoutFetcher) blobPath(digest string) string {
+	algo, hex := "sha256", digest
+	if i := strings.Index(digest, ":"); i != -1 {
+		algo, hex = digest[:i], digest[i+1:]
+	}
+	return filepath.Join(f.Dir, "blobs", algo, hex)
+}
+
+// selectOCIManifest picks the manifest descriptor matching ref out of an
+// index.json's manifest list.
+func selectOCIManifest(manifests []ociDescriptor, ref string) (ociDescriptor, error) {
+	if len(manifests) == 0 {
+		return ociDescriptor{}, errors.New("oci-layout: index.json lists no manifests")
+	}
+	if ref == "" || len(manifests) == 1 {
+		return manifests[0], nil
+	}
+	for _, d := range manifests {
+		if d.Annotations[ociRefNameAnnotation] == ref {
+			return d, nil
+		}
+	}
+	return ociDescriptor{}, fmt.Errorf("oci-layout: no manifest annotated %s=%q in index.json", ociRefNameAnnotation, ref)
+}
+
+// readJSONFile decodes the JSON object stored in path into v.
+func readJSONFile(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(f).Decode(v)
+}
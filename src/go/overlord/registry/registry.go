@@ -0,0 +1,279 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+/*
+Package registry lets the Overlord pull container images directly from an
+OCI/Docker v2 registry (or a previously exported tarball, see
+OCILayoutFetcher and DockerArchiveFetcher) without requiring a locally
+running Docker daemon.
+
+An ImageFetcher resolves a reference to a Manifest and streams individual
+layers on demand; the Overlord then walks each layer's tar stream, matches
+entries against the Minions' declared Interests, and dispatches matched
+files through the regular AnalyzeFiles RPC.
+*/
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// LayerDescriptor identifies a single layer within an image manifest.
+type LayerDescriptor struct {
+	Digest    string // Content-addressable digest, e.g. "sha256:abcd..."
+	MediaType string // e.g. "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	Size      int64
+}
+
+// Manifest is the subset of an OCI/Docker v2 image manifest that the
+// Overlord cares about: an ordered list of layers to apply.
+type Manifest struct {
+	ConfigDigest string
+	Layers       []LayerDescriptor
+}
+
+// ImageFetcher resolves an image reference and streams its layers. Callers
+// must Close() every io.ReadCloser returned by Layer.
+type ImageFetcher interface {
+	// Manifest resolves ref (e.g. "gcr.io/foo/bar:tag" or a "...@sha256:..."
+	// digest reference) to its Manifest.
+	Manifest(ctx context.Context, ref string) (*Manifest, error)
+	// Layer streams the raw (possibly compressed) blob for the given digest.
+	Layer(ctx context.Context, ref string, digest string) (io.ReadCloser, error)
+}
+
+// ErrUnauthorized is returned by an ImageFetcher when the registry rejects
+// our credentials (or lack thereof) for a reference.
+var ErrUnauthorized = errors.New("registry: not authorized for this reference")
+
+// RegistryFetcher is an ImageFetcher backed by a live Docker v2 registry. It
+// implements the Bearer token challenge/response flow used by Docker Hub,
+// GCR, ECR and most v2-compatible registries, pulling credentials from the
+// supplied KeyChain (e.g. one backed by ~/.docker/config.json).
+type RegistryFetcher struct {
+	Client    *http.Client
+	KeyChain  KeyChain
+	UserAgent string
+}
+
+// KeyChain resolves registry credentials for a given host. Implementations
+// typically wrap ~/.docker/config.json or a cloud provider's credential
+// helper (e.g. docker-credential-gcr, docker-credential-ecr-login).
+type KeyChain interface {
+	// Resolve returns the basic-auth username/password (or empty strings
+	// for anonymous access) to use against host.
+	Resolve(host string) (username, password string, err error)
+}
+
+// NewRegistryFetcher returns a RegistryFetcher using the provided KeyChain
+// for authentication. A nil KeyChain results in anonymous, unauthenticated
+// pulls, which is sufficient for public images.
+func NewRegistryFetcher(kc KeyChain) *RegistryFetcher {
+	if kc == nil {
+		kc = anonymousKeyChain{}
+	}
+	return &RegistryFetcher{Client: http.DefaultClient, KeyChain: kc, UserAgent: "minions-overlord/registry"}
+}
+
+type anonymousKeyChain struct{}
+
+func (anonymousKeyChain) Resolve(string) (string, string, error) { return "", "", nil }
+
+// reference splits a "host/repo:tag" or "host/repo@digest" reference into
+// its constituent parts. It's deliberately naive: no support for insecure
+// registries or registry mirrors beyond what's encoded in the ref itself.
+type reference struct {
+	host, repo, identifier string // identifier is either a tag or a "sha256:..." digest
+}
+
+func parseReference(ref string) (reference, error) {
+	host, rest := "", ref
+	if i := strings.Index(ref, "/"); i != -1 && (strings.Contains(ref[:i], ".") || strings.Contains(ref[:i], ":") || ref[:i] == "localhost") {
+		host, rest = ref[:i], ref[i+1:]
+	} else {
+		host = "index.docker.io"
+	}
+	if at := strings.LastIndex(rest, "@"); at != -1 {
+		return reference{host, rest[:at], rest[at+1:]}, nil
+	}
+	if colon := strings.LastIndex(rest, ":"); colon != -1 {
+		return reference{host, rest[:colon], rest[colon+1:]}, nil
+	}
+	return reference{host, rest, "latest"}, nil
+}
+
+// Manifest resolves ref against the registry, performing the bearer-token
+// challenge/response handshake transparently.
+func (f *RegistryFetcher) Manifest(ctx context.Context, ref string) (*Manifest, error) {
+	r, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("https://%s/v2/%s/manifests/%s", r.host, r.repo, r.identifier)
+	resp, err := f.doAuthenticated(ctx, r, url, "application/vnd.docker.distribution.manifest.v2+json, application/vnd.oci.image.manifest.v1+json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var raw struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Layers []struct {
+			Digest    string `json:"digest"`
+			MediaType string `json:"mediaType"`
+			Size      int64  `json:"size"`
+		} `json:"layers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("registry: decoding manifest for %q: %v", ref, err)
+	}
+
+	m := &Manifest{ConfigDigest: raw.Config.Digest}
+	for _, l := range raw.Layers {
+		m.Layers = append(m.Layers, LayerDescriptor{Digest: l.Digest, MediaType: l.MediaType, Size: l.Size})
+	}
+	return m, nil
+}
+
+// Layer streams the blob identified by digest from the same repository as
+// ref.
+func (f *RegistryFetcher) Layer(ctx context.Context, ref string, digest string) (io.ReadCloser, error) {
+	r, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("https://%s/v2/%s/blobs/%s", r.host, r.repo, digest)
+	resp, err := f.doAuthenticated(ctx, r, url, "*/*")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// doAuthenticated performs req against url, transparently handling the v2
+// Bearer token challenge (a 401 with a WWW-Authenticate header) the first
+// time it's encountered.
+func (f *RegistryFetcher) doAuthenticated(ctx context.Context, r reference, url string, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", f.UserAgent)
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		token, err := f.fetchBearerToken(ctx, r, resp.Header.Get("Www-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+		req2, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req2.Header.Set("Accept", accept)
+		req2.Header.Set("Authorization", "Bearer "+token)
+		resp, err = f.Client.Do(req2)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		return nil, ErrUnauthorized
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry: unexpected status %s for %s", resp.Status, url)
+	}
+	return resp, nil
+}
+
+// fetchBearerToken parses a WWW-Authenticate: Bearer challenge and exchanges
+// it for a short-lived token at the advertised realm, optionally presenting
+// basic-auth credentials resolved from the KeyChain.
+func (f *RegistryFetcher) fetchBearerToken(ctx context.Context, r reference, challenge string) (string, error) {
+	realm, service, scope, err := parseBearerChallenge(challenge)
+	if err != nil {
+		return "", err
+	}
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", r.repo)
+	}
+	url := fmt.Sprintf("%s?service=%s&scope=%s", realm, service, scope)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	if user, pass, err := f.KeyChain.Resolve(r.host); err == nil && user != "" {
+		req.SetBasicAuth(user, pass)
+	}
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry: token exchange against %s failed: %s", realm, resp.Status)
+	}
+	var tok struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", err
+	}
+	if tok.Token != "" {
+		return tok.Token, nil
+	}
+	return tok.AccessToken, nil
+}
+
+// parseBearerChallenge extracts realm/service/scope from a header like:
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:foo/bar:pull"`
+func parseBearerChallenge(challenge string) (realm, service, scope string, err error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", "", "", fmt.Errorf("registry: unsupported auth challenge: %q", challenge)
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		v := strings.Trim(kv[1], `"`)
+		switch kv[0] {
+		case "realm":
+			realm = v
+		case "service":
+			service = v
+		case "scope":
+			scope = v
+		}
+	}
+	if realm == "" {
+		return "", "", "", errors.New("registry: auth challenge missing realm")
+	}
+	return realm, service, scope, nil
+}
@@ -0,0 +1,160 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// cycloneDXBOM and friends cover only the subset of the CycloneDX 1.5 JSON
+// schema renderCycloneDXJSON actually populates: one component per scanned
+// file plus one vulnerability entry per Finding, affects[].ref pointing
+// back at the matching component's bom-ref.
+type cycloneDXBOM struct {
+	BOMFormat       string                   `json:"bomFormat"`
+	SpecVersion     string                   `json:"specVersion"`
+	Version         int                      `json:"version"`
+	Components      []cycloneDXComponent     `json:"components,omitempty"`
+	Vulnerabilities []cycloneDXVulnerability `json:"vulnerabilities,omitempty"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef   string                   `json:"bom-ref"`
+	Type     string                   `json:"type"`
+	Name     string                   `json:"name"`
+	Hashes   []cycloneDXHash          `json:"hashes,omitempty"`
+	Licenses []cycloneDXLicenseChoice `json:"licenses,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDXLicenseChoice struct {
+	License cycloneDXLicense `json:"license"`
+}
+
+type cycloneDXLicense struct {
+	ID string `json:"id"`
+}
+
+type cycloneDXVulnerability struct {
+	ID          string            `json:"id"`
+	Source      cycloneDXSource   `json:"source,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Ratings     []cycloneDXRating `json:"ratings,omitempty"`
+	Affects     []cycloneDXAffect `json:"affects,omitempty"`
+}
+
+type cycloneDXSource struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXRating struct {
+	Severity string `json:"severity"`
+}
+
+type cycloneDXAffect struct {
+	Ref string `json:"ref"`
+}
+
+// renderCycloneDXJSON builds a CycloneDX 1.5 BOM as JSON: one component
+// per entry in files plus one vulnerability entry per Finding, mirroring
+// goblins.RenderCycloneDXVEX's severity mapping but additionally carrying
+// the file/component inventory a VEX-only document omits.
+func renderCycloneDXJSON(files []*pb.File, findings []*mpb.Finding) ([]byte, error) {
+	bom := cycloneDXBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+	}
+
+	refByPath := make(map[string]string, len(files))
+	for i, f := range files {
+		path := f.GetMetadata().GetPath()
+		ref := fmt.Sprintf("component-%d", i)
+		refByPath[path] = ref
+
+		component := cycloneDXComponent{
+			BOMRef: ref,
+			Type:   "file",
+			Name:   path,
+		}
+		if sha := f.GetMetadata().GetSha256(); sha != "" {
+			component.Hashes = append(component.Hashes, cycloneDXHash{Alg: "SHA-256", Content: sha})
+		}
+		bom.Components = append(bom.Components, component)
+	}
+
+	for _, f := range findings {
+		var affects []cycloneDXAffect
+		for _, res := range f.GetVulnerableResources() {
+			if ref, ok := refByPath[res.GetPath()]; ok {
+				affects = append(affects, cycloneDXAffect{Ref: ref})
+				if license := f.GetAdvisory().GetLicense(); license != "" {
+					attachLicense(&bom, ref, license)
+				}
+			}
+		}
+
+		bom.Vulnerabilities = append(bom.Vulnerabilities, cycloneDXVulnerability{
+			ID:          f.GetAdvisory().GetReference(),
+			Source:      cycloneDXSource{Name: f.GetSource().GetMinion()},
+			Description: f.GetAdvisory().GetDescription(),
+			Ratings:     []cycloneDXRating{{Severity: cycloneDXSeverity(f.GetSeverity())}},
+			Affects:     affects,
+		})
+	}
+
+	return json.MarshalIndent(bom, "", "  ")
+}
+
+// attachLicense records license (an SPDX identifier, per a Finding's
+// Advisory) on the component identified by ref, the first time it's seen.
+func attachLicense(bom *cycloneDXBOM, ref, license string) {
+	for i, c := range bom.Components {
+		if c.BOMRef != ref {
+			continue
+		}
+		for _, l := range c.Licenses {
+			if l.License.ID == license {
+				return
+			}
+		}
+		bom.Components[i].Licenses = append(bom.Components[i].Licenses, cycloneDXLicenseChoice{License: cycloneDXLicense{ID: license}})
+		return
+	}
+}
+
+// cycloneDXSeverity maps a Finding's Severity to a CycloneDX rating
+// severity string, matching goblins.cycloneDXSeverity.
+func cycloneDXSeverity(s mpb.Finding_Severity) string {
+	switch s {
+	case mpb.Finding_SEVERITY_CRITICAL:
+		return "critical"
+	case mpb.Finding_SEVERITY_HIGH:
+		return "high"
+	case mpb.Finding_SEVERITY_MEDIUM:
+		return "medium"
+	case mpb.Finding_SEVERITY_LOW:
+		return "low"
+	default:
+		return "unknown"
+	}
+}
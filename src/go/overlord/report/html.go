@@ -0,0 +1,88 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package report
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	mpb "github.com/google/minions/proto/minions"
+)
+
+// htmlSeverityOrder lists severities from worst to least severe, the order
+// renderHTMLNotice groups findings in.
+var htmlSeverityOrder = []mpb.Finding_Severity{
+	mpb.Finding_SEVERITY_CRITICAL,
+	mpb.Finding_SEVERITY_HIGH,
+	mpb.Finding_SEVERITY_MEDIUM,
+	mpb.Finding_SEVERITY_LOW,
+	mpb.Finding_SEVERITY_UNKNOWN,
+}
+
+// renderHTMLNotice builds a human-readable notice page: one section per
+// severity (worst first), each listing its findings grouped by the minion
+// that reported them, with an anchor per minion so a link can jump
+// straight to "what vulners found" or "what tomcat found".
+func renderHTMLNotice(findings []*mpb.Finding) ([]byte, error) {
+	bySeverity := make(map[mpb.Finding_Severity][]*mpb.Finding)
+	for _, f := range findings {
+		bySeverity[f.GetSeverity()] = append(bySeverity[f.GetSeverity()], f)
+	}
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>Scan notice</title></head><body>\n")
+	b.WriteString("<h1>Scan notice</h1>\n")
+
+	for _, sev := range htmlSeverityOrder {
+		group := bySeverity[sev]
+		if len(group) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(sev.String()))
+
+		byMinion := make(map[string][]*mpb.Finding)
+		var minions []string
+		for _, f := range group {
+			minion := f.GetSource().GetMinion()
+			if _, ok := byMinion[minion]; !ok {
+				minions = append(minions, minion)
+			}
+			byMinion[minion] = append(byMinion[minion], f)
+		}
+		sort.Strings(minions)
+
+		for _, minion := range minions {
+			fmt.Fprintf(&b, "<h3 id=\"%s\">%s</h3>\n<ul>\n", html.EscapeString(minion), html.EscapeString(minion))
+			for _, f := range byMinion[minion] {
+				fmt.Fprintf(&b, "<li><strong>%s</strong>: %s",
+					html.EscapeString(f.GetAdvisory().GetReference()),
+					html.EscapeString(f.GetAdvisory().GetDescription()))
+				var paths []string
+				for _, res := range f.GetVulnerableResources() {
+					paths = append(paths, res.GetPath())
+				}
+				if len(paths) > 0 {
+					fmt.Fprintf(&b, " (%s)", html.EscapeString(strings.Join(paths, ", ")))
+				}
+				b.WriteString("</li>\n")
+			}
+			b.WriteString("</ul>\n")
+		}
+	}
+
+	b.WriteString("</body></html>\n")
+	return []byte(b.String()), nil
+}
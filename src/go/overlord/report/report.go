@@ -0,0 +1,93 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+// Package report renders a scan's accumulated files and findings into
+// industry-standard compliance documents: SPDX (JSON and tag-value),
+// CycloneDX JSON and a human-readable HTML notice page. Unlike
+// goblins.RenderSARIF/RenderCycloneDXVEX, which render only the findings a
+// single goblin invocation collected, Render works off the full
+// server-side state of a scan (see overlord/state.StateManager), so its
+// CycloneDX and SPDX documents carry a complete file/component inventory
+// rather than just the vulnerabilities found in it.
+package report
+
+import (
+	"fmt"
+
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// Format selects which document Render produces.
+type Format int
+
+const (
+	// SPDXJSON renders an SPDX 2.3 document as JSON.
+	SPDXJSON Format = iota
+	// SPDXTagValue renders an SPDX 2.3 document in tag-value form.
+	SPDXTagValue
+	// CycloneDXJSON renders a CycloneDX 1.5 BOM, components and
+	// vulnerabilities, as JSON.
+	CycloneDXJSON
+	// HTMLNotice renders a human-readable page grouping findings by
+	// severity, with an anchor per minion.
+	HTMLNotice
+)
+
+// ContentType returns the MIME type ExportReport should send back for f.
+func (f Format) ContentType() string {
+	switch f {
+	case SPDXJSON:
+		return "application/spdx+json"
+	case SPDXTagValue:
+		return "text/spdx"
+	case CycloneDXJSON:
+		return "application/vnd.cyclonedx+json"
+	case HTMLNotice:
+		return "text/html"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Render builds the document for format out of scanID's files and
+// findings. files is the complete inventory known for the scan (see
+// StateManager.GetFiles); findings is everything collected for it so far
+// (see StateManager.GetFindings).
+func Render(format Format, scanID string, files []*pb.File, findings []*mpb.Finding) ([]byte, error) {
+	switch format {
+	case SPDXJSON:
+		return renderSPDXJSON(scanID, files, findings)
+	case SPDXTagValue:
+		return renderSPDXTagValue(scanID, files, findings)
+	case CycloneDXJSON:
+		return renderCycloneDXJSON(files, findings)
+	case HTMLNotice:
+		return renderHTMLNotice(findings)
+	default:
+		return nil, fmt.Errorf("unknown report format %v", format)
+	}
+}
+
+// findingsByPath indexes findings by every VulnerableResource.Path they
+// name, so the per-file renderers can attach the right annotations/
+// vulnerability refs to each file without an O(files*findings) scan.
+func findingsByPath(findings []*mpb.Finding) map[string][]*mpb.Finding {
+	byPath := make(map[string][]*mpb.Finding)
+	for _, f := range findings {
+		for _, res := range f.GetVulnerableResources() {
+			byPath[res.GetPath()] = append(byPath[res.GetPath()], f)
+		}
+	}
+	return byPath
+}
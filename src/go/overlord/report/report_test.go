@@ -0,0 +1,78 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package report
+
+import (
+	"testing"
+
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+	"github.com/stretchr/testify/require"
+)
+
+func filesFixture() []*pb.File {
+	return []*pb.File{
+		{Metadata: &pb.FileMetadata{Path: "/usr/lib/libfoo.so", Sha256: "deadbeef"}},
+	}
+}
+
+func findingsFixture() []*mpb.Finding {
+	return []*mpb.Finding{{
+		Advisory: &mpb.Advisory{Reference: "CVE-2020-1234", Description: "foodesc", License: "GPL-2.0-only"},
+		Source:   &mpb.Source{Minion: "vulners"},
+		Severity: mpb.Finding_SEVERITY_CRITICAL,
+		VulnerableResources: []*mpb.VulnerableResource{
+			{Path: "/usr/lib/libfoo.so"},
+		},
+	}}
+}
+
+func TestRender_SPDXJSON_mapsFileAndFinding(t *testing.T) {
+	out, err := Render(SPDXJSON, "scan-1", filesFixture(), findingsFixture())
+	require.NoError(t, err)
+	require.Contains(t, string(out), "scan-1")
+	require.Contains(t, string(out), "/usr/lib/libfoo.so")
+	require.Contains(t, string(out), "deadbeef")
+	require.Contains(t, string(out), "CVE-2020-1234")
+	require.Contains(t, string(out), "GPL-2.0-only")
+}
+
+func TestRender_SPDXTagValue_mapsFileAndFinding(t *testing.T) {
+	out, err := Render(SPDXTagValue, "scan-1", filesFixture(), findingsFixture())
+	require.NoError(t, err)
+	require.Contains(t, string(out), "DocumentNamespace: https://minions.google/spdxdocs/scan-scan-1")
+	require.Contains(t, string(out), "FileName: /usr/lib/libfoo.so")
+	require.Contains(t, string(out), "CVE-2020-1234")
+}
+
+func TestRender_CycloneDXJSON_mapsComponentAndVulnerability(t *testing.T) {
+	out, err := Render(CycloneDXJSON, "scan-1", filesFixture(), findingsFixture())
+	require.NoError(t, err)
+	require.Contains(t, string(out), "/usr/lib/libfoo.so")
+	require.Contains(t, string(out), "CVE-2020-1234")
+	require.Contains(t, string(out), `"severity": "critical"`)
+	require.Contains(t, string(out), "GPL-2.0-only")
+}
+
+func TestRender_HTMLNotice_groupsBySeverityAndMinion(t *testing.T) {
+	out, err := Render(HTMLNotice, "scan-1", filesFixture(), findingsFixture())
+	require.NoError(t, err)
+	require.Contains(t, string(out), `id="vulners"`)
+	require.Contains(t, string(out), "CVE-2020-1234")
+}
+
+func TestRender_onUnknownFormat_returnsError(t *testing.T) {
+	_, err := Render(Format(99), "scan-1", nil, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,146 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// spdxDocument and friends cover only the subset of the SPDX 2.3 JSON
+// schema renderSPDXJSON actually populates: one File element per scanned
+// file, annotated with an OTHER-type Annotation per Finding that affects
+// it.
+type spdxDocument struct {
+	SPDXVersion       string           `json:"spdxVersion"`
+	DataLicense       string           `json:"dataLicense"`
+	SPDXID            string           `json:"SPDXID"`
+	Name              string           `json:"name"`
+	DocumentNamespace string           `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo `json:"creationInfo"`
+	Files             []spdxFile       `json:"files,omitempty"`
+}
+
+type spdxCreationInfo struct {
+	Creators []string `json:"creators"`
+}
+
+type spdxFile struct {
+	SPDXID           string           `json:"SPDXID"`
+	FileName         string           `json:"fileName"`
+	Checksums        []spdxChecksum   `json:"checksums,omitempty"`
+	LicenseConcluded string           `json:"licenseConcluded,omitempty"`
+	Annotations      []spdxAnnotation `json:"annotations,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxAnnotation struct {
+	AnnotationType string `json:"annotationType"`
+	Annotator      string `json:"annotator"`
+	Comment        string `json:"comment"`
+}
+
+// spdxNamespace derives a document-unique SPDXDocument namespace from
+// scanID, as the spec requires.
+func spdxNamespace(scanID string) string {
+	return "https://minions.google/spdxdocs/scan-" + scanID
+}
+
+func spdxFiles(files []*pb.File, byPath map[string][]*mpb.Finding) []spdxFile {
+	var out []spdxFile
+	for i, f := range files {
+		path := f.GetMetadata().GetPath()
+		sf := spdxFile{
+			SPDXID:   fmt.Sprintf("SPDXRef-File-%d", i),
+			FileName: path,
+		}
+		if sha := f.GetMetadata().GetSha256(); sha != "" {
+			sf.Checksums = append(sf.Checksums, spdxChecksum{Algorithm: "SHA256", ChecksumValue: sha})
+		}
+
+		var licenses []string
+		for _, finding := range byPath[path] {
+			sf.Annotations = append(sf.Annotations, spdxAnnotation{
+				AnnotationType: "OTHER",
+				Annotator:      "Tool: " + finding.GetSource().GetMinion(),
+				Comment:        fmt.Sprintf("%s: %s", finding.GetAdvisory().GetReference(), finding.GetAdvisory().GetDescription()),
+			})
+			if license := finding.GetAdvisory().GetLicense(); license != "" {
+				licenses = append(licenses, license)
+			}
+		}
+		if len(licenses) > 0 {
+			sf.LicenseConcluded = strings.Join(licenses, " AND ")
+		}
+
+		out = append(out, sf)
+	}
+	return out
+}
+
+// renderSPDXJSON builds an SPDX 2.3 document as JSON, one File element per
+// entry in files, annotated with every Finding affecting it.
+func renderSPDXJSON(scanID string, files []*pb.File, findings []*mpb.Finding) ([]byte, error) {
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              "minions-scan-" + scanID,
+		DocumentNamespace: spdxNamespace(scanID),
+		CreationInfo:      spdxCreationInfo{Creators: []string{"Tool: minions-overlord"}},
+		Files:             spdxFiles(files, findingsByPath(findings)),
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// renderSPDXTagValue builds the same document as renderSPDXJSON, in SPDX's
+// tag-value form instead of JSON.
+func renderSPDXTagValue(scanID string, files []*pb.File, findings []*mpb.Finding) ([]byte, error) {
+	byPath := findingsByPath(findings)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SPDXVersion: SPDX-2.3\n")
+	fmt.Fprintf(&b, "DataLicense: CC0-1.0\n")
+	fmt.Fprintf(&b, "SPDXID: SPDXRef-DOCUMENT\n")
+	fmt.Fprintf(&b, "DocumentName: minions-scan-%s\n", scanID)
+	fmt.Fprintf(&b, "DocumentNamespace: %s\n", spdxNamespace(scanID))
+	fmt.Fprintf(&b, "Creator: Tool: minions-overlord\n")
+
+	for i, f := range files {
+		path := f.GetMetadata().GetPath()
+		fmt.Fprintf(&b, "\nFileName: %s\n", path)
+		fmt.Fprintf(&b, "SPDXID: SPDXRef-File-%s\n", strconv.Itoa(i))
+		if sha := f.GetMetadata().GetSha256(); sha != "" {
+			fmt.Fprintf(&b, "FileChecksum: SHA256: %s\n", sha)
+		}
+		for _, finding := range byPath[path] {
+			fmt.Fprintf(&b, "Annotator: Tool: %s\n", finding.GetSource().GetMinion())
+			fmt.Fprintf(&b, "AnnotationType: OTHER\n")
+			fmt.Fprintf(&b, "AnnotationComment: %s: %s\n", finding.GetAdvisory().GetReference(), finding.GetAdvisory().GetDescription())
+			if license := finding.GetAdvisory().GetLicense(); license != "" {
+				fmt.Fprintf(&b, "LicenseConcluded: %s\n", license)
+			}
+		}
+	}
+	return []byte(b.String()), nil
+}
@@ -14,16 +14,22 @@
 package main
 
 import (
+	"database/sql"
 	"flag"
 	"fmt"
 	"log"
 	"net"
 	"strings"
+	"time"
 
 	"golang.org/x/net/context"
 
+	"github.com/go-redis/redis"
 	"github.com/google/minions/go/grpcutil"
+	"github.com/google/minions/go/minions/vulndb"
 	"github.com/google/minions/go/overlord"
+	"github.com/google/minions/go/overlord/state"
+	"github.com/google/minions/go/throttle"
 	pb "github.com/google/minions/proto/overlord"
 	"google.golang.org/grpc"
 )
@@ -40,29 +46,112 @@ func (f *flagStrings) Set(value string) error {
 }
 
 var (
-	minions flagStrings
-	port    = flag.Int("port", 10000, "Overlord server port")
-	sslCert = flag.String("ssl_cert", "", "Path to the SSL certificate (crt)")
-	sslKey  = flag.String("ssl_key", "", "Path to the SSL key (key)")
-	caCert  = flag.String("ca_cert", "", "Path to the Certificate Authority certificate used to validate Minions certificates")
+	minions    flagStrings
+	configFile = flag.String("config", "", "Path to a YAML/JSON config file declaring minions with per-minion TLS, tags, path-scope globs and dispatch limits; takes precedence over -minions when set, and is hot-reloaded on SIGHUP")
+	port       = flag.Int("port", 10000, "Overlord server port")
+	sslCert    = flag.String("ssl_cert", "", "Path to the SSL certificate (crt)")
+	sslKey     = flag.String("ssl_key", "", "Path to the SSL key (key)")
+	caCert     = flag.String("ca_cert", "", "Path to the Certificate Authority certificate used to validate Minions certificates")
+	clientCA   = flag.String("client_ca", "", "Path to a CA certificate; if set, only clients (e.g. goblins senders) presenting a client cert signed by it may call this Overlord")
+	tlsCert    = flag.String("tls_cert", "", "Path to a client certificate this Overlord presents when dialing a minion started with --client_ca; used together with -tls_key")
+	tlsKey     = flag.String("tls_key", "", "Path to the client key paired with -tls_cert")
+
+	vulndbPath     = flag.String("vulndb", "", "Path to a local vulndb BoltDB file to keep refreshed; disabled if empty")
+	vulndbOsvDir   = flag.String("vulndb_osv_dir", "", "Directory holding an extracted OSV export, fed into -vulndb")
+	vulndbInterval = flag.Duration("vulndb_interval", 24*time.Hour, "How often to refresh -vulndb")
+
+	stateBackend   = flag.String("state_backend", "local", "StateManager backend to use: local, bolt, sql or redis")
+	stateBoltPath  = flag.String("state_bolt_path", "overlord_state.db", "Path to the BoltDB file to use with -state_backend=bolt")
+	stateSQLDriver = flag.String("state_sql_driver", "sqlite3", "database/sql driver name to use with -state_backend=sql")
+	stateSQLDSN    = flag.String("state_sql_dsn", "", "Data source name to use with -state_backend=sql")
+	stateRedisAddr = flag.String("state_redis_addr", "localhost:6379", "Redis address to use with -state_backend=redis")
+
+	maxBps     = flag.Int64("max_bps", 0, "Maximum outbound bytes/sec dispatched to minions, per scan ID; 0 disables throttling")
+	burstBytes = flag.Int64("burst_bytes", 0, "Burst size in bytes a scan may spend immediately before -max_bps throttling kicks in")
 )
 
+// newStateManager builds the StateManager selected by -state_backend. Local
+// scans don't survive an Overlord restart; bolt, sql and redis do - bolt at
+// the cost of being pinned to this machine's disk, sql and redis at the
+// cost of requiring that backing service to be reachable.
+func newStateManager() (overlord.StateManager, error) {
+	switch *stateBackend {
+	case "local":
+		return state.NewLocal(), nil
+	case "bolt":
+		return state.OpenBolt(*stateBoltPath)
+	case "sql":
+		db, err := sql.Open(*stateSQLDriver, *stateSQLDSN)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s database: %v", *stateSQLDriver, err)
+		}
+		return state.NewSQL(db)
+	case "redis":
+		client := redis.NewClient(&redis.Options{Addr: *stateRedisAddr})
+		return state.NewRedis(client), nil
+	default:
+		return nil, fmt.Errorf("unknown -state_backend %q", *stateBackend)
+	}
+}
+
+// startVulnDBUpdater opens the local vulnerability database at *vulndbPath,
+// if configured, and starts refreshing it in the background for the
+// lifetime of the process - a minion sharing the same disk whose
+// vuln.Client is a vulndb.VulnClient over this file then always has a
+// reasonably fresh local cache without managing its own fetch schedule.
+// The vulners runner's -backend choices (vulners, osv, both) do not
+// include such a client.
+func startVulnDBUpdater() {
+	if *vulndbPath == "" {
+		return
+	}
+	if *vulndbOsvDir == "" {
+		log.Fatal("-vulndb_osv_dir is required when -vulndb is set")
+	}
+	store, err := vulndb.OpenBoltStore(*vulndbPath)
+	if err != nil {
+		log.Fatalf("opening %s: %v", *vulndbPath, err)
+	}
+	updater := vulndb.NewUpdater(store, *vulndbInterval, vulndb.NewOSVFetcher(*vulndbOsvDir))
+	go updater.Start(context.Background())
+	log.Printf("vulndb: refreshing %s from %s every %s", *vulndbPath, *vulndbOsvDir, *vulndbInterval)
+}
+
 func newServer() (*overlord.Server, error) {
 	ctx := context.Background()
-	return overlord.New(ctx, minions, *caCert)
+	var s *overlord.Server
+	var err error
+	if *configFile != "" {
+		s, err = overlord.NewFromConfig(ctx, *configFile, *caCert, *tlsCert, *tlsKey)
+	} else {
+		s, err = overlord.New(ctx, minions, *caCert, *tlsCert, *tlsKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sm, err := newStateManager()
+	if err != nil {
+		return nil, fmt.Errorf("building StateManager: %v", err)
+	}
+	s.SetStateManager(sm)
+	if *maxBps > 0 {
+		s.SetThrottle(throttle.NewRegistry(*maxBps, *burstBytes))
+	}
+	return s, nil
 }
 
 func main() {
 	flag.Var(&minions, "minions", "Addresses of minions to boot against")
 
 	flag.Parse()
+	startVulnDBUpdater()
 	fmt.Printf("Starting up overlord server. Got these minion addresses: %s \n", minions)
 	lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", *port))
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 	var opts []grpc.ServerOption
-	creds, err := grpcutil.GetSslServerCreds(*sslCert, *sslKey, "") // We don't validate client certs.
+	creds, err := grpcutil.GetSslServerCreds(*sslCert, *sslKey, *clientCA)
 	if err != nil {
 		log.Fatalf("Failed to retrieve SSL creds: %v", err)
 	}
@@ -76,6 +165,9 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to build server: %v", err)
 	}
+	if *configFile != "" {
+		go s.WatchConfig(context.Background())
+	}
 	pb.RegisterOverlordServer(grpcServer, s)
 	fmt.Println("Server created and registered, entering busy loop!")
 	grpcServer.Serve(lis)
@@ -0,0 +1,453 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package state
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+var (
+	scansBucket          = []byte("scans")
+	filesBucket          = []byte("files")
+	interestsBucket      = []byte("interests")
+	dispatchCountsBucket = []byte("dispatch_counts")
+	findingsBucket       = []byte("findings")
+	filtersBucket        = []byte("filters")
+	filesExcludedBucket  = []byte("files_excluded")
+)
+
+// Bolt is a StateManager backed by an embedded BoltDB file, so a scan's
+// state survives an Overlord restart without needing a separate database
+// process - suitable for a single-node deployment. Every scan gets its own
+// nested bucket, keyed by scanID, under the files, interests,
+// dispatch_counts and findings buckets above, so CreateScan can simply
+// delete-and-recreate those nested buckets rather than scanning the whole
+// file; scans, filters and files_excluded hold one flat key per scan.
+//
+// Like SQL and Redis, values are JSON-encoded rather than raw protobuf
+// wire format, for consistency with those sibling backends (see sql.go,
+// redis.go) - callers never see the encoding either way, since it's
+// hidden behind the StateManager interface.
+type Bolt struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens (creating if needed) a Bolt StateManager at path.
+func OpenBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("state: opening %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{scansBucket, filesBucket, interestsBucket, dispatchCountsBucket, findingsBucket, filtersBucket, filesExcludedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Bolt{db: db}, nil
+}
+
+// Close releases the underlying BoltDB file.
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+// CreateScan initializes the state for a scan. It resets the state if it
+// already exists.
+func (b *Bolt) CreateScan(scanID string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, parent := range [][]byte{filesBucket, interestsBucket, dispatchCountsBucket, findingsBucket} {
+			parentBucket := tx.Bucket(parent)
+			if err := parentBucket.DeleteBucket([]byte(scanID)); err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+			if _, err := parentBucket.CreateBucket([]byte(scanID)); err != nil {
+				return err
+			}
+		}
+		for _, flat := range [][]byte{filtersBucket, filesExcludedBucket} {
+			if err := tx.Bucket(flat).Delete([]byte(scanID)); err != nil {
+				return err
+			}
+		}
+		status, err := json.Marshal(pb.ScanStatus_RUNNING)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(scansBucket).Put([]byte(scanID), status)
+	})
+}
+
+// ScanExists returns true if any state at all is known about the scan.
+func (b *Bolt) ScanExists(scanID string) bool {
+	exists := false
+	b.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(scansBucket).Get([]byte(scanID)) != nil
+		return nil
+	})
+	return exists
+}
+
+// ResumeScan checks scanID was already known before this restart; like SQL
+// and Redis, Bolt never drops files or interests of its own accord, so
+// there is nothing further to rehydrate.
+func (b *Bolt) ResumeScan(scanID string) error {
+	if !b.ScanExists(scanID) {
+		return fmt.Errorf("cannot resume scan %s: no persisted state found", scanID)
+	}
+	return nil
+}
+
+// SetScanStatus atomically transitions scanID's lifecycle status.
+func (b *Bolt) SetScanStatus(scanID string, status pb.ScanStatus) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(scansBucket).Get([]byte(scanID)) == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		data, err := json.Marshal(status)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(scansBucket).Put([]byte(scanID), data)
+	})
+}
+
+// GetScanStatus returns scanID's current lifecycle status.
+func (b *Bolt) GetScanStatus(scanID string) (pb.ScanStatus, error) {
+	var status pb.ScanStatus
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(scansBucket).Get([]byte(scanID))
+		if data == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		return json.Unmarshal(data, &status)
+	})
+	return status, err
+}
+
+// SetScanFilters persists the ScanFilters a scan was created with, so
+// ScanFiles keeps applying them consistently across an Overlord restart.
+// Stored in its own top-level bucket (rather than alongside the status
+// blob) so a scan with no filters set costs no extra key.
+func (b *Bolt) SetScanFilters(scanID string, filters *pb.ScanFilters) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(scansBucket).Get([]byte(scanID)) == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		data, err := json.Marshal(filters)
+		if err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucketIfNotExists(filtersBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(scanID), data)
+	})
+}
+
+// GetScanFilters returns the ScanFilters in effect for scanID, or nil if
+// none were set.
+func (b *Bolt) GetScanFilters(scanID string) (*pb.ScanFilters, error) {
+	var filters *pb.ScanFilters
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(filtersBucket)
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get([]byte(scanID))
+		if data == nil {
+			return nil
+		}
+		filters = &pb.ScanFilters{}
+		return json.Unmarshal(data, filters)
+	})
+	return filters, err
+}
+
+// RecordFilteredFiles adds n to the running count of files scanID has
+// dropped because they matched a ScanFilters exclusion.
+func (b *Bolt) RecordFilteredFiles(scanID string, n int) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(filesExcludedBucket)
+		if err != nil {
+			return err
+		}
+		count := n
+		if data := bucket.Get([]byte(scanID)); data != nil {
+			var existing int
+			if err := json.Unmarshal(data, &existing); err != nil {
+				return err
+			}
+			count += existing
+		}
+		data, err := json.Marshal(count)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(scanID), data)
+	})
+}
+
+// GetFilteredFileCount returns how many files scanID has dropped so far
+// because they matched a ScanFilters exclusion.
+func (b *Bolt) GetFilteredFileCount(scanID string) (int, error) {
+	count := 0
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(filesExcludedBucket)
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get([]byte(scanID))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &count)
+	})
+	return count, err
+}
+
+// AddFiles adds a set of files to the state. Each file's DataChunks are
+// merged into the chunks already known for that path, keyed by Offset
+// (see mergeChunk in chunks.go): chunks may arrive out of order, in
+// parallel, and with gaps between them, but a chunk whose byte range
+// truly overlaps one already received is rejected.
+func (b *Bolt) AddFiles(scanID string, files []*pb.File) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(filesBucket).Bucket([]byte(scanID))
+		if bucket == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		for _, f := range files {
+			path := f.GetMetadata().GetPath()
+			key := []byte(path)
+			currentFile := &pb.File{Metadata: f.GetMetadata()}
+			if data := bucket.Get(key); data != nil {
+				if err := json.Unmarshal(data, currentFile); err != nil {
+					return err
+				}
+			}
+			merged, err := mergeChunks(currentFile.GetDataChunks(), f.GetDataChunks())
+			if err != nil {
+				return fmt.Errorf("file %s: %v", path, err)
+			}
+			currentFile.DataChunks = merged
+			data, err := json.Marshal(currentFile)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(key, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RemoveFile removes a given file from the state for a scan, if present.
+// Returns true if the file has been removed, false otherwise (i.e. the
+// file was not in the state).
+func (b *Bolt) RemoveFile(scanID string, file *pb.File) (bool, error) {
+	removed := false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(filesBucket).Bucket([]byte(scanID))
+		if bucket == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		key := []byte(file.GetMetadata().GetPath())
+		if bucket.Get(key) == nil {
+			return nil
+		}
+		removed = true
+		return bucket.Delete(key)
+	})
+	return removed, err
+}
+
+// AddInterest adds a new interest for a given minion to the state of the
+// scan, scoped to root (pass "" for unscoped - see MappedInterest.Root).
+// Interests are appended under a monotonically increasing sequence key
+// (via the bucket's NextSequence), so GetInterests returns them back in
+// the order they were added.
+func (b *Bolt) AddInterest(scanID string, interest *mpb.Interest, minion, root string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(interestsBucket).Bucket([]byte(scanID))
+		if bucket == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		data, err := json.Marshal(&MappedInterest{Interest: interest, Minion: minion, Root: root})
+		if err != nil {
+			return err
+		}
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(sequenceKey(seq), data)
+	})
+}
+
+// GetFiles returns all the files known for a given ScanID.
+func (b *Bolt) GetFiles(scanID string) ([]*pb.File, error) {
+	var files []*pb.File
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(filesBucket).Bucket([]byte(scanID))
+		if bucket == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var f pb.File
+			if err := json.Unmarshal(v, &f); err != nil {
+				return err
+			}
+			files = append(files, &f)
+			return nil
+		})
+	})
+	return files, err
+}
+
+// GetInterests returns all the interests known for a given ScanID, mapped
+// to minions, in the order they were added.
+func (b *Bolt) GetInterests(scanID string) ([]*MappedInterest, error) {
+	var interests []*MappedInterest
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(interestsBucket).Bucket([]byte(scanID))
+		if bucket == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var mi MappedInterest
+			if err := json.Unmarshal(v, &mi); err != nil {
+				return err
+			}
+			interests = append(interests, &mi)
+			return nil
+		})
+	})
+	return interests, err
+}
+
+// RecordDispatch adds fileCount to the running total of files dispatched
+// to minion for scanID.
+func (b *Bolt) RecordDispatch(scanID, minion string, fileCount int) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(dispatchCountsBucket).Bucket([]byte(scanID))
+		if bucket == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		key := []byte(minion)
+		count := fileCount
+		if data := bucket.Get(key); data != nil {
+			var existing int
+			if err := json.Unmarshal(data, &existing); err != nil {
+				return err
+			}
+			count += existing
+		}
+		data, err := json.Marshal(count)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, data)
+	})
+}
+
+// GetDispatchCounts returns, for scanID, how many files have been
+// dispatched to each minion so far.
+func (b *Bolt) GetDispatchCounts(scanID string) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(dispatchCountsBucket).Bucket([]byte(scanID))
+		if bucket == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var count int
+			if err := json.Unmarshal(v, &count); err != nil {
+				return err
+			}
+			counts[string(k)] = count
+			return nil
+		})
+	})
+	return counts, err
+}
+
+// AddFindings appends findings to the set collected for scanID, keyed by
+// a monotonically increasing sequence so GetFindings returns them back in
+// the order they were added.
+func (b *Bolt) AddFindings(scanID string, findings []*mpb.Finding) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(findingsBucket).Bucket([]byte(scanID))
+		if bucket == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		for _, f := range findings {
+			data, err := json.Marshal(f)
+			if err != nil {
+				return err
+			}
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(sequenceKey(seq), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetFindings returns every finding collected for scanID so far.
+func (b *Bolt) GetFindings(scanID string) ([]*mpb.Finding, error) {
+	var findings []*mpb.Finding
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(findingsBucket).Bucket([]byte(scanID))
+		if bucket == nil {
+			return fmt.Errorf("scan does not exist")
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var f mpb.Finding
+			if err := json.Unmarshal(v, &f); err != nil {
+				return err
+			}
+			findings = append(findings, &f)
+			return nil
+		})
+	})
+	return findings, err
+}
+
+// sequenceKey renders a bucket sequence number as a fixed-width big-endian
+// key, so bucket.ForEach (which iterates in byte-sorted key order) visits
+// entries in the order they were inserted.
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
@@ -0,0 +1,70 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package state
+
+import "fmt"
+
+// Range is a [Start, End) byte range, exported only so Chunked can be
+// JSON-marshaled as a small index (see Redis.addFile) without ever
+// touching the chunk data itself.
+type Range struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// Chunked tracks which byte ranges of a file have arrived so far, enforcing
+// the same ordering/overlap rule as mergeChunk (see chunks.go), but without
+// ever holding a chunk's data in memory - only its [offset, offset+length)
+// range. Backends that stream chunk bytes straight into storage (see
+// Redis.addFile, which SETRANGEs each chunk directly into its file's blob
+// key) keep a Chunked instead of assembling a []*mpb.DataChunk, so a
+// multi-gigabyte file in flight never needs to fit in the Overlord's own
+// memory just to be merged.
+type Chunked struct {
+	Ranges []Range // kept sorted by Start and non-overlapping.
+}
+
+// Add records a chunk spanning [offset, offset+length), rejecting it if it
+// overlaps a range already recorded.
+func (c *Chunked) Add(offset, length int64) error {
+	start, end := offset, offset+length
+	ranges := c.Ranges
+
+	idx := 0
+	for idx < len(ranges) && ranges[idx].Start < start {
+		idx++
+	}
+	if idx > 0 && ranges[idx-1].End > start {
+		return fmt.Errorf("chunk at offset %d overlaps a previously received chunk", start)
+	}
+	if idx < len(ranges) && ranges[idx].Start < end {
+		return fmt.Errorf("chunk at offset %d overlaps a previously received chunk", start)
+	}
+
+	merged := make([]Range, 0, len(ranges)+1)
+	merged = append(merged, ranges[:idx]...)
+	merged = append(merged, Range{Start: start, End: end})
+	merged = append(merged, ranges[idx:]...)
+	c.Ranges = merged
+	return nil
+}
+
+// Size returns the total bytes recorded across every range added so far.
+func (c *Chunked) Size() int64 {
+	var n int64
+	for _, r := range c.Ranges {
+		n += r.End - r.Start
+	}
+	return n
+}
@@ -0,0 +1,66 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package state
+
+import (
+	"fmt"
+	"sort"
+
+	mpb "github.com/google/minions/proto/minions"
+)
+
+// mergeChunk inserts chunk into chunks (kept sorted by Offset), rejecting
+// it if its byte range truly overlaps an already-present chunk's.
+//
+// Unlike the strictly-sequential assembly this replaces, chunks are no
+// longer required to arrive offset-0-first and contiguous: a scanner can
+// upload out of order and in parallel, and a gap between two chunks is
+// fine (a Minion with a sparse Interest, see mpb.Interest.GetByteRanges,
+// may only ever want a handful of byte ranges out of a multi-gigabyte
+// file). Only a genuine overlap - two chunks claiming the same byte twice
+// - is rejected, since there's no sane way to reconcile which one wins.
+func mergeChunk(chunks []*mpb.DataChunk, chunk *mpb.DataChunk) ([]*mpb.DataChunk, error) {
+	start := chunk.GetOffset()
+	end := start + int64(len(chunk.GetData()))
+
+	idx := sort.Search(len(chunks), func(i int) bool { return chunks[i].GetOffset() >= start })
+	if idx > 0 {
+		prev := chunks[idx-1]
+		if prev.GetOffset()+int64(len(prev.GetData())) > start {
+			return nil, fmt.Errorf("chunk at offset %d overlaps a previously received chunk", start)
+		}
+	}
+	if idx < len(chunks) && chunks[idx].GetOffset() < end {
+		return nil, fmt.Errorf("chunk at offset %d overlaps a previously received chunk", start)
+	}
+
+	merged := make([]*mpb.DataChunk, 0, len(chunks)+1)
+	merged = append(merged, chunks[:idx]...)
+	merged = append(merged, chunk)
+	merged = append(merged, chunks[idx:]...)
+	return merged, nil
+}
+
+// mergeChunks merges every chunk in newChunks into chunks in turn,
+// stopping at the first overlap.
+func mergeChunks(chunks []*mpb.DataChunk, newChunks []*mpb.DataChunk) ([]*mpb.DataChunk, error) {
+	var err error
+	for _, chunk := range newChunks {
+		chunks, err = mergeChunk(chunks, chunk)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return chunks, nil
+}
@@ -0,0 +1,218 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package state
+
+import (
+	"sync"
+	"testing"
+
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+	"github.com/stretchr/testify/require"
+)
+
+// backend names a StateManager implementation under conformance test. We
+// only exercise Local and Bolt here: SQL needs a reachable database/sql
+// driver and Redis needs a live server, and nothing else in this repo
+// spins either up for tests (see minions/vulners/minion_test.go's
+// stateBackends for the same call).
+type backend struct {
+	name string
+	new  func(t *testing.T) (overlordStateManager, func())
+}
+
+// overlordStateManager is the subset of overlord.StateManager this suite
+// exercises, restated here rather than imported to avoid state depending on
+// its own only consumer.
+type overlordStateManager interface {
+	CreateScan(scanID string) error
+	AddFiles(scanID string, files []*pb.File) error
+	GetFiles(scanID string) ([]*pb.File, error)
+	RemoveFile(scanID string, file *pb.File) (bool, error)
+	AddInterest(scanID string, interest *mpb.Interest, minion, root string) error
+	GetInterests(scanID string) ([]*MappedInterest, error)
+	ScanExists(scanID string) bool
+}
+
+var backends = []backend{
+	{name: "Local", new: func(t *testing.T) (overlordStateManager, func()) {
+		return NewLocal(), func() {}
+	}},
+	{name: "Bolt", new: func(t *testing.T) (overlordStateManager, func()) {
+		dir := t.TempDir()
+		b, err := OpenBolt(dir + "/state.db")
+		require.NoError(t, err)
+		return b, func() { b.Close() }
+	}},
+}
+
+func TestConformance(t *testing.T) {
+	for _, b := range backends {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			t.Run("CreateScan", func(t *testing.T) { testCreateScan(t, b) })
+			t.Run("AddFiles_rebuildsOutOfOrderChunks", func(t *testing.T) { testChunkRebuild(t, b) })
+			t.Run("AddFiles_rejectsOverlappingChunks", func(t *testing.T) { testOverlapRejected(t, b) })
+			t.Run("RemoveFile", func(t *testing.T) { testRemoveFile(t, b) })
+			t.Run("AddInterest_preservesOrder", func(t *testing.T) { testInterestOrder(t, b) })
+			t.Run("ConcurrentAddFiles", func(t *testing.T) { testConcurrentAddFiles(t, b) })
+			t.Run("CreateScan_resetsPriorState", func(t *testing.T) { testCreateScanResets(t, b) })
+		})
+	}
+}
+
+func testCreateScan(t *testing.T, b backend) {
+	sm, cleanup := b.new(t)
+	defer cleanup()
+
+	require.False(t, sm.ScanExists("scan-1"))
+	require.NoError(t, sm.CreateScan("scan-1"))
+	require.True(t, sm.ScanExists("scan-1"))
+
+	files, err := sm.GetFiles("scan-1")
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+// testChunkRebuild uploads the same file's three chunks out of order and
+// with a gap between two of them, mirroring a Minion with a sparse
+// Interest (see mergeChunk) - every implementation must reassemble the
+// same sorted, complete chunk list regardless of arrival order.
+func testChunkRebuild(t *testing.T, b backend) {
+	sm, cleanup := b.new(t)
+	defer cleanup()
+	require.NoError(t, sm.CreateScan("scan-1"))
+
+	path := "/etc/passwd"
+	meta := &mpb.FileMetadata{Path: path}
+	chunk := func(offset int64, data string) *pb.File {
+		return &pb.File{Metadata: meta, DataChunks: []*mpb.DataChunk{{Offset: offset, Data: []byte(data)}}}
+	}
+
+	require.NoError(t, sm.AddFiles("scan-1", []*pb.File{chunk(10, "ccc")}))
+	require.NoError(t, sm.AddFiles("scan-1", []*pb.File{chunk(0, "aaa")}))
+	require.NoError(t, sm.AddFiles("scan-1", []*pb.File{chunk(4, "bb")}))
+
+	files, err := sm.GetFiles("scan-1")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	chunks := files[0].GetDataChunks()
+	require.Len(t, chunks, 3)
+	require.Equal(t, []int64{0, 4, 10}, []int64{chunks[0].GetOffset(), chunks[1].GetOffset(), chunks[2].GetOffset()})
+}
+
+func testOverlapRejected(t *testing.T, b backend) {
+	sm, cleanup := b.new(t)
+	defer cleanup()
+	require.NoError(t, sm.CreateScan("scan-1"))
+
+	meta := &mpb.FileMetadata{Path: "/etc/passwd"}
+	first := &pb.File{Metadata: meta, DataChunks: []*mpb.DataChunk{{Offset: 0, Data: []byte("aaaa")}}}
+	overlapping := &pb.File{Metadata: meta, DataChunks: []*mpb.DataChunk{{Offset: 2, Data: []byte("bbbb")}}}
+
+	require.NoError(t, sm.AddFiles("scan-1", []*pb.File{first}))
+	require.Error(t, sm.AddFiles("scan-1", []*pb.File{overlapping}))
+}
+
+func testRemoveFile(t *testing.T, b backend) {
+	sm, cleanup := b.new(t)
+	defer cleanup()
+	require.NoError(t, sm.CreateScan("scan-1"))
+
+	f := &pb.File{Metadata: &mpb.FileMetadata{Path: "/etc/passwd"}}
+	require.NoError(t, sm.AddFiles("scan-1", []*pb.File{f}))
+
+	removed, err := sm.RemoveFile("scan-1", f)
+	require.NoError(t, err)
+	require.True(t, removed)
+
+	removedAgain, err := sm.RemoveFile("scan-1", f)
+	require.NoError(t, err)
+	require.False(t, removedAgain)
+
+	files, err := sm.GetFiles("scan-1")
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+func testInterestOrder(t *testing.T, b backend) {
+	sm, cleanup := b.new(t)
+	defer cleanup()
+	require.NoError(t, sm.CreateScan("scan-1"))
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		interest := &mpb.Interest{PathRegexp: path}
+		require.NoError(t, sm.AddInterest("scan-1", interest, "minion-1", ""))
+	}
+
+	interests, err := sm.GetInterests("scan-1")
+	require.NoError(t, err)
+	require.Len(t, interests, 3)
+	var paths []string
+	for _, i := range interests {
+		paths = append(paths, i.Interest.GetPathRegexp())
+	}
+	require.Equal(t, []string{"/a", "/b", "/c"}, paths)
+}
+
+// testConcurrentAddFiles sends each chunk of a ten-chunk file from its own
+// goroutine, so a StateManager whose AddFiles isn't safe for concurrent
+// writers against the same scan (a lost update from an unserialized
+// read-modify-write, for instance) drops one or more chunks instead of
+// reassembling all ten.
+func testConcurrentAddFiles(t *testing.T, b backend) {
+	sm, cleanup := b.new(t)
+	defer cleanup()
+	require.NoError(t, sm.CreateScan("scan-1"))
+
+	path := "/etc/passwd"
+	meta := &mpb.FileMetadata{Path: path}
+	const chunks = 10
+
+	var wg sync.WaitGroup
+	for i := 0; i < chunks; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f := &pb.File{Metadata: meta, DataChunks: []*mpb.DataChunk{{Offset: int64(i), Data: []byte("x")}}}
+			require.NoError(t, sm.AddFiles("scan-1", []*pb.File{f}))
+		}(i)
+	}
+	wg.Wait()
+
+	files, err := sm.GetFiles("scan-1")
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Len(t, files[0].GetDataChunks(), chunks)
+}
+
+func testCreateScanResets(t *testing.T, b backend) {
+	sm, cleanup := b.new(t)
+	defer cleanup()
+	require.NoError(t, sm.CreateScan("scan-1"))
+
+	f := &pb.File{Metadata: &mpb.FileMetadata{Path: "/etc/passwd"}}
+	require.NoError(t, sm.AddFiles("scan-1", []*pb.File{f}))
+	require.NoError(t, sm.AddInterest("scan-1", &mpb.Interest{PathRegexp: "/a"}, "minion-1", ""))
+
+	require.NoError(t, sm.CreateScan("scan-1"))
+
+	files, err := sm.GetFiles("scan-1")
+	require.NoError(t, err)
+	require.Empty(t, files)
+
+	interests, err := sm.GetInterests("scan-1")
+	require.NoError(t, err)
+	require.Empty(t, interests)
+}
@@ -0,0 +1,409 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis"
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// Redis is a StateManager backed by a Redis instance, so a scan's state
+// survives an Overlord restart (and can be shared by several Overlord
+// replicas, unlike Local or SQL's single *sql.DB).
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis returns a Redis StateManager using client.
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+func (r *Redis) scanKey(scanID string) string { return "minions:scan:" + scanID }
+
+// filesKey is a Hash, field=path, value=JSON(FileMetadata) only - never the
+// chunk data itself, so listing or touching one file never requires
+// reading every other file's bytes. See fileChunksKey/fileDataKey for
+// where the actual chunks live.
+func (r *Redis) filesKey(scanID string) string { return "minions:scan:" + scanID + ":files" }
+
+// fileChunksKey holds a JSON-marshaled Chunked: the [offset, length) index
+// of which byte ranges of path have arrived so far, without any chunk data.
+func (r *Redis) fileChunksKey(scanID, path string) string {
+	return "minions:scan:" + scanID + ":file:" + path + ":chunks"
+}
+
+// fileDataKey holds path's raw received bytes. AddFiles writes into it with
+// SETRANGE at each chunk's offset, so a chunk's bytes go straight into
+// Redis without ever being held alongside the rest of the scan's files in
+// the Overlord's own memory.
+func (r *Redis) fileDataKey(scanID, path string) string {
+	return "minions:scan:" + scanID + ":file:" + path + ":data"
+}
+
+func (r *Redis) interestsKey(scanID string) string { return "minions:scan:" + scanID + ":interests" }
+func (r *Redis) statusKey(scanID string) string    { return "minions:scan:" + scanID + ":status" }
+func (r *Redis) dispatchCountsKey(scanID string) string {
+	return "minions:scan:" + scanID + ":dispatch_counts"
+}
+func (r *Redis) findingsKey(scanID string) string { return "minions:scan:" + scanID + ":findings" }
+func (r *Redis) filtersKey(scanID string) string  { return "minions:scan:" + scanID + ":filters" }
+func (r *Redis) filesExcludedKey(scanID string) string {
+	return "minions:scan:" + scanID + ":files_excluded"
+}
+
+// CreateScan initializes the state for a scan. It resets the state if it
+// already exists, including every per-path chunks/data key left over from
+// its previous run.
+func (r *Redis) CreateScan(scanID string) error {
+	paths, err := r.client.HKeys(r.filesKey(scanID)).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	keys := []string{r.filesKey(scanID), r.interestsKey(scanID), r.dispatchCountsKey(scanID), r.findingsKey(scanID), r.filtersKey(scanID), r.filesExcludedKey(scanID)}
+	for _, path := range paths {
+		keys = append(keys, r.fileChunksKey(scanID, path), r.fileDataKey(scanID, path))
+	}
+	if err := r.client.Del(keys...).Err(); err != nil {
+		return err
+	}
+	if err := r.client.Set(r.statusKey(scanID), int(pb.ScanStatus_RUNNING), 0).Err(); err != nil {
+		return err
+	}
+	return r.client.Set(r.scanKey(scanID), "1", 0).Err()
+}
+
+// ScanExists returns true if any state at all is known about the scan.
+func (r *Redis) ScanExists(scanID string) bool {
+	n, err := r.client.Exists(r.scanKey(scanID)).Result()
+	return err == nil && n > 0
+}
+
+// ResumeScan checks scanID was already known before this restart; like
+// SQL, Redis never drops files or interests of its own accord (short of
+// an administrator flushing the instance), so there is nothing further to
+// rehydrate.
+func (r *Redis) ResumeScan(scanID string) error {
+	if !r.ScanExists(scanID) {
+		return fmt.Errorf("cannot resume scan %s: no persisted state found", scanID)
+	}
+	return nil
+}
+
+// SetScanFilters persists the ScanFilters a scan was created with, so
+// ScanFiles keeps applying them consistently across an Overlord restart.
+func (r *Redis) SetScanFilters(scanID string, filters *pb.ScanFilters) error {
+	blob, err := json.Marshal(filters)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(r.filtersKey(scanID), blob, 0).Err()
+}
+
+// GetScanFilters returns the ScanFilters in effect for scanID, or nil if
+// none were set.
+func (r *Redis) GetScanFilters(scanID string) (*pb.ScanFilters, error) {
+	blob, err := r.client.Get(r.filtersKey(scanID)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var filters pb.ScanFilters
+	if err := json.Unmarshal(blob, &filters); err != nil {
+		return nil, err
+	}
+	return &filters, nil
+}
+
+// RecordFilteredFiles adds n to the running count of files scanID has
+// dropped because they matched a ScanFilters exclusion.
+func (r *Redis) RecordFilteredFiles(scanID string, n int) error {
+	return r.client.IncrBy(r.filesExcludedKey(scanID), int64(n)).Err()
+}
+
+// GetFilteredFileCount returns how many files scanID has dropped so far
+// because they matched a ScanFilters exclusion.
+func (r *Redis) GetFilteredFileCount(scanID string) (int, error) {
+	n, err := r.client.Get(r.filesExcludedKey(scanID)).Int()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// SetScanStatus atomically transitions scanID's lifecycle status.
+func (r *Redis) SetScanStatus(scanID string, status pb.ScanStatus) error {
+	return r.client.Set(r.statusKey(scanID), int(status), 0).Err()
+}
+
+// GetScanStatus returns scanID's current lifecycle status.
+func (r *Redis) GetScanStatus(scanID string) (pb.ScanStatus, error) {
+	n, err := r.client.Get(r.statusKey(scanID)).Int64()
+	if err == redis.Nil {
+		return pb.ScanStatus_RUNNING, fmt.Errorf("scan does not exist")
+	}
+	if err != nil {
+		return pb.ScanStatus_RUNNING, err
+	}
+	return pb.ScanStatus(n), nil
+}
+
+// RecordDispatch adds fileCount to the running total of files dispatched
+// to minion for scanID. Stored as a single scanID -> {minion: count}
+// JSON-encoded hash, read-modify-written under a per-scan watch like
+// AddFiles.
+func (r *Redis) RecordDispatch(scanID, minion string, fileCount int) error {
+	key := r.dispatchCountsKey(scanID)
+	return r.client.Watch(func(tx *redis.Tx) error {
+		counts, err := r.readDispatchCounts(tx, key)
+		if err != nil {
+			return err
+		}
+		counts[minion] += fileCount
+		blob, err := json.Marshal(counts)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.Set(key, blob, 0)
+			return nil
+		})
+		return err
+	}, key)
+}
+
+// GetDispatchCounts returns, for scanID, how many files have been
+// dispatched to each minion so far.
+func (r *Redis) GetDispatchCounts(scanID string) (map[string]int, error) {
+	return r.readDispatchCounts(r.client, r.dispatchCountsKey(scanID))
+}
+
+func (r *Redis) readDispatchCounts(c redis.Cmdable, key string) (map[string]int, error) {
+	blob, err := c.Get(key).Bytes()
+	if err == redis.Nil {
+		return make(map[string]int), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	if err := json.Unmarshal(blob, &counts); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// AddFindings appends findings to the set collected for scanID.
+func (r *Redis) AddFindings(scanID string, findings []*mpb.Finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+	blobs := make([]interface{}, 0, len(findings))
+	for _, f := range findings {
+		blob, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, blob)
+	}
+	return r.client.RPush(r.findingsKey(scanID), blobs...).Err()
+}
+
+// GetFindings returns every finding collected for scanID so far.
+func (r *Redis) GetFindings(scanID string) ([]*mpb.Finding, error) {
+	blobs, err := r.client.LRange(r.findingsKey(scanID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]*mpb.Finding, 0, len(blobs))
+	for _, blob := range blobs {
+		var f mpb.Finding
+		if err := json.Unmarshal([]byte(blob), &f); err != nil {
+			return nil, err
+		}
+		findings = append(findings, &f)
+	}
+	return findings, nil
+}
+
+// AddFiles adds a set of files to the state, one file at a time (see
+// addFile) - so a batch touching a handful of paths out of a
+// many-thousand-file scan never has to read or rewrite anyone else's
+// bytes.
+func (r *Redis) AddFiles(scanID string, files []*pb.File) error {
+	for _, f := range files {
+		if err := r.addFile(scanID, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFile merges f's DataChunks into the Chunked range index already known
+// for its path (enforcing the same offset/overlap rule as mergeChunk, see
+// chunks.go and Chunked.Add), then SETRANGEs each chunk's bytes straight
+// into the path's fileDataKey. Unlike Local and SQL, which hold a file's
+// received chunks (data included) as a Go slice or a single marshaled blob,
+// the chunk bytes here never pass through this process's own memory as
+// anything but the in-flight request: a multi-gigabyte file in progress
+// doesn't need to fit in the Overlord's heap to be stored.
+func (r *Redis) addFile(scanID string, f *pb.File) error {
+	path := f.GetMetadata().GetPath()
+	chunksKey := r.fileChunksKey(scanID, path)
+	dataKey := r.fileDataKey(scanID, path)
+
+	return r.client.Watch(func(tx *redis.Tx) error {
+		chunked, err := r.readChunked(tx, chunksKey)
+		if err != nil {
+			return err
+		}
+		for _, c := range f.GetDataChunks() {
+			if err := chunked.Add(c.GetOffset(), int64(len(c.GetData()))); err != nil {
+				return fmt.Errorf("file %s: %v", path, err)
+			}
+		}
+		chunksBlob, err := json.Marshal(chunked)
+		if err != nil {
+			return err
+		}
+		metadataBlob, err := json.Marshal(f.GetMetadata())
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.HSet(r.filesKey(scanID), path, metadataBlob)
+			pipe.Set(chunksKey, chunksBlob, 0)
+			for _, c := range f.GetDataChunks() {
+				pipe.SetRange(dataKey, c.GetOffset(), string(c.GetData()))
+			}
+			return nil
+		})
+		return err
+	}, chunksKey)
+}
+
+// RemoveFile removes a given file from the state for a scan, if present.
+// Returns true if the file has been removed, false otherwise (i.e. the
+// file was not in the state).
+func (r *Redis) RemoveFile(scanID string, file *pb.File) (bool, error) {
+	path := file.GetMetadata().GetPath()
+	removed, err := r.client.HDel(r.filesKey(scanID), path).Result()
+	if err != nil {
+		return false, err
+	}
+	if removed == 0 {
+		return false, nil
+	}
+	if err := r.client.Del(r.fileChunksKey(scanID, path), r.fileDataKey(scanID, path)).Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AddInterest adds a new interest for a given minion to the state of the
+// scan, scoped to root (pass "" for unscoped - see MappedInterest.Root).
+func (r *Redis) AddInterest(scanID string, interest *mpb.Interest, minion, root string) error {
+	blob, err := json.Marshal(&MappedInterest{Interest: interest, Minion: minion, Root: root})
+	if err != nil {
+		return err
+	}
+	return r.client.RPush(r.interestsKey(scanID), blob).Err()
+}
+
+// GetFiles returns all the files known for a given ScanID, each file's
+// DataChunks rebuilt from its fileDataKey bytes sliced along the ranges
+// recorded in its Chunked index - so a file with gaps (e.g. a minion with
+// a sparse byte-range Interest) comes back with exactly the ranges
+// received, not a contiguous blob padded with zeroes for the gaps.
+func (r *Redis) GetFiles(scanID string) ([]*pb.File, error) {
+	if !r.ScanExists(scanID) {
+		return nil, fmt.Errorf("scan does not exist")
+	}
+	metadataBlobs, err := r.client.HGetAll(r.filesKey(scanID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]*pb.File, 0, len(metadataBlobs))
+	for path, metadataBlob := range metadataBlobs {
+		var metadata mpb.FileMetadata
+		if err := json.Unmarshal([]byte(metadataBlob), &metadata); err != nil {
+			return nil, err
+		}
+		chunked, err := r.readChunked(r.client, r.fileChunksKey(scanID, path))
+		if err != nil {
+			return nil, err
+		}
+		var chunks []*mpb.DataChunk
+		if len(chunked.Ranges) > 0 {
+			data, err := r.client.Get(r.fileDataKey(scanID, path)).Bytes()
+			if err != nil && err != redis.Nil {
+				return nil, err
+			}
+			for _, rg := range chunked.Ranges {
+				b := data[rg.Start:rg.End]
+				sum := sha256.Sum256(b)
+				chunks = append(chunks, &mpb.DataChunk{Offset: rg.Start, Data: b, Sha256: sum[:]})
+			}
+		}
+		files = append(files, &pb.File{Metadata: &metadata, DataChunks: chunks})
+	}
+	return files, nil
+}
+
+// GetInterests returns all the interests known for a given ScanID, mapped
+// to minions.
+func (r *Redis) GetInterests(scanID string) ([]*MappedInterest, error) {
+	if !r.ScanExists(scanID) {
+		return nil, fmt.Errorf("scan does not exist")
+	}
+	blobs, err := r.client.LRange(r.interestsKey(scanID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	interests := make([]*MappedInterest, 0, len(blobs))
+	for _, blob := range blobs {
+		var mi MappedInterest
+		if err := json.Unmarshal([]byte(blob), &mi); err != nil {
+			return nil, err
+		}
+		interests = append(interests, &mi)
+	}
+	return interests, nil
+}
+
+// readChunked takes a redis.Cmdable rather than *redis.Client directly, so
+// addFile can reuse it from inside a Watch transaction's *redis.Tx as well
+// as for the plain read in GetFiles. A key with no Chunked yet recorded
+// reads back as an empty one, rather than an error.
+func (r *Redis) readChunked(c redis.Cmdable, key string) (*Chunked, error) {
+	blob, err := c.Get(key).Bytes()
+	if err == redis.Nil {
+		return &Chunked{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var chunked Chunked
+	if err := json.Unmarshal(blob, &chunked); err != nil {
+		return nil, err
+	}
+	return &chunked, nil
+}
@@ -0,0 +1,423 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package state
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// SQL is a StateManager backed by a database/sql database, so a scan's
+// state survives an Overlord restart. Its queries use sqlite3's dialect
+// (? placeholders, INSERT OR REPLACE), so it runs against a sqlite3
+// *sql.DB; postgres, whose placeholders are $1, $2, ... and which has no
+// INSERT OR REPLACE, does not accept them. Callers open the *sql.DB
+// themselves with the driver they've imported for side effects (e.g.
+// "github.com/mattn/go-sqlite3"), since this package doesn't force a
+// driver on callers that don't need it.
+type SQL struct {
+	db *sql.DB
+}
+
+// NewSQL returns a SQL StateManager using db, creating its tables if they
+// don't already exist.
+func NewSQL(db *sql.DB) (*SQL, error) {
+	s := &SQL{db: db}
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS scans (
+			scan_id TEXT PRIMARY KEY,
+			status INTEGER NOT NULL DEFAULT 0,
+			filters BLOB,
+			files_excluded_count INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS files (
+			scan_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			metadata BLOB NOT NULL,
+			chunks BLOB,
+			PRIMARY KEY (scan_id, path)
+		)`,
+		`CREATE TABLE IF NOT EXISTS interests (
+			scan_id TEXT NOT NULL,
+			minion TEXT NOT NULL,
+			interest BLOB NOT NULL,
+			root TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS dispatch_counts (
+			scan_id TEXT NOT NULL,
+			minion TEXT NOT NULL,
+			file_count INTEGER NOT NULL,
+			PRIMARY KEY (scan_id, minion)
+		)`,
+		`CREATE TABLE IF NOT EXISTS findings (
+			scan_id TEXT NOT NULL,
+			finding BLOB NOT NULL
+		)`,
+	} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return nil, fmt.Errorf("creating schema: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// CreateScan initializes the state for a scan. It resets the state if it
+// already exists.
+func (s *SQL) CreateScan(scanID string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM files WHERE scan_id = ?`, scanID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM interests WHERE scan_id = ?`, scanID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM dispatch_counts WHERE scan_id = ?`, scanID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM findings WHERE scan_id = ?`, scanID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO scans (scan_id, status) VALUES (?, ?)`, scanID, pb.ScanStatus_RUNNING); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ScanExists returns true if any state at all is known about the scan.
+func (s *SQL) ScanExists(scanID string) bool {
+	var discard string
+	err := s.db.QueryRow(`SELECT scan_id FROM scans WHERE scan_id = ?`, scanID).Scan(&discard)
+	return err == nil
+}
+
+// ResumeScan checks scanID was already known before this restart; unlike
+// Local, SQL never drops files or interests of its own accord, so there is
+// nothing further to rehydrate - GetFiles/GetInterests already read
+// straight from the database.
+func (s *SQL) ResumeScan(scanID string) error {
+	if !s.ScanExists(scanID) {
+		return fmt.Errorf("cannot resume scan %s: no persisted state found", scanID)
+	}
+	return nil
+}
+
+// SetScanFilters persists the ScanFilters a scan was created with, so
+// ScanFiles keeps applying them consistently across an Overlord restart.
+func (s *SQL) SetScanFilters(scanID string, filters *pb.ScanFilters) error {
+	blob, err := json.Marshal(filters)
+	if err != nil {
+		return err
+	}
+	res, err := s.db.Exec(`UPDATE scans SET filters = ? WHERE scan_id = ?`, blob, scanID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("scan does not exist")
+	}
+	return nil
+}
+
+// GetScanFilters returns the ScanFilters in effect for scanID, or nil if
+// none were set.
+func (s *SQL) GetScanFilters(scanID string) (*pb.ScanFilters, error) {
+	var blob []byte
+	err := s.db.QueryRow(`SELECT filters FROM scans WHERE scan_id = ?`, scanID).Scan(&blob)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("scan does not exist")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) == 0 {
+		return nil, nil
+	}
+	var filters pb.ScanFilters
+	if err := json.Unmarshal(blob, &filters); err != nil {
+		return nil, err
+	}
+	return &filters, nil
+}
+
+// RecordFilteredFiles adds n to the running count of files scanID has
+// dropped because they matched a ScanFilters exclusion.
+func (s *SQL) RecordFilteredFiles(scanID string, n int) error {
+	res, err := s.db.Exec(`UPDATE scans SET files_excluded_count = files_excluded_count + ? WHERE scan_id = ?`, n, scanID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("scan does not exist")
+	}
+	return nil
+}
+
+// GetFilteredFileCount returns how many files scanID has dropped so far
+// because they matched a ScanFilters exclusion.
+func (s *SQL) GetFilteredFileCount(scanID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT files_excluded_count FROM scans WHERE scan_id = ?`, scanID).Scan(&count)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("scan does not exist")
+	}
+	return count, err
+}
+
+// SetScanStatus atomically transitions scanID's lifecycle status.
+func (s *SQL) SetScanStatus(scanID string, status pb.ScanStatus) error {
+	res, err := s.db.Exec(`UPDATE scans SET status = ? WHERE scan_id = ?`, status, scanID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("scan does not exist")
+	}
+	return nil
+}
+
+// GetScanStatus returns scanID's current lifecycle status.
+func (s *SQL) GetScanStatus(scanID string) (pb.ScanStatus, error) {
+	var status pb.ScanStatus
+	err := s.db.QueryRow(`SELECT status FROM scans WHERE scan_id = ?`, scanID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return pb.ScanStatus_RUNNING, fmt.Errorf("scan does not exist")
+	}
+	return status, err
+}
+
+// RecordDispatch adds fileCount to the running total of files dispatched
+// to minion for scanID.
+func (s *SQL) RecordDispatch(scanID, minion string, fileCount int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO dispatch_counts (scan_id, minion, file_count) VALUES (?, ?, ?)
+		ON CONFLICT (scan_id, minion) DO UPDATE SET file_count = file_count + excluded.file_count`,
+		scanID, minion, fileCount)
+	return err
+}
+
+// GetDispatchCounts returns, for scanID, how many files have been
+// dispatched to each minion so far.
+func (s *SQL) GetDispatchCounts(scanID string) (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT minion, file_count FROM dispatch_counts WHERE scan_id = ?`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var minion string
+		var count int
+		if err := rows.Scan(&minion, &count); err != nil {
+			return nil, err
+		}
+		counts[minion] = count
+	}
+	return counts, rows.Err()
+}
+
+// AddFindings appends findings to the set collected for scanID.
+func (s *SQL) AddFindings(scanID string, findings []*mpb.Finding) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, f := range findings {
+		blob, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO findings (scan_id, finding) VALUES (?, ?)`, scanID, blob); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetFindings returns every finding collected for scanID so far.
+func (s *SQL) GetFindings(scanID string) ([]*mpb.Finding, error) {
+	rows, err := s.db.Query(`SELECT finding FROM findings WHERE scan_id = ?`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var findings []*mpb.Finding
+	for rows.Next() {
+		var blob []byte
+		if err := rows.Scan(&blob); err != nil {
+			return nil, err
+		}
+		var f mpb.Finding
+		if err := json.Unmarshal(blob, &f); err != nil {
+			return nil, err
+		}
+		findings = append(findings, &f)
+	}
+	return findings, rows.Err()
+}
+
+// AddFiles adds a set of files to the state. Each file's DataChunks are
+// merged into the chunks already known for that path, keyed by Offset
+// (see mergeChunk in chunks.go): chunks may arrive out of order, in
+// parallel, and with gaps between them, but a chunk whose byte range
+// truly overlaps one already received is rejected.
+func (s *SQL) AddFiles(scanID string, files []*pb.File) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, f := range files {
+		path := f.GetMetadata().GetPath()
+		var metadataBlob, chunksBlob []byte
+		err := tx.QueryRow(`SELECT metadata, chunks FROM files WHERE scan_id = ? AND path = ?`, scanID, path).Scan(&metadataBlob, &chunksBlob)
+		var chunks []*mpb.DataChunk
+		if err == sql.ErrNoRows {
+			metadataBlob, err = json.Marshal(f.GetMetadata())
+			if err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else if len(chunksBlob) > 0 {
+			if err := json.Unmarshal(chunksBlob, &chunks); err != nil {
+				return err
+			}
+		}
+
+		chunks, err = mergeChunks(chunks, f.GetDataChunks())
+		if err != nil {
+			return fmt.Errorf("file %s: %v", path, err)
+		}
+		chunksBlob, err = json.Marshal(chunks)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO files (scan_id, path, metadata, chunks) VALUES (?, ?, ?, ?)`,
+			scanID, path, metadataBlob, chunksBlob); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RemoveFile removes a given file from the state for a scan, if present.
+// Returns true if the file has been removed, false otherwise (i.e. the
+// file was not in the state).
+func (s *SQL) RemoveFile(scanID string, file *pb.File) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM files WHERE scan_id = ? AND path = ?`, scanID, file.GetMetadata().GetPath())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// AddInterest adds a new interest for a given minion to the state of the
+// scan, scoped to root (pass "" for unscoped - see MappedInterest.Root).
+func (s *SQL) AddInterest(scanID string, interest *mpb.Interest, minion, root string) error {
+	blob, err := json.Marshal(interest)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO interests (scan_id, minion, interest, root) VALUES (?, ?, ?, ?)`, scanID, minion, blob, root)
+	return err
+}
+
+// GetFiles returns all the files known for a given ScanID.
+func (s *SQL) GetFiles(scanID string) ([]*pb.File, error) {
+	if !s.ScanExists(scanID) {
+		return nil, fmt.Errorf("scan does not exist")
+	}
+	rows, err := s.db.Query(`SELECT metadata, chunks FROM files WHERE scan_id = ?`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []*pb.File
+	for rows.Next() {
+		var metadataBlob, chunksBlob []byte
+		if err := rows.Scan(&metadataBlob, &chunksBlob); err != nil {
+			return nil, err
+		}
+		var metadata mpb.FileMetadata
+		if err := json.Unmarshal(metadataBlob, &metadata); err != nil {
+			return nil, err
+		}
+		f := &pb.File{Metadata: &metadata}
+		if len(chunksBlob) > 0 {
+			if err := json.Unmarshal(chunksBlob, &f.DataChunks); err != nil {
+				return nil, err
+			}
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// GetInterests returns all the interests known for a given ScanID, mapped
+// to minions.
+func (s *SQL) GetInterests(scanID string) ([]*MappedInterest, error) {
+	if !s.ScanExists(scanID) {
+		return nil, fmt.Errorf("scan does not exist")
+	}
+	rows, err := s.db.Query(`SELECT minion, interest, root FROM interests WHERE scan_id = ?`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interests []*MappedInterest
+	for rows.Next() {
+		var minion, root string
+		var blob []byte
+		if err := rows.Scan(&minion, &blob, &root); err != nil {
+			return nil, err
+		}
+		var interest mpb.Interest
+		if err := json.Unmarshal(blob, &interest); err != nil {
+			return nil, err
+		}
+		interests = append(interests, &MappedInterest{Interest: &interest, Minion: minion, Root: root})
+	}
+	return interests, rows.Err()
+}
@@ -17,7 +17,7 @@ package state
 import (
 	"errors"
 	"fmt"
-	"sort"
+	"sync"
 	"time"
 
 	mpb "github.com/google/minions/proto/minions"
@@ -25,66 +25,78 @@ import (
 	"github.com/patrickmn/go-cache"
 )
 
-// Local handles state through a local time-expiring cache.
+// Local handles state through a local time-expiring cache: a scan not
+// touched for localScanTTL is dropped, freeing memory from overlords that
+// are never explicitly told a scan is done. Every other StateManager in
+// this package (Bolt, SQL, Redis) exists for durability instead, so none of
+// them expire scans this way - see the StateManager doc comment.
+//
+// mu serializes every method below: a read-modify-write against lc's
+// in-memory state (e.g. AddFiles's merge) isn't atomic on its own the way a
+// single BoltDB/SQL transaction or a Redis WATCH/MULTI is, so two
+// goroutines racing to update the same scan could otherwise lose one's
+// write.
 type Local struct {
+	mu sync.Mutex
 	lc *cache.Cache
 }
 
-// MappedInterest stores the interest along with the address of the minion which expressed it.
+// localScanTTL is how long a scan's state survives without being touched
+// before Local drops it; see the Local doc comment.
+const localScanTTL = 5 * time.Minute
+
+// MappedInterest stores the interest along with the address of the minion
+// which expressed it. Root, if set, scopes the interest to files under
+// that path prefix (see a CreateScanRequest's PolicyScope and
+// interests.IsMatchingInRoot); empty means the interest applies anywhere.
 type MappedInterest struct {
 	Interest *mpb.Interest
 	Minion   string
+	Root     string
 }
 
 // state stores the current state of a scan
 type state struct {
-	interests []*MappedInterest
-	files     map[string]*pb.File
+	interests          []*MappedInterest
+	files              map[string]*pb.File
+	status             pb.ScanStatus
+	dispatchCounts     map[string]int
+	findings           []*mpb.Finding
+	filters            *pb.ScanFilters
+	filesExcludedCount int
 }
 
 // NewLocal creates a StateManager backed by a local cache.
 func NewLocal() *Local {
-	lc := cache.New(5*time.Minute, 10*time.Minute)
+	lc := cache.New(localScanTTL, 2*localScanTTL)
 	return &Local{lc: lc}
 }
 
-// AddFiles adds a set of files to the state. This will also dynamically
-// merge chunks of files.
+// AddFiles adds a set of files to the state. Each file's DataChunks are
+// merged into the chunks already known for that path, keyed by Offset
+// (see mergeChunk): chunks may arrive out of order, in parallel, and with
+// gaps between them, but a chunk whose byte range truly overlaps one
+// already received is rejected.
 func (l *Local) AddFiles(scanID string, files []*pb.File) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	s, found := l.getState(scanID)
 	if !found {
 		return errors.New("Cannot find state of scan")
 	}
 	for _, f := range files {
-		currentFile, alreadyKnown := s.files[f.GetMetadata().GetPath()]
+		path := f.GetMetadata().GetPath()
+		currentFile, alreadyKnown := s.files[path]
 		if !alreadyKnown {
-			currentFile = &pb.File{
-				Metadata: f.GetMetadata(),
-				DataChunks: []*pb.DataChunk{
-					&pb.DataChunk{
-						Offset: 0,
-					},
-				},
-			}
-			s.files[f.GetMetadata().GetPath()] = currentFile
+			currentFile = &pb.File{Metadata: f.GetMetadata()}
+			s.files[path] = currentFile
 		}
-		currentChunk := currentFile.GetDataChunks()[0]
-		size := int64(len(currentChunk.GetData()))
-
-		newChunks := f.GetDataChunks()
-		sort.Slice(newChunks, func(i, j int) bool {
-			return newChunks[i].GetOffset() < newChunks[j].GetOffset()
-		})
-		for _, chunk := range newChunks {
-			if chunk.GetOffset() < size {
-				return fmt.Errorf("received a file with overlapping DataChunks")
-			}
-			if chunk.GetOffset() != size {
-				return fmt.Errorf("received a file with missing DataChunks")
-			}
-			currentChunk.Data = append(currentChunk.Data, chunk.GetData()...)
-			size += int64(len(chunk.GetData()))
+		merged, err := mergeChunks(currentFile.GetDataChunks(), f.GetDataChunks())
+		if err != nil {
+			return fmt.Errorf("file %s: %v", path, err)
 		}
+		currentFile.DataChunks = merged
 	}
 	l.setState(scanID, s)
 	return nil
@@ -93,9 +105,14 @@ func (l *Local) AddFiles(scanID string, files []*pb.File) error {
 // CreateScan initializes the state for a scan. It resets the state
 // if it already exists.
 func (l *Local) CreateScan(scanID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	l.setState(scanID, state{
-		interests: make([]*MappedInterest, 0),
-		files:     make(map[string]*pb.File),
+		interests:      make([]*MappedInterest, 0),
+		files:          make(map[string]*pb.File),
+		status:         pb.ScanStatus_RUNNING,
+		dispatchCounts: make(map[string]int),
 	})
 	return nil
 }
@@ -104,6 +121,9 @@ func (l *Local) CreateScan(scanID string) error {
 // Returns true if the file has been removed, false otherwise
 // (i.e. the file was not in the state)
 func (l *Local) RemoveFile(scanID string, file *pb.File) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	s, ok := l.getState(scanID)
 	if !ok {
 		return false, fmt.Errorf("No state for scan %s", scanID)
@@ -117,12 +137,17 @@ func (l *Local) RemoveFile(scanID string, file *pb.File) (bool, error) {
 	return false, nil
 }
 
-// AddInterest adds a new interest for a given minion to the state of the scan.
-func (l *Local) AddInterest(scanID string, interest *mpb.Interest, minion string) error {
+// AddInterest adds a new interest for a given minion to the state of the
+// scan, scoped to root (see MappedInterest.Root; pass "" for unscoped).
+func (l *Local) AddInterest(scanID string, interest *mpb.Interest, minion, root string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	s, _ := l.getState(scanID)
 	s.interests = append(s.interests, &MappedInterest{
 		Interest: interest,
 		Minion:   minion,
+		Root:     root,
 	})
 	l.setState(scanID, s)
 	return nil
@@ -130,12 +155,166 @@ func (l *Local) AddInterest(scanID string, interest *mpb.Interest, minion string
 
 // ScanExists returns true if any state at all is known about the scan.
 func (l *Local) ScanExists(scanID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	_, exists := l.lc.Get(scanID)
 	return exists
 }
 
+// ResumeScan always fails: Local keeps no state besides its in-memory
+// cache, so there is nothing left to rehydrate once the process that held
+// it is gone. Use SQL or Redis (see sql.go, redis.go) if scans need to
+// survive an Overlord restart.
+func (l *Local) ResumeScan(scanID string) error {
+	return fmt.Errorf("cannot resume scan %s: state.Local keeps no state across restarts", scanID)
+}
+
+// SetScanStatus atomically transitions scanID's lifecycle status.
+func (l *Local) SetScanStatus(scanID string, status pb.ScanStatus) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, found := l.getState(scanID)
+	if !found {
+		return fmt.Errorf("no state for scan %s", scanID)
+	}
+	s.status = status
+	l.setState(scanID, s)
+	return nil
+}
+
+// GetScanStatus returns scanID's current lifecycle status.
+func (l *Local) GetScanStatus(scanID string) (pb.ScanStatus, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, found := l.getState(scanID)
+	if !found {
+		return pb.ScanStatus_RUNNING, fmt.Errorf("no state for scan %s", scanID)
+	}
+	return s.status, nil
+}
+
+// RecordDispatch adds fileCount to the running total of files dispatched
+// to minion for scanID.
+func (l *Local) RecordDispatch(scanID, minion string, fileCount int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, found := l.getState(scanID)
+	if !found {
+		return fmt.Errorf("no state for scan %s", scanID)
+	}
+	if s.dispatchCounts == nil {
+		s.dispatchCounts = make(map[string]int)
+	}
+	s.dispatchCounts[minion] += fileCount
+	l.setState(scanID, s)
+	return nil
+}
+
+// GetDispatchCounts returns, for scanID, how many files have been
+// dispatched to each minion so far.
+func (l *Local) GetDispatchCounts(scanID string) (map[string]int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, found := l.getState(scanID)
+	if !found {
+		return nil, fmt.Errorf("no state for scan %s", scanID)
+	}
+	return s.dispatchCounts, nil
+}
+
+// AddFindings appends findings to the set collected for scanID.
+func (l *Local) AddFindings(scanID string, findings []*mpb.Finding) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, found := l.getState(scanID)
+	if !found {
+		return fmt.Errorf("no state for scan %s", scanID)
+	}
+	s.findings = append(s.findings, findings...)
+	l.setState(scanID, s)
+	return nil
+}
+
+// GetFindings returns every finding collected for scanID so far.
+func (l *Local) GetFindings(scanID string) ([]*mpb.Finding, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, found := l.getState(scanID)
+	if !found {
+		return nil, fmt.Errorf("no state for scan %s", scanID)
+	}
+	return s.findings, nil
+}
+
+// SetScanFilters records the ScanFilters a scan was created with, so
+// ScanFiles keeps applying them to every later batch of the scan. Like the
+// rest of Local's state, they do not survive an Overlord restart.
+func (l *Local) SetScanFilters(scanID string, filters *pb.ScanFilters) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, found := l.getState(scanID)
+	if !found {
+		return fmt.Errorf("no state for scan %s", scanID)
+	}
+	s.filters = filters
+	l.setState(scanID, s)
+	return nil
+}
+
+// GetScanFilters returns the ScanFilters in effect for scanID, or nil if
+// none were set.
+func (l *Local) GetScanFilters(scanID string) (*pb.ScanFilters, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, found := l.getState(scanID)
+	if !found {
+		return nil, fmt.Errorf("no state for scan %s", scanID)
+	}
+	return s.filters, nil
+}
+
+// RecordFilteredFiles adds n to the running count of files scanID has
+// dropped because they matched a ScanFilters exclusion.
+func (l *Local) RecordFilteredFiles(scanID string, n int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, found := l.getState(scanID)
+	if !found {
+		return fmt.Errorf("no state for scan %s", scanID)
+	}
+	s.filesExcludedCount += n
+	l.setState(scanID, s)
+	return nil
+}
+
+// GetFilteredFileCount returns how many files scanID has dropped so far
+// because they matched a ScanFilters exclusion.
+func (l *Local) GetFilteredFileCount(scanID string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, found := l.getState(scanID)
+	if !found {
+		return 0, fmt.Errorf("no state for scan %s", scanID)
+	}
+	return s.filesExcludedCount, nil
+}
+
 // GetFiles returns all the files known for a given ScanID
 func (l *Local) GetFiles(scanID string) ([]*pb.File, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	s, found := l.getState(scanID)
 	if !found {
 		return nil, errors.New("Scan does not exist")
@@ -149,6 +328,9 @@ func (l *Local) GetFiles(scanID string) ([]*pb.File, error) {
 
 // GetInterests returns all the interests known for a given ScanID, mapped to minions
 func (l *Local) GetInterests(scanID string) ([]*MappedInterest, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	s, found := l.getState(scanID)
 	if !found {
 		return nil, errors.New("Scan does not exist")
@@ -0,0 +1,59 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"io"
+
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// StreamScanFiles is the client-streaming counterpart to ScanFiles: a
+// goblin can spread one file's chunks (or many small files) across any
+// number of ScanFilesRequest messages instead of packing them into a
+// single, potentially file-sized unary call - see
+// goblins.SendFilesStreaming, which opens each file with os.Open and
+// streams it straight onto the wire rather than reading it whole first.
+// Each message is handled exactly like a ScanFiles call (state is updated
+// and routeAndDispatch runs as soon as the message arrives, so a completed
+// file is flushed to minions immediately rather than held until the
+// stream closes), and the results and any NewInterests seen across the
+// whole stream are aggregated into the single response a client-streaming
+// RPC can return.
+//
+// A client-streaming RPC has no way to push NewInterests back mid-stream,
+// unlike ScanFiles' per-call response; a sender that wants to react to
+// them before it's done sending should open a fresh StreamScanFiles call
+// once this one returns, the same way SendFilesWithFilter recurses on
+// NewInterests today.
+func (s *Server) StreamScanFiles(stream pb.Overlord_StreamScanFilesServer) error {
+	var results []*mpb.Finding
+	var newInterests []*mpb.Interest
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&pb.ScanFilesResponse{Results: results, NewInterests: newInterests})
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := s.ScanFiles(stream.Context(), req)
+		if err != nil {
+			return err
+		}
+		results = append(results, resp.GetResults()...)
+		newInterests = append(newInterests, resp.GetNewInterests()...)
+	}
+}
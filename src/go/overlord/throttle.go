@@ -0,0 +1,44 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// GetScanRate reports scanID's current throttle.Tracker stats - the
+// configured rate/burst (see SetThrottle) alongside the EMA throughput and
+// total bytes actually observed so far - so an operator can watch a large
+// scan that's saturating (or being needlessly throttled against) a
+// minion's host. Every field but ScanId is zero if SetThrottle was never
+// called.
+func (s *Server) GetScanRate(ctx context.Context, req *pb.GetScanRateRequest) (*pb.GetScanRateResponse, error) {
+	scanID := req.GetScanId()
+	if !s.stateManager.ScanExists(scanID) {
+		return nil, fmt.Errorf("unknown scan ID %s", scanID)
+	}
+	stats := s.throttle.Get(scanID).Stats()
+	return &pb.GetScanRateResponse{
+		ScanId:           scanID,
+		RateBytesPerSec:  stats.RateBytesPerSec,
+		LimitBytesPerSec: stats.LimitBytesPerSec,
+		BurstBytes:       stats.BurstBytes,
+		TotalBytes:       stats.TotalBytes,
+		Samples:          stats.Samples,
+	}, nil
+}
@@ -0,0 +1,89 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"fmt"
+	"log"
+
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// watchSinkQueueSize bounds how many undelivered findings batches a
+// WatchFindings call buffers - same tradeoff as webhookSink's queue (see
+// its doc comment): a subscriber that falls behind loses each batch that
+// arrives while its queue is full rather than stalling the ScanFiles call
+// that produced it.
+const watchSinkQueueSize = 256
+
+// watchSink is the per-call EventSink WatchFindings registers for the
+// duration of one stream: it only cares about OnFindingsProduced, only
+// for its own scanID, and forwards each batch onto a channel the RPC
+// handler drains into the stream.
+type watchSink struct {
+	scanID string
+	events chan *pb.WatchFindingsResponse
+}
+
+func newWatchSink(scanID string) *watchSink {
+	return &watchSink{scanID: scanID, events: make(chan *pb.WatchFindingsResponse, watchSinkQueueSize)}
+}
+
+// OnScanCreated implements EventSink; watchSink has nothing to do here.
+func (w *watchSink) OnScanCreated(scanID string) {}
+
+// OnScanCompleted implements EventSink; watchSink has nothing to do here.
+// WatchFindings ends the stream only once the client cancels the RPC
+// (see WatchFindings); a scan that completes does not close it.
+func (w *watchSink) OnScanCompleted(scanID string, report *pb.ScanReport) {}
+
+// OnFindingsProduced implements EventSink.
+func (w *watchSink) OnFindingsProduced(scanID, minion string, findings []*mpb.Finding) {
+	if scanID != w.scanID {
+		return
+	}
+	select {
+	case w.events <- &pb.WatchFindingsResponse{ScanId: scanID, Minion: minion, Findings: findings}:
+	default:
+		log.Printf("WatchFindings %s: queue full, dropping a findings batch from %s", scanID, minion)
+	}
+}
+
+// WatchFindings streams every Finding produced for req's scan from here
+// on, one batch per minion per ScanFiles call, until the client cancels
+// the RPC. It does not replay findings collected before the call started
+// - see GetScanStatus or FinalizeScan for a point-in-time snapshot
+// instead.
+func (s *Server) WatchFindings(req *pb.WatchFindingsRequest, stream pb.Overlord_WatchFindingsServer) error {
+	scanID := req.GetScanId()
+	if !s.stateManager.ScanExists(scanID) {
+		return fmt.Errorf("unknown scan ID %s", scanID)
+	}
+
+	sink := newWatchSink(scanID)
+	s.addSink(sink)
+	defer s.removeSink(sink)
+
+	for {
+		select {
+		case event := <-sink.events:
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
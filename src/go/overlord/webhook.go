@@ -0,0 +1,214 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package overlord
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	mpb "github.com/google/minions/proto/minions"
+	pb "github.com/google/minions/proto/overlord"
+)
+
+// WebhookEventType identifies the kind of lifecycle event a WebhookEvent
+// envelope carries.
+type WebhookEventType string
+
+// The event types a webhook sink may receive, mirroring the Harbor
+// scan-webhook taxonomy this was ported from.
+const (
+	EventScanCreated      WebhookEventType = "scan.created"
+	EventScanFileReceived WebhookEventType = "scan.file_received"
+	EventFindingEmitted   WebhookEventType = "finding.emitted"
+	EventInterestAdded    WebhookEventType = "interest.added"
+	EventScanCompleted    WebhookEventType = "scan.completed"
+)
+
+// WebhookEvent is the JSON envelope POSTed to every registered sink.
+type WebhookEvent struct {
+	Type         WebhookEventType `json:"type"`
+	ScanID       string           `json:"scan_id"`
+	Minion       string           `json:"minion,omitempty"`
+	Findings     []*mpb.Finding   `json:"findings,omitempty"`
+	NewInterests []*mpb.Interest  `json:"new_interests,omitempty"`
+	Ts           time.Time        `json:"ts"`
+}
+
+// WebhookOption configures a sink registered with SetWebhook.
+type WebhookOption func(*webhookSink)
+
+// WithWebhookSecret HMAC-SHA256-signs every delivery with secret, hex
+// encoded into the X-Minions-Signature header, the same way GitHub- and
+// Stripe-style webhooks let receivers authenticate the sender.
+func WithWebhookSecret(secret string) WebhookOption {
+	return func(w *webhookSink) { w.secret = secret }
+}
+
+// WithWebhookQueueSize bounds how many undelivered events a sink buffers.
+// Once full, SetWebhook's caller keeps running normally: the newest event
+// is dropped and logged rather than blocking the RPC that produced it.
+// Defaults to 1024.
+func WithWebhookQueueSize(n int) WebhookOption {
+	return func(w *webhookSink) { w.queueSize = n }
+}
+
+// WithWebhookRetry overrides the exponential backoff retry policy used
+// when a delivery's POST fails: maxAttempts tries total, doubling
+// baseDelay between each. Defaults to 5 attempts starting at 1 second.
+func WithWebhookRetry(maxAttempts int, baseDelay time.Duration) WebhookOption {
+	return func(w *webhookSink) {
+		w.maxAttempts = maxAttempts
+		w.baseDelay = baseDelay
+	}
+}
+
+// webhookSink is one HTTP(S) destination registered via SetWebhook, with
+// its own bounded queue and delivery goroutine so a slow or unreachable
+// sink can't affect any other sink or the request path that emitted the
+// event.
+//
+// Deliveries only live in this in-memory queue: a sink that's down for
+// longer than it takes to fill queueSize loses every event that arrives
+// while the queue is full, rather than spilling them to the StateManager. Making drops
+// durable would mean every StateManager backend (Local, Bolt, SQL, Redis)
+// growing a persisted outbox and a drain-on-restart path; given how rarely
+// a sink is down for that long, that cost isn't paid here - the queue
+// overflow log line is the signal an operator acts on instead.
+type webhookSink struct {
+	url         string
+	secret      string
+	queueSize   int
+	maxAttempts int
+	baseDelay   time.Duration
+	events      chan *WebhookEvent
+	client      *http.Client
+}
+
+// SetWebhook registers an HTTP(S) sink that every subsequent lifecycle
+// event (see WebhookEventType) is asynchronously POSTed to as a JSON
+// WebhookEvent. Delivery happens on its own goroutine, decoupled from
+// ScanFiles and friends by a bounded channel, so a webhook sink being slow
+// or unreachable never stalls a scan. Calling SetWebhook more than once
+// registers additional sinks; every event goes out to all of them.
+func (s *Server) SetWebhook(url string, opts ...WebhookOption) {
+	w := &webhookSink{
+		url:         url,
+		queueSize:   1024,
+		maxAttempts: 5,
+		baseDelay:   time.Second,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.events = make(chan *WebhookEvent, w.queueSize)
+	go w.run()
+	s.webhooks = append(s.webhooks, w)
+	s.addSink(w)
+}
+
+// emit fans event out to every registered webhook sink, non-blocking.
+// Used directly only for the two WebhookEventType values EventSink has no
+// equivalent for (EventScanFileReceived, EventInterestAdded); the other
+// three go through emitScanCreated/emitFindingsProduced/emitScanCompleted
+// instead, which also reach any non-webhook EventSink (see addSink).
+func (s *Server) emit(event *WebhookEvent) {
+	for _, w := range s.webhooks {
+		w.send(event)
+	}
+}
+
+// send queues event for delivery, dropping it (and logging) instead of
+// blocking if w's queue is full.
+func (w *webhookSink) send(event *WebhookEvent) {
+	select {
+	case w.events <- event:
+	default:
+		log.Printf("webhook %s: queue full, dropping %s event for scan %s", w.url, event.Type, event.ScanID)
+	}
+}
+
+// OnScanCreated implements EventSink.
+func (w *webhookSink) OnScanCreated(scanID string) {
+	w.send(&WebhookEvent{Type: EventScanCreated, ScanID: scanID, Ts: time.Now()})
+}
+
+// OnFindingsProduced implements EventSink.
+func (w *webhookSink) OnFindingsProduced(scanID, minion string, findings []*mpb.Finding) {
+	w.send(&WebhookEvent{Type: EventFindingEmitted, ScanID: scanID, Minion: minion, Findings: findings, Ts: time.Now()})
+}
+
+// OnScanCompleted implements EventSink.
+func (w *webhookSink) OnScanCompleted(scanID string, report *pb.ScanReport) {
+	w.send(&WebhookEvent{Type: EventScanCompleted, ScanID: scanID, Ts: time.Now()})
+}
+
+func (w *webhookSink) run() {
+	for event := range w.events {
+		if err := w.deliver(event); err != nil {
+			log.Printf("webhook %s: giving up on %s event for scan %s: %v", w.url, event.Type, event.ScanID, err)
+		}
+	}
+}
+
+func (w *webhookSink) deliver(event *WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshaling event: %v", err)
+	}
+
+	delay := w.baseDelay
+	var lastErr error
+	for attempt := 1; attempt <= w.maxAttempts; attempt++ {
+		lastErr = w.post(body)
+		if lastErr == nil {
+			return nil
+		}
+		if attempt == w.maxAttempts {
+			break
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+	return lastErr
+}
+
+func (w *webhookSink) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write(body)
+		req.Header.Set("X-Minions-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sink returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Tracker pairs a Limiter enforcing one scan's configured rate with a
+// Monitor observing what it actually achieves - the unit a Registry hands
+// out per scan ID.
+type Tracker struct {
+	Limiter *Limiter
+	Monitor *Monitor
+}
+
+// Wait blocks per t.Limiter, then folds the bytes and time spent waiting
+// into t.Monitor. Call it once per chunk (or per RPC, for a non-streaming
+// call that sends everything at once) so Stats reflects what this scan
+// actually moved. Safe to call on a nil Tracker.
+func (t *Tracker) Wait(n int64) {
+	if t == nil {
+		return
+	}
+	start := time.Now()
+	t.Limiter.Wait(n)
+	t.Monitor.Observe(n, time.Since(start))
+}
+
+// TrackerStats combines a Tracker's configured Limiter and observed
+// Monitor stats, for GetScanRate to report.
+type TrackerStats struct {
+	LimitBytesPerSec float64
+	BurstBytes       int64
+	RateBytesPerSec  float64
+	TotalBytes       int64
+	Samples          int64
+}
+
+// Stats returns t's current TrackerStats. Safe to call on a nil Tracker.
+func (t *Tracker) Stats() TrackerStats {
+	if t == nil {
+		return TrackerStats{}
+	}
+	ls := t.Limiter.Stats()
+	ms := t.Monitor.Stats()
+	return TrackerStats{
+		LimitBytesPerSec: ls.LimitBytesPerSec,
+		BurstBytes:       ls.BurstBytes,
+		RateBytesPerSec:  ms.RateBytesPerSec,
+		TotalBytes:       ms.TotalBytes,
+		Samples:          ms.Samples,
+	}
+}
+
+// Registry hands out one Tracker per scan ID, lazily created on first Get
+// with a shared configured rate/burst, so the Overlord's ScanFiles and a
+// minion's inbound interceptors can each attribute quota to the scan
+// they're currently serving instead of throttling the whole process as
+// one. A nil *Registry is safe to use and disables throttling entirely
+// (Get always returns a nil Tracker).
+type Registry struct {
+	limit, burst int64
+
+	mu       sync.Mutex
+	trackers map[string]*Tracker
+}
+
+// NewRegistry returns a Registry whose Trackers each allow
+// limitBytesPerSec bytes/sec with burstBytes of burst headroom, same as
+// NewLimiter.
+func NewRegistry(limitBytesPerSec, burstBytes int64) *Registry {
+	return &Registry{
+		limit:    limitBytesPerSec,
+		burst:    burstBytes,
+		trackers: make(map[string]*Tracker),
+	}
+}
+
+// Get returns scanID's Tracker, creating it on first use. Safe to call on
+// a nil Registry, returning a nil Tracker (itself safe to call Wait/Stats
+// on) so callers don't need to guard every call site with "if registry !=
+// nil".
+func (r *Registry) Get(scanID string) *Tracker {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.trackers[scanID]
+	if !ok {
+		t = &Tracker{
+			Limiter: NewLimiter(r.limit, r.burst),
+			Monitor: NewMonitor(DefaultAlpha),
+		}
+		r.trackers[scanID] = t
+	}
+	return t
+}
+
+// Delete forgets scanID's Tracker, freeing it for garbage collection. A
+// Registry never does this on its own - like StateManager, it leaves
+// reaping a finished scan's resources to the caller (see FinalizeScan).
+// Safe to call on a nil Registry.
+func (r *Registry) Delete(scanID string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.trackers, scanID)
+}
@@ -0,0 +1,166 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+/*
+Package throttle is a shared token-bucket rate limiter and transfer-rate
+monitor for chunked file traffic between an Overlord and its minions. It's
+used on the sender side (see goblins.Limiter, now a thin wrapper around
+this package) and the receiver side (minions.StartMinion's gRPC
+interceptors), and by the Overlord's Registry to attribute quota per scan
+ID - the same key StateManager uses - rather than across the whole
+process.
+*/
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is a token-bucket bandwidth limiter: Wait(n) blocks until n
+// bytes' worth of tokens are available, refilling the bucket at limit
+// bytes/sec up to a burst-sized cap. A nil *Limiter, or one with a
+// non-positive limit, never blocks - callers treat that as "unthrottled".
+type Limiter struct {
+	limit float64 // bytes/sec
+	burst float64 // bucket capacity, bytes
+
+	mu       sync.Mutex
+	bucket   float64
+	lastFill time.Time
+}
+
+// NewLimiter returns a Limiter allowing limitBytesPerSec bytes/sec on
+// average, with up to burstBytes available to spend immediately before
+// throttling kicks in. limitBytesPerSec <= 0 disables throttling.
+func NewLimiter(limitBytesPerSec, burstBytes int64) *Limiter {
+	return &Limiter{
+		limit:    float64(limitBytesPerSec),
+		burst:    float64(burstBytes),
+		bucket:   float64(burstBytes),
+		lastFill: time.Now(),
+	}
+}
+
+// Wait blocks until n bytes' worth of tokens are available and deducts
+// them. Safe for concurrent use - e.g. several AnalyzeFiles calls for the
+// same scan sharing one Limiter through a Registry.
+func (l *Limiter) Wait(n int64) {
+	if l == nil || l.limit <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.bucket += now.Sub(l.lastFill).Seconds() * l.limit
+	if l.bucket > l.burst {
+		l.bucket = l.burst
+	}
+
+	need := float64(n)
+	if l.bucket < need {
+		time.Sleep(time.Duration((need - l.bucket) / l.limit * float64(time.Second)))
+		l.bucket = 0
+	} else {
+		l.bucket -= need
+	}
+	// Stamp lastFill after any sleep above, not before: crediting the
+	// bucket for time this call itself spent blocked would let a
+	// sustained stream run at roughly 2x limit.
+	l.lastFill = time.Now()
+}
+
+// LimiterStats reports a Limiter's configuration.
+type LimiterStats struct {
+	LimitBytesPerSec float64
+	BurstBytes       int64
+}
+
+// Stats returns l's configured rate and burst. Safe to call on a nil
+// Limiter.
+func (l *Limiter) Stats() LimiterStats {
+	if l == nil {
+		return LimiterStats{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return LimiterStats{LimitBytesPerSec: l.limit, BurstBytes: int64(l.burst)}
+}
+
+// DefaultAlpha weights how quickly a Monitor's rate estimate reacts to a
+// new Observe sample versus its own history, used by NewMonitor when alpha
+// is out of range.
+const DefaultAlpha = 0.2
+
+// Monitor tracks how much data has actually moved, independent of any
+// Limiter throttling it: total bytes, sample count, and an
+// exponentially-weighted moving average of bytes/sec across Observe
+// calls. A nil *Monitor is safe to use and always reports a zero Stats.
+type Monitor struct {
+	alpha float64
+
+	mu         sync.Mutex
+	totalBytes int64
+	samples    int64
+	rate       float64 // EMA of observed throughput, bytes/sec
+}
+
+// NewMonitor returns a Monitor smoothing its rate estimate with alpha; an
+// alpha outside (0, 1] falls back to DefaultAlpha.
+func NewMonitor(alpha float64) *Monitor {
+	if alpha <= 0 || alpha > 1 {
+		alpha = DefaultAlpha
+	}
+	return &Monitor{alpha: alpha}
+}
+
+// Observe records that n bytes took elapsed to transfer, folding the
+// achieved rate into the moving average. elapsed <= 0 still counts n
+// towards TotalBytes and Samples, just not towards the rate estimate.
+func (m *Monitor) Observe(n int64, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totalBytes += n
+	m.samples++
+	if elapsed <= 0 {
+		return
+	}
+	sample := float64(n) / elapsed.Seconds()
+	if m.rate == 0 {
+		m.rate = sample
+	} else {
+		m.rate += m.alpha * (sample - m.rate)
+	}
+}
+
+// MonitorStats is a Monitor's observed throughput as of the moment it was
+// taken.
+type MonitorStats struct {
+	TotalBytes      int64
+	Samples         int64
+	RateBytesPerSec float64
+}
+
+// Stats returns m's current MonitorStats. Safe to call on a nil Monitor.
+func (m *Monitor) Stats() MonitorStats {
+	if m == nil {
+		return MonitorStats{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return MonitorStats{TotalBytes: m.totalBytes, Samples: m.samples, RateBytesPerSec: m.rate}
+}
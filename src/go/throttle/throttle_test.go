@@ -0,0 +1,104 @@
+//  Copyright 2018 Google LLC
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+
+//        https://www.apache.org/licenses/LICENSE-2.0
+
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//	limitations under the License.
+
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_nil_neverBlocks(t *testing.T) {
+	var l *Limiter
+	start := time.Now()
+	l.Wait(1 << 30)
+	require.Less(t, time.Since(start), 100*time.Millisecond)
+	require.Equal(t, LimiterStats{}, l.Stats())
+}
+
+func TestLimiter_disabled_neverBlocks(t *testing.T) {
+	l := NewLimiter(0, 0)
+	start := time.Now()
+	l.Wait(1 << 30)
+	require.Less(t, time.Since(start), 100*time.Millisecond)
+}
+
+func TestLimiter_overBurst_blocksRoughlyTheExpectedAmount(t *testing.T) {
+	l := NewLimiter(1000, 0) // 1000 bytes/sec, no burst headroom.
+	start := time.Now()
+	l.Wait(300) // Should take ~300ms.
+	elapsed := time.Since(start)
+	require.GreaterOrEqual(t, elapsed, 250*time.Millisecond)
+	require.Less(t, elapsed, 1*time.Second)
+}
+
+func TestLimiter_overSequentialWaits_sustainsConfiguredRate(t *testing.T) {
+	l := NewLimiter(1000, 500) // 1000 bytes/sec, 500 bytes of burst headroom.
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		l.Wait(300) // 1200 bytes total; ~700ms once the 500-byte burst is spent.
+	}
+	elapsed := time.Since(start)
+	// A lastFill stamped before Wait's sleep re-credits the bucket for the
+	// time this call itself spent blocked - with a non-zero burst that
+	// over-credit isn't clamped away, so a sustained stream runs at
+	// roughly 2x limit. A single Wait (TestLimiter_overBurst) can't catch
+	// this; it only shows up once the bucket empties across calls.
+	require.GreaterOrEqual(t, elapsed, 600*time.Millisecond)
+	require.Less(t, elapsed, 2*time.Second)
+}
+
+func TestMonitor_nil_neverPanics(t *testing.T) {
+	var m *Monitor
+	m.Observe(1024, time.Second)
+	require.Equal(t, MonitorStats{}, m.Stats())
+}
+
+func TestMonitor_tracksTotalsAndRate(t *testing.T) {
+	m := NewMonitor(1) // alpha=1: rate == most recent sample.
+	m.Observe(1000, time.Second)
+	m.Observe(500, time.Second)
+	stats := m.Stats()
+	require.Equal(t, int64(1500), stats.TotalBytes)
+	require.Equal(t, int64(2), stats.Samples)
+	require.Equal(t, 500.0, stats.RateBytesPerSec)
+}
+
+func TestRegistry_nil_returnsSafeTracker(t *testing.T) {
+	var r *Registry
+	tracker := r.Get("scan-1")
+	start := time.Now()
+	tracker.Wait(1 << 30)
+	require.Less(t, time.Since(start), 100*time.Millisecond)
+	require.Equal(t, TrackerStats{}, tracker.Stats())
+}
+
+func TestRegistry_get_isStablePerScanID(t *testing.T) {
+	r := NewRegistry(1000, 2000)
+	a := r.Get("scan-1")
+	b := r.Get("scan-1")
+	require.Same(t, a, b)
+
+	other := r.Get("scan-2")
+	require.NotSame(t, a, other)
+}
+
+func TestRegistry_delete_forgetsTracker(t *testing.T) {
+	r := NewRegistry(1000, 2000)
+	first := r.Get("scan-1")
+	r.Delete("scan-1")
+	second := r.Get("scan-1")
+	require.NotSame(t, first, second)
+}